@@ -63,6 +63,7 @@ upload_file() {
     echo "$resp" | sed "s|${HOST}|${PUBLIC_URL}|g"
     local filename=$(basename "$file_path")
     echo "" >&2
+    # 该链接依赖 svr.yml 中 server.http.file.public_download: true（默认开启）
     echo "公网下载链接: ${PUBLIC_URL}/api/v1/file/download?filename=${filename}" >&2
 }
 
@@ -86,6 +87,7 @@ upload_image() {
     echo "$resp" | sed "s|${HOST}|${PUBLIC_URL}|g"
     local filename=$(basename "$file_path")
     echo "" >&2
+    # 该链接依赖 svr.yml 中 server.http.file.public_download: true（默认开启）
     echo "公网查看链接: ${PUBLIC_URL}/api/v1/imageManage/download?filename=${filename}" >&2
 }
 
@@ -95,7 +97,12 @@ download_file() {
     if [ -z "$output" ]; then
         output="./$filename"
     fi
-    curl -s -o "$output" "${HOST}/api/v1/file/download?filename=${filename}"
+    # 带 AK/SK 签名下载，关闭 public_download 后同样可用
+    sign_request "GET" "/api/v1/file/download" "filename=${filename}" ""
+    curl -s -o "$output" "${HOST}/api/v1/file/download?filename=${filename}" \
+        -H "AccessKey: ${AK}" \
+        -H "Signature: ${SIGNATURE}" \
+        -H "TimeStamp: ${TIMESTAMP}"
     echo "{\"status\":\"ok\",\"saved_to\":\"${output}\"}"
 }
 
@@ -0,0 +1,56 @@
+package common
+
+import (
+	"strings"
+
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ========== v0.8.0 新增：个人 API Key 授权范围 ==========
+
+const (
+	APIKeyScopeRead   = "read"   // 浏览、下载
+	APIKeyScopeUpload = "upload" // 上传、新建目录、改名、恢复
+	APIKeyScopeDelete = "delete" // 删除、清空回收站
+	APIKeyScopeShare  = "share"  // 管理分享链接
+	APIKeyScopeAdmin  = "admin"  // 系统管理及账号相关接口
+)
+
+// APIKeyScopes 所有授权范围
+var APIKeyScopes = []string{APIKeyScopeRead, APIKeyScopeUpload, APIKeyScopeDelete, APIKeyScopeShare, APIKeyScopeAdmin}
+
+// apiKeyScopePermissions 授权范围对应的角色权限，创建 Key 时所属用户须具备该权限
+var apiKeyScopePermissions = map[string]Permission{
+	APIKeyScopeRead:   PermBrowse | PermDownload,
+	APIKeyScopeUpload: PermWrite,
+	APIKeyScopeDelete: PermWrite,
+	APIKeyScopeShare:  PermShare,
+	APIKeyScopeAdmin:  PermAdmin,
+}
+
+// APIKeyScopePermission 返回授权范围对应的权限，ok 为 false 表示无效的授权范围
+func APIKeyScopePermission(scope string) (Permission, bool) {
+	perm, ok := apiKeyScopePermissions[scope]
+	return perm, ok
+}
+
+// ParseAPIKeyScopes 解析 t_api_key.scopes
+func ParseAPIKeyScopes(s string) []string {
+	var scopes []string
+	for _, scope := range strings.Split(s, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// InitializeAPIKeyTable 初始化个人 API Key 表（v0.8.0）
+func InitializeAPIKeyTable(db *gorm.DB) {
+	if err := db.AutoMigrate(&models.APIKeyModel{}); err != nil {
+		ylog.Errorf("initDB", "create t_api_key table failed, err:%v", err)
+	}
+}
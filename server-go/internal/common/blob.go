@@ -0,0 +1,15 @@
+package common
+
+import (
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// InitializeBlobTable 初始化去重存储的 blob 表与引用表（v0.8.0）
+func InitializeBlobTable(db *gorm.DB) {
+	if err := db.AutoMigrate(&models.BlobModel{}, &models.BlobRefModel{}); err != nil {
+		ylog.Errorf("initDB", "create t_blob table failed, err:%v", err)
+	}
+}
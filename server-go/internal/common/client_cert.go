@@ -0,0 +1,70 @@
+package common
+
+import (
+	"strings"
+
+	"httpcat/internal/common/ylog"
+)
+
+// ========== v0.8.0 新增：客户端证书（mTLS）认证配置 ==========
+
+const (
+	ClientCertModeNone     = "none"     // 不请求客户端证书
+	ClientCertModeOptional = "optional" // 客户端提供证书时校验，未提供时照常使用其他认证方式
+	ClientCertModeRequire  = "require"  // 所有 HTTPS 连接都必须提供有效的客户端证书
+)
+
+const (
+	ClientCertIdentityCN    = "cn"    // Subject CN
+	ClientCertIdentityEmail = "email" // SAN 中的邮箱
+	ClientCertIdentityDNS   = "dns"   // SAN 中的 DNS 名
+	ClientCertIdentityURI   = "uri"   // SAN 中的 URI（如 SPIFFE ID）
+)
+
+// ClientCertMapping 证书身份到用户名的映射
+type ClientCertMapping struct {
+	Identity string `mapstructure:"identity"`
+	Username string `mapstructure:"username"`
+}
+
+// ClientCertEnabled 是否开启了客户端证书认证
+func ClientCertEnabled() bool {
+	return ClientCertMode != ClientCertModeNone
+}
+
+// loadClientCertConfig 读取并校验 server.http.ssl.client_auth
+func loadClientCertConfig() {
+	ClientCertMode = strings.ToLower(strings.TrimSpace(UserConfig.GetString("server.http.ssl.client_auth.mode")))
+	if ClientCertMode == "" {
+		ClientCertMode = ClientCertModeNone
+	}
+	switch ClientCertMode {
+	case ClientCertModeNone, ClientCertModeOptional, ClientCertModeRequire:
+	default:
+		ylog.Fatalf("initDefault", "invalid server.http.ssl.client_auth.mode %q, expect none, optional or require", ClientCertMode)
+	}
+	if ClientCertMode != ClientCertModeNone && !HttpSSLEnable {
+		ylog.Fatalf("initDefault", "server.http.ssl.client_auth requires server.http.ssl.enable")
+	}
+
+	ClientCertIdentity = strings.ToLower(strings.TrimSpace(UserConfig.GetString("server.http.ssl.client_auth.identity")))
+	if ClientCertIdentity == "" {
+		ClientCertIdentity = ClientCertIdentityCN
+	}
+	switch ClientCertIdentity {
+	case ClientCertIdentityCN, ClientCertIdentityEmail, ClientCertIdentityDNS, ClientCertIdentityURI:
+	default:
+		ylog.Fatalf("initDefault", "invalid server.http.ssl.client_auth.identity %q, expect cn, email, dns or uri", ClientCertIdentity)
+	}
+
+	ClientCertPassthrough = UserConfig.GetBool("server.http.ssl.client_auth.passthrough")
+	ClientCertMappings = nil
+	if err := UserConfig.UnmarshalKey("server.http.ssl.client_auth.mapping", &ClientCertMappings); err != nil {
+		ylog.Fatalf("initDefault", "invalid server.http.ssl.client_auth.mapping: %v", err)
+	}
+	for _, m := range ClientCertMappings {
+		if strings.TrimSpace(m.Identity) == "" || strings.TrimSpace(m.Username) == "" {
+			ylog.Fatalf("initDefault", "server.http.ssl.client_auth.mapping requires identity and username")
+		}
+	}
+}
@@ -29,6 +29,51 @@ var (
 	JwtSecret          string
 	HttpAuthEnable     bool
 	HttpAkSkMap        map[string]string //access key and secret key list, which used to identify whether the http request comes from a known subject
+
+	// 登录会话（v0.8.0 新增）：access token 过期后凭 refresh token 续期，会话可随时吊销
+	SessionAccessTTLMinutes int // access token（JWT）有效期，单位分钟，默认 60
+	SessionRefreshTTLHours  int // refresh token 有效期，单位小时，默认 168（7 天），每次续期重新计算
+
+	// 客户端证书认证（v0.8.0 新增）：HTTPS 下以 SSLCaFile 校验客户端证书，按证书身份映射到用户
+	ClientCertMode        string              // none（默认）/ optional / require
+	ClientCertIdentity    string              // 作为身份的证书字段：cn（默认）/ email / dns / uri
+	ClientCertMappings    []ClientCertMapping // 身份到用户名的映射
+	ClientCertPassthrough bool                // 未登记映射时以身份本身作为用户名，默认 false（拒绝）
+
+	// OpenID Connect 单点登录（v0.8.0 新增）：授权码 + PKCE，按 claim 映射用户与角色，首次登录自动创建用户
+	OIDCEnable        bool
+	OIDCIssuer        string
+	OIDCClientID      string
+	OIDCClientSecret  string
+	OIDCRedirectURL   string   // 回调地址，需在 IdP 登记，形如 https://<host>/api/v1/user/oidc/callback
+	OIDCScopes        []string // 默认 openid profile email
+	OIDCUsernameClaim string   // 用户名 claim，默认 preferred_username（缺失时使用 email）
+	OIDCEmailClaim    string   // 默认 email
+	OIDCGroupsClaim   string   // 默认 groups
+	OIDCRoleMappings  []RoleMapping
+	OIDCDefaultRole   string // 未匹配任何映射的新用户角色，默认 readonly
+	OIDCAutoProvision bool   // 首次登录自动创建用户，默认 true
+	OIDCPostLoginURL  string // 登录完成后跳转的前端地址，默认 /
+
+	// LDAP / Active Directory 认证（v0.8.0 新增）：绑定 + 搜索校验密码，按用户组映射角色，首次登录自动创建用户
+	LDAPEnable             bool
+	LDAPURL                string // ldap://host:389 或 ldaps://host:636
+	LDAPStartTLS           bool   // ldap:// 连接后升级为 TLS
+	LDAPInsecureSkipVerify bool
+	LDAPCAFile             string // 校验服务端证书的 CA，留空使用系统 CA
+	LDAPTimeout            int    // 连接与请求超时，单位秒，默认 10
+	LDAPBindDN             string // 用于搜索用户的服务账号，留空匿名绑定
+	LDAPBindPassword       string
+	LDAPBaseDN             string
+	LDAPUserFilter         string // 默认 (uid={username})，AD 可用 (sAMAccountName={username})
+	LDAPEmailAttr          string // 默认 mail
+	LDAPGroupAttr          string // 用户条目上的组属性，默认 memberOf
+	LDAPGroupBaseDN        string // 设置后额外按 group_filter 搜索用户所在的组
+	LDAPGroupFilter        string // 默认 (|(member={dn})(uniqueMember={dn}))
+	LDAPRoleMappings       []RoleMapping
+	LDAPDefaultRole        string // 未匹配任何映射的新用户角色，默认 readonly
+	LDAPAutoProvision      bool   // 首次登录自动创建用户，默认 true
+
 	SvrAK              string            // access key, which use for http sign
 	SvrSK              string            // secret key, which use for http sign
 	P2pEnable          bool
@@ -44,6 +89,7 @@ var (
 	UploadDir           string // 上传子目录（相对于 FileBaseDir）
 	DownloadDir         string // 下载子目录（相对于 FileBaseDir）
 	FileUploadEnable    bool //
+	FilePublicDownload  bool // 下载接口（/file/download、/imageManage/download）是否免认证，默认 true（v0.8.0）
 	EnableUploadToken   bool //是否开启文件上传token校验
 	AppKey              string
 	AppSecret           string
@@ -51,14 +97,70 @@ var (
 	EnableSqlite        bool
 	SqliteDBPath        string
 
+	StorageDriver string // 存储驱动（local，默认本地文件系统）
+	// S3 兼容对象存储（StorageDriver = s3 时生效，如 MinIO / AWS S3 / OSS 等）
+	StorageS3Endpoint  string // 服务地址，如 127.0.0.1:9000
+	StorageS3AccessKey string
+	StorageS3SecretKey string
+	StorageS3Bucket    string // 桶名（不存在时启动自动创建）
+	StorageS3Region    string
+	StorageS3Prefix    string // 对象 key 前缀（可选），作用等同本地驱动的 FileBaseDir
+	StorageS3UseSSL    bool
+	// 内容去重（v0.8.0 新增）：相同内容按 SHA-256 只保存一份，仅本地驱动 + SQLite 时生效
+	StorageDedup bool
+	// 静态加密（v0.8.0 新增）：文件以分块 AES-256-GCM 格式落盘，读取时透明解密
+	StorageEncryptionEnable    bool
+	StorageEncryptionActiveKey string          // 新写入文件使用的主密钥 ID
+	StorageEncryptionKeys      []EncryptionKey // 全部主密钥（轮换后旧密钥保留用于解密）
+
 	McpEnable    bool   // 是否启用 MCP Server
 	McpAuthToken string // MCP 认证 Token（可选，为空则不验证）
 
+	WebDAVEnable bool   // 是否启用 WebDAV（v0.8.0 新增）
+	WebDAVPrefix string // WebDAV 挂载路径前缀，默认 /dav
+
 	ShareEnable          bool // 是否启用分享功能
 	ShareAnonymousAccess bool // 是否允许匿名访问分享链接（false 时需要登录）
 
 	OpenAPIEnable bool // 是否启用 Open API（AK/SK 签名认证）
 
+	// 预签名 URL（v0.8.0 新增）：无状态、限时的 HMAC 签名链接
+	PresignSecret     string // 签名密钥，默认由 jwt_secret 经 HKDF 派生
+	PresignMaxExpires int64  // 最长有效期（秒），默认 7 天
+
+	// S3 兼容网关（v0.8.0 新增，独立端口，SigV4 鉴权复用 AK/SK）
+	S3ApiEnable bool
+	S3ApiPort   int
+	S3ApiRegion string // 签名校验使用的 region
+	S3ApiBucket string // 对外暴露的唯一桶名，桶根目录即上传目录
+
+	// 内置 SFTP 服务（v0.8.0 新增，独立端口，复用 t_user 用户与文件管理目录）
+	SftpEnable      bool
+	SftpPort        int
+	SftpHostKeyFile string // 主机私钥路径，不存在时自动生成 ed25519 密钥
+
+	// 文件历史版本（v0.8.0 新增）：上传覆盖已有文件时保留旧版本
+	VersioningEnable     bool
+	VersioningMaxCount   int              // 每个文件最多保留的版本数，默认 10
+	VersioningMaxAgeDays int              // 版本最长保留天数，默认 30，0 表示不限
+	VersioningRules      []VersioningRule // 按目录覆盖保留策略（最长前缀匹配）
+
+	// 回收站（v0.8.0 新增）：删除的文件 / 文件夹先移入回收站，保留期满后彻底删除
+	TrashEnable        bool
+	TrashRetentionDays int // 保留天数，默认 30，0 表示不自动清理
+
+	// 存储配额（v0.8.0 新增）：按用户 / 目录限制占用空间，配额条目存于 t_quota
+	QuotaEnable           bool
+	QuotaDefaultUserLimit int64 // 未单独设置配额的用户默认上限（字节），0 表示不限
+
+	// 文件系统监听（v0.8.0 新增）：绕过 httpcat 直接写入 / 删除的文件同步到文件索引、上传日志、图片表与分享
+	FileWatchEnable            bool
+	FileWatchReconcileInterval int // 全量对账间隔（分钟），默认 60；未开启监听时每天对账一次
+
+	// 用户主目录（v0.8.0 新增）：非管理员只能访问 <HomeDirRoot>/<用户名> 及经 t_path_acl 授权的共享目录
+	HomeDirEnable bool
+	HomeDirRoot   string // 主目录根（相对于文件管理目录），默认 home
+
 	PProfEnable bool
 	PProfPort   int //pprof
 
@@ -0,0 +1,9 @@
+package common
+
+// EncryptionKey 静态加密主密钥配置（v0.8.0）
+// Key 与 KeyFile 二选一，内容为 32 字节密钥的 base64 / hex 编码（KeyFile 也可直接存放 32 字节原始密钥）。
+type EncryptionKey struct {
+	ID      string `mapstructure:"id"` // 写入文件头，用于解密时选择主密钥，最长 16 个字符
+	Key     string `mapstructure:"key"`
+	KeyFile string `mapstructure:"key_file"`
+}
@@ -0,0 +1,68 @@
+package common
+
+import (
+	"strings"
+
+	"httpcat/internal/common/ylog"
+)
+
+// 用户来源（t_user.auth_source），空串表示本地用户
+const (
+	AuthSourceLocal = ""
+	AuthSourceOIDC  = "oidc"
+	AuthSourceLDAP  = "ldap" // v0.8.0 新增：LDAP / Active Directory 用户，密码由目录服务校验
+)
+
+// RoleMapping 外部身份源（OIDC、LDAP）用户组到角色的映射（v0.8.0）
+// 用户属于多个已映射的组时取权限最高的角色（按 Roles 顺序）。
+type RoleMapping struct {
+	Group string `mapstructure:"group"`
+	Role  string `mapstructure:"role"`
+}
+
+// MapGroupsToRole 按映射取用户组对应的最高角色，组名不区分大小写，未匹配时返回空串
+func MapGroupsToRole(mappings []RoleMapping, groups []string) string {
+	best := -1
+	for _, m := range mappings {
+		for _, g := range groups {
+			if !strings.EqualFold(g, m.Group) {
+				continue
+			}
+			for i, r := range Roles {
+				if r == m.Role && (best < 0 || i < best) {
+					best = i
+				}
+			}
+		}
+	}
+	if best < 0 {
+		return ""
+	}
+	return Roles[best]
+}
+
+// loadRoleMappings 读取并校验配置项 key 下的角色映射
+func loadRoleMappings(key string) []RoleMapping {
+	var mappings []RoleMapping
+	if err := UserConfig.UnmarshalKey(key, &mappings); err != nil {
+		ylog.Fatalf("initDefault", "invalid %s: %v", key, err)
+	}
+	for _, m := range mappings {
+		if !ValidRole(m.Role) {
+			ylog.Fatalf("initDefault", "invalid role %q in %s", m.Role, key)
+		}
+	}
+	return mappings
+}
+
+// loadDefaultRole 读取并校验配置项 key 指定的默认角色，未配置时为 readonly
+func loadDefaultRole(key string) string {
+	role := strings.TrimSpace(UserConfig.GetString(key))
+	if role == "" {
+		role = RoleReadOnly
+	}
+	if !ValidRole(role) {
+		ylog.Fatalf("initDefault", "invalid %s %q", key, role)
+	}
+	return role
+}
@@ -0,0 +1,49 @@
+package common
+
+import (
+	"strings"
+	"time"
+
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// VersioningRule 按目录覆盖的历史版本保留策略（v0.8.0）
+// MaxCount / MaxAgeDays 未配置时沿用全局值；MaxCount 为 0 表示该目录不保留历史版本。
+type VersioningRule struct {
+	Dir        string `mapstructure:"dir"` // 相对于上传目录，"" 表示整个上传目录
+	MaxCount   *int   `mapstructure:"max_count"`
+	MaxAgeDays *int   `mapstructure:"max_age_days"`
+}
+
+// InitializeFileVersionTable 初始化文件历史版本表（v0.8.0）
+func InitializeFileVersionTable(db *gorm.DB) {
+	if err := db.AutoMigrate(&models.FileVersionModel{}); err != nil {
+		ylog.Errorf("initDB", "create t_file_version table failed, err:%v", err)
+	}
+}
+
+// VersioningPolicy 返回上传目录下文件 name 适用的保留策略：最多保留的版本数与最长保留时间（0 表示不限）
+func VersioningPolicy(name string) (maxCount int, maxAge time.Duration) {
+	maxCount, maxAgeDays := VersioningMaxCount, VersioningMaxAgeDays
+	matched := -1
+	for _, rule := range VersioningRules {
+		if rule.Dir != "" && name != rule.Dir && !strings.HasPrefix(name, rule.Dir+"/") {
+			continue
+		}
+		if len(rule.Dir) <= matched {
+			continue
+		}
+		matched = len(rule.Dir)
+		maxCount, maxAgeDays = VersioningMaxCount, VersioningMaxAgeDays
+		if rule.MaxCount != nil {
+			maxCount = *rule.MaxCount
+		}
+		if rule.MaxAgeDays != nil {
+			maxAgeDays = *rule.MaxAgeDays
+		}
+	}
+	return maxCount, time.Duration(maxAgeDays) * 24 * time.Hour
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"testing"
 	"time"
 
 	"github.com/spf13/pflag"
@@ -23,6 +24,10 @@ var (
 )
 
 func init() {
+	// 单元测试不解析命令行、不加载配置文件，用到的全局配置由测试自行设置
+	if testing.Testing() {
+		return
+	}
 	// 结尾的Var表示支持将参数的值，绑定到变量
 	pflag.StringVar(&StaticDir, "static", "./static/", "Specify the path for static resources (web), ending with a forward slash (/)")
 	pflag.StringVar(&FileBaseDir, "basedir", "./", "Base directory for file upload/download (defaults to working directory)")
@@ -90,12 +95,103 @@ func initDefault() {
 	HttpIdleTimeout = UserConfig.GetInt64("server.http.idle_timeout")
 
 	HttpSSLEnable = UserConfig.GetBool("server.http.ssl.enable")
+	loadClientCertConfig()
 	HttpAuthEnable = UserConfig.GetBool("server.http.auth.enable")
 	HttpAkSkMap = UserConfig.GetStringMapString("server.http.auth.aksk")
 
+	// 登录会话：access token 与 refresh token 有效期
+	SessionAccessTTLMinutes = UserConfig.GetInt("server.http.auth.session.access_token_ttl")
+	if SessionAccessTTLMinutes <= 0 {
+		SessionAccessTTLMinutes = 60
+	}
+	SessionRefreshTTLHours = UserConfig.GetInt("server.http.auth.session.refresh_token_ttl")
+	if SessionRefreshTTLHours <= 0 {
+		SessionRefreshTTLHours = 7 * 24
+	}
+
+	// OpenID Connect 单点登录
+	OIDCEnable = UserConfig.GetBool("server.http.auth.oidc.enable")
+	OIDCIssuer = strings.TrimSpace(UserConfig.GetString("server.http.auth.oidc.issuer"))
+	OIDCClientID = UserConfig.GetString("server.http.auth.oidc.client_id")
+	OIDCClientSecret = UserConfig.GetString("server.http.auth.oidc.client_secret")
+	OIDCRedirectURL = strings.TrimSpace(UserConfig.GetString("server.http.auth.oidc.redirect_url"))
+	OIDCScopes = UserConfig.GetStringSlice("server.http.auth.oidc.scopes")
+	if len(OIDCScopes) == 0 {
+		OIDCScopes = []string{"openid", "profile", "email"}
+	}
+	OIDCUsernameClaim = strings.TrimSpace(UserConfig.GetString("server.http.auth.oidc.username_claim"))
+	if OIDCUsernameClaim == "" {
+		OIDCUsernameClaim = "preferred_username"
+	}
+	OIDCEmailClaim = strings.TrimSpace(UserConfig.GetString("server.http.auth.oidc.email_claim"))
+	if OIDCEmailClaim == "" {
+		OIDCEmailClaim = "email"
+	}
+	OIDCGroupsClaim = strings.TrimSpace(UserConfig.GetString("server.http.auth.oidc.groups_claim"))
+	if OIDCGroupsClaim == "" {
+		OIDCGroupsClaim = "groups"
+	}
+	OIDCRoleMappings = loadRoleMappings("server.http.auth.oidc.role_mapping")
+	OIDCDefaultRole = loadDefaultRole("server.http.auth.oidc.default_role")
+	OIDCAutoProvision = true
+	if UserConfig.IsSet("server.http.auth.oidc.auto_provision") {
+		OIDCAutoProvision = UserConfig.GetBool("server.http.auth.oidc.auto_provision")
+	}
+	OIDCPostLoginURL = strings.TrimSpace(UserConfig.GetString("server.http.auth.oidc.post_login_url"))
+	if OIDCPostLoginURL == "" {
+		OIDCPostLoginURL = "/"
+	}
+	if OIDCEnable && (OIDCIssuer == "" || OIDCClientID == "" || OIDCRedirectURL == "") {
+		ylog.Fatalf("initDefault", "server.http.auth.oidc requires issuer, client_id and redirect_url")
+	}
+
+	// LDAP / Active Directory 认证
+	LDAPEnable = UserConfig.GetBool("server.http.auth.ldap.enable")
+	LDAPURL = strings.TrimSpace(UserConfig.GetString("server.http.auth.ldap.url"))
+	LDAPStartTLS = UserConfig.GetBool("server.http.auth.ldap.start_tls")
+	LDAPInsecureSkipVerify = UserConfig.GetBool("server.http.auth.ldap.insecure_skip_verify")
+	LDAPCAFile = strings.TrimSpace(UserConfig.GetString("server.http.auth.ldap.ca_file"))
+	LDAPTimeout = UserConfig.GetInt("server.http.auth.ldap.timeout")
+	if LDAPTimeout <= 0 {
+		LDAPTimeout = 10
+	}
+	LDAPBindDN = UserConfig.GetString("server.http.auth.ldap.bind_dn")
+	LDAPBindPassword = UserConfig.GetString("server.http.auth.ldap.bind_password")
+	LDAPBaseDN = strings.TrimSpace(UserConfig.GetString("server.http.auth.ldap.base_dn"))
+	LDAPUserFilter = strings.TrimSpace(UserConfig.GetString("server.http.auth.ldap.user_filter"))
+	if LDAPUserFilter == "" {
+		LDAPUserFilter = "(uid={username})"
+	}
+	LDAPEmailAttr = strings.TrimSpace(UserConfig.GetString("server.http.auth.ldap.email_attr"))
+	if LDAPEmailAttr == "" {
+		LDAPEmailAttr = "mail"
+	}
+	LDAPGroupAttr = strings.TrimSpace(UserConfig.GetString("server.http.auth.ldap.group_attr"))
+	if LDAPGroupAttr == "" {
+		LDAPGroupAttr = "memberOf"
+	}
+	LDAPGroupBaseDN = strings.TrimSpace(UserConfig.GetString("server.http.auth.ldap.group_base_dn"))
+	LDAPGroupFilter = strings.TrimSpace(UserConfig.GetString("server.http.auth.ldap.group_filter"))
+	if LDAPGroupFilter == "" {
+		LDAPGroupFilter = "(|(member={dn})(uniqueMember={dn}))"
+	}
+	LDAPRoleMappings = loadRoleMappings("server.http.auth.ldap.role_mapping")
+	LDAPDefaultRole = loadDefaultRole("server.http.auth.ldap.default_role")
+	LDAPAutoProvision = true
+	if UserConfig.IsSet("server.http.auth.ldap.auto_provision") {
+		LDAPAutoProvision = UserConfig.GetBool("server.http.auth.ldap.auto_provision")
+	}
+	if LDAPEnable && (LDAPURL == "" || LDAPBaseDN == "") {
+		ylog.Fatalf("initDefault", "server.http.auth.ldap requires url and base_dn")
+	}
+
 	// for 业务
 	FileUploadEnable = UserConfig.GetBool("server.http.file.upload_enable")
 	EnableUploadToken = UserConfig.GetBool("server.http.file.enable_upload_token")
+	FilePublicDownload = UserConfig.GetBool("server.http.file.public_download")
+	if !UserConfig.IsSet("server.http.file.public_download") {
+		FilePublicDownload = true // 默认与旧版一致，下载接口免认证
+	}
 	AppKey = UserConfig.GetString("server.http.file.app_key")
 	AppSecret = UserConfig.GetString("server.http.file.app_secret")
 	PersistentNotifyURL = UserConfig.GetString("server.http.file.upload_policy.persistent_notify_url")
@@ -124,6 +220,34 @@ func initDefault() {
 		DownloadDir = normalizedDownloadDir
 	}
 
+	// 存储驱动（默认 local）
+	StorageDriver = strings.TrimSpace(UserConfig.GetString("server.storage.driver"))
+	if StorageDriver == "" {
+		StorageDriver = "local"
+	}
+	StorageS3Endpoint = strings.TrimSpace(UserConfig.GetString("server.storage.s3.endpoint"))
+	StorageS3AccessKey = UserConfig.GetString("server.storage.s3.access_key")
+	StorageS3SecretKey = UserConfig.GetString("server.storage.s3.secret_key")
+	StorageS3Bucket = strings.TrimSpace(UserConfig.GetString("server.storage.s3.bucket"))
+	StorageS3Region = UserConfig.GetString("server.storage.s3.region")
+	StorageS3Prefix = strings.Trim(UserConfig.GetString("server.storage.s3.prefix"), "/")
+	StorageS3UseSSL = UserConfig.GetBool("server.storage.s3.use_ssl")
+	StorageDedup = UserConfig.GetBool("server.storage.dedup")
+
+	// 静态加密：keys 列表，或单个 key / key_file（ID 为 default）
+	StorageEncryptionEnable = UserConfig.GetBool("server.storage.encryption.enable")
+	StorageEncryptionKeys = nil
+	if err := UserConfig.UnmarshalKey("server.storage.encryption.keys", &StorageEncryptionKeys); err != nil {
+		ylog.Fatalf("initDefault", "invalid server.storage.encryption.keys: %v", err)
+	}
+	if key, keyFile := UserConfig.GetString("server.storage.encryption.key"), UserConfig.GetString("server.storage.encryption.key_file"); key != "" || keyFile != "" {
+		StorageEncryptionKeys = append(StorageEncryptionKeys, EncryptionKey{ID: "default", Key: key, KeyFile: keyFile})
+	}
+	StorageEncryptionActiveKey = strings.TrimSpace(UserConfig.GetString("server.storage.encryption.active_key"))
+	if StorageEncryptionActiveKey == "" && len(StorageEncryptionKeys) == 1 {
+		StorageEncryptionActiveKey = StorageEncryptionKeys[0].ID
+	}
+
 	// 缩略图配置（默认 250x150）
 	ThumbWidth = UserConfig.GetInt("server.http.file.thumb_width")
 	if ThumbWidth == 0 {
@@ -152,6 +276,14 @@ func initDefault() {
 		ShareAnonymousAccess = true // 默认允许匿名访问
 	}
 
+	// for webdav
+	WebDAVEnable = UserConfig.GetBool("server.webdav.enable")
+	WebDAVPrefix = "/" + strings.Trim(strings.TrimSpace(UserConfig.GetString("server.webdav.prefix")), "/")
+	if WebDAVPrefix == "/" {
+		WebDAVPrefix = "/dav"
+	}
+	validateWebDAVConfig()
+
 	// for mcp
 	McpEnable = UserConfig.GetBool("server.mcp.enable")
 	McpAuthToken = strings.TrimSpace(UserConfig.GetString("server.mcp.auth_token"))
@@ -160,6 +292,102 @@ func initDefault() {
 	// for open api (AK/SK)
 	OpenAPIEnable = UserConfig.GetBool("server.http.auth.open_api_enable")
 
+	// for presigned url
+	PresignSecret = UserConfig.GetString("server.http.presign.secret")
+	if PresignSecret == "" {
+		PresignSecret = derivePresignSecret(JwtSecret)
+	}
+	PresignMaxExpires = UserConfig.GetInt64("server.http.presign.max_expires")
+	if PresignMaxExpires <= 0 {
+		PresignMaxExpires = 7 * 24 * 3600
+	}
+
+	// for s3 api gateway
+	S3ApiEnable = UserConfig.GetBool("server.s3.enable")
+	S3ApiPort = UserConfig.GetInt("server.s3.port")
+	if S3ApiPort == 0 {
+		S3ApiPort = 8889
+	}
+	S3ApiRegion = strings.TrimSpace(UserConfig.GetString("server.s3.region"))
+	if S3ApiRegion == "" {
+		S3ApiRegion = "us-east-1"
+	}
+	S3ApiBucket = strings.TrimSpace(UserConfig.GetString("server.s3.bucket"))
+	if S3ApiBucket == "" {
+		S3ApiBucket = "httpcat"
+	}
+
+	// for sftp
+	SftpEnable = UserConfig.GetBool("server.sftp.enable")
+	SftpPort = UserConfig.GetInt("server.sftp.port")
+	if SftpPort == 0 {
+		SftpPort = 2222
+	}
+	SftpHostKeyFile = strings.TrimSpace(UserConfig.GetString("server.sftp.host_key"))
+	if SftpHostKeyFile == "" {
+		SftpHostKeyFile = filepath.Join(filepath.Dir(SqliteDBPath), "sftp_host_ed25519_key")
+	}
+
+	// 文件历史版本
+	VersioningEnable = UserConfig.GetBool("server.http.file.versioning.enable")
+	VersioningMaxCount = 10
+	if UserConfig.IsSet("server.http.file.versioning.max_count") {
+		VersioningMaxCount = UserConfig.GetInt("server.http.file.versioning.max_count")
+	}
+	VersioningMaxAgeDays = 30
+	if UserConfig.IsSet("server.http.file.versioning.max_age_days") {
+		VersioningMaxAgeDays = UserConfig.GetInt("server.http.file.versioning.max_age_days")
+	}
+	VersioningRules = nil
+	if err := UserConfig.UnmarshalKey("server.http.file.versioning.rules", &VersioningRules); err != nil {
+		ylog.Fatalf("initDefault", "invalid server.http.file.versioning.rules: %v", err)
+	}
+	for i := range VersioningRules {
+		dir, err := cleanRelativePath(strings.TrimSpace(VersioningRules[i].Dir))
+		if err != nil {
+			ylog.Fatalf("initDefault", "invalid server.http.file.versioning.rules dir %q: %v", VersioningRules[i].Dir, err)
+		}
+		if dir == "." {
+			dir = ""
+		}
+		VersioningRules[i].Dir = filepath.ToSlash(dir)
+	}
+
+	// 回收站
+	TrashEnable = UserConfig.GetBool("server.http.file.trash.enable")
+	TrashRetentionDays = 30
+	if UserConfig.IsSet("server.http.file.trash.retention_days") {
+		TrashRetentionDays = UserConfig.GetInt("server.http.file.trash.retention_days")
+	}
+
+	// 存储配额
+	QuotaEnable = UserConfig.GetBool("server.http.file.quota.enable")
+	QuotaDefaultUserLimit = UserConfig.GetInt64("server.http.file.quota.default_user_limit")
+
+	// 文件系统监听
+	FileWatchEnable = UserConfig.GetBool("server.http.file.watch.enable")
+	FileWatchReconcileInterval = 60
+	if UserConfig.IsSet("server.http.file.watch.reconcile_interval") {
+		FileWatchReconcileInterval = UserConfig.GetInt("server.http.file.watch.reconcile_interval")
+	}
+	if FileWatchReconcileInterval <= 0 {
+		ylog.Fatalf("initDefault", "invalid server.http.file.watch.reconcile_interval %d: must be positive", FileWatchReconcileInterval)
+	}
+
+	// 用户主目录与共享目录
+	HomeDirEnable = UserConfig.GetBool("server.http.file.home_dirs.enable")
+	HomeDirRoot = "home"
+	if UserConfig.IsSet("server.http.file.home_dirs.root") {
+		HomeDirRoot = strings.TrimSpace(UserConfig.GetString("server.http.file.home_dirs.root"))
+	}
+	if root, err := cleanRelativePath(HomeDirRoot); err != nil {
+		ylog.Fatalf("initDefault", "invalid server.http.file.home_dirs.root %q: %v", HomeDirRoot, err)
+	} else if root == "." {
+		HomeDirRoot = ""
+	} else {
+		HomeDirRoot = filepath.ToSlash(root)
+	}
+
 	// for p2p
 	P2pEnable = UserConfig.GetBool("server.p2p.enable")
 	P2pListenIP = UserConfig.GetString("server.p2p.listen.ip")
@@ -214,6 +442,18 @@ func validateMCPConfig() {
 	}
 }
 
+// validateWebDAVConfig WebDAV 前缀不能与已有路由冲突
+func validateWebDAVConfig() {
+	if !WebDAVEnable {
+		return
+	}
+	for _, reserved := range []string{"/api", "/static", "/mcp", "/s"} {
+		if WebDAVPrefix == reserved || strings.HasPrefix(WebDAVPrefix, reserved+"/") {
+			ylog.Fatalf("initDefault", "server.webdav.prefix %q conflicts with reserved route %s", WebDAVPrefix, reserved)
+		}
+	}
+}
+
 func initDB() {
 	// 打印初始化
 	fmt.Println("####初始化:", "initDB")
@@ -285,9 +525,39 @@ func initDB() {
 		// 创建 t_operation_log 表（如果不存在）
 		InitializeOperationLogTable(db)
 
+		// v0.8.0: 创建 t_presigned_upload 表（预签名上传链接使用记录），须在会话清理任务启动前创建
+		InitializePresignedUploadTable(db)
+
 		// v0.7.0: 创建 t_upload_session 表（分片上传会话）
 		InitializeUploadSessionTable(db)
 
+		// v0.8.0: 创建 t_ssh_key 表（SFTP 公钥登录）
+		InitializeSSHKeyTable(db)
+
+		// v0.8.0: 创建 t_blob / t_blob_ref 表（内容去重存储）
+		InitializeBlobTable(db)
+
+		// v0.8.0: 创建 t_file_version 表（文件历史版本）
+		InitializeFileVersionTable(db)
+
+		// v0.8.0: 创建 t_trash 表（回收站）
+		InitializeTrashTable(db)
+
+		// v0.8.0: 创建 t_file_meta / t_quota 表（文件索引与存储配额）
+		InitializeQuotaTable(db)
+
+		// v0.8.0: 创建 t_path_acl 表（共享目录授权）
+		InitializePathACLTable(db)
+
+		// v0.8.0: 创建 t_user_2fa 表（TOTP 两步验证）
+		InitializeTwoFactorTable(db)
+
+		// v0.8.0: 创建 t_session 表（登录会话与 refresh token）
+		InitializeSessionTable(db)
+
+		// v0.8.0: 创建 t_api_key 表（个人 API Key）
+		InitializeAPIKeyTable(db)
+
 		ylog.Infof("initDB", "init end~")
 	}
 
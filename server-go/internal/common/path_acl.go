@@ -0,0 +1,15 @@
+package common
+
+import (
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// InitializePathACLTable 初始化共享目录授权表（v0.8.0）
+func InitializePathACLTable(db *gorm.DB) {
+	if err := db.AutoMigrate(&models.PathACLModel{}); err != nil {
+		ylog.Errorf("initDB", "create t_path_acl table failed, err:%v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"time"
+
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+
+	"golang.org/x/crypto/hkdf"
+	"gorm.io/gorm"
+)
+
+// presignKeyInfo 由 jwt_secret 派生预签名密钥时使用的 HKDF info
+const presignKeyInfo = "httpcat presign v1"
+
+// derivePresignSecret 未配置 presign.secret 时由 jwt_secret 经 HKDF-SHA256 派生独立的签名密钥，
+// 避免预签名链接与 JWT 共用同一把密钥
+func derivePresignSecret(jwtSecret string) string {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, []byte(jwtSecret), nil, []byte(presignKeyInfo)), key); err != nil {
+		ylog.Fatalf("initDefault", "derive presign secret failed: %v", err)
+	}
+	return hex.EncodeToString(key)
+}
+
+// InitializePresignedUploadTable 初始化预签名上传链接使用记录表（v0.8.0）
+func InitializePresignedUploadTable(db *gorm.DB) {
+	if err := db.AutoMigrate(&models.PresignedUploadModel{}); err != nil {
+		ylog.Errorf("initDB", "create t_presigned_upload table failed, err:%v", err)
+	}
+}
+
+// cleanupExpiredPresignedUploads 删除已过截止时间的链接使用记录（过期链接的签名校验已不能通过）
+func cleanupExpiredPresignedUploads(db *gorm.DB, now time.Time) {
+	result := db.Where("expires_at < ?", now).Delete(&models.PresignedUploadModel{})
+	if result.Error != nil {
+		ylog.Errorf("UploadSessionCleanup", "purge presigned uploads failed: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		ylog.Infof("UploadSessionCleanup", "purged %d expired presigned upload record(s)", result.RowsAffected)
+	}
+}
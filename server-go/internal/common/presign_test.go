@@ -0,0 +1,36 @@
+package common
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestDerivePresignSecret(t *testing.T) {
+	tests := []struct {
+		name      string
+		jwtSecret string
+	}{
+		{"empty", ""},
+		{"short", "secret"},
+		{"default", "httpcat-jwt-secret"},
+	}
+	seen := make(map[string]string)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := derivePresignSecret(tt.jwtSecret)
+			if key, err := hex.DecodeString(got); err != nil || len(key) != 32 {
+				t.Fatalf("derivePresignSecret(%q) = %q, want 32 hex-encoded bytes", tt.jwtSecret, got)
+			}
+			if got == tt.jwtSecret {
+				t.Errorf("derivePresignSecret(%q) returned the jwt secret", tt.jwtSecret)
+			}
+			if again := derivePresignSecret(tt.jwtSecret); again != got {
+				t.Errorf("derivePresignSecret(%q) is not deterministic: %q != %q", tt.jwtSecret, got, again)
+			}
+			if other, dup := seen[got]; dup {
+				t.Errorf("derivePresignSecret(%q) collides with %q", tt.jwtSecret, other)
+			}
+			seen[got] = tt.jwtSecret
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package common
+
+import (
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// InitializeQuotaTable 初始化文件索引与配额表（v0.8.0）
+func InitializeQuotaTable(db *gorm.DB) {
+	if err := db.AutoMigrate(&models.FileMetaModel{}); err != nil {
+		ylog.Errorf("initDB", "create t_file_meta table failed, err:%v", err)
+	}
+	if err := db.AutoMigrate(&models.QuotaModel{}); err != nil {
+		ylog.Errorf("initDB", "create t_quota table failed, err:%v", err)
+	}
+}
@@ -35,6 +35,9 @@ const (
 	DirISNotExists
 	FileIsNotExists
 	ReadDirFailed
+	QuotaExceeded         // v0.8.0 新增：超出存储配额
+	PermissionDenied      // v0.8.0 新增：当前角色无权访问
+	UploadPolicyViolation // v0.8.0 新增：违反上传凭证的上传策略
 )
 
 var ErrorDescriptions = map[int]string{
@@ -59,9 +62,12 @@ var ErrorDescriptions = map[int]string{
 	UserLocked:               "user locked",
 
 	//业务错误码
-	DirISNotExists:  "did is not exists",
-	FileIsNotExists: "file is not exists",
-	ReadDirFailed:   "read dir failed",
+	DirISNotExists:        "did is not exists",
+	FileIsNotExists:       "file is not exists",
+	ReadDirFailed:         "read dir failed",
+	QuotaExceeded:         "storage quota exceeded",
+	PermissionDenied:      "permission denied",
+	UploadPolicyViolation: "upload policy violation",
 }
 
 type Response struct {
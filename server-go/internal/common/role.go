@@ -0,0 +1,62 @@
+package common
+
+// ========== v0.8.0 新增：角色与权限 ==========
+//
+// 每个用户属于一个角色（t_user.role），角色决定可访问的接口（见 midware.RBACAuth）：
+//   - admin：全部权限，包括系统配置、重启、用户管理、上传凭证、配额、操作日志
+//   - uploader：浏览、下载、上传 / 改名 / 删除、分享
+//   - readonly：浏览、下载
+//   - shareonly：浏览、管理分享链接（不能直接下载或修改文件）
+// 被禁用的用户没有任何权限，也无法登录（Web、WebDAV、SFTP）。
+
+const (
+	RoleAdmin     = "admin"
+	RoleUploader  = "uploader"
+	RoleReadOnly  = "readonly"
+	RoleShareOnly = "shareonly"
+)
+
+// Permission 权限位
+type Permission uint
+
+const (
+	// PermBrowse 浏览目录、文件信息与统计
+	PermBrowse Permission = 1 << iota
+	// PermDownload 下载、预览、打包下载
+	PermDownload
+	// PermWrite 上传、新建目录、改名、删除、恢复
+	PermWrite
+	// PermShare 创建与管理分享链接
+	PermShare
+	// PermAdmin 系统管理
+	PermAdmin
+)
+
+var rolePermissions = map[string]Permission{
+	RoleAdmin:     PermBrowse | PermDownload | PermWrite | PermShare | PermAdmin,
+	RoleUploader:  PermBrowse | PermDownload | PermWrite | PermShare,
+	RoleReadOnly:  PermBrowse | PermDownload,
+	RoleShareOnly: PermBrowse | PermShare,
+}
+
+// Roles 所有角色，按权限从高到低
+var Roles = []string{RoleAdmin, RoleUploader, RoleReadOnly, RoleShareOnly}
+
+// ValidRole 判断角色名是否有效
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Can 判断用户是否拥有权限 p；nil 或被禁用的用户没有任何权限
+func (u *User) Can(p Permission) bool {
+	if u == nil || u.Disabled {
+		return false
+	}
+	return rolePermissions[u.Role]&p == p
+}
+
+// UserCan 按用户名判断权限（读取用户缓存）
+func UserCan(username string, p Permission) bool {
+	return GetUser(username).Can(p)
+}
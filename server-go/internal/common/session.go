@@ -0,0 +1,15 @@
+package common
+
+import (
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// InitializeSessionTable 初始化登录会话表（v0.8.0）
+func InitializeSessionTable(db *gorm.DB) {
+	if err := db.AutoMigrate(&models.SessionModel{}); err != nil {
+		ylog.Errorf("initDB", "create t_session table failed, err:%v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package common
+
+import (
+	"time"
+
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// InitializeSSHKeyTable 初始化用户 SSH 公钥表（v0.8.0）
+func InitializeSSHKeyTable(db *gorm.DB) {
+	if err := db.AutoMigrate(&models.SSHKeyModel{}); err != nil {
+		ylog.Errorf("initDB", "create t_ssh_key table failed, err:%v", err)
+	}
+}
+
+// FindSSHKey 按用户名与公钥 SHA256 指纹查找已登记的公钥
+func FindSSHKey(username, fingerprint string) (*models.SSHKeyModel, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+	var key models.SSHKeyModel
+	if err := db.Where("username = ? AND fingerprint = ?", username, fingerprint).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// TouchSSHKey 更新公钥最近使用时间
+func TouchSSHKey(id uint) {
+	db, err := GetDB()
+	if err != nil {
+		return
+	}
+	if err := db.Model(&models.SSHKeyModel{}).Where("id = ?", id).Update("last_used_at", time.Now()).Error; err != nil {
+		ylog.Errorf("TouchSSHKey", "update last_used_at failed: %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+package common
+
+import (
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// InitializeTrashTable 初始化回收站表（v0.8.0）
+func InitializeTrashTable(db *gorm.DB) {
+	if err := db.AutoMigrate(&models.TrashModel{}); err != nil {
+		ylog.Errorf("initDB", "create t_trash table failed, err:%v", err)
+	}
+}
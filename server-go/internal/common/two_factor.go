@@ -0,0 +1,29 @@
+package common
+
+import (
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// InitializeTwoFactorTable 初始化两步验证表（v0.8.0）
+func InitializeTwoFactorTable(db *gorm.DB) {
+	if err := db.AutoMigrate(&models.TwoFactorModel{}); err != nil {
+		ylog.Errorf("initDB", "create t_user_2fa table failed, err:%v", err)
+	}
+}
+
+// TwoFactorEnabled 用户是否启用了两步验证；WebDAV 与 SFTP 无法输入验证码，据此拒绝仅凭密码的登录（v0.8.0）
+func TwoFactorEnabled(username string) bool {
+	if !EnableSqlite {
+		return false
+	}
+	db, err := GetDB()
+	if err != nil {
+		return false
+	}
+	var count int64
+	db.Model(&models.TwoFactorModel{}).Where("username = ? AND enabled = ?", username, true).Count(&count)
+	return count > 0
+}
@@ -86,6 +86,9 @@ func cleanupExpiredUploadSessions() {
 		ylog.Infof("UploadSessionCleanup", "cleaned up %d expired upload sessions", len(expiredSessions))
 	}
 
+	// v0.8.0：清理过期的预签名上传链接使用记录
+	cleanupExpiredPresignedUploads(db, now)
+
 	// 清理已完成超过 1 天但遗留分片目录的会话（防御性清理）
 	var staleCompleted []models.UploadSessionModel
 	cutoff := now.Add(-24 * time.Hour)
@@ -2,6 +2,8 @@ package common
 
 import (
 	"log"
+	"path"
+	"strings"
 	"sync"
 	"time"
 
@@ -34,6 +36,12 @@ type User struct {
 	Salt               string `gorm:"column:salt"`
 	Level              int    `gorm:"column:level"`
 	Config             []byte `gorm:"column:config"`
+	// v0.8.0 新增：角色（admin / uploader / readonly / shareonly，见 role.go）与禁用标记
+	Role     string `gorm:"column:role"`
+	Disabled bool   `gorm:"column:disabled;default:false"`
+	// v0.8.0 新增：用户来源（空串为本地用户，oidc 为单点登录自动创建）及其在外部身份源中的唯一标识
+	AuthSource string `gorm:"column:auth_source"`
+	ExternalID string `gorm:"column:external_id;index"`
 }
 
 type UserTag struct {
@@ -46,7 +54,7 @@ type UserRegion struct {
 	Key   string `json:"key" bson:"key"`
 }
 
-// 权限等级 0-->admin; 1-->高级用户(xxx)； 2-->xxx；
+// 权限等级 0-->admin; 1-->高级用户(xxx)； 2-->xxx；（v0.8.0 起由 Role 决定权限）
 var (
 	UserTable map[string]*User
 	UserLock  sync.RWMutex
@@ -72,6 +80,15 @@ func GetUser(userName string) *User {
 	return user
 }
 
+// ValidUsername 判断用户名能否用于新建用户：用户名同时作为主目录名，
+// 不能为空、不能包含 ":"、路径分隔符或空格，也不能是 "."、".." 等经路径规范化会改变的名字（v0.8.0）
+func ValidUsername(name string) bool {
+	if name == "" || strings.ContainsAny(name, ":/\\ ") {
+		return false
+	}
+	return name != "." && name != ".." && path.Clean(name) == name
+}
+
 // GetLoginSessionTimeoutMinute returns the login session idle timeout time in minutes.
 func GetLoginSessionTimeoutMinute() int64 {
 	return LoginSessionTimeoutMin
@@ -81,26 +98,26 @@ func GetLoginSessionTimeoutMinute() int64 {
 // 在程序启动时从数据库加载用户数据，并将加载到的数据赋值给全局变量 UserTable，然后开启一个定时任务，在每隔3秒钟重新加载一次数据库中的用户数据，并更新全局变量 UserTable。
 // 使用读写锁，它保证了在更新过程中不会发生竞争条件，从而确保了数据的一致性和安全性
 func InitUser() {
-	table := loadUserFromDB()
-	if table != nil {
-		UserLock.Lock()
-		UserTable = table
-		UserLock.Unlock()
-	}
+	ReloadUsers()
 
 	go func() {
 		for {
 			time.Sleep(10 * time.Second)
-			table := loadUserFromDB()
-			if table != nil {
-				UserLock.Lock()
-				UserTable = table
-				UserLock.Unlock()
-			}
+			ReloadUsers()
 		}
 	}()
 }
 
+// ReloadUsers 立即从数据库重新加载用户缓存（v0.8.0：用户管理接口修改后调用，禁用 / 改角色即时生效）
+func ReloadUsers() {
+	table := loadUserFromDB()
+	if table != nil {
+		UserLock.Lock()
+		UserTable = table
+		UserLock.Unlock()
+	}
+}
+
 func InitUploadToken() {
 	table := loadUploadTokenFromDB()
 	if table != nil {
@@ -185,6 +202,11 @@ func InitializeUserTable(db *gorm.DB) {
 		return
 	}
 
+	// v0.8.0: 升级前的用户均为管理员
+	if err := db.Model(&User{}).Where("role IS NULL OR role = ''").Update("role", RoleAdmin).Error; err != nil {
+		ylog.Errorf("initDB", "set default role failed, err:%v", err)
+	}
+
 	var count int64
 	db.Model(&User{}).Where("username = ?", DefaultAdminUsername).Count(&count)
 	if count == 0 {
@@ -217,6 +239,7 @@ func InitializeUserTable(db *gorm.DB) {
 			Salt:               "",
 			Level:              0,
 			Config:             nil,
+			Role:               RoleAdmin,
 		}
 		err = db.Create(&user).Error
 		if err != nil {
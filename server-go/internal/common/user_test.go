@@ -0,0 +1,28 @@
+package common
+
+import "testing"
+
+func TestValidUsername(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"bob", true},
+		{"bob.smith", true},
+		{"bob..smith", true},
+		{"张三", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"a/b", false},
+		{"../bob", false},
+		{`a\b`, false},
+		{"a b", false},
+		{"openapi:ak", false},
+	}
+	for _, tt := range tests {
+		if got := ValidUsername(tt.name); got != tt.want {
+			t.Errorf("ValidUsername(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package v1
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/midware"
+	"httpcat/internal/models"
+	"httpcat/internal/storage"
+)
+
+// ========== v0.8.0 新增：个人 API Key ==========
+//
+//   GET    /api/v1/user/apiKeys       当前用户的 API Key；管理员可用 ?username= 查看其他用户
+//   POST   /api/v1/user/apiKeys       创建  body: { "name": "backup", "scopes": ["read","upload"], "path": "backup", "expiresInDays": 90 }
+//   DELETE /api/v1/user/apiKeys/:id   吊销（自己的 Key，管理员可吊销任意 Key）
+// 创建时返回 accessKey 与 secretKey，secretKey 只返回这一次。签名方式与 Open API 相同（需开启 open_api_enable），
+// 鉴权与授权范围见 midware/apiKey.go。API Key 本身不能用于管理 API Key。
+
+// maxAPIKeysPerUser 每个用户最多持有的有效 API Key 数量
+const maxAPIKeysPerUser = 20
+
+// APIKeyVO API Key 列表项
+type APIKeyVO struct {
+	models.APIKeyModel
+	Scopes []string `json:"scopes"`
+	Status string   `json:"status"` // active / expired / revoked
+}
+
+func newAPIKeyVO(key models.APIKeyModel) APIKeyVO {
+	status := "active"
+	if key.RevokedAt != nil {
+		status = "revoked"
+	} else if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
+		status = "expired"
+	}
+	return APIKeyVO{APIKeyModel: key, Scopes: common.ParseAPIKeyScopes(key.Scopes), Status: status}
+}
+
+// apiKeyCaller 返回发起请求的登录用户；Open API 与 API Key 请求不能管理 API Key
+func apiKeyCaller(c *gin.Context) (*common.User, bool) {
+	username := c.GetString("user")
+	user := common.GetUser(username)
+	if user == nil || midware.APIKeyFromContext(c) != nil {
+		common.CreateResponse(c, common.PermissionDenied, "api keys can only be managed by a signed-in user")
+		return nil, false
+	}
+	return user, true
+}
+
+// ListAPIKeys API Key 列表（含已过期与已吊销的），按创建时间倒序
+// GET /api/v1/user/apiKeys?username=
+func ListAPIKeys(c *gin.Context) {
+	user, ok := apiKeyCaller(c)
+	if !ok {
+		return
+	}
+	username := user.Username
+	if target := c.Query("username"); target != "" && target != username {
+		if !user.Can(common.PermAdmin) {
+			common.CreateResponse(c, common.PermissionDenied, "permission denied")
+			return
+		}
+		username = target
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return
+	}
+	var keys []models.APIKeyModel
+	if err := db.Where("username = ?", username).Order("id DESC").Find(&keys).Error; err != nil {
+		ylog.Errorf("ListAPIKeys", "query api keys of %s failed: %v", username, err)
+		common.CreateResponse(c, common.ErrorCode, "failed to list api keys")
+		return
+	}
+	list := make([]APIKeyVO, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, newAPIKeyVO(k))
+	}
+	common.CreateResponse(c, common.SuccessCode, gin.H{
+		"list":           list,
+		"total":          len(list),
+		"scopes":         common.APIKeyScopes,
+		"openApiEnabled": common.OpenAPIEnable,
+	})
+}
+
+// CreateAPIKey 为当前用户创建 API Key，授权范围不能超出用户角色的权限
+// POST /api/v1/user/apiKeys
+func CreateAPIKey(c *gin.Context) {
+	user, ok := apiKeyCaller(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes" binding:"required"`
+		Path          string   `json:"path"`
+		ExpiresInDays int      `json:"expiresInDays"` // 0 表示永不过期
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(c, "invalid request body")
+		return
+	}
+	if req.ExpiresInDays < 0 {
+		common.BadRequest(c, "expiresInDays must not be negative")
+		return
+	}
+
+	// 按固定顺序去重，并校验用户具备对应权限
+	requested := make(map[string]bool)
+	for _, s := range req.Scopes {
+		s = strings.ToLower(strings.TrimSpace(s))
+		perm, valid := common.APIKeyScopePermission(s)
+		if !valid {
+			common.BadRequest(c, "invalid scope: "+s)
+			return
+		}
+		if !user.Can(perm) {
+			common.CreateResponse(c, common.PermissionDenied, "scope "+s+" exceeds the permissions of role "+user.Role)
+			return
+		}
+		requested[s] = true
+	}
+	var scopes []string
+	for _, s := range common.APIKeyScopes {
+		if requested[s] {
+			scopes = append(scopes, s)
+		}
+	}
+	if len(scopes) == 0 {
+		common.BadRequest(c, "at least one scope is required")
+		return
+	}
+
+	// 目录限制为用户视图内的路径，须能访问
+	prefix, err := storage.CleanPath(strings.Trim(strings.TrimSpace(req.Path), "/"))
+	if err != nil {
+		common.BadRequest(c, "invalid path")
+		return
+	}
+	if prefix != "" {
+		if _, ok := resolveCallerPath(c, prefix, false); !ok {
+			return
+		}
+	}
+
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return
+	}
+	var count int64
+	db.Model(&models.APIKeyModel{}).Where("username = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)",
+		user.Username, time.Now()).Count(&count)
+	if count >= maxAPIKeysPerUser {
+		common.BadRequest(c, "too many api keys, revoke unused ones first")
+		return
+	}
+
+	accessKey, secretKey := midware.GenerateAPIKeyPair()
+	key := models.APIKeyModel{
+		AccessKey:  accessKey,
+		SecretKey:  secretKey,
+		Username:   user.Username,
+		Name:       strings.TrimSpace(req.Name),
+		Scopes:     strings.Join(scopes, ","),
+		PathPrefix: prefix,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+	if err := db.Create(&key).Error; err != nil {
+		ylog.Errorf("CreateAPIKey", "create api key for %s failed: %v", user.Username, err)
+		common.CreateResponse(c, common.ErrorCode, "failed to create api key")
+		return
+	}
+	ylog.Infof("CreateAPIKey", "api key %s created for %s, scopes=%s path=%q", accessKey, user.Username, key.Scopes, prefix)
+	common.CreateResponse(c, common.SuccessCode, gin.H{
+		"key":       newAPIKeyVO(key),
+		"accessKey": accessKey,
+		"secretKey": secretKey,
+	})
+}
+
+// RevokeAPIKey 吊销 API Key，立即失效
+// DELETE /api/v1/user/apiKeys/:id
+func RevokeAPIKey(c *gin.Context) {
+	user, ok := apiKeyCaller(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		common.BadRequest(c, "invalid id")
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return
+	}
+	var key models.APIKeyModel
+	if db.Where("id = ?", id).Limit(1).Find(&key).RowsAffected == 0 ||
+		(key.Username != user.Username && !user.Can(common.PermAdmin)) {
+		// 不暴露其他用户的 Key 是否存在
+		common.CreateResponse(c, common.ErrorCode, "api key not found")
+		return
+	}
+	if key.RevokedAt == nil {
+		if err := db.Model(&models.APIKeyModel{}).Where("id = ?", key.ID).Update("revoked_at", time.Now()).Error; err != nil {
+			ylog.Errorf("RevokeAPIKey", "revoke api key %s failed: %v", key.AccessKey, err)
+			common.CreateResponse(c, common.ErrorCode, "failed to revoke api key")
+			return
+		}
+		ylog.Infof("RevokeAPIKey", "api key %s of %s revoked by %s", key.AccessKey, key.Username, user.Username)
+	}
+	common.CreateResponse(c, common.SuccessCode, gin.H{"id": key.ID, "accessKey": key.AccessKey})
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/md5"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -18,6 +19,7 @@ import (
 	"httpcat/internal/common/utils"
 	"httpcat/internal/common/ylog"
 	"httpcat/internal/models"
+	"httpcat/internal/storage"
 	"httpcat/internal/storage/auth"
 
 	"github.com/gin-gonic/gin"
@@ -69,6 +71,7 @@ type initUploadReq struct {
 	ChunkSize   int64  `json:"chunkSize"` // 可选，默认 5MB
 	TotalChunks int    `json:"totalChunks"` // 可选，若不传则由服务端根据 fileSize/chunkSize 计算
 	FileMD5     string `json:"fileMD5"`     // 可选，整体 MD5
+	FileSHA256  string `json:"fileSHA256"`  // 可选，整体 SHA-256（v0.8.0，开启去重存储时可按内容秒传）
 	Dir         string `json:"dir"`         // 可选，目标子目录
 	Overwrite   bool   `json:"overwrite"`   // 若最终文件已存在，是否允许覆盖（默认 false）
 }
@@ -85,41 +88,64 @@ type initUploadResp struct {
 
 // ---------- 工具：UploadToken 校验（复用 UploadFile 的逻辑） ----------
 
-// verifyUploadTokenIfNeeded 校验 UploadToken。
-// 返回 (appkey, ok)；若 EnableUploadToken=false，则直接返回 ("", true)。
-// 未通过时已写入响应体，调用方只需 return。
-func verifyUploadTokenIfNeeded(c *gin.Context) (string, bool) {
-	if !common.EnableUploadToken {
-		return "", true
-	}
-	uploadToken := c.Request.Header.Get("UploadToken")
+// errUploadTokenEmpty 未携带 UploadToken
+var errUploadTokenEmpty = errors.New("UploadToken is empty")
+
+// checkUploadToken 校验 UploadToken 的签名与有效期，返回 appkey 与凭证中的上传策略
+func checkUploadToken(uploadToken string) (string, *storage.UploadPolicy, error) {
 	if uploadToken == "" {
-		common.BadRequest(c, "UploadToken is empty")
-		return "", false
+		return "", nil, errUploadTokenEmpty
 	}
 	parts := strings.Split(uploadToken, ":")
 	if len(parts) != 3 {
-		common.Unauthorized(c, "Invalid UploadToken format")
-		return "", false
+		return "", nil, errors.New("Invalid UploadToken format")
 	}
 	appkey := parts[0]
 	common.UploadTokenLock.RLock()
 	tokenItem, ok := common.UploadTokenTable[appkey]
 	common.UploadTokenLock.RUnlock()
 	if !ok {
-		common.Unauthorized(c, "Invalid Appkey")
-		return "", false
+		return "", nil, errors.New("Invalid Appkey")
 	}
 	if tokenItem.State == "closed" {
-		common.Unauthorized(c, "Invalid Appkey, appkey is closed")
-		return "", false
+		return "", nil, errors.New("Invalid Appkey, appkey is closed")
 	}
 	mac := auth.New(appkey, tokenItem.Appsecret)
 	if !mac.VerifyUploadToken(uploadToken) {
-		common.Unauthorized(c, "UploadToken is invalid")
-		return "", false
+		return "", nil, errors.New("UploadToken is invalid")
+	}
+	// v0.8.0：上传策略随凭证签名，截止时间在每次请求（含 complete）时校验
+	policy, err := storage.ParseUploadPolicy(uploadToken)
+	if err != nil {
+		return "", nil, errors.New("UploadToken is invalid")
+	}
+	if err := policy.CheckDeadline(); err != nil {
+		return "", nil, err
+	}
+	return appkey, policy, nil
+}
+
+// verifyUploadTokenIfNeeded 校验 UploadToken。
+// 返回 (appkey, policy, ok)；若 EnableUploadToken=false，则直接返回 ("", nil, true)，nil 策略不做限制。
+// v0.8.0：经预签名上传链接访问时不需要 UploadToken，返回链接对应的上传策略。
+// 未通过时已写入响应体，调用方只需 return。
+func verifyUploadTokenIfNeeded(c *gin.Context) (string, *storage.UploadPolicy, bool) {
+	if p := presignedUpload(c); p != nil {
+		return "", p.UploadPolicy(), true
+	}
+	if !common.EnableUploadToken {
+		return "", nil, true
+	}
+	appkey, policy, err := checkUploadToken(c.Request.Header.Get("UploadToken"))
+	if errors.Is(err, errUploadTokenEmpty) {
+		common.BadRequest(c, err.Error())
+		return "", nil, false
+	}
+	if err != nil {
+		common.Unauthorized(c, err.Error())
+		return "", nil, false
 	}
-	return appkey, true
+	return appkey, policy, true
 }
 
 // ---------- bitmap 辅助 ----------
@@ -178,7 +204,7 @@ func InitChunkUpload(c *gin.Context) {
 		return
 	}
 
-	appkey, ok := verifyUploadTokenIfNeeded(c)
+	appkey, policy, ok := verifyUploadTokenIfNeeded(c)
 	if !ok {
 		return
 	}
@@ -188,6 +214,8 @@ func InitChunkUpload(c *gin.Context) {
 		common.BadRequest(c, "invalid request body: "+err.Error())
 		return
 	}
+	// v0.8.0：上传策略固定了保存路径（预签名上传链接）时忽略请求中的目录与文件名
+	req.Dir, req.FileName = policy.Target(req.Dir, req.FileName)
 
 	// 校验文件大小
 	if req.FileSize <= 0 {
@@ -217,27 +245,34 @@ func InitChunkUpload(c *gin.Context) {
 	}
 
 	// 解析目标目录
-	uploadBaseDir := common.GetUploadDir()
+	fsys := storage.UploadFS()
 	relDir := strings.TrimSpace(req.Dir)
-	if relDir != "" {
-		resolvedDir, err := common.ResolvePathWithinBase(common.GetUploadDir(), relDir)
-		if err != nil {
-			common.BadRequest(c, "invalid dir")
-			return
-		}
-		uploadBaseDir = resolvedDir
+	cleanDir, err := storage.CleanPath(relDir)
+	if err != nil {
+		common.BadRequest(c, "invalid dir")
+		return
+	}
+	// v0.8.0：按上传策略校验目标位置与声明的文件大小，complete 时再按实际文件校验
+	if !checkUploadPolicy(c, policy, cleanDir, fileName, req.FileSize) {
+		return
+	}
+	// v0.8.0：按用户主目录 / 共享目录授权翻译目标目录
+	cleanDir, ok = resolveCallerDir(c, cleanDir)
+	if !ok {
+		return
 	}
 
-	// 校验目标文件是否已存在
-	finalPath, err := common.ResolvePathWithinBase(uploadBaseDir, fileName)
-	if err != nil {
+	// 校验目标文件是否已存在（finalPath 为相对上传目录的存储路径）
+	finalPath := storage.Join(cleanDir, fileName)
+	info, err := fsys.Stat(finalPath)
+	if errors.Is(err, storage.ErrInvalidPath) {
 		common.BadRequest(c, "invalid fileName path")
 		return
 	}
-	if info, err := os.Stat(finalPath); err == nil && !info.IsDir() && !req.Overwrite {
+	if err == nil && !info.IsDir() && !req.Overwrite {
 		// 若已存在且 MD5 匹配，可支持"秒传"
 		if req.FileMD5 != "" {
-			if existingMD5, err := utils.CalculateMD5(finalPath); err == nil && strings.EqualFold(existingMD5, req.FileMD5) {
+			if existingMD5, err := storage.CachedMD5(fsys, finalPath, storage.UploadPath(finalPath), info); err == nil && strings.EqualFold(existingMD5, req.FileMD5) {
 				c.JSON(http.StatusOK, gin.H{
 					"errorCode": common.SuccessCode,
 					"msg":       "success",
@@ -257,6 +292,10 @@ func InitChunkUpload(c *gin.Context) {
 		common.CreateResponse(c, common.ErrorCode, "target file already exists (set overwrite=true to replace)")
 		return
 	}
+	// v0.8.0：上传策略禁止覆盖时，overwrite=true 同样拒绝
+	if err == nil && !info.IsDir() && respondUploadPolicy(c, policy.CheckOverwrite(true)) {
+		return
+	}
 
 	// 计算分片参数
 	chunkSize := req.ChunkSize
@@ -273,25 +312,97 @@ func InitChunkUpload(c *gin.Context) {
 		return
 	}
 
-	// 秒传：若上传过相同 MD5，找到已 completed 的会话
-	if req.FileMD5 != "" {
+	// v0.8.0：按声明的文件大小预先校验用户 / 目录配额（秒传同样占用配额）
+	if !checkUploadQuota(c, finalPath, appkey, req.FileSize) {
+		return
+	}
+	// v0.8.0：预签名上传链接只能使用一次（含秒传），校验通过后即登记；未能秒传或创建会话时撤销登记
+	if !claimPresignedUpload(c) {
+		return
+	}
+	claimed := false
+	defer func() {
+		if !claimed {
+			releasePresignedUpload(c)
+		}
+	}()
+
+	// v0.8.0 秒传：开启去重存储时，按 SHA-256 命中调用方可读取的文件引用过的内容（只凭 MD5 不秒传）。
+	// 调用方无权下载文件时不秒传，否则凭摘要即可取得读不到的文件；上传策略限定了 MIME 类型时同样不秒传，文件内容须实际上传后识别
+	readScope, canRead := callerReadScope(c)
+	if storage.DedupEnabled() && req.FileSHA256 != "" && canRead && !policy.NeedMime() {
+		version, ok := saveVersionBeforeOverwrite(c, finalPath, appkey, "chunk_upload")
+		if !ok {
+			return
+		}
+		blob, err := storage.LinkBlob(storage.UploadPath(finalPath), req.FileSHA256, req.FileSize, readScope)
+		if err == nil {
+			claimed = true
+			logVersionCreated(c, appkey, version)
+			storage.SetFileOwner(finalPath, requestOperator(c, appkey))
+			ylog.Infof("InitChunkUpload", "instant upload by blob %s -> %s", blob.Hash, finalPath)
+			if common.EnableSqlite {
+				go insertUploadLog(c.ClientIP(), appkey,
+					time.Now().Format("2006-01-02 15:04:05"),
+					fileName, utils.FormatSize(req.FileSize),
+					blob.MD5, time.Now().Unix(), time.Now().Unix())
+			}
+			c.JSON(http.StatusOK, gin.H{
+				"errorCode": common.SuccessCode,
+				"msg":       "success",
+				"data": initUploadResp{
+					UploadID:    "instant-" + blob.Hash,
+					ChunkSize:   chunkSize,
+					TotalChunks: totalChunks,
+					UploadedNum: totalChunks,
+					UploadedIdx: fullIdxList(totalChunks),
+					Instant:     true,
+					ExpireAt:    time.Now().Add(DefaultSessionTTL).Unix(),
+				},
+			})
+			return
+		}
+		storage.DiscardVersion(version)
+		if !errors.Is(err, storage.ErrBlobNotFound) {
+			ylog.Errorf("InitChunkUpload", "instant upload by blob failed, fallback to normal upload: %v", err)
+		}
+	}
+
+	// 秒传：v0.8.0 起先按文件索引查找内容相同（MD5 与大小一致）的文件，未命中时再找上传过相同 MD5 且已 completed 的会话；
+	// 两者都只接受调用方可读取的文件
+	if req.FileMD5 != "" && canRead && !policy.NeedMime() {
 		db, err := common.GetDB()
 		if err == nil {
-			var prior models.UploadSessionModel
-			err := db.Where("file_md5 = ? AND status = ?", req.FileMD5, "completed").
-				Order("updated_at DESC").
-				First(&prior).Error
-			if err == nil && prior.FinalPath != "" {
-				if _, err := os.Stat(prior.FinalPath); err == nil {
-					// 硬链接到目标位置（同分区），不占额外空间；失败则退化为拷贝
-					_ = os.MkdirAll(filepath.Dir(finalPath), 0o755)
-					if err := os.Link(prior.FinalPath, finalPath); err != nil {
-						if err := copyFile(prior.FinalPath, finalPath); err == nil {
-							ylog.Infof("InitChunkUpload", "instant upload via copy: %s", finalPath)
-						}
-					} else {
-						ylog.Infof("InitChunkUpload", "instant upload via hardlink: %s", finalPath)
+			// priorPath 为相对于存储根目录的路径
+			priorPath, found := storage.FindByChecksum(req.FileMD5, req.FileSize, readScope)
+			if !found {
+				var prior models.UploadSessionModel
+				err := db.Where("file_md5 = ? AND status = ?", req.FileMD5, "completed").
+					Order("updated_at DESC").
+					First(&prior).Error
+				if err == nil && prior.FinalPath != "" {
+					priorPath = storage.UploadPath(sessionStoragePath(prior.FinalPath))
+					if readScope.CanReadKey(priorPath) {
+						_, err = storage.Backend().Stat(priorPath)
+						found = err == nil
 					}
+				}
+			}
+			if found {
+				// v0.8.0：覆盖已有文件（overwrite=true）前保存旧版本
+				version, ok := saveVersionBeforeOverwrite(c, finalPath, appkey, "chunk_upload")
+				if !ok {
+					return
+				}
+				// 本地驱动优先硬链接到目标位置（同分区，不占额外空间），失败则退化为拷贝
+				if err := storage.Copy(storage.Backend(), priorPath, storage.UploadPath(finalPath)); err != nil {
+					storage.DiscardVersion(version)
+					ylog.Errorf("InitChunkUpload", "instant upload failed, fallback to normal upload: %v", err)
+				} else {
+					claimed = true
+					logVersionCreated(c, appkey, version)
+					storage.SetFileOwner(finalPath, requestOperator(c, appkey))
+					ylog.Infof("InitChunkUpload", "instant upload: %s -> %s", priorPath, finalPath)
 					// 记录上传日志
 					if common.EnableSqlite {
 						go insertUploadLog(c.ClientIP(), appkey,
@@ -342,7 +453,7 @@ func InitChunkUpload(c *gin.Context) {
 		UploadedBits: strings.Repeat("0", totalChunks),
 		UploadedNum:  0,
 		Status:       "active",
-		FinalPath:    finalPath,
+		FinalPath:    finalPath, // v0.8.0 起为相对上传目录的存储路径
 		IP:           c.ClientIP(),
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
@@ -354,15 +465,46 @@ func InitChunkUpload(c *gin.Context) {
 		common.CreateResponse(c, common.ErrorCode, "database unavailable")
 		return
 	}
+
+	// v0.8.0：驱动支持分段上传（S3）且分片参数满足其限制时，会话直接映射为一次分段上传；
+	// 否则（本地驱动、分片过小等）仍将分片暂存到 ChunkSessionDir，complete 时再流式合并
+	mp, useMultipart := fsys.(storage.MultipartUploader)
+	if useMultipart && totalChunks > 1 && (chunkSize < mp.MinPartSize() || totalChunks > mp.MaxParts()) {
+		ylog.Infof("InitChunkUpload", "chunkSize %d not suitable for %s multipart upload, stage chunks locally", chunkSize, fsys.Name())
+		useMultipart = false
+	}
+	if useMultipart {
+		// 声明了整体 MD5 时先写到临时路径，校验通过后再移动到最终位置，避免校验失败时覆盖已有文件
+		storageKey := finalPath
+		if session.FileMD5 != "" {
+			storageKey = fmt.Sprintf("%s.%s.merging", finalPath, uploadID)
+		}
+		storageUploadID, err := mp.InitMultipart(storageKey)
+		if err != nil {
+			ylog.Errorf("InitChunkUpload", "init multipart upload failed: %v", err)
+			common.CreateResponse(c, common.ErrorCode, "failed to create session")
+			return
+		}
+		session.StorageUploadID = storageUploadID
+		session.StorageKey = storageKey
+	}
+
 	if err := db.Create(&session).Error; err != nil {
 		ylog.Errorf("InitChunkUpload", "create session failed: %v", err)
+		if session.StorageUploadID != "" {
+			_ = mp.AbortMultipart(session.StorageKey, session.StorageUploadID)
+		}
 		common.CreateResponse(c, common.ErrorCode, "failed to create session")
 		return
 	}
+	claimed = true
+	bindPresignedUpload(c, uploadID)
 
 	// 创建分片临时目录
-	if err := os.MkdirAll(common.ChunkSessionDir(uploadID), 0o755); err != nil {
-		ylog.Errorf("InitChunkUpload", "create chunk dir failed: %v", err)
+	if session.StorageUploadID == "" {
+		if err := os.MkdirAll(common.ChunkSessionDir(uploadID), 0o755); err != nil {
+			ylog.Errorf("InitChunkUpload", "create chunk dir failed: %v", err)
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -396,10 +538,13 @@ func GetChunkUploadStatus(c *gin.Context) {
 	}
 
 	var session models.UploadSessionModel
-	if err := db.Where("upload_id = ?", uploadID).First(&session).Error; err != nil {
+	if err := db.Where("upload_id = ?", uploadID).First(&session).Error; err != nil || session.Source != "" {
 		common.CreateResponse(c, common.ErrorCode, "session not found")
 		return
 	}
+	if !checkPresignedSession(c, uploadID) {
+		return
+	}
 
 	common.CreateResponse(c, common.SuccessCode, gin.H{
 		"uploadId":    session.UploadID,
@@ -423,7 +568,8 @@ func UploadChunk(c *gin.Context) {
 		common.CreateResponse(c, common.ErrorCode, "File service is not enabled")
 		return
 	}
-	if _, ok := verifyUploadTokenIfNeeded(c); !ok {
+	_, policy, ok := verifyUploadTokenIfNeeded(c)
+	if !ok {
 		return
 	}
 
@@ -455,10 +601,13 @@ func UploadChunk(c *gin.Context) {
 	}
 
 	var session models.UploadSessionModel
-	if err := db.Where("upload_id = ?", uploadID).First(&session).Error; err != nil {
+	if err := db.Where("upload_id = ?", uploadID).First(&session).Error; err != nil || session.Source != "" {
 		common.CreateResponse(c, common.ErrorCode, "session not found")
 		return
 	}
+	if !checkPresignedSession(c, uploadID) {
+		return
+	}
 	if session.Status != "active" {
 		common.CreateResponse(c, common.ErrorCode, "session is not active: "+session.Status)
 		return
@@ -478,12 +627,78 @@ func UploadChunk(c *gin.Context) {
 		return
 	}
 
+	// v0.8.0：首个分片决定文件的 MIME 类型，记录到会话供 complete 时按上传策略校验
+	mimeType := ""
+	if chunkIndex == 0 {
+		if mimeType, err = sniffMime(file); err != nil {
+			ylog.Errorf("UploadChunk", "sniff content type: %v", err)
+			common.CreateResponse(c, common.ErrorCode, "failed to read chunk")
+			return
+		}
+		if respondUploadPolicy(c, policy.CheckMime(mimeType)) {
+			return
+		}
+	}
+
+	var gotMD5 string
+	var written bool
+	if session.StorageUploadID != "" {
+		gotMD5, written = putChunkPart(c, &session, chunkIndex, file, header.Size, chunkMD5Expect)
+	} else {
+		gotMD5, written = writeChunkFile(c, uploadID, chunkIndex, file, chunkMD5Expect)
+	}
+	if !written {
+		return
+	}
+
+	// 更新 bitmap（同一会话串行化）
+	mu := lockSession(uploadID)
+	mu.Lock()
+	defer unlockAndCleanup(uploadID, mu)
+
+	// 重新读一次 session 拿最新 bitmap
+	if err := db.Where("upload_id = ?", uploadID).First(&session).Error; err != nil || session.Source != "" {
+		common.CreateResponse(c, common.ErrorCode, "session not found")
+		return
+	}
+
+	newBitmap, changed := bitmapSet(session.UploadedBits, chunkIndex, session.TotalChunks)
+	if changed || mimeType != "" {
+		updates := map[string]interface{}{"updated_at": time.Now()}
+		if changed {
+			session.UploadedBits = newBitmap
+			session.UploadedNum++
+			updates["uploaded_bits"] = newBitmap
+			updates["uploaded_num"] = session.UploadedNum
+		}
+		if mimeType != "" {
+			updates["mime_type"] = mimeType
+		}
+		if err := db.Model(&models.UploadSessionModel{}).
+			Where("upload_id = ?", uploadID).
+			Updates(updates).Error; err != nil {
+			ylog.Errorf("UploadChunk", "update session: %v", err)
+		}
+	}
+
+	common.CreateResponse(c, common.SuccessCode, gin.H{
+		"uploadId":    uploadID,
+		"chunkIndex":  chunkIndex,
+		"chunkMD5":    gotMD5,
+		"uploadedNum": session.UploadedNum,
+		"totalChunks": session.TotalChunks,
+	})
+}
+
+// writeChunkFile 将分片写入本地临时目录（先写 .part，完成后 rename 保证幂等），返回分片 MD5
+// 失败时已写入响应体
+func writeChunkFile(c *gin.Context, uploadID string, chunkIndex int, file io.Reader, chunkMD5Expect string) (string, bool) {
 	// 将分片写入临时文件（先写 .part，完成后 rename 保证幂等）
 	chunkDir := common.ChunkSessionDir(uploadID)
 	if err := os.MkdirAll(chunkDir, 0o755); err != nil {
 		ylog.Errorf("UploadChunk", "mkdir chunk dir: %v", err)
 		common.CreateResponse(c, common.ErrorCode, "failed to prepare chunk dir")
-		return
+		return "", false
 	}
 
 	chunkPath := filepath.Join(chunkDir, fmt.Sprintf("%06d", chunkIndex))
@@ -493,7 +708,7 @@ func UploadChunk(c *gin.Context) {
 	if err != nil {
 		ylog.Errorf("UploadChunk", "create tmp file: %v", err)
 		common.CreateResponse(c, common.ErrorCode, "failed to write chunk")
-		return
+		return "", false
 	}
 
 	hasher := md5.New()
@@ -502,7 +717,7 @@ func UploadChunk(c *gin.Context) {
 		_ = os.Remove(tmpPath)
 		ylog.Errorf("UploadChunk", "copy chunk: %v", err)
 		common.CreateResponse(c, common.ErrorCode, "failed to write chunk")
-		return
+		return "", false
 	}
 	_ = out.Close()
 
@@ -510,50 +725,41 @@ func UploadChunk(c *gin.Context) {
 	if chunkMD5Expect != "" && chunkMD5Expect != gotMD5 {
 		_ = os.Remove(tmpPath)
 		common.BadRequest(c, fmt.Sprintf("chunk MD5 mismatch: expect %s, got %s", chunkMD5Expect, gotMD5))
-		return
+		return "", false
 	}
 
 	if err := os.Rename(tmpPath, chunkPath); err != nil {
 		_ = os.Remove(tmpPath)
 		ylog.Errorf("UploadChunk", "rename chunk: %v", err)
 		common.CreateResponse(c, common.ErrorCode, "failed to finalize chunk")
-		return
+		return "", false
 	}
+	return gotMD5, true
+}
 
-	// 更新 bitmap（同一会话串行化）
-	mu := lockSession(uploadID)
-	mu.Lock()
-	defer unlockAndCleanup(uploadID, mu)
-
-	// 重新读一次 session 拿最新 bitmap
-	if err := db.Where("upload_id = ?", uploadID).First(&session).Error; err != nil {
-		common.CreateResponse(c, common.ErrorCode, "session not found")
-		return
+// putChunkPart 将分片作为一段直接写入存储驱动（v0.8.0，S3 multipart），重复上传同一分片会覆盖该段
+// 失败时已写入响应体
+func putChunkPart(c *gin.Context, session *models.UploadSessionModel, chunkIndex int, file io.Reader, size int64, chunkMD5Expect string) (string, bool) {
+	mp, ok := storage.UploadFS().(storage.MultipartUploader)
+	if !ok {
+		common.CreateResponse(c, common.ErrorCode, "storage driver does not support multipart upload")
+		return "", false
 	}
 
-	newBitmap, changed := bitmapSet(session.UploadedBits, chunkIndex, session.TotalChunks)
-	if changed {
-		session.UploadedBits = newBitmap
-		session.UploadedNum++
-		session.UpdatedAt = time.Now()
-		if err := db.Model(&models.UploadSessionModel{}).
-			Where("upload_id = ?", uploadID).
-			Updates(map[string]interface{}{
-				"uploaded_bits": newBitmap,
-				"uploaded_num":  session.UploadedNum,
-				"updated_at":    session.UpdatedAt,
-			}).Error; err != nil {
-			ylog.Errorf("UploadChunk", "update session: %v", err)
-		}
+	hasher := md5.New()
+	if err := mp.PutPart(session.StorageKey, session.StorageUploadID, chunkIndex+1, io.TeeReader(file, hasher), size); err != nil {
+		ylog.Errorf("UploadChunk", "put part: %v", err)
+		common.CreateResponse(c, common.ErrorCode, "failed to write chunk")
+		return "", false
 	}
 
-	common.CreateResponse(c, common.SuccessCode, gin.H{
-		"uploadId":    uploadID,
-		"chunkIndex":  chunkIndex,
-		"chunkMD5":    gotMD5,
-		"uploadedNum": session.UploadedNum,
-		"totalChunks": session.TotalChunks,
-	})
+	// 校验失败的段不计入 bitmap，客户端重传后会覆盖
+	gotMD5 := hex.EncodeToString(hasher.Sum(nil))
+	if chunkMD5Expect != "" && chunkMD5Expect != gotMD5 {
+		common.BadRequest(c, fmt.Sprintf("chunk MD5 mismatch: expect %s, got %s", chunkMD5Expect, gotMD5))
+		return "", false
+	}
+	return gotMD5, true
 }
 
 // CompleteChunkUpload 合并分片
@@ -564,7 +770,7 @@ func CompleteChunkUpload(c *gin.Context) {
 		common.CreateResponse(c, common.ErrorCode, "File service is not enabled")
 		return
 	}
-	appkey, ok := verifyUploadTokenIfNeeded(c)
+	appkey, policy, ok := verifyUploadTokenIfNeeded(c)
 	if !ok {
 		return
 	}
@@ -584,10 +790,13 @@ func CompleteChunkUpload(c *gin.Context) {
 	}
 
 	var session models.UploadSessionModel
-	if err := db.Where("upload_id = ?", req.UploadID).First(&session).Error; err != nil {
+	if err := db.Where("upload_id = ?", req.UploadID).First(&session).Error; err != nil || session.Source != "" {
 		common.CreateResponse(c, common.ErrorCode, "session not found")
 		return
 	}
+	if !checkPresignedSession(c, req.UploadID) {
+		return
+	}
 	if session.Status != "active" {
 		common.CreateResponse(c, common.ErrorCode, "session is not active: "+session.Status)
 		return
@@ -599,7 +808,7 @@ func CompleteChunkUpload(c *gin.Context) {
 	defer unlockAndCleanup(req.UploadID, mu)
 
 	// 再读一次最新状态
-	if err := db.Where("upload_id = ?", req.UploadID).First(&session).Error; err != nil {
+	if err := db.Where("upload_id = ?", req.UploadID).First(&session).Error; err != nil || session.Source != "" {
 		common.CreateResponse(c, common.ErrorCode, "session not found")
 		return
 	}
@@ -614,75 +823,42 @@ func CompleteChunkUpload(c *gin.Context) {
 		return
 	}
 
-	// 确保目标目录存在
-	if err := os.MkdirAll(filepath.Dir(session.FinalPath), 0o755); err != nil {
-		ylog.Errorf("CompleteChunkUpload", "mkdir final dir: %v", err)
-		common.CreateResponse(c, common.ErrorCode, "failed to prepare final dir")
-		return
-	}
-
-	// 合并：顺序读取每个分片，写入目标文件，同时计算 MD5
+	fsys := storage.UploadFS()
+	finalPath := sessionStoragePath(session.FinalPath)
 	chunkDir := common.ChunkSessionDir(req.UploadID)
-	tmpFinal := session.FinalPath + ".merging"
-	out, err := os.Create(tmpFinal)
-	if err != nil {
-		ylog.Errorf("CompleteChunkUpload", "create final tmp: %v", err)
-		common.CreateResponse(c, common.ErrorCode, "failed to create final file")
-		return
-	}
 
-	hasher := md5.New()
-	var written int64
-	for i := 0; i < session.TotalChunks; i++ {
-		chunkPath := filepath.Join(chunkDir, fmt.Sprintf("%06d", i))
-		in, err := os.Open(chunkPath)
-		if err != nil {
-			_ = out.Close()
-			_ = os.Remove(tmpFinal)
-			ylog.Errorf("CompleteChunkUpload", "open chunk %d: %v", i, err)
-			common.CreateResponse(c, common.ErrorCode, fmt.Sprintf("failed to open chunk %d", i))
-			return
-		}
-		n, err := io.Copy(io.MultiWriter(out, hasher), in)
-		_ = in.Close()
-		if err != nil {
-			_ = out.Close()
-			_ = os.Remove(tmpFinal)
-			ylog.Errorf("CompleteChunkUpload", "merge chunk %d: %v", i, err)
-			common.CreateResponse(c, common.ErrorCode, fmt.Sprintf("failed to merge chunk %d", i))
+	// v0.8.0：按本次请求所带凭证的上传策略校验最终文件（目标位置、大小、内容类型、是否覆盖）
+	if policy != nil {
+		viewDir, _ := storage.CleanPath(session.RelDir)
+		if !checkUploadPolicy(c, policy, viewDir, session.FileName, session.FileSize) ||
+			respondUploadPolicy(c, policy.CheckMime(session.MimeType)) ||
+			!checkUploadOverwrite(c, policy, fsys, finalPath) {
 			return
 		}
-		written += n
-	}
-	if err := out.Close(); err != nil {
-		_ = os.Remove(tmpFinal)
-		ylog.Errorf("CompleteChunkUpload", "close final: %v", err)
-		common.CreateResponse(c, common.ErrorCode, "failed to close final file")
-		return
 	}
 
-	// 校验总大小
-	if written != session.FileSize {
-		_ = os.Remove(tmpFinal)
-		common.CreateResponse(c, common.ErrorCode, fmt.Sprintf("final size mismatch: expect %d, got %d", session.FileSize, written))
+	// v0.8.0：覆盖已有文件（overwrite=true）前保存旧版本
+	version, ok := saveVersionBeforeOverwrite(c, finalPath, appkey, "chunk_upload")
+	if !ok {
 		return
 	}
 
-	// 校验整体 MD5（若客户端声明了）
-	finalMD5 := hex.EncodeToString(hasher.Sum(nil))
-	if session.FileMD5 != "" && !strings.EqualFold(session.FileMD5, finalMD5) {
-		_ = os.Remove(tmpFinal)
-		common.CreateResponse(c, common.ErrorCode, fmt.Sprintf("file MD5 mismatch: expect %s, got %s", session.FileMD5, finalMD5))
-		return
+	var finalMD5 string
+	var merged bool
+	if session.StorageUploadID != "" {
+		finalMD5, merged = completeMultipartSession(c, fsys, &session, finalPath)
+	} else {
+		finalMD5, merged = mergeChunkFiles(c, fsys, &session, chunkDir, finalPath)
 	}
-
-	// 原子 rename 到最终位置
-	if err := os.Rename(tmpFinal, session.FinalPath); err != nil {
-		_ = os.Remove(tmpFinal)
-		ylog.Errorf("CompleteChunkUpload", "rename final: %v", err)
-		common.CreateResponse(c, common.ErrorCode, "failed to finalize file")
+	if !merged {
+		storage.DiscardVersion(version)
 		return
 	}
+	logVersionCreated(c, appkey, version)
+	storage.SetFileOwner(finalPath, requestOperator(c, appkey))
+	if info, err := fsys.Stat(finalPath); err == nil {
+		storage.RememberMD5(storage.UploadPath(finalPath), info, finalMD5)
+	}
 
 	// 更新会话状态
 	db.Model(&models.UploadSessionModel{}).
@@ -720,10 +896,109 @@ func CompleteChunkUpload(c *gin.Context) {
 		"fileName": session.FileName,
 		"fileSize": session.FileSize,
 		"fileMD5":  finalMD5,
-		"path":     session.FinalPath,
+		"path":     finalPath,
 	})
 }
 
+// mergeChunkFiles 顺序读取本地暂存的分片，以流的方式写入存储驱动并校验大小与 MD5，返回整体 MD5
+// 失败时已写入响应体
+func mergeChunkFiles(c *gin.Context, fsys storage.Driver, session *models.UploadSessionModel, chunkDir, finalPath string) (string, bool) {
+	// 先写入 .merging 临时对象，校验通过后再 rename 到最终位置
+	tmpFinal := finalPath + ".merging"
+
+	hasher := md5.New()
+	reader := &chunkSequenceReader{dir: chunkDir, total: session.TotalChunks}
+	written, err := fsys.Put(tmpFinal, io.TeeReader(reader, hasher), session.FileSize)
+	reader.Close()
+	if err != nil {
+		_ = fsys.Remove(tmpFinal)
+		ylog.Errorf("CompleteChunkUpload", "merge chunks: %v", err)
+		common.CreateResponse(c, common.ErrorCode, "failed to merge chunks")
+		return "", false
+	}
+
+	// 校验总大小
+	if written != session.FileSize {
+		_ = fsys.Remove(tmpFinal)
+		common.CreateResponse(c, common.ErrorCode, fmt.Sprintf("final size mismatch: expect %d, got %d", session.FileSize, written))
+		return "", false
+	}
+
+	// 校验整体 MD5（若客户端声明了）
+	finalMD5 := hex.EncodeToString(hasher.Sum(nil))
+	if session.FileMD5 != "" && !strings.EqualFold(session.FileMD5, finalMD5) {
+		_ = fsys.Remove(tmpFinal)
+		common.CreateResponse(c, common.ErrorCode, fmt.Sprintf("file MD5 mismatch: expect %s, got %s", session.FileMD5, finalMD5))
+		return "", false
+	}
+
+	// rename 到最终位置（本地驱动为原子操作）
+	if err := fsys.Rename(tmpFinal, finalPath); err != nil {
+		_ = fsys.Remove(tmpFinal)
+		ylog.Errorf("CompleteChunkUpload", "rename final: %v", err)
+		common.CreateResponse(c, common.ErrorCode, "failed to finalize file")
+		return "", false
+	}
+	return finalMD5, true
+}
+
+// completeMultipartSession 完成驱动侧的分段上传（v0.8.0，S3 multipart）并校验大小；
+// 客户端声明了整体 MD5 时回读对象校验，通过后再移动到最终位置。
+// 未声明 MD5 时不回读（避免为计算 MD5 把整个对象再下载一遍），返回的 MD5 为空。
+// 失败时已写入响应体
+func completeMultipartSession(c *gin.Context, fsys storage.Driver, session *models.UploadSessionModel, finalPath string) (string, bool) {
+	mp, ok := fsys.(storage.MultipartUploader)
+	if !ok {
+		common.CreateResponse(c, common.ErrorCode, "storage driver does not support multipart upload")
+		return "", false
+	}
+	if err := mp.CompleteMultipart(session.StorageKey, session.StorageUploadID, session.TotalChunks); err != nil {
+		ylog.Errorf("CompleteChunkUpload", "complete multipart: %v", err)
+		common.CreateResponse(c, common.ErrorCode, "failed to merge chunks")
+		return "", false
+	}
+
+	// 校验总大小
+	info, err := fsys.Stat(session.StorageKey)
+	if err != nil {
+		ylog.Errorf("CompleteChunkUpload", "stat merged file: %v", err)
+		common.CreateResponse(c, common.ErrorCode, "failed to merge chunks")
+		return "", false
+	}
+	if info.Size() != session.FileSize {
+		_ = fsys.Remove(session.StorageKey)
+		common.CreateResponse(c, common.ErrorCode, fmt.Sprintf("final size mismatch: expect %d, got %d", session.FileSize, info.Size()))
+		return "", false
+	}
+
+	if session.FileMD5 == "" {
+		return "", true
+	}
+
+	// 校验整体 MD5
+	finalMD5, err := storage.FileMD5(fsys, session.StorageKey)
+	if err != nil {
+		ylog.Errorf("CompleteChunkUpload", "read merged file: %v", err)
+		common.CreateResponse(c, common.ErrorCode, "failed to verify merged file")
+		return "", false
+	}
+	if !strings.EqualFold(session.FileMD5, finalMD5) {
+		_ = fsys.Remove(session.StorageKey)
+		common.CreateResponse(c, common.ErrorCode, fmt.Sprintf("file MD5 mismatch: expect %s, got %s", session.FileMD5, finalMD5))
+		return "", false
+	}
+
+	if session.StorageKey != finalPath {
+		if err := fsys.Rename(session.StorageKey, finalPath); err != nil {
+			_ = fsys.Remove(session.StorageKey)
+			ylog.Errorf("CompleteChunkUpload", "rename final: %v", err)
+			common.CreateResponse(c, common.ErrorCode, "failed to finalize file")
+			return "", false
+		}
+	}
+	return finalMD5, true
+}
+
 // AbortChunkUpload 中止会话
 // POST /api/v1/file/upload/abort
 // body: { "uploadId": "..." }
@@ -743,10 +1018,22 @@ func AbortChunkUpload(c *gin.Context) {
 	}
 
 	var session models.UploadSessionModel
-	if err := db.Where("upload_id = ?", req.UploadID).First(&session).Error; err != nil {
+	if err := db.Where("upload_id = ?", req.UploadID).First(&session).Error; err != nil || session.Source != "" {
 		common.CreateResponse(c, common.ErrorCode, "session not found")
 		return
 	}
+	if !checkPresignedSession(c, req.UploadID) {
+		return
+	}
+
+	// 中止驱动侧的分段上传（v0.8.0）
+	if session.StorageUploadID != "" && session.Status == "active" {
+		if mp, ok := storage.UploadFS().(storage.MultipartUploader); ok {
+			if err := mp.AbortMultipart(session.StorageKey, session.StorageUploadID); err != nil {
+				ylog.Errorf("AbortChunkUpload", "abort multipart upload: %v", err)
+			}
+		}
+	}
 
 	// 清理分片目录
 	chunkDir := common.ChunkSessionDir(req.UploadID)
@@ -761,6 +1048,10 @@ func AbortChunkUpload(c *gin.Context) {
 			"status":     "aborted",
 			"updated_at": time.Now(),
 		})
+	// v0.8.0：中止未完成的会话时撤销预签名上传链接的登记（已完成的会话不撤销，链接不能再用）
+	if session.Status == "active" {
+		releasePresignedUpload(c)
+	}
 
 	common.CreateResponse(c, common.SuccessCode, gin.H{
 		"uploadId": req.UploadID,
@@ -786,19 +1077,60 @@ func fullIdxList(n int) []int {
 	return idx
 }
 
-func copyFile(src, dst string) error {
-	in, err := os.Open(src)
+// sessionStoragePath 将会话中记录的 FinalPath 转换为相对上传目录的存储路径
+// v0.8.0 之前的会话记录的是本地绝对路径，这里做兼容转换
+func sessionStoragePath(finalPath string) string {
+	if !filepath.IsAbs(finalPath) {
+		return finalPath
+	}
+	base, err := filepath.Abs(common.GetUploadDir())
 	if err != nil {
-		return err
+		return finalPath
+	}
+	rel, err := filepath.Rel(base, finalPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return finalPath
 	}
-	defer in.Close()
+	return filepath.ToSlash(rel)
+}
 
-	out, err := os.Create(dst)
-	if err != nil {
-		return err
+// chunkSequenceReader 按分片序号依次读取分片文件，对外表现为一个连续的流
+type chunkSequenceReader struct {
+	dir   string
+	total int
+	next  int
+	cur   *os.File
+}
+
+func (r *chunkSequenceReader) Read(p []byte) (int, error) {
+	for {
+		if r.cur == nil {
+			if r.next >= r.total {
+				return 0, io.EOF
+			}
+			f, err := os.Open(filepath.Join(r.dir, fmt.Sprintf("%06d", r.next)))
+			if err != nil {
+				return 0, fmt.Errorf("open chunk %d: %w", r.next, err)
+			}
+			r.cur = f
+			r.next++
+		}
+		n, err := r.cur.Read(p)
+		if err == io.EOF {
+			_ = r.cur.Close()
+			r.cur = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
 	}
-	defer out.Close()
+}
 
-	_, err = io.Copy(out, in)
-	return err
+func (r *chunkSequenceReader) Close() {
+	if r.cur != nil {
+		_ = r.cur.Close()
+		r.cur = nil
+	}
 }
@@ -0,0 +1,64 @@
+package v1
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/storage"
+)
+
+// ========== v0.8.0 新增：静态加密 ==========
+//
+//   GET  /api/v1/storage/encryption/status   是否启用、当前主密钥、已配置的密钥 ID、最近一次轮换进度
+//   POST /api/v1/storage/encryption/rotate   后台轮换主密钥  body: { "encryptPlaintext": true }（可选，顺带加密存量明文文件）
+
+// GetEncryptionStatus 静态加密状态
+// GET /api/v1/storage/encryption/status
+func GetEncryptionStatus(c *gin.Context) {
+	if !storage.EncryptionEnabled() {
+		common.CreateResponse(c, common.SuccessCode, gin.H{"enabled": false})
+		return
+	}
+	active, keyIDs, rotation := storage.EncryptionStatus()
+	sort.Strings(keyIDs)
+	common.CreateResponse(c, common.SuccessCode, gin.H{
+		"enabled":   true,
+		"activeKey": active,
+		"keys":      keyIDs,
+		"rotation":  rotation,
+	})
+}
+
+// RotateEncryptionKey 将旧主密钥加密的文件改用当前主密钥（只重写文件头，不重新加密内容）
+// POST /api/v1/storage/encryption/rotate
+func RotateEncryptionKey(c *gin.Context) {
+	if !storage.EncryptionEnabled() {
+		common.CreateResponse(c, common.ErrorCode, "Storage encryption is not enabled")
+		return
+	}
+	var req struct {
+		EncryptPlaintext bool `json:"encryptPlaintext"`
+	}
+	// body 可省略
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			common.BadRequest(c, "invalid request body")
+			return
+		}
+	}
+	if err := storage.RotateEncryption(req.EncryptPlaintext); err != nil {
+		if errors.Is(err, storage.ErrRotationRunning) {
+			common.CreateResponse(c, common.ErrorCode, "Key rotation is already running")
+			return
+		}
+		ylog.Errorf("RotateEncryptionKey", "start key rotation failed: %v", err)
+		common.CreateResponse(c, common.ErrorCode, "failed to start key rotation")
+		return
+	}
+	ylog.Infof("RotateEncryptionKey", "key rotation started by %s (encryptPlaintext=%v)", requestOperator(c, ""), req.EncryptPlaintext)
+	common.CreateResponse(c, common.SuccessCode, gin.H{"started": true})
+}
@@ -0,0 +1,184 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/utils"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+	"httpcat/internal/storage"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// ========== v0.8.0 新增：外部改动同步 ==========
+//
+// 文件系统监听与定期对账发现的外部改动（storage.ChangeEvent.External，如 rsync、cp 直接写入下载目录）
+// 与 API 上传一样记录上传日志与操作日志；图片目录中的新图片生成缩略图并登记到图片表，
+// 被删除的图片移除其记录，指向已不存在文件的分享标记为失效。
+
+// externalUploader 外部改动在上传日志与操作日志中记录的上传者
+const externalUploader = "external"
+
+// SubscribeExternalChanges 订阅外部改动，须在 storage.Backend() 之前调用，以免错过启动时对账发布的事件
+func SubscribeExternalChanges() {
+	if !common.EnableSqlite {
+		return
+	}
+	storage.Subscribe(func(ev storage.ChangeEvent) {
+		if !ev.External {
+			return
+		}
+		switch ev.Op {
+		case storage.OpPut:
+			go syncExternalPut(ev.Path)
+		case storage.OpRemoveAll:
+			go syncExternalRemove(ev.Path)
+		}
+	})
+}
+
+// relUnder 返回 name 相对于 dir 的路径（均相对于存储根目录），name 不在 dir 下时返回 false
+func relUnder(name, dir string) (string, bool) {
+	switch {
+	case dir == "":
+		return name, true
+	case name == dir:
+		return "", true
+	case strings.HasPrefix(name, dir+"/"):
+		return name[len(dir)+1:], true
+	}
+	return "", false
+}
+
+// syncExternalPut 外部新增或修改了文件 name（相对于存储根目录）
+func syncExternalPut(name string) {
+	if rel, ok := relUnder(name, storage.UploadPath(imagesDirName)); ok {
+		if rel != "" && !strings.Contains(rel, "/") && !strings.HasPrefix(rel, "thumb_") {
+			syncExternalImage(rel)
+		}
+		return
+	}
+	rel, ok := relUnder(name, storage.DownloadPath(""))
+	if !ok {
+		if rel, ok = relUnder(name, storage.UploadPath("")); !ok {
+			return
+		}
+	}
+
+	info, err := storage.Backend().Stat(name)
+	if err != nil || info.IsDir() {
+		return
+	}
+	// 顺带计算校验和写入文件索引，之后的下载、文件信息查询无需重新计算
+	sums, err := storage.FileChecksums(storage.Backend(), name, name, info)
+	if err != nil {
+		ylog.Errorf("externalChange", "checksum %s failed: %v", name, err)
+	}
+	now := time.Now()
+	insertUploadLog("", externalUploader, now.Format("2006-01-02 15:04:05"), rel,
+		utils.FormatSize(info.Size()), sums.MD5, info.ModTime().Unix(), info.ModTime().Unix())
+	saveExternalOperationLog("external_put", rel, fmt.Sprintf("外部写入: /%s (%s)", rel, utils.FormatSize(info.Size())))
+}
+
+// syncExternalImage 图片目录中出现新图片（或图片被替换）：生成缩略图并登记到图片表
+func syncExternalImage(filename string) {
+	thumbName := "thumb_" + filename
+	if err := generateThumbnail(imageFS(), filename, thumbName); err != nil {
+		ylog.Warnf("externalChange", "skip image %s: %v", filename, err)
+		return
+	}
+	info, err := imageFS().Stat(filename)
+	if err != nil {
+		return
+	}
+	key := storage.UploadPath(storage.Join(imagesDirName, filename))
+	sums, err := storage.FileChecksums(storage.Backend(), key, key, info)
+	if err != nil {
+		ylog.Errorf("externalChange", "checksum %s failed: %v", key, err)
+	}
+
+	db, err := common.GetDB()
+	if err != nil {
+		return
+	}
+	var image models.UploadImageModel
+	if db.Where("file_name = ?", filename).Limit(1).Find(&image).RowsAffected > 0 {
+		db.Model(&image).Updates(map[string]interface{}{"size": info.Size(), "file_md5": sums.MD5})
+		return
+	}
+	db.Create(&models.UploadImageModel{
+		FileUUID:      uuid.NewV4().String(),
+		Size:          info.Size(),
+		FileName:      filename,
+		FilePath:      storage.Join(imagesDirName, filename),
+		ThumbFilePath: storage.Join(imagesDirName, thumbName),
+		FileMD5:       sums.MD5,
+		Sort:          1000,
+		UploadTime:    time.Now().Format("2006-01-02 15:04:05"),
+		UploadUser:    externalUploader,
+		Status:        "done",
+	})
+	saveExternalOperationLog("external_put", storage.Join(imagesDirName, filename), fmt.Sprintf("外部写入图片: %s", filename))
+}
+
+// syncExternalRemove 外部删除了 name（文件或目录，相对于存储根目录）：清理图片记录，失效指向其中文件的分享
+func syncExternalRemove(name string) {
+	db, err := common.GetDB()
+	if err != nil {
+		return
+	}
+	deactivate := func(query string, args ...interface{}) {
+		result := db.Model(&models.ShareModel{}).Where("is_active = ?", true).Where(query, args...).
+			Update("is_active", false)
+		if result.RowsAffected > 0 {
+			ylog.Infof("externalChange", "deactivated %d shares of removed %s", result.RowsAffected, name)
+		}
+	}
+
+	logged := false
+	if rel, ok := relUnder(name, storage.UploadPath(imagesDirName)); ok {
+		switch {
+		case rel == "":
+			db.Unscoped().Where("1 = 1").Delete(&models.UploadImageModel{})
+			deactivate("file_type = ?", "image")
+		case !strings.Contains(rel, "/") && !strings.HasPrefix(rel, "thumb_"):
+			db.Unscoped().Where("file_name = ?", rel).Delete(&models.UploadImageModel{})
+			_ = imageFS().Remove("thumb_" + rel)
+			deactivate("file_type = ? AND file_path = ?", "image", rel)
+		}
+		saveExternalOperationLog("external_remove", storage.Join(imagesDirName, rel), fmt.Sprintf("外部删除图片: %s", rel))
+		logged = true
+	}
+	if rel, ok := relUnder(name, storage.DownloadPath("")); ok {
+		if rel == "" {
+			deactivate("file_type <> ?", "image")
+		} else {
+			// "0" 是 "/" 的下一个字符，[rel/, rel0) 即 rel/ 下的所有路径
+			deactivate("file_type <> ? AND (file_path = ? OR (file_path >= ? AND file_path < ?))", "image", rel, rel+"/", rel+"0")
+		}
+		if !logged {
+			saveExternalOperationLog("external_remove", rel, fmt.Sprintf("外部删除: /%s", rel))
+			logged = true
+		}
+	}
+	if rel, ok := relUnder(name, storage.UploadPath("")); ok && !logged {
+		saveExternalOperationLog("external_remove", rel, fmt.Sprintf("外部删除: /%s", rel))
+	}
+}
+
+func saveExternalOperationLog(action, rel, detail string) {
+	saveOperationLog(models.OperationLogModel{
+		Username:  externalUploader,
+		Method:    "FS",
+		Path:      "/" + rel,
+		Action:    action,
+		Detail:    truncateString(detail, 1024),
+		Status:    http.StatusOK,
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
@@ -1,11 +1,10 @@
 package v1
 
 import (
-	"crypto/md5"
-	"encoding/hex"
+	"errors"
 	"io"
+	"io/fs"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 
@@ -13,21 +12,23 @@ import (
 
 	"httpcat/internal/common"
 	"httpcat/internal/common/ylog"
+	"httpcat/internal/storage"
 )
 
-// DownloadFile 文件下载（v0.7.0：支持 HTTP Range，断点续传/拖动进度条）
+// DownloadFile 文件下载（v0.7.0：支持 HTTP Range，断点续传/拖动进度条；v0.8.0：ETag 与条件请求）
 func DownloadFile(c *gin.Context) {
 	fileName := c.Query("filename")
-	path, err := common.ResolvePathWithinBase(common.GetDownloadDir(), fileName)
-	if err != nil {
-		common.BadRequest(c, "invalid filename")
-		return
-	}
+	scope := callerScope(c)
+	fsys := scope.FS(storage.DownloadFS())
 
-	ylog.Infof("downloadFile", "download file from: %s", path)
+	ylog.Infof("downloadFile", "download file from: %s", fileName)
 
-	file, err := os.Open(path)
+	file, err := fsys.Open(fileName)
 	if err != nil {
+		if errors.Is(err, storage.ErrInvalidPath) {
+			common.BadRequest(c, "invalid filename")
+			return
+		}
 		c.AbortWithStatus(404)
 		ylog.Errorf("downloadFile", "打开文件失败,文件不存在: %v", err)
 		common.CreateResponse(c, common.FileIsNotExists, nil)
@@ -41,31 +42,16 @@ func DownloadFile(c *gin.Context) {
 		return
 	}
 
-	// 设置响应头（ServeContent 会自动处理 Content-Length/Content-Range/If-Modified-Since 等）
-	c.Writer.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(fileInfo.Name()))
-	c.Writer.Header().Set("Content-Type", "application/octet-stream")
-	c.Writer.Header().Set("Accept-Ranges", "bytes")
-
 	// 记录下载日志（异步；Range 请求可能多次触发，这里粗略记录一次即可）
 	// 仅在非 Range 请求或 Range 起始为 0 时记录，避免重复刷日志
 	rangeHeader := c.Request.Header.Get("Range")
 	shouldLog := rangeHeader == "" || rangeHeader == "bytes=0-"
 
-	// 计算 MD5（仅在需要记录日志时计算，避免大文件每次下载都算 MD5）
-	fileMD5 := ""
-	if shouldLog {
-		if h, err := computeFileMD5(path); err == nil {
-			fileMD5 = h
-		}
-	}
+	realPath, _ := scope.Resolve(fileName, false)
+	fileMD5 := serveDownload(c, fsys, fileName, storage.DownloadPath(realPath), file, fileInfo, fileInfo.Name())
 
-	// 使用 http.ServeContent 处理内容发送：
-	// - 支持 Range 请求（断点续传）
-	// - 支持 If-Modified-Since 304
-	// - 自动处理 Content-Length
-	http.ServeContent(c.Writer, c.Request, fileInfo.Name(), fileInfo.ModTime(), file)
-
-	if shouldLog {
+	// 304 / 412 / 416 等未发送内容的响应不记录
+	if shouldLog && c.Writer.Status() < http.StatusMultipleChoices {
 		size := int(fileInfo.Size())
 		createdTime := fileInfo.ModTime().Format("2006-01-02 15:04:05")
 		modifiedTime := createdTime
@@ -74,18 +60,22 @@ func DownloadFile(c *gin.Context) {
 	}
 }
 
-// computeFileMD5 计算文件 MD5
-func computeFileMD5(path string) (string, error) {
-	f, err := os.Open(path)
+// serveDownload 以附件形式发送文件，返回文件 MD5（计算失败时为空）。v0.8.0：以缓存的 MD5 作为 ETag，
+// http.ServeContent 据此与修改时间处理 Range / If-Range（断点续传）、If-None-Match / If-Modified-Since（304）
+// 与 If-Match / If-Unmodified-Since（412）。key 为文件相对于存储根目录的路径，用作 MD5 缓存的键
+func serveDownload(c *gin.Context, fsys storage.Driver, name, key string, file io.ReadSeeker, info fs.FileInfo, downloadName string) string {
+	fileMD5, err := storage.CachedMD5(fsys, name, key, info)
 	if err != nil {
-		return "", err
-	}
-	defer f.Close()
-	h := md5.New()
-	if _, err := io.Copy(h, f); err != nil {
-		return "", err
+		ylog.Warnf("download", "compute md5 of %s failed, serve without ETag: %v", key, err)
+	} else {
+		c.Header("ETag", strconv.Quote(fileMD5))
 	}
-	return hex.EncodeToString(h.Sum(nil)), nil
+	c.Header("Content-Disposition", "attachment; filename="+strconv.Quote(downloadName))
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Accept-Ranges", "bytes")
+	// ServeContent 自动处理 Content-Length / Content-Range 与 HEAD 请求
+	http.ServeContent(c.Writer, c.Request, downloadName, info.ModTime(), file)
+	return fileMD5
 }
 
 func recordDownloadLog(fileName string, ip string, fileSize int, createdTime string, modifiedTime string, fileMD5 string) {
@@ -2,12 +2,10 @@ package v1
 
 import (
 	"archive/zip"
-	"crypto/md5"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -49,91 +47,109 @@ func UploadFile(c *gin.Context) {
 	}
 	defer file.Close()
 
-	appkey := ""
-	if common.EnableUploadToken {
-		uploadToken := c.Request.Header.Get("UploadToken")
-		if uploadToken == "" {
-			common.BadRequest(c, "UploadToken is empty")
-			return
-		}
-
-		parts := strings.Split(uploadToken, ":")
-		if len(parts) != 3 {
-			common.Unauthorized(c, "Invalid UploadToken format")
-			return
-		}
-		appkey = parts[0]
-		common.UploadTokenLock.RLock()
-		tokenItem, ok := common.UploadTokenTable[appkey]
-		common.UploadTokenLock.RUnlock()
-		if !ok {
-			common.Unauthorized(c, "Invalid Appkey")
-			return
-		}
-
-		if tokenItem.State == "closed" {
-			common.Unauthorized(c, "Invalid Appkey, appkey is closed")
-			return
-		}
+	// 支持上传到指定子目录（v0.6.0 新增：拖拽上传到当前目录）
+	saveUploadedFile(c, file, header.Filename, header.Size, c.PostForm("dir"))
+}
 
-		mac := auth.New(appkey, tokenItem.Appsecret)
-		if !mac.VerifyUploadToken(uploadToken) {
-			common.Unauthorized(c, "UploadToken is invalid")
-			return
-		}
+// saveUploadedFile 校验上传凭证与上传策略后保存单个文件，结果已写入响应体（v0.8.0：普通上传与预签名 PUT 上传共用）
+func saveUploadedFile(c *gin.Context, file io.ReadSeeker, name string, size int64, dir string) {
+	appkey, policy, ok := verifyUploadTokenIfNeeded(c)
+	if !ok {
+		return
 	}
 
-	filename, err := common.NormalizeSafeFileName(header.Filename)
+	// v0.8.0：上传策略固定了保存路径（预签名上传链接）时忽略请求中的目录与文件名
+	dir, name = policy.Target(dir, name)
+	filename, err := common.NormalizeSafeFileName(name)
 	if err != nil {
 		common.BadRequest(c, "invalid filename")
 		return
 	}
 
-	// 支持上传到指定子目录（v0.6.0 新增：拖拽上传到当前目录）
-	uploadBaseDir := common.GetUploadDir()
-	dir := c.PostForm("dir")
-	if dir != "" {
-		uploadBaseDir, err = common.ResolvePathWithinBase(common.GetUploadDir(), dir)
-		if err != nil {
+	fsys := storage.UploadFS()
+	relDir, err := storage.CleanPath(dir)
+	if err != nil {
+		common.BadRequest(c, "invalid dir")
+		return
+	}
+	// v0.8.0：按上传凭证的上传策略校验目标位置、大小与内容类型
+	if !checkUploadPolicy(c, policy, relDir, filename, size) || !checkUploadMime(c, policy, file) {
+		return
+	}
+	// v0.8.0：按用户主目录 / 共享目录授权翻译目标目录
+	relDir, ok = resolveCallerDir(c, relDir)
+	if !ok {
+		return
+	}
+
+	filePath := storage.Join(relDir, filename)
+
+	// v0.8.0：按文件大小校验用户 / 目录配额
+	if !checkUploadQuota(c, filePath, appkey, size) {
+		return
+	}
+
+	if err := fsys.Mkdir(relDir); err != nil {
+		if errors.Is(err, storage.ErrInvalidPath) {
 			common.BadRequest(c, "invalid dir")
 			return
 		}
-	}
-
-	if err := os.MkdirAll(uploadBaseDir, 0755); err != nil {
 		ylog.Errorf("uploadFile", "创建目录失败: %v", err)
 		common.CreateResponse(c, common.ErrorCode, "Failed to prepare upload directory")
 		return
 	}
 
-	filePath, err := common.ResolvePathWithinBase(uploadBaseDir, filename)
-	if err != nil {
-		common.BadRequest(c, "invalid filename")
+	ylog.Infof("uploadFile", "upload file to: %s", filePath)
+
+	if !checkUploadOverwrite(c, policy, fsys, filePath) {
 		return
 	}
+	// v0.8.0：预签名上传链接只能使用一次，校验通过后即登记；写入失败时撤销登记，链接可重试
+	if !claimPresignedUpload(c) {
+		return
+	}
+	written := false
+	defer func() {
+		if !written {
+			releasePresignedUpload(c)
+		}
+	}()
 
-	ylog.Infof("uploadFile", "upload file to: %s", filePath)
-	out, err := os.Create(filePath)
-	if err != nil {
-		ylog.Errorf("uploadFile", "创建文件失败: %v", err)
-		common.CreateResponse(c, common.ErrorCode, "Failed to create file")
+	// v0.8.0：覆盖已有文件前保存旧版本
+	version, ok := saveVersionBeforeOverwrite(c, filePath, appkey, "upload")
+	if !ok {
 		return
 	}
-	defer out.Close()
 
-	if _, err = io.Copy(out, file); err != nil {
+	// 写入时同步计算校验和，避免写完再读一遍
+	checksums := storage.NewChecksumWriter()
+	n, err := fsys.Put(filePath, io.TeeReader(file, checksums), size)
+	if err != nil {
+		storage.DiscardVersion(version)
+		if errors.Is(err, storage.ErrInvalidPath) {
+			common.BadRequest(c, "invalid filename")
+			return
+		}
 		ylog.Errorf("uploadFile", "写入文件失败: %v", err)
 		common.CreateResponse(c, common.ErrorCode, "Failed to save file")
 		return
 	}
+	written = true
+	logVersionCreated(c, appkey, version)
+	storage.SetFileOwner(filePath, requestOperator(c, appkey))
 
 	ip := c.ClientIP()
 	uploadTime := time.Now().Format("2006-01-02 15:04:05")
-	fileInfo, _ := os.Stat(filePath)
-	fileSize := utils.FormatSize(fileInfo.Size())
-	fileMD5, _ := utils.CalculateMD5(filePath)
-	fileCreatedTime := fileInfo.ModTime().Unix()
-	fileModifiedTime := fileInfo.ModTime().Unix()
+	fileSize := utils.FormatSize(n)
+	sums := checksums.Sums()
+	fileMD5 := sums.MD5
+	fileCreatedTime := time.Now().Unix()
+	if fileInfo, err := fsys.Stat(filePath); err == nil {
+		fileCreatedTime = fileInfo.ModTime().Unix()
+		// v0.8.0：写入时已算出校验和，下载与查询时无需重新计算
+		storage.RememberChecksums(storage.UploadPath(filePath), fileInfo, sums)
+	}
+	fileModifiedTime := fileCreatedTime
 
 	fmt.Println("PersistentNotifyURL:", common.PersistentNotifyURL)
 	if common.PersistentNotifyURL != "" {
@@ -190,16 +206,25 @@ func insertUploadLog(ip string, appkey string, uploadTime string, filename strin
 
 // ListFiles 获取目录文件列表（支持子目录导航，包含目录条目）
 func ListFiles(c *gin.Context) {
-	dirPath, err := common.ResolvePathWithinBase(common.GetDownloadDir(), c.Query("dir"))
+	scope := callerScope(c)
+	fsys := scope.FS(storage.DownloadFS())
+	dirPath, err := storage.CleanPath(c.Query("dir"))
 	if err != nil {
 		common.BadRequest(c, "invalid dir")
 		return
 	}
 	ylog.Infof("ListFiles", "dirPath:%s", dirPath)
 
-	info, err := os.Stat(dirPath)
+	info, err := fsys.Stat(dirPath)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if errors.Is(err, storage.ErrInvalidPath) {
+			common.BadRequest(c, "invalid dir")
+			return
+		}
+		if respondAccessDenied(c, err) {
+			return
+		}
+		if storage.IsNotExist(err) {
 			ylog.Errorf("ListFiles", "目录不存在: %v", err)
 			common.CreateResponse(c, common.DirISNotExists, "Directory does not exist")
 		} else {
@@ -214,10 +239,9 @@ func ListFiles(c *gin.Context) {
 		return
 	}
 
-	// 使用 os.ReadDir 替代废弃的 ioutil.ReadDir
-	entries, err := os.ReadDir(dirPath)
+	items, err := fsys.List(dirPath)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if storage.IsNotExist(err) {
 			ylog.Errorf("ListFiles", "目录不存在: %v", err)
 			common.CreateResponse(c, common.DirISNotExists, "Directory does not exist")
 		} else {
@@ -228,41 +252,46 @@ func ListFiles(c *gin.Context) {
 		return
 	}
 
-	// 获取 FileInfo 列表用于排序
-	type fileItem struct {
-		entry os.DirEntry
-		info  os.FileInfo
-	}
-	var items []fileItem
-	for _, entry := range entries {
-		fi, err := entry.Info()
-		if err != nil {
-			continue
-		}
-		items = append(items, fileItem{entry: entry, info: fi})
-	}
-
 	// 目录优先，然后按修改时间倒序
 	sort.SliceStable(items, func(i, j int) bool {
-		iIsDir := items[i].info.IsDir()
-		jIsDir := items[j].info.IsDir()
+		iIsDir := items[i].IsDir()
+		jIsDir := items[j].IsDir()
 		if iIsDir != jIsDir {
 			return iIsDir // 目录排在前面
 		}
-		return items[j].info.ModTime().Before(items[i].info.ModTime())
+		return items[j].ModTime().Before(items[i].ModTime())
 	})
 
+	// v0.8.0：附带文件索引中已知的上传者与校验和（一次批量查询，不读取文件内容）
+	var metas map[string]*models.FileMetaModel
+	if realDir, err := scope.Resolve(dirPath, false); err == nil {
+		names := make([]string, 0, len(items))
+		for _, item := range items {
+			if !item.IsDir() {
+				names = append(names, item.Name())
+			}
+		}
+		metas = storage.LookupFileMetas(storage.DownloadPath(realDir), names)
+	}
+
 	var fileList []map[string]interface{}
 	for _, item := range items {
 		fileEntry := map[string]interface{}{
-			"FileName":     item.info.Name(),
-			"LastModified": item.info.ModTime().Format("2006-01-02 15:04:05"),
-			"IsDir":        item.info.IsDir(),
+			"FileName":     item.Name(),
+			"LastModified": item.ModTime().Format("2006-01-02 15:04:05"),
+			"IsDir":        item.IsDir(),
 		}
-		if item.info.IsDir() {
+		if item.IsDir() {
 			fileEntry["Size"] = "-"
 		} else {
-			fileEntry["Size"] = utils.FormatSize(item.info.Size())
+			fileEntry["Size"] = utils.FormatSize(item.Size())
+			if meta := metas[item.Name()]; meta != nil {
+				fileEntry["Uploader"] = meta.Owner
+				if sums, ok := storage.IndexedChecksums(meta, item); ok {
+					fileEntry["MD5"] = sums.MD5
+					fileEntry["MimeType"] = sums.MimeType
+				}
+			}
 		}
 		fileList = append(fileList, fileEntry)
 	}
@@ -280,16 +309,17 @@ func GetFileInfo(c *gin.Context) {
 		return
 	}
 
-	filePath, err := common.ResolvePathWithinBase(common.GetDownloadDir(), fileName)
-	if err != nil {
-		common.BadRequest(c, "invalid filename")
-		return
-	}
-	ylog.Infof("GetFileInfo", "filePath:%s", filePath)
+	scope := callerScope(c)
+	fsys := scope.FS(storage.DownloadFS())
+	ylog.Infof("GetFileInfo", "filePath:%s", fileName)
 
-	fileInfo, err := os.Stat(filePath)
+	fileInfo, err := fsys.Stat(fileName)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if errors.Is(err, storage.ErrInvalidPath) {
+			common.BadRequest(c, "invalid filename")
+			return
+		}
+		if storage.IsNotExist(err) {
 			ylog.Errorf("GetFileInfo", "文件不存在: %v", err)
 			common.CreateResponse(c, common.FileIsNotExists, nil)
 		} else {
@@ -304,29 +334,30 @@ func GetFileInfo(c *gin.Context) {
 		return
 	}
 
-	file, err := os.Open(filePath)
+	// v0.8.0：校验和优先取自文件索引 / 缓存，文件变化后才重新计算
+	realPath, _ := scope.Resolve(fileName, false)
+	key := storage.DownloadPath(realPath)
+	sums, err := storage.FileChecksums(fsys, fileName, key, fileInfo)
 	if err != nil {
-		ylog.Errorf("GetFileInfo", "打开文件失败: %v", err)
+		ylog.Errorf("GetFileInfo", "计算文件校验和失败: %v", err)
 		common.CreateResponse(c, common.ErrorCode, "Failed to open the file")
 		c.AbortWithStatus(500)
 		return
 	}
-	defer file.Close()
-
-	hash := md5.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		c.AbortWithStatus(500)
-		return
+	uploader := ""
+	if meta, ok := storage.LookupFileMeta(key); ok {
+		uploader = meta.Owner
 	}
 
-	md5Hash := hex.EncodeToString(hash.Sum(nil))
-
 	fileEntry := map[string]interface{}{
 		"fileName":     fileInfo.Name(),
 		"lastModified": fileInfo.ModTime().Format("2006-01-02 15:04:05"),
 		"size":         utils.FormatSize(fileInfo.Size()),
-		"md5":          md5Hash,
-		"md5Match":     fileMD5Param == "" || fileMD5Param == md5Hash,
+		"md5":          sums.MD5,
+		"md5Match":     fileMD5Param == "" || strings.EqualFold(fileMD5Param, sums.MD5),
+		"sha256":       sums.SHA256,
+		"mimeType":     sums.MimeType,
+		"uploader":     uploader,
 	}
 
 	common.CreateResponse(c, common.SuccessCode, fileEntry)
@@ -443,14 +474,12 @@ func DeleteFiles(c *gin.Context) {
 		return
 	}
 
-	basePath := common.GetDownloadDir()
-	if req.Dir != "" {
-		var err error
-		basePath, err = common.ResolvePathWithinBase(common.GetDownloadDir(), req.Dir)
-		if err != nil {
-			common.BadRequest(c, "invalid dir")
-			return
-		}
+	scope := callerScope(c)
+	fsys := scope.FS(storage.DownloadFS())
+	basePath, err := storage.CleanPath(req.Dir)
+	if err != nil {
+		common.BadRequest(c, "invalid dir")
+		return
 	}
 
 	var deleted []string
@@ -463,15 +492,12 @@ func DeleteFiles(c *gin.Context) {
 			continue
 		}
 
-		filePath, err := common.ResolvePathWithinBase(basePath, safeName)
-		if err != nil {
-			failed = append(failed, map[string]string{"file": fileName, "error": "invalid path"})
-			continue
-		}
-
-		info, err := os.Stat(filePath)
+		filePath := storage.Join(basePath, safeName)
+		info, err := fsys.Stat(filePath)
 		if err != nil {
-			if os.IsNotExist(err) {
+			if errors.Is(err, storage.ErrInvalidPath) {
+				failed = append(failed, map[string]string{"file": fileName, "error": "invalid path"})
+			} else if storage.IsNotExist(err) {
 				failed = append(failed, map[string]string{"file": fileName, "error": "file not found"})
 			} else {
 				failed = append(failed, map[string]string{"file": fileName, "error": err.Error()})
@@ -479,21 +505,35 @@ func DeleteFiles(c *gin.Context) {
 			continue
 		}
 
-		if info.IsDir() {
+		// v0.8.0：按用户主目录 / 共享目录授权翻译为真实路径并校验写权限
+		realPath, err := scope.Resolve(filePath, true)
+		if err != nil {
+			failed = append(failed, map[string]string{"file": fileName, "error": "permission denied"})
+			continue
+		}
+
+		if storage.TrashEnabled() {
+			// v0.8.0：开启回收站时移入回收站（文件夹无需为空）
+			if _, err := storage.MoveToTrash(realPath, requestOperator(c, ""), "web", c.ClientIP()); err != nil {
+				ylog.Errorf("DeleteFiles", "move %s to trash failed: %v", filePath, err)
+				failed = append(failed, map[string]string{"file": fileName, "error": "failed to move to recycle bin"})
+				continue
+			}
+		} else if info.IsDir() {
 			// 删除目录需要目录为空
-			if err := os.Remove(filePath); err != nil {
+			if err := fsys.Remove(filePath); err != nil {
 				failed = append(failed, map[string]string{"file": fileName, "error": "directory is not empty or cannot be removed"})
 				continue
 			}
 		} else {
-			if err := os.Remove(filePath); err != nil {
+			if err := fsys.Remove(filePath); err != nil {
 				failed = append(failed, map[string]string{"file": fileName, "error": err.Error()})
 				continue
 			}
 		}
 
 		deleted = append(deleted, fileName)
-		ylog.Infof("DeleteFiles", "deleted: %s", filePath)
+		ylog.Infof("DeleteFiles", "deleted: %s", realPath)
 	}
 
 	common.CreateResponse(c, common.SuccessCode, gin.H{
@@ -519,28 +559,28 @@ func CreateFolder(c *gin.Context) {
 		return
 	}
 
-	basePath := common.GetDownloadDir()
-	if req.Dir != "" {
-		basePath, err = common.ResolvePathWithinBase(common.GetDownloadDir(), req.Dir)
-		if err != nil {
-			common.BadRequest(c, "invalid dir")
-			return
-		}
-	}
-
-	folderPath, err := common.ResolvePathWithinBase(basePath, safeName)
+	fsys := callerScope(c).FS(storage.DownloadFS())
+	basePath, err := storage.CleanPath(req.Dir)
 	if err != nil {
-		common.BadRequest(c, "invalid folder path")
+		common.BadRequest(c, "invalid dir")
 		return
 	}
+	folderPath := storage.Join(basePath, safeName)
 
 	// 检查是否已存在
-	if _, err := os.Stat(folderPath); err == nil {
+	if _, err := fsys.Stat(folderPath); err == nil {
 		common.CreateResponse(c, common.ErrorCode, "folder already exists")
 		return
 	}
 
-	if err := os.MkdirAll(folderPath, 0755); err != nil {
+	if err := fsys.Mkdir(folderPath); err != nil {
+		if errors.Is(err, storage.ErrInvalidPath) {
+			common.BadRequest(c, "invalid folder path")
+			return
+		}
+		if respondAccessDenied(c, err) {
+			return
+		}
 		ylog.Errorf("CreateFolder", "创建文件夹失败: %v", err)
 		common.CreateResponse(c, common.ErrorCode, "Failed to create folder")
 		return
@@ -573,39 +613,42 @@ func RenameFile(c *gin.Context) {
 		return
 	}
 
-	basePath := common.GetDownloadDir()
-	if req.Dir != "" {
-		basePath, err = common.ResolvePathWithinBase(common.GetDownloadDir(), req.Dir)
-		if err != nil {
-			common.BadRequest(c, "invalid dir")
-			return
-		}
-	}
-
-	oldPath, err := common.ResolvePathWithinBase(basePath, safeOldName)
-	if err != nil {
-		common.BadRequest(c, "invalid old path")
-		return
-	}
-	newPath, err := common.ResolvePathWithinBase(basePath, safeNewName)
+	fsys := callerScope(c).FS(storage.DownloadFS())
+	basePath, err := storage.CleanPath(req.Dir)
 	if err != nil {
-		common.BadRequest(c, "invalid new path")
+		common.BadRequest(c, "invalid dir")
 		return
 	}
 
+	oldPath := storage.Join(basePath, safeOldName)
+	newPath := storage.Join(basePath, safeNewName)
+
 	// 检查源文件是否存在
-	if _, err := os.Stat(oldPath); os.IsNotExist(err) {
-		common.CreateResponse(c, common.FileIsNotExists, "file not found")
-		return
+	if _, err := fsys.Stat(oldPath); err != nil {
+		if errors.Is(err, storage.ErrInvalidPath) {
+			common.BadRequest(c, "invalid old path")
+			return
+		}
+		if storage.IsNotExist(err) {
+			common.CreateResponse(c, common.FileIsNotExists, "file not found")
+			return
+		}
 	}
 
 	// 检查目标是否已存在
-	if _, err := os.Stat(newPath); err == nil {
+	if _, err := fsys.Stat(newPath); err == nil {
 		common.CreateResponse(c, common.ErrorCode, "target name already exists")
 		return
 	}
 
-	if err := os.Rename(oldPath, newPath); err != nil {
+	if err := fsys.Rename(oldPath, newPath); err != nil {
+		if errors.Is(err, storage.ErrInvalidPath) {
+			common.BadRequest(c, "invalid new path")
+			return
+		}
+		if respondAccessDenied(c, err) {
+			return
+		}
 		ylog.Errorf("RenameFile", "重命名失败: %v", err)
 		common.CreateResponse(c, common.ErrorCode, "Failed to rename")
 		return
@@ -616,10 +659,19 @@ func RenameFile(c *gin.Context) {
 }
 
 // CreateUploadToken 创建上传凭证
+// v0.8.0：可选上传策略 { "deadline": 有效期秒数, "fsizeMin", "fsizeLimit", "scope", "mimeLimit", "extLimit", "insertOnly" }，
+// 未指定有效期与大小限制时使用 upload_policy 配置
 func CreateUploadToken(c *gin.Context) {
 	type MessageData struct {
-		AccessKey string `json:"appkey" binding:"required"`
-		SecretKey string `json:"appsecret" binding:"required"`
+		AccessKey  string `json:"appkey" binding:"required"`
+		SecretKey  string `json:"appsecret" binding:"required"`
+		Deadline   int64  `json:"deadline"`
+		FsizeMin   int64  `json:"fsizeMin"`
+		FsizeLimit int64  `json:"fsizeLimit"`
+		Scope      string `json:"scope"`
+		MimeLimit  string `json:"mimeLimit"`
+		ExtLimit   string `json:"extLimit"`
+		InsertOnly bool   `json:"insertOnly"`
 	}
 
 	var data MessageData
@@ -627,8 +679,31 @@ func CreateUploadToken(c *gin.Context) {
 		common.BadRequest(c, err.Error())
 		return
 	}
+	if data.Deadline < 0 || data.FsizeMin < 0 || data.FsizeLimit < 0 {
+		common.BadRequest(c, "deadline, fsizeMin and fsizeLimit must not be negative")
+		return
+	}
+	if data.FsizeLimit > 0 && data.FsizeMin > data.FsizeLimit {
+		common.BadRequest(c, "fsizeMin must not be greater than fsizeLimit")
+		return
+	}
+	scope, err := storage.NormalizeUploadScope(data.Scope)
+	if err != nil {
+		common.BadRequest(c, "invalid scope")
+		return
+	}
 
-	p := storage.UploadPolicy{}
+	p := storage.UploadPolicy{
+		Deadline:   uint64(data.Deadline),
+		FsizeMin:   data.FsizeMin,
+		FsizeLimit: data.FsizeLimit,
+		Scope:      scope,
+		MimeLimit:  strings.TrimSpace(data.MimeLimit),
+		ExtLimit:   strings.TrimSpace(data.ExtLimit),
+	}
+	if data.InsertOnly {
+		p.InsertOnly = 1
+	}
 	mac := auth.New(data.AccessKey, data.SecretKey)
 	token := p.UploadToken(mac)
 
@@ -724,15 +799,14 @@ func PreviewFile(c *gin.Context) {
 		return
 	}
 
-	filePath, err := common.ResolvePathWithinBase(common.GetDownloadDir(), fileName)
-	if err != nil {
-		common.BadRequest(c, "invalid filename")
-		return
-	}
-
-	fileInfo, err := os.Stat(filePath)
+	fsys := callerScope(c).FS(storage.DownloadFS())
+	fileInfo, err := fsys.Stat(fileName)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if errors.Is(err, storage.ErrInvalidPath) {
+			common.BadRequest(c, "invalid filename")
+			return
+		}
+		if storage.IsNotExist(err) {
 			common.CreateResponse(c, common.FileIsNotExists, nil)
 		} else {
 			common.CreateResponse(c, common.ErrorCode, "Failed to get file information")
@@ -762,7 +836,7 @@ func PreviewFile(c *gin.Context) {
 		return
 	}
 
-	file, err := os.Open(filePath)
+	file, err := fsys.Open(fileName)
 	if err != nil {
 		ylog.Errorf("PreviewFile", "打开文件失败: %v", err)
 		common.CreateResponse(c, common.ErrorCode, "Failed to open file")
@@ -797,15 +871,14 @@ func GetPreviewInfo(c *gin.Context) {
 		return
 	}
 
-	filePath, err := common.ResolvePathWithinBase(common.GetDownloadDir(), fileName)
-	if err != nil {
-		common.BadRequest(c, "invalid filename")
-		return
-	}
-
-	fileInfo, err := os.Stat(filePath)
+	fsys := callerScope(c).FS(storage.DownloadFS())
+	fileInfo, err := fsys.Stat(fileName)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if errors.Is(err, storage.ErrInvalidPath) {
+			common.BadRequest(c, "invalid filename")
+			return
+		}
+		if storage.IsNotExist(err) {
 			common.CreateResponse(c, common.FileIsNotExists, nil)
 		} else {
 			common.CreateResponse(c, common.ErrorCode, "Failed to get file information")
@@ -872,14 +945,11 @@ func DownloadZip(c *gin.Context) {
 		return
 	}
 
-	basePath := common.GetDownloadDir()
-	if req.Dir != "" {
-		var err error
-		basePath, err = common.ResolvePathWithinBase(common.GetDownloadDir(), req.Dir)
-		if err != nil {
-			common.BadRequest(c, "invalid dir")
-			return
-		}
+	fsys := callerScope(c).FS(storage.DownloadFS())
+	basePath, err := storage.CleanPath(req.Dir)
+	if err != nil {
+		common.BadRequest(c, "invalid dir")
+		return
 	}
 
 	// 生成下载文件名
@@ -904,13 +974,8 @@ func DownloadZip(c *gin.Context) {
 			continue
 		}
 
-		filePath, err := common.ResolvePathWithinBase(basePath, safeName)
-		if err != nil {
-			ylog.Errorf("DownloadZip", "invalid path: %s, err: %v", fileName, err)
-			continue
-		}
-
-		info, err := os.Stat(filePath)
+		filePath := storage.Join(basePath, safeName)
+		info, err := fsys.Stat(filePath)
 		if err != nil {
 			ylog.Errorf("DownloadZip", "stat failed: %s, err: %v", filePath, err)
 			continue
@@ -918,12 +983,12 @@ func DownloadZip(c *gin.Context) {
 
 		if info.IsDir() {
 			// 递归添加目录到 zip
-			if err := addDirToZip(zipWriter, filePath, safeName, basePath); err != nil {
+			if err := addDirToZip(zipWriter, fsys, filePath, safeName); err != nil {
 				ylog.Errorf("DownloadZip", "add dir to zip failed: %s, err: %v", filePath, err)
 			}
 		} else {
 			// 添加单个文件到 zip
-			if err := addFileToZip(zipWriter, filePath, safeName); err != nil {
+			if err := addFileToZip(zipWriter, fsys, filePath, safeName); err != nil {
 				ylog.Errorf("DownloadZip", "add file to zip failed: %s, err: %v", filePath, err)
 			}
 		}
@@ -933,8 +998,8 @@ func DownloadZip(c *gin.Context) {
 }
 
 // addFileToZip 添加单个文件到 zip
-func addFileToZip(zipWriter *zip.Writer, filePath, nameInZip string) error {
-	file, err := os.Open(filePath)
+func addFileToZip(zipWriter *zip.Writer, fsys storage.Driver, filePath, nameInZip string) error {
+	file, err := fsys.Open(filePath)
 	if err != nil {
 		return fmt.Errorf("open file failed: %w", err)
 	}
@@ -964,9 +1029,9 @@ func addFileToZip(zipWriter *zip.Writer, filePath, nameInZip string) error {
 	return nil
 }
 
-// addDirToZip 递归添加目录到 zip
-func addDirToZip(zipWriter *zip.Writer, dirPath, namePrefix, basePath string) error {
-	entries, err := os.ReadDir(dirPath)
+// addDirToZip 递归添加目录到 zip（路径安全由存储驱动保证）
+func addDirToZip(zipWriter *zip.Writer, fsys storage.Driver, dirPath, namePrefix string) error {
+	entries, err := fsys.List(dirPath)
 	if err != nil {
 		return fmt.Errorf("read dir failed: %w", err)
 	}
@@ -979,23 +1044,15 @@ func addDirToZip(zipWriter *zip.Writer, dirPath, namePrefix, basePath string) er
 
 	for _, entry := range entries {
 		entryName := entry.Name()
-		entryPath := filepath.Join(dirPath, entryName)
+		entryPath := storage.Join(dirPath, entryName)
 		zipEntryName := namePrefix + "/" + entryName
 
-		// 安全检查：确保路径不逃逸
-		if resolved, err := common.ResolvePathWithinBase(basePath, filepath.Join(namePrefix, entryName)); err != nil {
-			ylog.Errorf("addDirToZip", "path escape detected: %s", entryPath)
-			continue
-		} else {
-			entryPath = resolved
-		}
-
 		if entry.IsDir() {
-			if err := addDirToZip(zipWriter, entryPath, zipEntryName, basePath); err != nil {
+			if err := addDirToZip(zipWriter, fsys, entryPath, zipEntryName); err != nil {
 				ylog.Errorf("addDirToZip", "add subdir failed: %s, err: %v", entryPath, err)
 			}
 		} else {
-			if err := addFileToZip(zipWriter, entryPath, zipEntryName); err != nil {
+			if err := addFileToZip(zipWriter, fsys, entryPath, zipEntryName); err != nil {
 				ylog.Errorf("addDirToZip", "add file failed: %s, err: %v", entryPath, err)
 			}
 		}
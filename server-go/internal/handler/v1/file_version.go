@@ -0,0 +1,222 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/utils"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/midware"
+	"httpcat/internal/models"
+	"httpcat/internal/storage"
+)
+
+// ========== v0.8.0 新增：文件历史版本 ==========
+//
+//   GET  /api/v1/file/versions?path=            列出文件的历史版本
+//   GET  /api/v1/file/versions/download?path=&versionId=   下载指定版本
+//   POST /api/v1/file/versions/restore          恢复指定版本（当前内容先保存为新版本）
+// path 均为相对于上传目录的文件路径；版本的保存与恢复单独写入操作日志（version_create / version_restore）。
+
+// requestOperator 返回发起请求的用户名，未登录（UploadToken 上传）时返回 appkey
+func requestOperator(c *gin.Context, appkey string) string {
+	if user, exists := c.Get("user"); exists {
+		if name := fmt.Sprintf("%v", user); name != "" {
+			return name
+		}
+	}
+	// 上传接口在认证中间件白名单内，携带有效 JWT 时自行解析用户名
+	if name := bearerUsername(c); name != "" {
+		return name
+	}
+	return appkey
+}
+
+// bearerUsername 校验 Authorization 头中的 JWT 并返回用户名，无效时返回空串
+func bearerUsername(c *gin.Context) string {
+	tokenStr := c.GetHeader("Authorization")
+	if len(tokenStr) > 7 && strings.ToUpper(tokenStr[0:7]) == "BEARER " {
+		tokenStr = tokenStr[7:]
+	}
+	if tokenStr == "" {
+		return ""
+	}
+	username, _, err := midware.ParseAccessToken(tokenStr)
+	if err != nil {
+		return ""
+	}
+	return username
+}
+
+// saveVersionBeforeOverwrite 覆盖上传目录中的 name 之前保存旧版本。
+// 返回保存的版本（文件不存在或未开启时为 nil）；保存失败时已写入响应体，ok 为 false。
+// 覆盖成功后调用 logVersionCreated，失败时调用 storage.DiscardVersion。
+func saveVersionBeforeOverwrite(c *gin.Context, name, appkey, reason string) (*models.FileVersionModel, bool) {
+	version, err := storage.SaveVersion(name, requestOperator(c, appkey), reason)
+	if err != nil {
+		ylog.Errorf("fileVersion", "save version of %s failed: %v", name, err)
+		common.CreateResponse(c, common.ErrorCode, "failed to save previous version of the file")
+		return nil, false
+	}
+	return version, true
+}
+
+// logVersionCreated 覆盖成功后记录 version_create 操作日志
+func logVersionCreated(c *gin.Context, appkey string, version *models.FileVersionModel) {
+	if version == nil {
+		return
+	}
+	logVersionEvent(c, appkey, "version_create",
+		fmt.Sprintf("保存历史版本: %s (%s, %s)", version.Path, version.VersionID, utils.FormatSize(version.Size)))
+}
+
+// logVersionEvent 版本事件发生在上传 / 恢复请求中，单独记录一条操作日志
+func logVersionEvent(c *gin.Context, appkey, action, detail string) {
+	if !common.EnableSqlite {
+		return
+	}
+	go saveOperationLog(models.OperationLogModel{
+		Username:  requestOperator(c, appkey),
+		IP:        c.ClientIP(),
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		Action:    action,
+		Detail:    detail,
+		Status:    http.StatusOK,
+		UserAgent: truncateString(c.Request.UserAgent(), 512),
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+// checkVersioning 未开启历史版本时写入响应体并返回 false
+func checkVersioning(c *gin.Context) bool {
+	if !storage.VersioningEnabled() {
+		common.CreateResponse(c, common.ErrorCode, "File versioning is not enabled")
+		return false
+	}
+	return true
+}
+
+// ListFileVersions 列出文件的历史版本
+// GET /api/v1/file/versions?path=dir/a.txt
+func ListFileVersions(c *gin.Context) {
+	if !checkVersioning(c) {
+		return
+	}
+	name, ok := resolveCallerPath(c, c.Query("path"), false)
+	if !ok {
+		return
+	}
+	versions, err := storage.ListVersions(name)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidPath) {
+			common.BadRequest(c, "invalid path")
+			return
+		}
+		ylog.Errorf("ListFileVersions", "list versions failed: %v", err)
+		common.CreateResponse(c, common.ErrorCode, "failed to list versions")
+		return
+	}
+	common.CreateResponse(c, common.SuccessCode, gin.H{
+		"path":     c.Query("path"),
+		"versions": versions,
+		"total":    len(versions),
+	})
+}
+
+// DownloadFileVersion 下载指定版本（支持 Range）
+// GET /api/v1/file/versions/download?path=dir/a.txt&versionId=xxx
+func DownloadFileVersion(c *gin.Context) {
+	if !checkVersioning(c) {
+		return
+	}
+	filePath, ok := resolveCallerPath(c, c.Query("path"), false)
+	if !ok {
+		return
+	}
+	version, err := storage.GetVersion(filePath, c.Query("versionId"))
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidPath) {
+			common.BadRequest(c, "invalid path")
+			return
+		}
+		common.CreateResponse(c, common.FileIsNotExists, "version not found")
+		return
+	}
+	file, err := storage.OpenVersion(version)
+	if err != nil {
+		ylog.Errorf("DownloadFileVersion", "open version %s failed: %v", version.VersionID, err)
+		common.CreateResponse(c, common.FileIsNotExists, "version not found")
+		return
+	}
+	defer file.Close()
+
+	name := path.Base(version.Path)
+	c.Writer.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(name))
+	c.Writer.Header().Set("Content-Type", "application/octet-stream")
+	c.Writer.Header().Set("Accept-Ranges", "bytes")
+	c.Writer.Header().Set("X-Version-Id", version.VersionID)
+	http.ServeContent(c.Writer, c.Request, name, version.ModTime, file)
+}
+
+// RestoreFileVersion 恢复指定版本到原路径
+// POST /api/v1/file/versions/restore  body: { "path": "dir/a.txt", "versionId": "xxx" }
+func RestoreFileVersion(c *gin.Context) {
+	if !checkVersioning(c) {
+		return
+	}
+	var req struct {
+		Path      string `json:"path" binding:"required"`
+		VersionID string `json:"versionId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(c, "invalid request body")
+		return
+	}
+	name, ok := resolveCallerPath(c, req.Path, true)
+	if !ok {
+		return
+	}
+	version, err := storage.GetVersion(name, req.VersionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidPath) {
+			common.BadRequest(c, "invalid path")
+			return
+		}
+		common.CreateResponse(c, common.FileIsNotExists, "version not found")
+		return
+	}
+
+	saved, err := storage.RestoreVersion(version, requestOperator(c, ""))
+	if err != nil {
+		if errors.Is(err, storage.ErrVersionNotFound) {
+			common.CreateResponse(c, common.FileIsNotExists, "version content is missing")
+			return
+		}
+		ylog.Errorf("RestoreFileVersion", "restore %s@%s failed: %v", version.Path, version.VersionID, err)
+		common.CreateResponse(c, common.ErrorCode, "failed to restore version")
+		return
+	}
+
+	detail := fmt.Sprintf("恢复历史版本: %s (%s)", version.Path, version.VersionID)
+	savedID := ""
+	if saved != nil {
+		savedID = saved.VersionID
+		detail += fmt.Sprintf("，恢复前内容保存为 %s", saved.VersionID)
+	}
+	logVersionEvent(c, "", "version_restore", detail)
+
+	common.CreateResponse(c, common.SuccessCode, gin.H{
+		"path":           version.Path,
+		"versionId":      version.VersionID,
+		"savedVersionId": savedID,
+	})
+}
@@ -1,23 +1,23 @@
 package v1
 
 import (
+	"bytes"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"httpcat/internal/common"
-	"httpcat/internal/common/utils"
 	"httpcat/internal/common/ylog"
 	"httpcat/internal/midware"
 	"httpcat/internal/models"
-	"httpcat/internal/storage/auth"
+	"httpcat/internal/storage"
 
 	"github.com/disintegration/imaging"
 	"github.com/gin-gonic/gin"
@@ -33,53 +33,29 @@ func UploadImage(c *gin.Context) {
 
 	jwtAuth := false
 	jwtUsername := ""
+	owner := "" // v0.8.0：配额归属，JWT 用户名或 UploadToken 的 appkey
 	if authHeader := c.Request.Header.Get("Authorization"); authHeader != "" {
 		tokenStr := authHeader
 		if len(tokenStr) > 7 && strings.ToUpper(tokenStr[0:7]) == "BEARER " {
 			tokenStr = tokenStr[7:]
 		}
 		if tokenStr != "" {
-			if claims, err := midware.VerifyToken(tokenStr, []byte(common.JwtSecret)); err == nil {
+			if username, _, err := midware.ParseAccessToken(tokenStr); err == nil {
 				jwtAuth = true
-				if username, ok := (*claims)["username"].(string); ok {
-					jwtUsername = username
-				}
+				jwtUsername = username
+				owner = username
 			}
 		}
 	}
 
+	var policy *storage.UploadPolicy
 	if !jwtAuth && common.EnableUploadToken {
-		uploadToken := c.Request.Header.Get("UploadToken")
-		if uploadToken == "" {
-			common.BadRequest(c, "UploadToken is empty")
-			return
-		}
-
-		parts := strings.Split(uploadToken, ":")
-		if len(parts) != 3 {
-			common.Unauthorized(c, "Invalid UploadToken format")
-			return
-		}
-		appkey := parts[0]
-
-		common.UploadTokenLock.RLock()
-		tokenItem, ok := common.UploadTokenTable[appkey]
-		common.UploadTokenLock.RUnlock()
+		appkey, tokenPolicy, ok := verifyUploadTokenIfNeeded(c)
 		if !ok {
-			common.Unauthorized(c, "Invalid Appkey")
-			return
-		}
-
-		if tokenItem.State == "closed" {
-			common.Unauthorized(c, "Invalid Appkey, appkey is closed")
-			return
-		}
-
-		mac := auth.New(appkey, tokenItem.Appsecret)
-		if !mac.VerifyUploadToken(uploadToken) {
-			common.Unauthorized(c, "UploadToken is invalid")
 			return
 		}
+		owner = appkey
+		policy = tokenPolicy
 	} else if !jwtAuth {
 		common.Unauthorized(c, "Authentication required")
 		return
@@ -107,66 +83,50 @@ func UploadImage(c *gin.Context) {
 	}
 	fmt.Println(file, err, filename)
 
-	imagesDir, err := common.ResolvePathWithinBase(common.GetUploadDir(), "images")
-	if err != nil {
-		common.CreateResponse(c, common.ErrorCode, "Failed to resolve images directory")
-		return
-	}
-	if err := os.MkdirAll(imagesDir, 0755); err != nil {
-		ylog.Errorf("uploadFile", "创建目录失败: %v", err)
-		common.CreateResponse(c, common.ErrorCode, "Failed to prepare images directory")
-		return
-	}
-
-	filePath, err := common.ResolvePathWithinBase(imagesDir, filename)
-	if err != nil {
-		common.BadRequest(c, "invalid filename")
+	// v0.8.0：按上传凭证的上传策略校验（图片存放在上传目录下的 images 目录）
+	if !checkUploadPolicy(c, policy, imagesDirName, filename, header.Size) || !checkUploadMime(c, policy, file) {
 		return
 	}
 
-	if _, err := os.Stat(filePath); err == nil {
+	imagesFS := imageFS()
+	if _, err := imagesFS.Stat(filename); err == nil {
 		common.BadRequest(c, "File already exists")
 		return
+	} else if errors.Is(err, storage.ErrInvalidPath) {
+		common.BadRequest(c, "invalid filename")
+		return
 	}
 
-	ylog.Infof("uploadFile", "upload file to: %s", filePath)
-	out, err := os.Create(filePath)
-	if err != nil {
-		ylog.Errorf("uploadImage", "创建文件失败: %v", err)
-		common.CreateResponse(c, common.ErrorCode, fmt.Sprintf("Failed to create file: %v", err))
+	// v0.8.0：校验用户 / 目录配额
+	if !checkUploadQuota(c, storage.Join(imagesDirName, filename), owner, header.Size) {
 		return
 	}
-	defer out.Close()
 
-	if _, err = io.Copy(out, file); err != nil {
+	ylog.Infof("uploadFile", "upload file to: %s", filename)
+	checksums := storage.NewChecksumWriter()
+	if _, err := imagesFS.Put(filename, io.TeeReader(file, checksums), header.Size); err != nil {
 		ylog.Errorf("uploadImage", "写入文件失败: %v", err)
 		common.CreateResponse(c, common.ErrorCode, fmt.Sprintf("Failed to save file: %v", err))
 		return
 	}
 
-	thumbFilePath, err := common.ResolvePathWithinBase(imagesDir, "thumb_"+filename)
-	if err != nil {
-		os.Remove(filePath)
-		common.CreateResponse(c, common.ErrorCode, "Invalid thumbnail path")
-		return
-	}
-	thumbImage, err := imaging.Open(filePath)
-	if err != nil {
-		ylog.Errorf("uploadImage", "解析图片失败（文件可能不是有效图片）: %v", err)
-		os.Remove(filePath)
+	thumbName := "thumb_" + filename
+	if err := generateThumbnail(imagesFS, filename, thumbName); err != nil {
+		ylog.Errorf("uploadImage", "生成缩略图失败（文件可能不是有效图片）: %v", err)
+		_ = imagesFS.Remove(filename)
 		common.CreateResponse(c, common.ErrorCode, fmt.Sprintf("Invalid image file, failed to parse: %v", err))
 		return
 	}
-	thumbImage = imaging.Resize(thumbImage, common.ThumbWidth, common.ThumbHeight, imaging.Lanczos)
-	if err = imaging.Save(thumbImage, thumbFilePath); err != nil {
-		ylog.Errorf("uploadImage", "保存缩略图失败: %v", err)
-		common.CreateResponse(c, common.ErrorCode, fmt.Sprintf("Failed to generate thumbnail: %v", err))
-		return
-	}
+	storage.SetFileOwner(storage.Join(imagesDirName, filename), owner)
+	storage.SetFileOwner(storage.Join(imagesDirName, thumbName), owner)
 
 	Ip := c.ClientIP()
 	uploadTime := time.Now().Format("2006-01-02 15:04:05")
-	fileMD5, _ := utils.CalculateMD5(filePath)
+	sums := checksums.Sums()
+	fileMD5 := sums.MD5
+	if info, err := imagesFS.Stat(filename); err == nil {
+		storage.RememberChecksums(storage.UploadPath(storage.Join(imagesDirName, filename)), info, sums)
+	}
 	fileUUID := uuid.NewV4().String()
 
 	if common.EnableSqlite {
@@ -182,8 +142,8 @@ func UploadImage(c *gin.Context) {
 			FileUUID:      fileUUID,
 			Size:          header.Size,
 			FileName:      filename,
-			FilePath:      filePath,
-			ThumbFilePath: thumbFilePath,
+			FilePath:      storage.Join(imagesDirName, filename), // v0.8.0 起为相对上传目录的存储路径
+			ThumbFilePath: storage.Join(imagesDirName, thumbName),
 			FileMD5:       fileMD5,
 			DownloadCount: 0,
 			Sort:          1000,
@@ -223,29 +183,22 @@ func RenameImage(c *gin.Context) {
 		return
 	}
 
-	imagesDir, err := common.ResolvePathWithinBase(common.GetUploadDir(), "images")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "图片目录无效"})
-		return
-	}
-	filePath, err := common.ResolvePathWithinBase(imagesDir, filename)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "文件名不合法"})
-		return
-	}
-	newFilePath, err := common.ResolvePathWithinBase(imagesDir, newName)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "新文件名不合法"})
-		return
-	}
-
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+	imagesFS := imageFS()
+	if _, err := imagesFS.Stat(filename); err != nil {
+		if errors.Is(err, storage.ErrInvalidPath) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "文件名不合法"})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"message": "文件不存在"})
 		return
 	}
 
-	err = os.Rename(filePath, newFilePath)
+	err = imagesFS.Rename(filename, newName)
 	if err != nil {
+		if errors.Is(err, storage.ErrInvalidPath) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "新文件名不合法"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "重命名失败"})
 		return
 	}
@@ -272,19 +225,12 @@ func DeleteImage(c *gin.Context) {
 		db.Unscoped().Delete(&image)
 	}
 
-	imagesDir, err := common.ResolvePathWithinBase(common.GetUploadDir(), "images")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "图片目录无效"})
-		return
-	}
-	filePath, err := common.ResolvePathWithinBase(imagesDir, filename)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "文件名不合法"})
-		return
-	}
-
-	err = os.Remove(filePath)
+	err = imageFS().Remove(filename)
 	if err != nil {
+		if errors.Is(err, storage.ErrInvalidPath) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "文件名不合法"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "删除失败"})
 		return
 	}
@@ -308,12 +254,7 @@ func ClearImage(c *gin.Context) {
 		return
 	}
 
-	dirPath, err := common.ResolvePathWithinBase(common.GetUploadDir(), "images")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "图片目录无效"})
-		return
-	}
-	err = os.RemoveAll(dirPath)
+	err = storage.UploadFS().RemoveAll(imagesDirName)
 	if err != nil {
 		ylog.Errorf("clearImage", "清空照片失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "清空照片失败"})
@@ -329,23 +270,23 @@ func DownloadImage(c *gin.Context) {
 		c.String(http.StatusBadRequest, "Invalid filename")
 		return
 	}
-	imagesDir, err := common.ResolvePathWithinBase(common.GetUploadDir(), "images")
+	file, err := imageFS().Open(filename)
 	if err != nil {
-		c.String(http.StatusInternalServerError, "Invalid image directory")
-		return
-	}
-	filePath, err := common.ResolvePathWithinBase(imagesDir, filename)
-	if err != nil {
-		c.String(http.StatusBadRequest, "Invalid filename")
+		if storage.IsNotExist(err) {
+			c.String(http.StatusNotFound, "File not found")
+			return
+		}
+		if errors.Is(err, storage.ErrInvalidPath) {
+			c.String(http.StatusBadRequest, "Invalid filename")
+			return
+		}
+		c.String(http.StatusInternalServerError, "Failed to read file")
 		return
 	}
+	defer file.Close()
 
-	info, err := os.Stat(filePath)
+	info, err := file.Stat()
 	if err != nil {
-		if os.IsNotExist(err) {
-			c.String(http.StatusNotFound, "File not found")
-			return
-		}
 		c.String(http.StatusInternalServerError, "Failed to read file")
 		return
 	}
@@ -354,9 +295,7 @@ func DownloadImage(c *gin.Context) {
 		return
 	}
 
-	c.Header("Content-Disposition", "attachment; filename="+strconv.Quote(info.Name()))
-	c.Header("Content-Type", "application/octet-stream")
-	c.File(filePath)
+	serveDownload(c, imageFS(), filename, storage.UploadPath(storage.Join(imagesDirName, filename)), file, info, info.Name())
 }
 
 // 分页信息结构体
@@ -376,11 +315,7 @@ func GetThumbnails(c *gin.Context) {
 	page, _ := strconv.Atoi(pageStr)
 	pageSize, _ := strconv.Atoi(pageSizeStr)
 
-	imagesDir, err := common.ResolvePathWithinBase(common.GetUploadDir(), "images")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid image directory"})
-		return
-	}
+	imagesFS := imageFS()
 
 	db, err := common.GetDB()
 	if err != nil {
@@ -405,30 +340,22 @@ func GetThumbnails(c *gin.Context) {
 
 	for i := range thumbnails {
 		thumbnailPath := thumbnails[i].ThumbFilePath
-		relThumbnailPath, err := filepath.Rel(imagesDir, thumbnailPath)
-		if err != nil {
-			ylog.Warnf("GetThumbnails", "skip thumbnail with invalid path %q: %v", thumbnailPath, err)
-			continue
-		}
-		safeThumbnailPath, err := common.ResolvePathWithinBase(imagesDir, relThumbnailPath)
-		if err != nil {
-			ylog.Warnf("GetThumbnails", "skip thumbnail outside images dir %q: %v", thumbnailPath, err)
+		thumbName, ok := imageStorageName(thumbnailPath)
+		if !ok {
+			ylog.Warnf("GetThumbnails", "skip thumbnail with invalid path %q", thumbnailPath)
 			continue
 		}
 
-		// 检查缩略图文件是否存在
-		_, err = os.Stat(safeThumbnailPath)
-		if os.IsNotExist(err) {
+		// 读取缩略图文件
+		fileBytes, err := readAll(imagesFS, thumbName)
+		if storage.IsNotExist(err) {
 			// 缩略图不存在，跳过当前循环
 			continue
 		}
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read thumbnail file"})
-			return
+		if errors.Is(err, storage.ErrInvalidPath) {
+			ylog.Warnf("GetThumbnails", "skip thumbnail outside images dir %q: %v", thumbnailPath, err)
+			continue
 		}
-
-		// 读取缩略图文件
-		fileBytes, err := os.ReadFile(safeThumbnailPath)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read thumbnail file"})
 			return
@@ -457,3 +384,71 @@ func GetThumbnails(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
+
+// imagesDirName 图片在上传目录下的子目录名
+const imagesDirName = "images"
+
+// imageFS 返回以图片目录为根的存储驱动
+func imageFS() storage.Driver {
+	return storage.Sub(storage.UploadFS(), imagesDirName)
+}
+
+// imageStorageName 将数据库中记录的图片路径转换为图片目录内的文件名
+// v0.8.0 之前记录的是本地绝对路径，之后为 "images/<name>" 形式的相对路径
+func imageStorageName(p string) (string, bool) {
+	if filepath.IsAbs(p) {
+		imagesDir, err := filepath.Abs(filepath.Join(common.GetUploadDir(), imagesDirName))
+		if err != nil {
+			return "", false
+		}
+		rel, err := filepath.Rel(imagesDir, p)
+		if err != nil {
+			return "", false
+		}
+		p = filepath.ToSlash(rel)
+	} else {
+		p = strings.TrimPrefix(p, imagesDirName+"/")
+	}
+	name, err := storage.CleanPath(p)
+	if err != nil || name == "" {
+		return "", false
+	}
+	return name, true
+}
+
+// generateThumbnail 读取原图生成缩略图，编码格式与文件扩展名保持一致
+func generateThumbnail(fsys storage.Driver, srcName, thumbName string) error {
+	format, err := imaging.FormatFromFilename(srcName)
+	if err != nil {
+		return err
+	}
+
+	src, err := fsys.Open(srcName)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	img, err := imaging.Decode(src)
+	if err != nil {
+		return err
+	}
+	img = imaging.Resize(img, common.ThumbWidth, common.ThumbHeight, imaging.Lanczos)
+
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, img, format); err != nil {
+		return err
+	}
+	_, err = fsys.Put(thumbName, &buf, int64(buf.Len()))
+	return err
+}
+
+// readAll 读取存储中的整个文件
+func readAll(fsys storage.Driver, name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
@@ -0,0 +1,347 @@
+package v1
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/midware"
+)
+
+// ========== v0.8.0 新增：OpenID Connect 单点登录 ==========
+//
+//   GET /api/v1/user/oidc/config     是否开启单点登录及登录入口（登录页据此显示按钮）
+//   GET /api/v1/user/oidc/login      跳转到 IdP 授权页（授权码 + PKCE），可选 ?redirect=/path 指定登录后的前端页面
+//   GET /api/v1/user/oidc/callback   IdP 回调：换取并校验 ID Token，映射 / 创建用户后签发 JWT
+// 回调完成后跳转到 post_login_url（或 redirect），令牌放在 URL fragment 中：#token=<jwt>&refreshToken=<...>&currentAuthority=<role>，
+// 失败时为 #error=<原因>。用户按 ExternalID（issuer + sub）识别，用户名取 username_claim，
+// 角色由 groups claim 经 role_mapping 映射（每次登录同步），未匹配时新用户使用 default_role。
+
+// oidcStateTTL 授权请求（state / nonce / PKCE verifier）的有效期
+const oidcStateTTL = 10 * time.Minute
+
+// oidcLoginState 发起授权时保存的状态，回调时按 state 取出（一次性）
+type oidcLoginState struct {
+	Verifier  string
+	Nonce     string
+	Redirect  string
+	ExpiresAt time.Time
+}
+
+var (
+	oidcMu       sync.Mutex
+	oidcProvider *oidc.Provider
+	oidcStates   = make(map[string]oidcLoginState)
+)
+
+// oidcConfig 返回 OAuth2 配置与 ID Token 校验器；首次调用时做 discovery，失败后下次重试
+func oidcConfig(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	if oidcProvider == nil {
+		provider, err := oidc.NewProvider(ctx, common.OIDCIssuer)
+		if err != nil {
+			return nil, nil, fmt.Errorf("oidc discovery failed: %w", err)
+		}
+		oidcProvider = provider
+	}
+	cfg := &oauth2.Config{
+		ClientID:     common.OIDCClientID,
+		ClientSecret: common.OIDCClientSecret,
+		RedirectURL:  common.OIDCRedirectURL,
+		Endpoint:     oidcProvider.Endpoint(),
+		Scopes:       common.OIDCScopes,
+	}
+	verifier := oidcProvider.Verifier(&oidc.Config{ClientID: common.OIDCClientID})
+	return cfg, verifier, nil
+}
+
+// randomToken 生成 n 字节随机数的十六进制串
+func randomToken(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// saveOIDCState 保存授权状态并顺带清理过期条目
+func saveOIDCState(state string, s oidcLoginState) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	now := time.Now()
+	for k, v := range oidcStates {
+		if now.After(v.ExpiresAt) {
+			delete(oidcStates, k)
+		}
+	}
+	oidcStates[state] = s
+}
+
+// takeOIDCState 取出并删除授权状态
+func takeOIDCState(state string) (oidcLoginState, bool) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	s, ok := oidcStates[state]
+	delete(oidcStates, state)
+	if !ok || time.Now().After(s.ExpiresAt) {
+		return oidcLoginState{}, false
+	}
+	return s, true
+}
+
+// safeRedirect 只允许站内相对路径，防止开放重定向
+func safeRedirect(target string) string {
+	if target == "" || !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.Contains(target, "\\") {
+		return common.OIDCPostLoginURL
+	}
+	return target
+}
+
+// redirectWithFragment 跳转到前端页面，参数放在 fragment 中（不会发送到服务端或写入访问日志）
+func redirectWithFragment(c *gin.Context, target string, values url.Values) {
+	if i := strings.Index(target, "#"); i >= 0 {
+		target = target[:i]
+	}
+	c.Redirect(http.StatusFound, target+"#"+values.Encode())
+}
+
+// GetOIDCConfig 单点登录配置
+// GET /api/v1/user/oidc/config
+func GetOIDCConfig(c *gin.Context) {
+	common.CreateResponse(c, common.SuccessCode, gin.H{
+		"enabled":  common.OIDCEnable,
+		"loginUrl": "/api/v1/user/oidc/login",
+	})
+}
+
+// OIDCLogin 跳转到 IdP 授权页
+// GET /api/v1/user/oidc/login?redirect=/
+func OIDCLogin(c *gin.Context) {
+	if !common.OIDCEnable {
+		common.CreateResponse(c, common.ErrorCode, "OIDC login is not enabled")
+		return
+	}
+	cfg, _, err := oidcConfig(c.Request.Context())
+	if err != nil {
+		ylog.Errorf("OIDCLogin", "%v", err)
+		common.CreateResponse(c, common.ErrorCode, "identity provider is unavailable")
+		return
+	}
+
+	state := randomToken(16)
+	s := oidcLoginState{
+		Verifier:  oauth2.GenerateVerifier(),
+		Nonce:     randomToken(16),
+		Redirect:  safeRedirect(c.Query("redirect")),
+		ExpiresAt: time.Now().Add(oidcStateTTL),
+	}
+	saveOIDCState(state, s)
+
+	authURL := cfg.AuthCodeURL(state, oidc.Nonce(s.Nonce), oauth2.S256ChallengeOption(s.Verifier))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback IdP 回调
+// GET /api/v1/user/oidc/callback?code=&state=
+func OIDCCallback(c *gin.Context) {
+	if !common.OIDCEnable {
+		common.CreateResponse(c, common.ErrorCode, "OIDC login is not enabled")
+		return
+	}
+	clientIP := c.ClientIP()
+	fail := func(target, reason string) {
+		midware.RecordLoginFailure(clientIP)
+		redirectWithFragment(c, target, url.Values{"error": {reason}})
+	}
+
+	s, ok := takeOIDCState(c.Query("state"))
+	if !ok {
+		fail(common.OIDCPostLoginURL, "invalid or expired login state")
+		return
+	}
+	if e := c.Query("error"); e != "" {
+		ylog.Warnf("OIDCCallback", "authorization failed: %s %s", e, c.Query("error_description"))
+		fail(s.Redirect, e)
+		return
+	}
+
+	ctx := c.Request.Context()
+	cfg, verifier, err := oidcConfig(ctx)
+	if err != nil {
+		ylog.Errorf("OIDCCallback", "%v", err)
+		fail(s.Redirect, "identity provider is unavailable")
+		return
+	}
+	token, err := cfg.Exchange(ctx, c.Query("code"), oauth2.VerifierOption(s.Verifier))
+	if err != nil {
+		ylog.Errorf("OIDCCallback", "exchange code failed: %v", err)
+		fail(s.Redirect, "failed to exchange authorization code")
+		return
+	}
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		fail(s.Redirect, "id_token missing in token response")
+		return
+	}
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		ylog.Errorf("OIDCCallback", "verify id_token failed: %v", err)
+		fail(s.Redirect, "invalid id_token")
+		return
+	}
+	if idToken.Nonce != s.Nonce {
+		fail(s.Redirect, "nonce mismatch")
+		return
+	}
+
+	claims := map[string]interface{}{}
+	if err := idToken.Claims(&claims); err != nil {
+		fail(s.Redirect, "invalid id_token claims")
+		return
+	}
+	// ID Token 中缺少用户名 / 组时从 UserInfo 端点补全
+	if claimString(claims, common.OIDCUsernameClaim) == "" || claims[common.OIDCGroupsClaim] == nil {
+		oidcMu.Lock()
+		provider := oidcProvider
+		oidcMu.Unlock()
+		if info, err := provider.UserInfo(ctx, oauth2.StaticTokenSource(token)); err == nil {
+			extra := map[string]interface{}{}
+			if err := info.Claims(&extra); err == nil {
+				for k, v := range extra {
+					if _, ok := claims[k]; !ok {
+						claims[k] = v
+					}
+				}
+			}
+		}
+	}
+
+	user, err := provisionOIDCUser(idToken.Issuer, idToken.Subject, claims)
+	if err != nil {
+		ylog.Warnf("OIDCCallback", "login rejected for sub %s: %v", idToken.Subject, err)
+		fail(s.Redirect, err.Error())
+		return
+	}
+	tokens, err := midware.IssueSession(user.Username, clientIP, c.Request.UserAgent())
+	if err != nil {
+		ylog.Errorf("OIDCCallback", "create session for %s failed: %v", user.Username, err)
+		fail(s.Redirect, "failed to issue token")
+		return
+	}
+	midware.RecordLoginSuccess(clientIP)
+	// 供操作日志中间件记录登录用户
+	c.Set("user", user.Username)
+	ylog.Infof("OIDCCallback", "user %s signed in via OIDC (role %s)", user.Username, user.Role)
+	redirectWithFragment(c, s.Redirect, url.Values{
+		"token":            {tokens.AccessToken},
+		"refreshToken":     {tokens.RefreshToken},
+		"currentAuthority": {user.Role},
+	})
+}
+
+// claimString 读取字符串 claim
+func claimString(claims map[string]interface{}, name string) string {
+	v, _ := claims[name].(string)
+	return strings.TrimSpace(v)
+}
+
+// claimStrings 读取字符串数组 claim（也接受单个字符串）
+func claimStrings(claims map[string]interface{}, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// provisionOIDCUser 按 issuer + sub 查找单点登录用户，不存在时自动创建；每次登录同步邮箱与角色
+func provisionOIDCUser(issuer, subject string, claims map[string]interface{}) (*common.User, error) {
+	db, err := common.GetDB()
+	if err != nil {
+		return nil, errors.New("database unavailable")
+	}
+	externalID := issuer + "|" + subject
+	email := claimString(claims, common.OIDCEmailClaim)
+	role := common.MapGroupsToRole(common.OIDCRoleMappings, claimStrings(claims, common.OIDCGroupsClaim))
+
+	var user common.User
+	if db.Where("auth_source = ? AND external_id = ?", common.AuthSourceOIDC, externalID).Limit(1).Find(&user).RowsAffected > 0 {
+		if user.Disabled {
+			return nil, errors.New("user is disabled")
+		}
+		updates := map[string]interface{}{}
+		if email != "" && email != user.Email {
+			updates["email"] = email
+		}
+		if role != "" && role != user.Role {
+			updates["role"] = role
+		}
+		if len(updates) > 0 {
+			if err := db.Model(&user).Updates(updates).Error; err != nil {
+				return nil, fmt.Errorf("update user failed: %w", err)
+			}
+			common.ReloadUsers()
+		}
+		return &user, nil
+	}
+
+	if !common.OIDCAutoProvision {
+		return nil, errors.New("user is not registered")
+	}
+	username := claimString(claims, common.OIDCUsernameClaim)
+	if username == "" {
+		username = email
+	}
+	if !common.ValidUsername(username) {
+		return nil, fmt.Errorf("invalid username claim %q", common.OIDCUsernameClaim)
+	}
+	var count int64
+	db.Model(&common.User{}).Where("username = ?", username).Count(&count)
+	if count > 0 {
+		// 不接管同名的本地用户
+		return nil, fmt.Errorf("username %s is already taken", username)
+	}
+	if role == "" {
+		role = common.OIDCDefaultRole
+	}
+	// 单点登录用户不使用本地密码，填入随机哈希
+	hashed, err := common.HashPassword(randomToken(32))
+	if err != nil {
+		return nil, err
+	}
+	user = common.User{
+		Username:           username,
+		Email:              email,
+		Password:           hashed,
+		PasswordUpdateTime: time.Now().Unix(),
+		Role:               role,
+		AuthSource:         common.AuthSourceOIDC,
+		ExternalID:         externalID,
+	}
+	if err := db.Create(&user).Error; err != nil {
+		return nil, fmt.Errorf("create user failed: %w", err)
+	}
+	common.ReloadUsers()
+	ylog.Infof("OIDC", "provisioned user %s (%s) with role %s", username, externalID, role)
+	return &user, nil
+}
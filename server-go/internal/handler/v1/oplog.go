@@ -3,11 +3,13 @@ package v1
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
 	"httpcat/internal/common"
 	"httpcat/internal/common/ylog"
+	"httpcat/internal/midware"
 	"httpcat/internal/models"
 
 	"github.com/gin-gonic/gin"
@@ -147,12 +149,44 @@ var pathActionMap = map[string]string{
 	"/api/v1/imageManage/rename":  "image_rename",
 	"/api/v1/imageManage/clear":   "image_clear",
 	"/api/v1/share":               "share_create",
+	"/api/v1/file/tus":            "tus_upload_create",
+	"/api/v1/file/versions/download": "version_download",
+	"/api/v1/file/presign":           "presign_download",
+	"/api/v1/file/presignUpload":     "presign_upload",
+	"/api/v1/trash/restore":          "trash_restore",
+	"/api/v1/trash/purge":            "trash_purge",
+	"/api/v1/quota":                  "quota_set",
+	"/api/v1/acl":                    "acl_set",
+	"/api/v1/storage/encryption/rotate": "encryption_rotate",
+	"/api/v1/user/create":              "user_create",
+	"/api/v1/user/role":                "user_role",
+	"/api/v1/user/group":               "user_group",
+	"/api/v1/user/disable":             "user_disable",
+	"/api/v1/user/resetPasswd":         "user_reset_password",
+	"/api/v1/user/2fa/reset":           "user_reset_2fa",
+	"/api/v1/user/delete":              "user_delete",
 	"/api/v1/user/login/account":  "login",
+	"/api/v1/user/oidc/callback":  "login_oidc",
+	"/api/v1/user/login/2fa":      "login_2fa",
+	"/api/v1/user/2fa/activate":   "2fa_enable",
+	"/api/v1/user/2fa/disable":    "2fa_disable",
+	"/api/v1/user/sessions/revokeAll": "session_revoke_all",
 	"/api/v1/user/changePasswd":   "change_password",
+	"/api/v1/user/sshKeys":        "ssh_key_add",
+	"/api/v1/user/apiKeys":        "api_key_create",
 	"/api/v1/conf/sysConfig":      "config_update",
 	"/api/v1/conf/restart":        "restart",
 }
 
+// WebDAV 方法到操作类型的映射
+var davActionMap = map[string]string{
+	http.MethodPut:    "dav_upload",
+	http.MethodDelete: "dav_delete",
+	"MKCOL":           "dav_mkdir",
+	"MOVE":            "dav_move",
+	"COPY":            "dav_copy",
+}
+
 // OperationLogger Gin 中间件：自动记录操作日志
 func OperationLogger() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -188,6 +222,10 @@ func OperationLogger() gin.HandlerFunc {
 
 		// 获取请求详情
 		detail := buildDetail(c, action)
+		// v0.8.0: 个人 API Key 发起的操作记录所用的 Key
+		if key := midware.APIKeyFromContext(c); key != nil {
+			detail = fmt.Sprintf("%s [API Key %s]", detail, key.AccessKey)
+		}
 
 		// 异步写入日志
 		logEntry := models.OperationLogModel{
@@ -218,6 +256,10 @@ func resolveAction(path, method string) string {
 			return action
 		case path == "/api/v1/file/preview" && method == "GET":
 			return action
+		case path == "/api/v1/file/versions/download" && method == "GET":
+			return action
+		case path == "/api/v1/user/oidc/callback" && method == "GET":
+			return action
 		case path == "/api/v1/file/previewInfo" && method == "GET":
 			return "" // previewInfo 是辅助接口，不单独记录
 		case method == "GET":
@@ -227,10 +269,39 @@ func resolveAction(path, method string) string {
 		}
 	}
 
+	// v0.8.0: WebDAV 写操作（读操作与 PROPFIND 数量大，不记录）
+	if common.WebDAVEnable && (path == common.WebDAVPrefix || strings.HasPrefix(path, common.WebDAVPrefix+"/")) {
+		return davActionMap[method]
+	}
+
 	// 模糊匹配：DELETE /api/v1/share/:code
 	if strings.HasPrefix(path, "/api/v1/share/") && method == "DELETE" {
 		return "share_delete"
 	}
+	// 模糊匹配：DELETE /api/v1/file/tus/:id（PATCH 数量大，仅在上传完成时单独记录）
+	if strings.HasPrefix(path, "/api/v1/file/tus/") && method == "DELETE" {
+		return "tus_upload_abort"
+	}
+	// 模糊匹配：DELETE /api/v1/user/sessions/:id
+	if strings.HasPrefix(path, "/api/v1/user/sessions/") && method == "DELETE" {
+		return "session_revoke"
+	}
+	// 模糊匹配：DELETE /api/v1/user/apiKeys/:id
+	if strings.HasPrefix(path, "/api/v1/user/apiKeys/") && method == "DELETE" {
+		return "api_key_revoke"
+	}
+	// 模糊匹配：DELETE /api/v1/user/sshKeys/:id
+	if strings.HasPrefix(path, "/api/v1/user/sshKeys/") && method == "DELETE" {
+		return "ssh_key_delete"
+	}
+	// 模糊匹配：DELETE /api/v1/quota/:id
+	if strings.HasPrefix(path, "/api/v1/quota/") && method == "DELETE" {
+		return "quota_delete"
+	}
+	// 模糊匹配：DELETE /api/v1/acl/:id
+	if strings.HasPrefix(path, "/api/v1/acl/") && method == "DELETE" {
+		return "acl_delete"
+	}
 
 	return ""
 }
@@ -257,6 +328,10 @@ func buildDetail(c *gin.Context, action string) string {
 		return fmt.Sprintf("预览文件: %s", filename)
 	case "download_zip":
 		return "打包下载"
+	case "presign_download":
+		return "生成预签名下载链接"
+	case "presign_upload":
+		return "生成预签名上传链接"
 	case "share_create":
 		return "创建分享"
 	case "share_delete":
@@ -264,8 +339,28 @@ func buildDetail(c *gin.Context, action string) string {
 		return fmt.Sprintf("删除分享: %s", code)
 	case "login":
 		return "用户登录"
+	case "login_oidc":
+		return "单点登录"
+	case "login_2fa":
+		return "两步验证登录"
+	case "2fa_enable":
+		return "启用两步验证"
+	case "2fa_disable":
+		return "关闭两步验证"
+	case "session_revoke":
+		return fmt.Sprintf("吊销会话: %s", strings.TrimPrefix(c.Request.URL.Path, "/api/v1/user/sessions/"))
+	case "session_revoke_all":
+		return "吊销全部会话"
 	case "change_password":
 		return "修改密码"
+	case "ssh_key_add":
+		return "添加 SSH 公钥"
+	case "ssh_key_delete":
+		return fmt.Sprintf("删除 SSH 公钥: %s", strings.TrimPrefix(c.Request.URL.Path, "/api/v1/user/sshKeys/"))
+	case "api_key_create":
+		return "创建 API Key"
+	case "api_key_revoke":
+		return fmt.Sprintf("吊销 API Key: %s", strings.TrimPrefix(c.Request.URL.Path, "/api/v1/user/apiKeys/"))
 	case "config_update":
 		return "更新系统配置"
 	case "restart":
@@ -285,11 +380,58 @@ func buildDetail(c *gin.Context, action string) string {
 		return "完成分片上传"
 	case "chunk_upload_abort":
 		return "中止分片上传"
+	case "tus_upload_create":
+		return "创建 tus 上传"
+	case "tus_upload_abort":
+		return fmt.Sprintf("终止 tus 上传: %s", strings.TrimPrefix(c.Request.URL.Path, "/api/v1/file/tus/"))
+	case "trash_restore":
+		return "从回收站恢复"
+	case "trash_purge":
+		return "彻底删除回收站条目"
+	case "quota_set":
+		return "设置存储配额"
+	case "quota_delete":
+		return fmt.Sprintf("删除存储配额: %s", strings.TrimPrefix(c.Request.URL.Path, "/api/v1/quota/"))
+	case "acl_set":
+		return "设置共享目录授权"
+	case "acl_delete":
+		return fmt.Sprintf("删除共享目录授权: %s", strings.TrimPrefix(c.Request.URL.Path, "/api/v1/acl/"))
+	case "encryption_rotate":
+		return "轮换静态加密主密钥"
+	case "user_create":
+		return "创建用户"
+	case "user_role":
+		return "修改用户角色"
+	case "user_group":
+		return "修改用户组"
+	case "user_disable":
+		return "禁用 / 启用用户"
+	case "user_reset_password":
+		return "重置用户密码"
+	case "user_reset_2fa":
+		return "重置用户两步验证"
+	case "user_delete":
+		return fmt.Sprintf("删除用户: %s", c.Query("username"))
+	case "version_download":
+		return fmt.Sprintf("下载历史版本: %s (%s)", c.Query("path"), c.Query("versionId"))
+	case "dav_upload", "dav_delete", "dav_mkdir":
+		return fmt.Sprintf("WebDAV %s: %s", c.Request.Method, davDetailPath(c.Request.URL.Path))
+	case "dav_move", "dav_copy":
+		dst := c.GetHeader("Destination")
+		if u, err := url.Parse(dst); err == nil {
+			dst = u.Path
+		}
+		return fmt.Sprintf("WebDAV %s: %s -> %s", c.Request.Method, davDetailPath(c.Request.URL.Path), davDetailPath(dst))
 	default:
 		return ""
 	}
 }
 
+// davDetailPath 去掉 WebDAV 前缀，得到相对文件路径
+func davDetailPath(p string) string {
+	return "/" + strings.TrimPrefix(strings.TrimPrefix(p, common.WebDAVPrefix), "/")
+}
+
 func saveOperationLog(log models.OperationLogModel) {
 	db, err := common.GetDB()
 	if err != nil {
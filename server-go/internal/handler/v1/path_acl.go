@@ -0,0 +1,254 @@
+package v1
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/midware"
+	"httpcat/internal/models"
+	"httpcat/internal/storage"
+)
+
+// ========== v0.8.0 新增：用户主目录与共享目录授权 ==========
+//
+//   GET    /api/v1/acl/mine    当前用户的目录视图（主目录与可访问的共享目录）
+//   GET    /api/v1/acl/list    授权列表（可选 ?path=&grantee=）
+//   POST   /api/v1/acl         新增或修改授权  body: { "path": "team/docs", "granteeType": "group", "grantee": "dev", "permission": "rw" }
+//   DELETE /api/v1/acl/:id     删除授权
+// 开启 home_dirs 后，文件管理、上传、分享与 MCP list_files 均按调用者的视图解析路径（storage/scope.go）。
+
+// callerScope 当前请求用户的目录视图，不受限时为 nil；v0.8.0: 限制了目录的 API Key 只能访问该目录
+func callerScope(c *gin.Context) *storage.UserScope {
+	username := c.GetString("user")
+	scope := storage.ScopeFor(username)
+	if key := midware.APIKeyFromContext(c); key != nil {
+		return scope.Within(username, key.PathPrefix)
+	}
+	return scope
+}
+
+// callerReadScope 返回调用方读取文件使用的目录视图；调用方不能下载文件（仅凭 UploadToken、角色或 API Key 无读取权限）时返回 false
+func callerReadScope(c *gin.Context) (*storage.UserScope, bool) {
+	username := c.GetString("user")
+	if username == "" {
+		return nil, false
+	}
+	if !strings.HasPrefix(username, "openapi:") {
+		user := common.GetUser(username)
+		if user == nil || user.Disabled || !user.Can(common.PermDownload) {
+			return nil, false
+		}
+		if key := midware.APIKeyFromContext(c); key != nil && !midware.APIKeyHasScope(key, common.APIKeyScopeRead) {
+			return nil, false
+		}
+	}
+	return callerScope(c), true
+}
+
+// respondAccessDenied err 为越权访问时写入响应体并返回 true
+func respondAccessDenied(c *gin.Context, err error) bool {
+	if !errors.Is(err, storage.ErrAccessDenied) {
+		return false
+	}
+	ylog.Warnf("scope", "reject %s %s for user %s: %v", c.Request.Method, c.Request.URL.Path, c.GetString("user"), err)
+	common.CreateResponse(c, common.PermissionDenied, "permission denied")
+	return true
+}
+
+// resolveCallerPath 把请求中的文件路径翻译为真实路径，失败时写入响应体并返回 false
+func resolveCallerPath(c *gin.Context, name string, write bool) (string, bool) {
+	realPath, err := callerScope(c).Resolve(name, write)
+	if err == nil {
+		return realPath, true
+	}
+	if respondAccessDenied(c, err) {
+		return "", false
+	}
+	if storage.IsNotExist(err) {
+		common.CreateResponse(c, common.FileIsNotExists, "file not found")
+	} else {
+		common.BadRequest(c, "invalid path")
+	}
+	return "", false
+}
+
+// resolveCallerDir 把上传目标目录翻译为真实路径，失败时写入响应体并返回 false
+func resolveCallerDir(c *gin.Context, dir string) (string, bool) {
+	realDir, err := callerScope(c).ResolveDir(dir)
+	if err == nil {
+		return realDir, true
+	}
+	if respondAccessDenied(c, err) {
+		return "", false
+	}
+	if storage.IsNotExist(err) {
+		common.CreateResponse(c, common.DirISNotExists, "Directory does not exist")
+	} else {
+		common.BadRequest(c, "invalid dir")
+	}
+	return "", false
+}
+
+// GetMyScope 当前用户的目录视图
+// GET /api/v1/acl/mine
+func GetMyScope(c *gin.Context) {
+	scope := callerScope(c)
+	if scope == nil {
+		common.CreateResponse(c, common.SuccessCode, gin.H{
+			"restricted": false,
+			"home":       "",
+			"shared":     []storage.SharedMount{},
+		})
+		return
+	}
+	if scope.FullTree {
+		// 不受主目录限制、但限制了目录的 API Key
+		common.CreateResponse(c, common.SuccessCode, gin.H{
+			"restricted": false,
+			"home":       "",
+			"shared":     []storage.SharedMount{},
+			"pathPrefix": scope.PathPrefix,
+		})
+		return
+	}
+	mounts := scope.Mounts
+	if mounts == nil {
+		mounts = []storage.SharedMount{}
+	}
+	common.CreateResponse(c, common.SuccessCode, gin.H{
+		"restricted": true,
+		"home":       scope.Home,
+		"sharedDir":  storage.SharedDirName,
+		"shared":     mounts,
+		"pathPrefix": scope.PathPrefix,
+	})
+}
+
+// ListPathACLs 授权列表
+// GET /api/v1/acl/list?path=team/docs&grantee=bob
+func ListPathACLs(c *gin.Context) {
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return
+	}
+	query := db.Model(&models.PathACLModel{})
+	if p := strings.Trim(strings.TrimSpace(c.Query("path")), "/"); p != "" {
+		query = query.Where("path = ?", p)
+	}
+	if grantee := strings.TrimSpace(c.Query("grantee")); grantee != "" {
+		query = query.Where("grantee = ?", grantee)
+	}
+	var grants []models.PathACLModel
+	if err := query.Order("path, grantee_type, grantee").Find(&grants).Error; err != nil {
+		ylog.Errorf("ListPathACLs", "query path acl failed: %v", err)
+		common.CreateResponse(c, common.ErrorCode, "failed to list grants")
+		return
+	}
+	common.CreateResponse(c, common.SuccessCode, gin.H{
+		"list":    grants,
+		"total":   len(grants),
+		"enabled": storage.HomeDirsEnabled(),
+	})
+}
+
+// SetPathACL 新增或修改授权（同一目录 + 授权对象只有一条）
+// POST /api/v1/acl
+func SetPathACL(c *gin.Context) {
+	var req struct {
+		Path        string `json:"path" binding:"required"`
+		GranteeType string `json:"granteeType" binding:"required"`
+		Grantee     string `json:"grantee" binding:"required"`
+		Permission  string `json:"permission"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(c, "invalid request body")
+		return
+	}
+	if req.GranteeType != models.ACLGranteeUser && req.GranteeType != models.ACLGranteeGroup {
+		common.BadRequest(c, "granteeType must be user or group")
+		return
+	}
+	if req.Permission == "" {
+		req.Permission = models.ACLPermRead
+	}
+	if req.Permission != models.ACLPermRead && req.Permission != models.ACLPermReadWrite {
+		common.BadRequest(c, "permission must be read or rw")
+		return
+	}
+	grantee := strings.TrimSpace(req.Grantee)
+	if grantee == "" {
+		common.BadRequest(c, "grantee is required")
+		return
+	}
+	p, err := storage.NormalizeACLPath(req.Path)
+	if err != nil {
+		common.BadRequest(c, err.Error())
+		return
+	}
+	info, err := storage.DownloadFS().Stat(p)
+	if err != nil || !info.IsDir() {
+		common.CreateResponse(c, common.DirISNotExists, "Directory does not exist")
+		return
+	}
+
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return
+	}
+	var grant models.PathACLModel
+	found := db.Where("path = ? AND grantee_type = ? AND grantee = ?", p, req.GranteeType, grantee).
+		Limit(1).Find(&grant).RowsAffected > 0
+	grant.Permission = req.Permission
+	grant.UpdatedAt = time.Now()
+	if found {
+		err = db.Save(&grant).Error
+	} else {
+		grant.Path = p
+		grant.GranteeType = req.GranteeType
+		grant.Grantee = grantee
+		grant.CreatedBy = requestOperator(c, "")
+		grant.CreatedAt = grant.UpdatedAt
+		err = db.Create(&grant).Error
+	}
+	if err != nil {
+		ylog.Errorf("SetPathACL", "save grant %s -> %s:%s failed: %v", p, req.GranteeType, grantee, err)
+		common.CreateResponse(c, common.ErrorCode, "failed to save grant")
+		return
+	}
+	ylog.Infof("SetPathACL", "%q granted %s to %s %s", grant.Path, grant.Permission, grant.GranteeType, grant.Grantee)
+	common.CreateResponse(c, common.SuccessCode, grant)
+}
+
+// DeletePathACL 删除授权
+// DELETE /api/v1/acl/:id
+func DeletePathACL(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		common.BadRequest(c, "invalid id")
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return
+	}
+	result := db.Delete(&models.PathACLModel{}, id)
+	if result.Error != nil {
+		ylog.Errorf("DeletePathACL", "delete grant %d failed: %v", id, result.Error)
+		common.CreateResponse(c, common.ErrorCode, "failed to delete grant")
+		return
+	}
+	if result.RowsAffected == 0 {
+		common.CreateResponse(c, common.ErrorCode, "grant not found")
+		return
+	}
+	common.CreateResponse(c, common.SuccessCode, gin.H{"id": id})
+}
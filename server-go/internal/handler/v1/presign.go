@@ -0,0 +1,479 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+	"httpcat/internal/storage"
+)
+
+// ========== v0.8.0 新增：预签名下载 / 上传链接 ==========
+//
+//   POST /api/v1/file/presign            生成下载链接  body: { "path": "reports/q3.pdf", "expiresIn": 3600, "ip": "203.0.113.7", "range": "0-1048575" }
+//   GET  /api/v1/presigned/download      凭链接下载（公开路由，支持 HEAD 与 Range）
+//   POST /api/v1/file/presignUpload      生成上传链接  body: { "path": "inbox/report.pdf", "expiresIn": 600, "maxSize": 10485760, "mimeLimit": "application/pdf" }
+//   PUT  /api/v1/presigned/upload        凭链接上传请求体；POST 为 multipart 表单（字段 f1），同 /api/v1/file/upload
+//   /api/v1/presigned/upload/{init,status,chunk,complete,abort}  凭同一链接的查询串进行分片上传，同 /api/v1/file/upload/*
+// 链接无状态（不同于 t_share），签名内容见 storage/presign.go，因此无法单独吊销；
+// 签发用户被禁用、删除或失去该路径的访问权限后链接立即失效，轮换 server.http.presign.secret 可使全部链接失效。
+// 上传链接固定保存路径，只能上传一次：第一次上传（或分片 init）时登记到 t_presigned_upload，分片的后续请求只能访问该会话。
+
+// defaultPresignExpires 未指定有效期时预签名链接的有效期（秒）
+const defaultPresignExpires = 3600
+
+// CreatePresignedDownload 为调用者可下载的文件生成限时的预签名下载链接
+// POST /api/v1/file/presign
+func CreatePresignedDownload(c *gin.Context) {
+	var req struct {
+		Path      string `json:"path" binding:"required"`
+		ExpiresIn int64  `json:"expiresIn"` // 有效期（秒），0 使用默认值
+		IP        string `json:"ip"`        // 绑定的客户端 IP
+		Range     string `json:"range"`     // 允许下载的字节范围 "start-end" 或 "start-"
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(c, "invalid request body")
+		return
+	}
+	if req.ExpiresIn == 0 {
+		req.ExpiresIn = defaultPresignExpires
+	}
+	if req.ExpiresIn < 0 || req.ExpiresIn > common.PresignMaxExpires {
+		common.BadRequest(c, fmt.Sprintf("expiresIn must be between 1 and %d seconds", common.PresignMaxExpires))
+		return
+	}
+	viewPath, err := storage.CleanPath(strings.Trim(strings.TrimSpace(req.Path), "/"))
+	if err != nil || viewPath == "" {
+		common.BadRequest(c, "invalid path")
+		return
+	}
+	realPath, ok := resolveCallerPath(c, viewPath, false)
+	if !ok {
+		return
+	}
+	info, err := storage.DownloadFS().Stat(realPath)
+	if err != nil || info.IsDir() {
+		common.CreateResponse(c, common.FileIsNotExists, "file not found")
+		return
+	}
+
+	ip := strings.TrimSpace(req.IP)
+	if ip != "" {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			common.BadRequest(c, "invalid ip")
+			return
+		}
+		ip = parsed.String()
+	}
+	byteRange := strings.TrimSpace(req.Range)
+	if byteRange != "" {
+		start, _, err := storage.ParseByteRange(byteRange)
+		if err != nil {
+			common.BadRequest(c, "invalid range, expected start-end or start-")
+			return
+		}
+		if start >= info.Size() {
+			common.BadRequest(c, "range start exceeds file size")
+			return
+		}
+	}
+
+	p := &storage.Presigned{
+		Op:      storage.PresignDownload,
+		Path:    viewPath,
+		User:    c.GetString("user"),
+		Expires: time.Now().Unix() + req.ExpiresIn,
+		IP:      ip,
+		Range:   byteRange,
+	}
+	ylog.Infof("presign", "presigned download %q issued by %s, expires in %ds", viewPath, p.User, req.ExpiresIn)
+	common.CreateResponse(c, common.SuccessCode, gin.H{
+		"url":      "/api/v1/presigned/download?" + p.Query(),
+		"expireAt": p.Expires,
+		"size":     info.Size(),
+	})
+}
+
+// presignIssuerValid 签发用户是否仍然有效且具备权限 perm
+func presignIssuerValid(username string, perm common.Permission) bool {
+	if ak, ok := strings.CutPrefix(username, "openapi:"); ok {
+		return common.OpenAPIEnable && openAPIKeyExists(ak)
+	}
+	user := common.GetUser(username)
+	return user != nil && !user.Disabled && user.Can(perm)
+}
+
+// openAPIKeyExists 全局 AK/SK 中是否存在 ak（配置读取时键名可能被转为小写，按不区分大小写比较）
+func openAPIKeyExists(ak string) bool {
+	if _, ok := common.HttpAkSkMap[ak]; ok {
+		return true
+	}
+	for k := range common.HttpAkSkMap {
+		if strings.EqualFold(k, ak) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyPresigned 校验预签名链接的签名、有效期、绑定 IP 与签发用户，失败时以 403 中止请求
+func verifyPresigned(c *gin.Context, op string, perm common.Permission) (*storage.Presigned, bool) {
+	p, err := storage.ParsePresigned(c.Request.URL.Query(), op)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if p.IP != "" && p.IP != c.ClientIP() {
+		ylog.Warnf("presign", "reject presigned %s %q from %s: bound to %s", op, p.Path, c.ClientIP(), p.IP)
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "presigned url is bound to another client ip"})
+		return nil, false
+	}
+	if !presignIssuerValid(p.User, perm) {
+		ylog.Warnf("presign", "reject presigned %s %q: issuer %s is no longer valid", op, p.Path, p.User)
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "presigned url is no longer valid"})
+		return nil, false
+	}
+	return p, true
+}
+
+// DownloadPresigned 凭预签名链接下载文件
+// GET /api/v1/presigned/download
+func DownloadPresigned(c *gin.Context) {
+	p, ok := verifyPresigned(c, storage.PresignDownload, common.PermDownload)
+	if !ok {
+		return
+	}
+	scope := storage.ScopeFor(p.User)
+
+	fsys := scope.FS(storage.DownloadFS())
+	file, err := fsys.Open(p.Path)
+	if err != nil {
+		if errors.Is(err, storage.ErrAccessDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "presigned url is no longer valid"})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil || fileInfo.IsDir() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+
+	fullRequest := c.Request.Header.Get("Range") == ""
+	if p.Range != "" {
+		start, end, _ := storage.ParseByteRange(p.Range)
+		if !limitPresignedRange(c.Request, start, end, fileInfo.Size()) {
+			c.Header("Content-Range", fmt.Sprintf("bytes */%d", fileInfo.Size()))
+			c.JSON(http.StatusRequestedRangeNotSatisfiable, gin.H{"error": "requested range is outside the presigned range " + p.Range})
+			return
+		}
+	}
+
+	realPath, _ := scope.Resolve(p.Path, false)
+	fileMD5 := serveDownload(c, fsys, p.Path, storage.DownloadPath(realPath), file, fileInfo, fileInfo.Name())
+
+	if fullRequest && c.Request.Method == http.MethodGet && c.Writer.Status() < http.StatusMultipleChoices {
+		createdTime := fileInfo.ModTime().Format("2006-01-02 15:04:05")
+		go recordDownloadLog(fileInfo.Name(), c.ClientIP(), int(fileInfo.Size()), createdTime, createdTime, fileMD5)
+	}
+}
+
+// limitPresignedRange 把请求的 Range 限制在预签名范围 [start, end] 内（end 为 -1 表示到文件末尾）：
+// 未带 Range 时改写为整个允许范围；只接受落在范围内的单段 Range，超出时返回 false
+func limitPresignedRange(r *http.Request, start, end, size int64) bool {
+	if end < 0 || end >= size {
+		end = size - 1
+	}
+	if start > end {
+		return false
+	}
+	// If-Range 不满足时 ServeContent 会忽略 Range 返回整个文件
+	r.Header.Del("If-Range")
+
+	header := r.Header.Get("Range")
+	if header == "" {
+		r.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+		return true
+	}
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return false
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return false
+	}
+	var from, to int64
+	if first == "" {
+		// 后缀形式 bytes=-n：文件最后 n 个字节
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return false
+		}
+		from, to = max(size-n, 0), size-1
+	} else {
+		var err error
+		if from, err = strconv.ParseInt(first, 10, 64); err != nil {
+			return false
+		}
+		to = size - 1
+		if last != "" {
+			if to, err = strconv.ParseInt(last, 10, 64); err != nil {
+				return false
+			}
+			to = min(to, size-1)
+		}
+	}
+	if from < start || to > end || from > to {
+		return false
+	}
+	r.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", from, to))
+	return true
+}
+
+// ---------- 预签名上传链接 ----------
+
+// presignedUploadKey gin.Context 中保存已校验的预签名上传链接的键
+const presignedUploadKey = "presignedUpload"
+
+// CreatePresignedUpload 生成只能使用一次的预签名上传链接，保存路径固定为 path
+// POST /api/v1/file/presignUpload
+func CreatePresignedUpload(c *gin.Context) {
+	var req struct {
+		Path      string `json:"path" binding:"required"` // 保存路径（含文件名）
+		ExpiresIn int64  `json:"expiresIn"`               // 有效期（秒），0 使用默认值
+		MaxSize   int64  `json:"maxSize"`                 // 文件大小上限（字节），0 使用全局配置
+		MimeLimit string `json:"mimeLimit"`               // 允许的内容类型，如 "image/*;application/pdf"
+		Overwrite bool   `json:"overwrite"`               // 是否允许覆盖已存在的文件
+		IP        string `json:"ip"`                      // 绑定的客户端 IP
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(c, "invalid request body")
+		return
+	}
+	if req.ExpiresIn == 0 {
+		req.ExpiresIn = defaultPresignExpires
+	}
+	if req.ExpiresIn < 0 || req.ExpiresIn > common.PresignMaxExpires {
+		common.BadRequest(c, fmt.Sprintf("expiresIn must be between 1 and %d seconds", common.PresignMaxExpires))
+		return
+	}
+	if req.MaxSize < 0 || (common.UploadPolicyFSizeLimit > 0 && req.MaxSize > common.UploadPolicyFSizeLimit) {
+		common.BadRequest(c, fmt.Sprintf("maxSize must not exceed upload policy limit %d bytes", common.UploadPolicyFSizeLimit))
+		return
+	}
+	viewPath, err := storage.CleanPath(strings.Trim(strings.TrimSpace(req.Path), "/"))
+	if err != nil || viewPath == "" {
+		common.BadRequest(c, "invalid path")
+		return
+	}
+	dir, name := path.Split(viewPath)
+	if safeName, err := common.NormalizeSafeFileName(name); err != nil || safeName != name {
+		common.BadRequest(c, "invalid file name")
+		return
+	}
+	// 调用者须能写入目标目录
+	if _, ok := resolveCallerDir(c, strings.TrimSuffix(dir, "/")); !ok {
+		return
+	}
+
+	ip := strings.TrimSpace(req.IP)
+	if ip != "" {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			common.BadRequest(c, "invalid ip")
+			return
+		}
+		ip = parsed.String()
+	}
+	nonce, err := generateUploadID()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "failed to create presigned url")
+		return
+	}
+
+	p := &storage.Presigned{
+		Op:        storage.PresignUpload,
+		Path:      viewPath,
+		User:      c.GetString("user"),
+		Expires:   time.Now().Unix() + req.ExpiresIn,
+		IP:        ip,
+		Nonce:     nonce,
+		MaxSize:   req.MaxSize,
+		MimeLimit: strings.TrimSpace(req.MimeLimit),
+		Overwrite: req.Overwrite,
+	}
+	query := p.Query()
+	ylog.Infof("presign", "presigned upload %q issued by %s, expires in %ds", viewPath, p.User, req.ExpiresIn)
+	common.CreateResponse(c, common.SuccessCode, gin.H{
+		"url":      "/api/v1/presigned/upload?" + query,
+		"query":    query,
+		"path":     viewPath,
+		"expireAt": p.Expires,
+	})
+}
+
+// PresignedUploadAuth 预签名上传路由的认证：校验链接后以签发用户的身份与目录视图处理上传，
+// 上传策略由 verifyUploadTokenIfNeeded 按链接生成
+func PresignedUploadAuth(c *gin.Context) {
+	p, ok := verifyPresigned(c, storage.PresignUpload, common.PermWrite)
+	if !ok {
+		return
+	}
+	if p.Nonce == "" || p.Path == "" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": storage.ErrPresignInvalid.Error()})
+		return
+	}
+	c.Set("user", p.User)
+	c.Set(presignedUploadKey, p)
+	c.Next()
+}
+
+// presignedUpload 当前请求所用的预签名上传链接，非预签名请求返回 nil
+func presignedUpload(c *gin.Context) *storage.Presigned {
+	if v, ok := c.Get(presignedUploadKey); ok {
+		return v.(*storage.Presigned)
+	}
+	return nil
+}
+
+// claimPresignedUpload 登记预签名上传链接已被使用，链接已用过时写入响应体并返回 false
+func claimPresignedUpload(c *gin.Context) bool {
+	p := presignedUpload(c)
+	if p == nil {
+		return true
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return false
+	}
+	claim := models.PresignedUploadModel{
+		Nonce:     p.Nonce,
+		Username:  p.User,
+		Path:      p.Path,
+		IP:        c.ClientIP(),
+		ExpiresAt: time.Unix(p.Expires, 0),
+	}
+	if err := db.Create(&claim).Error; err != nil {
+		var count int64
+		db.Model(&models.PresignedUploadModel{}).Where("nonce = ?", p.Nonce).Count(&count)
+		if count == 0 {
+			ylog.Errorf("presign", "record presigned upload %q failed: %v", p.Path, err)
+			common.CreateResponse(c, common.ErrorCode, "database unavailable")
+			return false
+		}
+		ylog.Warnf("presign", "reject presigned upload %q from %s: url has already been used", p.Path, c.ClientIP())
+		c.JSON(http.StatusForbidden, gin.H{"error": "presigned url has already been used"})
+		return false
+	}
+	return true
+}
+
+// releasePresignedUpload 撤销本次请求对预签名上传链接的登记（写入失败或会话被中止时），链接可再次使用
+func releasePresignedUpload(c *gin.Context) {
+	p := presignedUpload(c)
+	if p == nil {
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		return
+	}
+	if err := db.Where("nonce = ?", p.Nonce).Delete(&models.PresignedUploadModel{}).Error; err != nil {
+		ylog.Errorf("presign", "release presigned upload %q failed: %v", p.Path, err)
+	}
+}
+
+// bindPresignedUpload 把已登记的预签名上传链接绑定到分片上传会话
+func bindPresignedUpload(c *gin.Context, uploadID string) {
+	p := presignedUpload(c)
+	if p == nil {
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		return
+	}
+	if err := db.Model(&models.PresignedUploadModel{}).Where("nonce = ?", p.Nonce).Update("upload_id", uploadID).Error; err != nil {
+		ylog.Errorf("presign", "bind presigned upload %q to session %s failed: %v", p.Path, uploadID, err)
+	}
+}
+
+// checkPresignedSession 预签名上传链接只能访问由它创建的分片上传会话，否则写入响应体并返回 false
+func checkPresignedSession(c *gin.Context, uploadID string) bool {
+	p := presignedUpload(c)
+	if p == nil {
+		return true
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return false
+	}
+	var claim models.PresignedUploadModel
+	if db.Where("nonce = ?", p.Nonce).Limit(1).Find(&claim).RowsAffected == 0 || claim.UploadID != uploadID {
+		// 不暴露其他会话是否存在
+		common.CreateResponse(c, common.ErrorCode, "session not found")
+		return false
+	}
+	return true
+}
+
+// PutPresignedUpload 凭预签名链接以请求体上传文件（须带 Content-Length），multipart 表单上传见 UploadFile
+// PUT /api/v1/presigned/upload
+func PutPresignedUpload(c *gin.Context) {
+	if !common.FileUploadEnable {
+		common.CreateResponse(c, common.ErrorCode, "File service is not enabled")
+		return
+	}
+	p := presignedUpload(c)
+	size := c.Request.ContentLength
+	if size < 0 {
+		c.JSON(http.StatusLengthRequired, gin.H{"error": "Content-Length is required"})
+		return
+	}
+	// 先按大小上限拒绝，避免接收过大的请求体
+	if respondUploadPolicy(c, p.UploadPolicy().CheckSize(size)) {
+		return
+	}
+
+	// 请求体暂存到数据目录，以便识别内容类型并交给 saveUploadedFile
+	tmp, err := os.CreateTemp(common.ChunkTempDir(), "presign-*")
+	if err != nil {
+		ylog.Errorf("presign", "create temp file failed: %v", err)
+		common.CreateResponse(c, common.ErrorCode, "Failed to save file")
+		return
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+	n, err := io.Copy(tmp, io.LimitReader(c.Request.Body, size))
+	if err != nil || n != size {
+		common.BadRequest(c, "request body is shorter than Content-Length")
+		return
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		common.CreateResponse(c, common.ErrorCode, "Failed to save file")
+		return
+	}
+	saveUploadedFile(c, tmp, path.Base(p.Path), size, "")
+}
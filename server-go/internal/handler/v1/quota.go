@@ -0,0 +1,181 @@
+package v1
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+	"httpcat/internal/storage"
+)
+
+// ========== v0.8.0 新增：存储配额 ==========
+//
+//   GET    /api/v1/quota/usage     当前用户的配额与用量、目录配额用量、文件总量（仪表盘）
+//   GET    /api/v1/quota/list      列出配额条目及用量（可选 ?scope=user|dir）
+//   POST   /api/v1/quota           新增或修改配额  body: { "scope": "dir", "target": "team/a", "limitBytes": 1073741824 }
+//   DELETE /api/v1/quota/:id       删除配额
+// 用量来自文件索引（t_file_meta），不遍历目录树。
+
+// checkUploadQuota 上传前校验配额（name 为相对于上传目录的目标路径），超出时写入响应体并返回 false
+func checkUploadQuota(c *gin.Context, name, appkey string, size int64) bool {
+	err := storage.CheckQuota(name, requestOperator(c, appkey), size)
+	if err == nil {
+		return true
+	}
+	var quotaErr *storage.QuotaError
+	switch {
+	case errors.As(err, &quotaErr):
+		ylog.Warnf("quota", "reject upload %s: %v", name, err)
+		common.CreateResponse(c, common.QuotaExceeded, gin.H{
+			"scope":    quotaErr.Scope,
+			"target":   quotaErr.Target,
+			"used":     quotaErr.Used,
+			"limit":    quotaErr.Limit,
+			"incoming": quotaErr.Incoming,
+		})
+	case errors.Is(err, storage.ErrInvalidPath):
+		common.BadRequest(c, "invalid filename")
+	default:
+		ylog.Errorf("quota", "check quota for %s failed: %v", name, err)
+		common.CreateResponse(c, common.ErrorCode, "failed to check storage quota")
+	}
+	return false
+}
+
+// checkQuotaEnabled 未开启配额时写入响应体并返回 false
+func checkQuotaEnabled(c *gin.Context) bool {
+	if !storage.QuotaEnabled() {
+		common.CreateResponse(c, common.ErrorCode, "Storage quota is not enabled")
+		return false
+	}
+	return true
+}
+
+// GetQuotaUsage 当前用户的配额与用量
+// GET /api/v1/quota/usage
+func GetQuotaUsage(c *gin.Context) {
+	if !storage.FileIndexEnabled() {
+		common.CreateResponse(c, common.ErrorCode, "File index requires sqlite")
+		return
+	}
+	dirs, err := storage.ListQuotaUsage(models.QuotaScopeDir)
+	if err != nil {
+		ylog.Errorf("GetQuotaUsage", "list dir quotas failed: %v", err)
+		common.CreateResponse(c, common.ErrorCode, "failed to query quota usage")
+		return
+	}
+	files, size := storage.IndexTotals()
+	common.CreateResponse(c, common.SuccessCode, gin.H{
+		"enabled":          storage.QuotaEnabled(),
+		"user":             storage.GetUserQuotaUsage(requestOperator(c, "")),
+		"dirs":             dirs,
+		"totalFiles":       files,
+		"totalSize":        size,
+		"defaultUserLimit": common.QuotaDefaultUserLimit,
+	})
+}
+
+// ListQuotas 列出配额条目及其用量
+// GET /api/v1/quota/list?scope=dir
+func ListQuotas(c *gin.Context) {
+	if !checkQuotaEnabled(c) {
+		return
+	}
+	scope := c.Query("scope")
+	if scope != "" && scope != models.QuotaScopeUser && scope != models.QuotaScopeDir {
+		common.BadRequest(c, "invalid scope")
+		return
+	}
+	quotas, err := storage.ListQuotaUsage(scope)
+	if err != nil {
+		ylog.Errorf("ListQuotas", "list quotas failed: %v", err)
+		common.CreateResponse(c, common.ErrorCode, "failed to list quotas")
+		return
+	}
+	common.CreateResponse(c, common.SuccessCode, gin.H{
+		"list":  quotas,
+		"total": len(quotas),
+	})
+}
+
+// SetQuota 新增或修改配额（同一 scope + target 只有一条）
+// POST /api/v1/quota
+func SetQuota(c *gin.Context) {
+	if !checkQuotaEnabled(c) {
+		return
+	}
+	var req struct {
+		Scope      string `json:"scope" binding:"required"`
+		Target     string `json:"target"`
+		LimitBytes int64  `json:"limitBytes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.LimitBytes < 0 {
+		common.BadRequest(c, "invalid request body")
+		return
+	}
+	target, err := storage.NormalizeQuotaTarget(req.Scope, req.Target)
+	if err != nil {
+		common.BadRequest(c, err.Error())
+		return
+	}
+
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return
+	}
+	var quota models.QuotaModel
+	found := db.Where("scope = ? AND target = ?", req.Scope, target).Limit(1).Find(&quota).RowsAffected > 0
+	quota.LimitBytes = req.LimitBytes
+	quota.UpdatedAt = time.Now()
+	if found {
+		err = db.Save(&quota).Error
+	} else {
+		quota.Scope = req.Scope
+		quota.Target = target
+		quota.CreatedBy = requestOperator(c, "")
+		quota.CreatedAt = quota.UpdatedAt
+		err = db.Create(&quota).Error
+	}
+	if err != nil {
+		ylog.Errorf("SetQuota", "save quota %s:%s failed: %v", req.Scope, target, err)
+		common.CreateResponse(c, common.ErrorCode, "failed to save quota")
+		return
+	}
+	ylog.Infof("SetQuota", "%s quota %q set to %d bytes", quota.Scope, quota.Target, quota.LimitBytes)
+	common.CreateResponse(c, common.SuccessCode, quota)
+}
+
+// DeleteQuota 删除配额（用户配额删除后回落到默认配额）
+// DELETE /api/v1/quota/:id
+func DeleteQuota(c *gin.Context) {
+	if !checkQuotaEnabled(c) {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		common.BadRequest(c, "invalid id")
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return
+	}
+	result := db.Delete(&models.QuotaModel{}, id)
+	if result.Error != nil {
+		ylog.Errorf("DeleteQuota", "delete quota %d failed: %v", id, result.Error)
+		common.CreateResponse(c, common.ErrorCode, "failed to delete quota")
+		return
+	}
+	if result.RowsAffected == 0 {
+		common.CreateResponse(c, common.ErrorCode, "quota not found")
+		return
+	}
+	common.CreateResponse(c, common.SuccessCode, gin.H{"id": id})
+}
@@ -0,0 +1,154 @@
+package v1
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/midware"
+	"httpcat/internal/models"
+)
+
+// ========== v0.8.0 新增：登录会话管理 ==========
+//
+//   POST   /api/v1/user/login/refresh       凭 refresh token 续期  body: { "refreshToken": "..." }
+//   GET    /api/v1/user/sessions            当前用户的活动会话；管理员可用 ?username= 查看其他用户
+//   DELETE /api/v1/user/sessions/:id        吊销会话（自己的会话，管理员可吊销任意会话）
+//   POST   /api/v1/user/sessions/revokeAll  吊销除当前会话外的全部会话；管理员可指定 { "username": "bob" } 吊销该用户全部会话
+// 登出（/login/outLogin）吊销当前会话；修改密码吊销其他会话；重置密码、禁用与删除用户吊销该用户全部会话。
+
+// SessionVO 会话列表项
+type SessionVO struct {
+	models.SessionModel
+	Current bool `json:"current"` // 是否为发起请求的会话
+}
+
+// RefreshToken 凭 refresh token 续期，返回新的 access token 与 refresh token（旧 refresh token 作废）
+// POST /api/v1/user/login/refresh
+func RefreshToken(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refreshToken" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(c, "invalid request body")
+		return
+	}
+	tokens, username, err := midware.RefreshSession(req.RefreshToken, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		if err != midware.ErrSessionRevoked {
+			ylog.Errorf("RefreshToken", "refresh session failed: %v", err)
+		}
+		midware.RecordLoginFailure(c.ClientIP())
+		common.Unauthorized(c, "session expired, please sign in again")
+		return
+	}
+	user := common.GetUser(username)
+	role := ""
+	if user != nil {
+		role = user.Role
+	}
+	common.CreateResponse(c, common.SuccessCode, gin.H{
+		"token":            tokens.AccessToken,
+		"refreshToken":     tokens.RefreshToken,
+		"expiresIn":        tokens.ExpiresIn,
+		"currentAuthority": role,
+	})
+}
+
+// isAdminCaller 发起请求的用户是否为管理员（含 Open API）
+func isAdminCaller(c *gin.Context) bool {
+	username := c.GetString("user")
+	return strings.HasPrefix(username, "openapi:") || common.UserCan(username, common.PermAdmin)
+}
+
+// ListSessions 活动会话列表（未过期、未吊销），按最近活动时间倒序
+// GET /api/v1/user/sessions?username=
+func ListSessions(c *gin.Context) {
+	username := c.GetString("user")
+	if target := c.Query("username"); target != "" && target != username {
+		if !isAdminCaller(c) {
+			common.CreateResponse(c, common.PermissionDenied, "permission denied")
+			return
+		}
+		username = target
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return
+	}
+	var sessions []models.SessionModel
+	if err := db.Where("username = ? AND revoked_at IS NULL AND expires_at > ?", username, time.Now()).
+		Order("last_active_at DESC").Find(&sessions).Error; err != nil {
+		ylog.Errorf("ListSessions", "query sessions of %s failed: %v", username, err)
+		common.CreateResponse(c, common.ErrorCode, "failed to list sessions")
+		return
+	}
+	current := c.GetString("session")
+	list := make([]SessionVO, 0, len(sessions))
+	for _, s := range sessions {
+		list = append(list, SessionVO{SessionModel: s, Current: s.SessionID == current})
+	}
+	common.CreateResponse(c, common.SuccessCode, gin.H{
+		"list":  list,
+		"total": len(list),
+	})
+}
+
+// RevokeUserSession 吊销指定会话
+// DELETE /api/v1/user/sessions/:id
+func RevokeUserSession(c *gin.Context) {
+	sessionID := c.Param("id")
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return
+	}
+	var session models.SessionModel
+	if db.Where("session_id = ?", sessionID).Limit(1).Find(&session).RowsAffected == 0 {
+		common.CreateResponse(c, common.ErrorCode, "session not found")
+		return
+	}
+	if session.Username != c.GetString("user") && !isAdminCaller(c) {
+		// 不暴露其他用户会话是否存在
+		common.CreateResponse(c, common.ErrorCode, "session not found")
+		return
+	}
+	if err := midware.RevokeSession(sessionID); err != nil {
+		ylog.Errorf("RevokeUserSession", "revoke session %s failed: %v", sessionID, err)
+		common.CreateResponse(c, common.ErrorCode, "failed to revoke session")
+		return
+	}
+	ylog.Infof("RevokeUserSession", "session %s of %s revoked by %s", sessionID, session.Username, c.GetString("user"))
+	common.CreateResponse(c, common.SuccessCode, gin.H{"id": sessionID})
+}
+
+// RevokeAllSessions 吊销当前用户的其他会话，或由管理员吊销指定用户的全部会话
+// POST /api/v1/user/sessions/revokeAll
+func RevokeAllSessions(c *gin.Context) {
+	var req struct {
+		Username string `json:"username"`
+	}
+	_ = c.ShouldBindJSON(&req)
+
+	username := c.GetString("user")
+	except := c.GetString("session")
+	if req.Username != "" && req.Username != username {
+		if !isAdminCaller(c) {
+			common.CreateResponse(c, common.PermissionDenied, "permission denied")
+			return
+		}
+		username = req.Username
+		except = ""
+	}
+	count, err := midware.RevokeUserSessions(username, except)
+	if err != nil {
+		ylog.Errorf("RevokeAllSessions", "revoke sessions of %s failed: %v", username, err)
+		common.CreateResponse(c, common.ErrorCode, "failed to revoke sessions")
+		return
+	}
+	common.CreateResponse(c, common.SuccessCode, gin.H{"username": username, "revoked": count})
+}
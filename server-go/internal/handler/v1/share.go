@@ -2,11 +2,10 @@ package v1
 
 import (
 	"crypto/rand"
+	"errors"
 	"fmt"
-	"io"
 	"math/big"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -14,6 +13,7 @@ import (
 	"httpcat/internal/common"
 	"httpcat/internal/common/ylog"
 	"httpcat/internal/models"
+	"httpcat/internal/storage"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
@@ -129,25 +129,28 @@ func CreateShare(c *gin.Context) {
 		fileType = "file"
 	}
 
-	var baseDir string
-	if fileType == "image" {
-		imgDir, err := common.ResolvePathWithinBase(common.GetUploadDir(), "images")
-		if err != nil {
-			common.BadRequest(c, "无效的图片目录")
+	// v0.8.0：按用户主目录 / 共享目录授权翻译为真实路径，分享记录保存真实路径
+	filePath := req.FilePath
+	if fileType != "image" {
+		var err error
+		if filePath, err = callerScope(c).Resolve(filePath, false); err != nil {
+			if respondAccessDenied(c, err) {
+				return
+			}
+			if errors.Is(err, storage.ErrInvalidPath) {
+				common.BadRequest(c, "无效的文件路径")
+			} else {
+				common.BadRequest(c, "文件不存在")
+			}
 			return
 		}
-		baseDir = imgDir
-	} else {
-		baseDir = common.GetDownloadDir()
 	}
 
-	filePath, err := common.ResolvePathWithinBase(baseDir, req.FilePath)
-	if err != nil {
+	info, err := shareFS(fileType).Stat(filePath)
+	if errors.Is(err, storage.ErrInvalidPath) {
 		common.BadRequest(c, "无效的文件路径")
 		return
 	}
-
-	info, err := os.Stat(filePath)
 	if err != nil || info.IsDir() {
 		common.BadRequest(c, "文件不存在")
 		return
@@ -185,7 +188,7 @@ func CreateShare(c *gin.Context) {
 
 	share := models.ShareModel{
 		ShareCode:    shareCode,
-		FilePath:     req.FilePath,
+		FilePath:     filePath,
 		FileName:     req.FileName,
 		FileType:     fileType,
 		CreatedBy:    username.(string),
@@ -358,6 +361,7 @@ func VerifyShareCode(c *gin.Context) {
 }
 
 // DownloadShareFile 下载分享文件 GET /s/:code/download
+// v0.8.0：支持 Range 断点续传与 ETag 条件请求；限制了下载次数的分享每次请求都计为一次完整下载，不支持二者
 func DownloadShareFile(c *gin.Context) {
 	if !checkShareAnonymousAccess(c) {
 		return
@@ -391,26 +395,12 @@ func DownloadShareFile(c *gin.Context) {
 	}
 
 	// 解析文件路径
-	var baseDir string
-	if share.FileType == "image" {
-		imgDir, err := common.ResolvePathWithinBase(common.GetUploadDir(), "images")
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "文件目录无效"})
+	file, err := shareFS(share.FileType).Open(share.FilePath)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidPath) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "文件路径无效"})
 			return
 		}
-		baseDir = imgDir
-	} else {
-		baseDir = common.GetDownloadDir()
-	}
-
-	filePath, err := common.ResolvePathWithinBase(baseDir, share.FilePath)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "文件路径无效"})
-		return
-	}
-
-	file, err := os.Open(filePath)
-	if err != nil {
 		ylog.Errorf("DownloadShareFile", "打开文件失败: %v", err)
 		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
 		return
@@ -423,6 +413,13 @@ func DownloadShareFile(c *gin.Context) {
 		return
 	}
 
+	// 限制了下载次数时，部分内容与 304 无法判断是否算作一次下载，一律按完整下载发送并计数
+	if share.MaxDownloads > 0 {
+		for _, h := range []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since"} {
+			c.Request.Header.Del(h)
+		}
+	}
+
 	// 原子更新下载计数（防并发超发）
 	// 使用 SQL 条件确保在有限制时不会超过 max_downloads
 	updateResult := db.Model(&models.ShareModel{}).
@@ -437,22 +434,23 @@ func DownloadShareFile(c *gin.Context) {
 	}
 
 	// 发送文件
-	c.Writer.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(share.FileName))
-	c.Writer.Header().Set("Content-Type", "application/octet-stream")
-	c.Writer.Header().Set("Content-Length", strconv.FormatInt(fileInfo.Size(), 10))
-
-	buf := make([]byte, 4096)
-	for {
-		n, err := file.Read(buf)
-		if n == 0 {
-			break
-		}
-		if err != nil && err != io.EOF {
-			ylog.Errorf("DownloadShareFile", "读取文件失败: %v", err)
-			break
-		}
-		_, _ = c.Writer.Write(buf[:n])
+	serveDownload(c, shareFS(share.FileType), share.FilePath, shareStoragePath(share.FileType, share.FilePath), file, fileInfo, share.FileName)
+}
+
+// shareFS 根据分享的文件类型返回对应的存储驱动（图片分享位于上传目录的 images 下）
+func shareFS(fileType string) storage.Driver {
+	if fileType == "image" {
+		return imageFS()
+	}
+	return storage.DownloadFS()
+}
+
+// shareStoragePath 分享文件相对于存储根目录的路径
+func shareStoragePath(fileType, name string) string {
+	if fileType == "image" {
+		return storage.UploadPath(storage.Join(imagesDirName, name))
 	}
+	return storage.DownloadPath(name)
 }
 
 // ShareStats 分享统计 GET /api/v1/share/stats
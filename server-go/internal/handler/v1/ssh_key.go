@@ -0,0 +1,121 @@
+package v1
+
+import (
+	"strconv"
+	"strings"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/ssh"
+)
+
+// ========== v0.8.0 新增：SSH 公钥管理（SFTP 公钥登录） ==========
+
+type AddSSHKeyRequest struct {
+	Name      string `json:"name"`                         // 备注名（为空时取公钥注释）
+	PublicKey string `json:"publicKey" binding:"required"` // authorized_keys 格式的一行公钥
+}
+
+// sshKeyOwner 返回当前登录用户；AK/SK 等非用户身份不能管理公钥
+func sshKeyOwner(c *gin.Context) (string, bool) {
+	user, _ := c.Get("user")
+	username, _ := user.(string)
+	if username == "" || common.GetUser(username) == nil {
+		common.BadRequest(c, "仅登录用户可以管理 SSH 公钥")
+		return "", false
+	}
+	return username, true
+}
+
+// ListSSHKeys 列出当前用户的 SSH 公钥 GET /api/v1/user/sshKeys
+func ListSSHKeys(c *gin.Context) {
+	username, ok := sshKeyOwner(c)
+	if !ok {
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "数据库连接失败")
+		return
+	}
+	var keys []models.SSHKeyModel
+	if err := db.Where("username = ?", username).Order("id DESC").Find(&keys).Error; err != nil {
+		common.CreateResponse(c, common.ErrorCode, "查询失败")
+		return
+	}
+	common.CreateResponse(c, common.SuccessCode, keys)
+}
+
+// AddSSHKey 为当前用户添加 SSH 公钥 POST /api/v1/user/sshKeys
+func AddSSHKey(c *gin.Context) {
+	username, ok := sshKeyOwner(c)
+	if !ok {
+		return
+	}
+	var req AddSSHKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	pub, comment, _, rest, err := ssh.ParseAuthorizedKey([]byte(strings.TrimSpace(req.PublicKey)))
+	if err != nil || len(strings.TrimSpace(string(rest))) > 0 {
+		common.BadRequest(c, "公钥格式错误，请粘贴 authorized_keys 格式的单行公钥")
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = comment
+	}
+
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "数据库连接失败")
+		return
+	}
+	key := models.SSHKeyModel{
+		Username:    username,
+		Name:        name,
+		Fingerprint: ssh.FingerprintSHA256(pub),
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub))),
+	}
+	var count int64
+	db.Model(&models.SSHKeyModel{}).Where("username = ? AND fingerprint = ?", username, key.Fingerprint).Count(&count)
+	if count > 0 {
+		common.BadRequest(c, "该公钥已存在")
+		return
+	}
+	if err := db.Create(&key).Error; err != nil {
+		ylog.Errorf("AddSSHKey", "create ssh key failed: %v", err)
+		common.CreateResponse(c, common.ErrorCode, "添加公钥失败")
+		return
+	}
+	common.CreateResponse(c, common.SuccessCode, key)
+}
+
+// DeleteSSHKey 删除当前用户的 SSH 公钥 DELETE /api/v1/user/sshKeys/:id
+func DeleteSSHKey(c *gin.Context) {
+	username, ok := sshKeyOwner(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		common.BadRequest(c, "参数错误")
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "数据库连接失败")
+		return
+	}
+	result := db.Where("id = ? AND username = ?", id, username).Delete(&models.SSHKeyModel{})
+	if result.RowsAffected == 0 {
+		common.BadRequest(c, "公钥不存在或无权操作")
+		return
+	}
+	common.CreateResponse(c, common.SuccessCode, "公钥已删除")
+}
@@ -2,13 +2,13 @@ package v1
 
 import (
 	"fmt"
+	"io/fs"
 	"math"
 	"net/http"
-	"os"
-	"path/filepath"
 	"time"
 
 	"httpcat/internal/common"
+	"httpcat/internal/storage"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -249,17 +249,12 @@ func GetDownloadHistoryLogs(c *gin.Context) {
 
 // GetFileOverview 获取文件总览统计：文件总数、目录数、总大小
 func GetFileOverview(c *gin.Context) {
-	baseDir := common.GetDownloadDir()
-
 	var totalFiles int64
 	var totalDirs int64
 	var totalSize int64
 
-	// 递归统计
-	filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // 跳过无法访问的文件
-		}
+	// 递归统计（遇到无法访问的目录时停止，已统计的部分照常返回）
+	_ = storage.Walk(storage.DownloadFS(), "", func(name string, info fs.FileInfo) error {
 		if info.IsDir() {
 			totalDirs++
 		} else {
@@ -274,12 +269,18 @@ func GetFileOverview(c *gin.Context) {
 		totalDirs--
 	}
 
-	common.CreateResponse(c, common.SuccessCode, gin.H{
+	overview := gin.H{
 		"totalFiles": totalFiles,
 		"totalDirs":  totalDirs,
 		"totalSize":  totalSize,
 		"totalSizeFormatted": formatBytes(totalSize),
-	})
+	}
+	// v0.8.0：开启去重存储时附带去重统计（physicalSize 为 blob 实际占用）
+	if stats := storage.GetDedupStats(); stats != nil {
+		overview["dedup"] = stats
+		overview["dedupSavedSize"] = stats.LogicalSize - stats.PhysicalSize
+	}
+	common.CreateResponse(c, common.SuccessCode, overview)
 }
 
 func formatBytes(bytes int64) string {
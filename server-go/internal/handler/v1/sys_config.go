@@ -350,8 +350,12 @@ func RestartServer(c *gin.Context) {
 		return
 	}
 
-	// 验证管理员密码
-	_, err := midware.CheckUser("admin", req.Password)
+	// 验证管理员密码（当前登录的管理员；Open API 调用时为内置 admin）
+	operator := c.GetString("user")
+	if common.GetUser(operator) == nil {
+		operator = common.DefaultAdminUsername
+	}
+	_, err := midware.CheckUser(operator, req.Password)
 	if err != nil {
 		c.JSON(http.StatusForbidden, gin.H{
 			"errorCode": common.AuthFailedErrorCode,
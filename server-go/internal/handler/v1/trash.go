@@ -0,0 +1,200 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+	"httpcat/internal/storage"
+)
+
+// ========== v0.8.0 新增：回收站 ==========
+//
+//   GET  /api/v1/trash/list       分页列出回收站条目（keyword 按原路径模糊匹配）
+//   POST /api/v1/trash/restore    恢复到原路径  body: { "ids": [1, 2] }
+//   POST /api/v1/trash/purge      彻底删除      body: { "ids": [1, 2] } 或 { "all": true }
+
+// checkTrash 未开启回收站时写入响应体并返回 false
+func checkTrash(c *gin.Context) bool {
+	if !storage.TrashEnabled() {
+		common.CreateResponse(c, common.ErrorCode, "Recycle bin is not enabled")
+		return false
+	}
+	return true
+}
+
+// scopeTrashQuery 按调用者视图过滤回收站条目：受主目录限制的用户只能看到自己删除的条目，
+// 限制了目录的 API Key 只能看到原路径在该目录下的条目
+func scopeTrashQuery(query *gorm.DB, scope *storage.UserScope) *gorm.DB {
+	if scope == nil {
+		return query
+	}
+	if !scope.FullTree {
+		query = query.Where("deleted_by = ?", scope.User)
+	}
+	if scope.PathPrefix != "" {
+		root, err := scope.PrefixRoot()
+		if err != nil {
+			return query.Where("1 = 0")
+		}
+		query = query.Where("(original_path = ? OR substr(original_path, 1, ?) = ?)", root, len(root)+1, root+"/")
+	}
+	return query
+}
+
+// trashItemVisible 回收站条目是否在调用者视图内，规则同 scopeTrashQuery
+func trashItemVisible(scope *storage.UserScope, item *models.TrashModel) bool {
+	if scope == nil {
+		return true
+	}
+	if !scope.FullTree && item.DeletedBy != scope.User {
+		return false
+	}
+	return scope.ContainsReal(item.OriginalPath)
+}
+
+// ListTrash 分页列出回收站条目（最近删除的在前）
+// GET /api/v1/trash/list?current=1&pageSize=20&keyword=
+func ListTrash(c *gin.Context) {
+	if !checkTrash(c) {
+		return
+	}
+	var params struct {
+		Current  int    `form:"current" binding:"required"`
+		PageSize int    `form:"pageSize" binding:"required"`
+		Keyword  string `form:"keyword"`
+	}
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.CreateResponse(c, common.ParamInvalidErrorCode, err.Error())
+		return
+	}
+
+	db, err := common.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	query := db.Model(&models.TrashModel{})
+	// v0.8.0：受主目录限制的用户只能看到自己删除的条目
+	query = scopeTrashQuery(query, callerScope(c))
+	if params.Keyword != "" {
+		query = query.Where("original_path LIKE ?", "%"+params.Keyword+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var items []models.TrashModel
+	offset := (params.Current - 1) * params.PageSize
+	if err := query.Order("deleted_at DESC").Offset(offset).Limit(params.PageSize).Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	common.CreateResponse(c, common.SuccessCode, gin.H{
+		"list":          items,
+		"current":       params.Current,
+		"pageSize":      params.PageSize,
+		"total":         total,
+		"retentionDays": common.TrashRetentionDays,
+	})
+}
+
+// RestoreTrash 将回收站条目恢复到原路径
+// POST /api/v1/trash/restore
+func RestoreTrash(c *gin.Context) {
+	if !checkTrash(c) {
+		return
+	}
+	var req struct {
+		IDs []uint `json:"ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.IDs) == 0 {
+		common.BadRequest(c, "invalid request body")
+		return
+	}
+
+	scope := callerScope(c)
+	restored := []string{}
+	var failed []map[string]interface{}
+	for _, id := range req.IDs {
+		item, err := storage.GetTrashItem(id)
+		if err == nil && !trashItemVisible(scope, item) {
+			err = storage.ErrTrashNotFound
+		}
+		if err == nil {
+			err = storage.RestoreFromTrash(item)
+		}
+		if err != nil {
+			msg := err.Error()
+			if !errors.Is(err, storage.ErrTrashNotFound) && !errors.Is(err, storage.ErrTrashTargetExists) {
+				ylog.Errorf("RestoreTrash", "restore trash item %d failed: %v", id, err)
+				msg = "failed to restore"
+			}
+			failed = append(failed, map[string]interface{}{"id": id, "error": msg})
+			continue
+		}
+		restored = append(restored, item.OriginalPath)
+		ylog.Infof("RestoreTrash", "restored: %s", item.OriginalPath)
+	}
+
+	common.CreateResponse(c, common.SuccessCode, gin.H{
+		"restored": restored,
+		"failed":   failed,
+	})
+}
+
+// PurgeTrash 彻底删除回收站条目
+// POST /api/v1/trash/purge
+func PurgeTrash(c *gin.Context) {
+	if !checkTrash(c) {
+		return
+	}
+	var req struct {
+		IDs []uint `json:"ids"`
+		All bool   `json:"all"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || (len(req.IDs) == 0 && !req.All) {
+		common.BadRequest(c, "invalid request body")
+		return
+	}
+
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return
+	}
+	var items []models.TrashModel
+	query := db.Model(&models.TrashModel{})
+	query = scopeTrashQuery(query, callerScope(c))
+	if !req.All {
+		query = query.Where("id IN ?", req.IDs)
+	}
+	if err := query.Find(&items).Error; err != nil {
+		common.CreateResponse(c, common.ErrorCode, "failed to query recycle bin")
+		return
+	}
+
+	purged := []string{}
+	var failed []map[string]interface{}
+	for i := range items {
+		if err := storage.PurgeTrash(&items[i]); err != nil {
+			ylog.Errorf("PurgeTrash", "purge trash item %d failed: %v", items[i].ID, err)
+			failed = append(failed, map[string]interface{}{"id": items[i].ID, "error": "failed to purge"})
+			continue
+		}
+		purged = append(purged, items[i].OriginalPath)
+	}
+
+	common.CreateResponse(c, common.SuccessCode, gin.H{
+		"purged": purged,
+		"failed": failed,
+	})
+}
@@ -0,0 +1,674 @@
+package v1
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/utils"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+	"httpcat/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ========== v0.8.0 新增：tus 1.0 断点续传协议 ==========
+//
+// 分片上传 API 是自有协议，只有 Web 界面能用；这里按 tus 1.0.0（core + creation / termination /
+// checksum / expiration 扩展）提供同等能力，Uppy、tus-js-client、tus-go-client 等现成客户端可直接续传：
+//   POST   /api/v1/file/tus       创建上传（Upload-Length，Upload-Metadata: filename、dir、overwrite）
+//   HEAD   /api/v1/file/tus/:id   查询已接收的字节数（Upload-Offset）
+//   PATCH  /api/v1/file/tus/:id   从 Upload-Offset 处追加数据，可带 Upload-Checksum
+//   DELETE /api/v1/file/tus/:id   终止上传
+//
+// 会话记录在 t_upload_session（source=tus），数据顺序追加到 ChunkSessionDir/<id>/data，
+// 该文件的大小即已接收字节数；全部接收后合并写入存储驱动。过期会话由分片上传的清理任务统一回收。
+// 鉴权与分片上传一致：TokenOrAKSKAuth，启用 UploadToken 时 POST / PATCH 需携带 UploadToken 请求头。
+
+const (
+	tusVersion            = "1.0.0"
+	tusExtensions         = "creation,termination,checksum,expiration"
+	tusChecksumAlgorithms = "md5,sha1,sha256"
+	tusBasePath           = "/api/v1/file/tus"
+	tusSource             = "tus"
+	tusDataFile           = "data"
+	// statusChecksumMismatch tus checksum 扩展定义的状态码
+	statusChecksumMismatch = 460
+)
+
+// tusMaxSize 单个上传允许的最大字节数
+func tusMaxSize() int64 {
+	if common.UploadPolicyFSizeLimit > 0 && common.UploadPolicyFSizeLimit < MaxFileSize {
+		return common.UploadPolicyFSizeLimit
+	}
+	return MaxFileSize
+}
+
+// tusAbort 以纯文本返回错误（tus 客户端只依据状态码处理）
+func tusAbort(c *gin.Context, status int, msg string) {
+	c.String(status, msg)
+	c.Abort()
+}
+
+// tusCheckVersion 校验 Tus-Resumable 请求头
+func tusCheckVersion(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		tusAbort(c, http.StatusPreconditionFailed, "unsupported tus version")
+		return false
+	}
+	return true
+}
+
+// tusCheckUpload 写入类请求的公共校验：协议版本、上传开关、UploadToken，返回 appkey 与凭证中的上传策略
+func tusCheckUpload(c *gin.Context) (string, *storage.UploadPolicy, bool) {
+	if !tusCheckVersion(c) {
+		return "", nil, false
+	}
+	if !common.FileUploadEnable {
+		tusAbort(c, http.StatusForbidden, "File service is not enabled")
+		return "", nil, false
+	}
+	if !common.EnableUploadToken {
+		return "", nil, true
+	}
+	appkey, policy, err := checkUploadToken(c.GetHeader("UploadToken"))
+	if errors.Is(err, errUploadTokenEmpty) {
+		tusAbort(c, http.StatusBadRequest, err.Error())
+		return "", nil, false
+	}
+	if err != nil {
+		tusAbort(c, http.StatusUnauthorized, err.Error())
+		return "", nil, false
+	}
+	return appkey, policy, true
+}
+
+// tusAbortFinalize 合并失败时按错误类型返回（违反上传策略为 403 / 413）
+func tusAbortFinalize(c *gin.Context, err error) {
+	if status := uploadPolicyStatus(err); status != 0 {
+		tusAbort(c, status, err.Error())
+		return
+	}
+	tusAbort(c, http.StatusInternalServerError, "failed to finalize upload")
+}
+
+// parseTusMetadata 解析 Upload-Metadata："key base64(value),key2 base64(value2),flag"
+func parseTusMetadata(header string) (map[string]string, error) {
+	meta := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Upload-Metadata value for key %q", key)
+		}
+		meta[key] = string(value)
+	}
+	return meta, nil
+}
+
+// parseTusChecksum 解析 Upload-Checksum："<算法> base64(摘要)"
+func parseTusChecksum(header string) (hash.Hash, []byte, error) {
+	algo, encoded, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok {
+		return nil, nil, errors.New("invalid Upload-Checksum")
+	}
+	sum, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, nil, errors.New("invalid Upload-Checksum")
+	}
+	switch strings.ToLower(algo) {
+	case "md5":
+		return md5.New(), sum, nil
+	case "sha1":
+		return sha1.New(), sum, nil
+	case "sha256":
+		return sha256.New(), sum, nil
+	default:
+		return nil, nil, errors.New("unsupported checksum algorithm: " + algo)
+	}
+}
+
+func tusDataPath(uploadID string) string {
+	return filepath.Join(common.ChunkSessionDir(uploadID), tusDataFile)
+}
+
+// tusOffset 已接收的字节数
+func tusOffset(session *models.UploadSessionModel) int64 {
+	if session.Status == "completed" {
+		return session.FileSize
+	}
+	info, err := os.Stat(tusDataPath(session.UploadID))
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// tusOwnsSession 判断调用方是否为会话的创建者：登录用户创建的会话须为同一用户，
+// 仅凭 UploadToken 创建的会话须携带同一 appkey 的有效 UploadToken
+func tusOwnsSession(c *gin.Context, session *models.UploadSessionModel) bool {
+	if session.Username != "" {
+		return c.GetString("user") == session.Username
+	}
+	if session.Appkey != "" {
+		appkey, _, err := checkUploadToken(c.GetHeader("UploadToken"))
+		return err == nil && appkey == session.Appkey
+	}
+	return true
+}
+
+// tusLoadSession 读取 tus 会话；不存在或不属于调用方返回 404，已终止或已过期返回 410
+func tusLoadSession(c *gin.Context) (*models.UploadSessionModel, bool) {
+	db, err := common.GetDB()
+	if err != nil {
+		tusAbort(c, http.StatusInternalServerError, "database unavailable")
+		return nil, false
+	}
+	var session models.UploadSessionModel
+	if err := db.Where("upload_id = ?", c.Param("id")).First(&session).Error; err != nil || session.Source != tusSource ||
+		!tusOwnsSession(c, &session) {
+		tusAbort(c, http.StatusNotFound, "upload not found")
+		return nil, false
+	}
+	if session.Status == "aborted" || (session.Status == "active" && time.Now().After(session.ExpireAt)) {
+		tusAbort(c, http.StatusGone, "upload terminated or expired")
+		return nil, false
+	}
+	return &session, true
+}
+
+func tusSetUploadHeaders(c *gin.Context, session *models.UploadSessionModel, offset int64) {
+	c.Header("Upload-Offset", strconv.FormatInt(offset, 10))
+	if session.Status == "active" {
+		c.Header("Upload-Expires", session.ExpireAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// TusDiscoveryHeaders 写入服务端支持的 tus 版本与扩展（OPTIONS 请求由 Cors 中间件统一应答）
+func TusDiscoveryHeaders(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Checksum-Algorithm", tusChecksumAlgorithms)
+	c.Header("Tus-Max-Size", strconv.FormatInt(tusMaxSize(), 10))
+}
+
+// TusCreate 创建上传
+// POST /api/v1/file/tus
+func TusCreate(c *gin.Context) {
+	appkey, policy, ok := tusCheckUpload(c)
+	if !ok {
+		return
+	}
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		tusAbort(c, http.StatusBadRequest, "invalid Upload-Length")
+		return
+	}
+	if length > tusMaxSize() {
+		tusAbort(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("Upload-Length exceeds the limit of %d bytes", tusMaxSize()))
+		return
+	}
+	if common.UploadPolicyFSizeMin > 0 && length < common.UploadPolicyFSizeMin {
+		tusAbort(c, http.StatusBadRequest, fmt.Sprintf("Upload-Length is smaller than upload policy min %d bytes", common.UploadPolicyFSizeMin))
+		return
+	}
+
+	meta, err := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		tusAbort(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Uppy 使用 name，tus-js-client 示例使用 filename
+	name := meta["filename"]
+	if name == "" {
+		name = meta["name"]
+	}
+	if name == "" {
+		tusAbort(c, http.StatusBadRequest, "filename is required in Upload-Metadata")
+		return
+	}
+	fileName, err := common.NormalizeSafeFileName(name)
+	if err != nil {
+		tusAbort(c, http.StatusBadRequest, "invalid filename")
+		return
+	}
+	cleanDir, err := storage.CleanPath(meta["dir"])
+	if err != nil {
+		tusAbort(c, http.StatusBadRequest, "invalid dir")
+		return
+	}
+	// v0.8.0：按上传策略校验目标位置与 Upload-Length，内容类型与是否覆盖在合并时校验
+	if err = policy.CheckTarget(cleanDir, fileName); err == nil {
+		err = policy.CheckSize(length)
+	}
+	if err != nil {
+		tusAbort(c, uploadPolicyStatus(err), err.Error())
+		return
+	}
+	// v0.8.0：按用户主目录 / 共享目录授权翻译目标目录
+	cleanDir, err = callerScope(c).ResolveDir(cleanDir)
+	if errors.Is(err, storage.ErrAccessDenied) {
+		tusAbort(c, http.StatusForbidden, "permission denied")
+		return
+	}
+	if err != nil {
+		tusAbort(c, http.StatusNotFound, "directory does not exist")
+		return
+	}
+
+	fsys := storage.UploadFS()
+	finalPath := storage.Join(cleanDir, fileName)
+	info, err := fsys.Stat(finalPath)
+	if errors.Is(err, storage.ErrInvalidPath) {
+		tusAbort(c, http.StatusBadRequest, "invalid filename path")
+		return
+	}
+	if err == nil && (info.IsDir() || meta["overwrite"] != "true") {
+		tusAbort(c, http.StatusConflict, "target file already exists (set overwrite=true in Upload-Metadata to replace)")
+		return
+	}
+	if err == nil {
+		if err := policy.CheckOverwrite(true); err != nil {
+			tusAbort(c, uploadPolicyStatus(err), err.Error())
+			return
+		}
+	}
+
+	// v0.8.0：按声明的 Upload-Length 预先校验用户 / 目录配额
+	if err := storage.CheckQuota(finalPath, requestOperator(c, appkey), length); err != nil {
+		if errors.Is(err, storage.ErrQuotaExceeded) {
+			tusAbort(c, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		ylog.Errorf("TusCreate", "check quota failed: %v", err)
+		tusAbort(c, http.StatusInternalServerError, "failed to check storage quota")
+		return
+	}
+
+	uploadID, err := generateUploadID()
+	if err != nil {
+		ylog.Errorf("TusCreate", "generate upload id failed: %v", err)
+		tusAbort(c, http.StatusInternalServerError, "failed to create upload")
+		return
+	}
+	if err := os.MkdirAll(common.ChunkSessionDir(uploadID), 0o755); err != nil {
+		ylog.Errorf("TusCreate", "create chunk dir failed: %v", err)
+		tusAbort(c, http.StatusInternalServerError, "failed to create upload")
+		return
+	}
+	if err := os.WriteFile(tusDataPath(uploadID), nil, 0o644); err != nil {
+		ylog.Errorf("TusCreate", "create data file failed: %v", err)
+		tusAbort(c, http.StatusInternalServerError, "failed to create upload")
+		return
+	}
+
+	username, _ := c.Get("user")
+	uname, _ := username.(string)
+	now := time.Now()
+	// tus 上传是单一的顺序字节流，按 1 个分片记录
+	session := models.UploadSessionModel{
+		UploadID:     uploadID,
+		Appkey:       appkey,
+		Username:     uname,
+		FileName:     fileName,
+		RelDir:       cleanDir,
+		FileSize:     length,
+		ChunkSize:    length,
+		TotalChunks:  1,
+		UploadedBits: "0",
+		Status:       "active",
+		FinalPath:    finalPath,
+		IP:           c.ClientIP(),
+		Source:       tusSource,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		ExpireAt:     now.Add(DefaultSessionTTL),
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		_ = os.RemoveAll(common.ChunkSessionDir(uploadID))
+		tusAbort(c, http.StatusInternalServerError, "database unavailable")
+		return
+	}
+	if err := db.Create(&session).Error; err != nil {
+		ylog.Errorf("TusCreate", "create session failed: %v", err)
+		_ = os.RemoveAll(common.ChunkSessionDir(uploadID))
+		tusAbort(c, http.StatusInternalServerError, "failed to create upload")
+		return
+	}
+
+	// 空文件无需 PATCH，直接完成
+	if length == 0 {
+		if err := tusFinalize(c, &session, policy); err != nil {
+			tusAbortFinalize(c, err)
+			return
+		}
+	}
+
+	c.Header("Location", tusBasePath+"/"+uploadID)
+	tusSetUploadHeaders(c, &session, 0)
+	c.Status(http.StatusCreated)
+}
+
+// TusHead 查询上传进度
+// HEAD /api/v1/file/tus/:id
+func TusHead(c *gin.Context) {
+	if !tusCheckVersion(c) {
+		return
+	}
+	session, ok := tusLoadSession(c)
+	if !ok {
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Length", strconv.FormatInt(session.FileSize, 10))
+	c.Header("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte(session.FileName)))
+	tusSetUploadHeaders(c, session, tusOffset(session))
+	c.Status(http.StatusOK)
+}
+
+// TusPatch 追加数据
+// PATCH /api/v1/file/tus/:id
+func TusPatch(c *gin.Context) {
+	_, policy, ok := tusCheckUpload(c)
+	if !ok {
+		return
+	}
+	if c.ContentType() != "application/offset+octet-stream" {
+		tusAbort(c, http.StatusUnsupportedMediaType, "Content-Type must be application/offset+octet-stream")
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		tusAbort(c, http.StatusBadRequest, "invalid Upload-Offset")
+		return
+	}
+	var hasher hash.Hash
+	var expectSum []byte
+	if header := c.GetHeader("Upload-Checksum"); header != "" {
+		if hasher, expectSum, err = parseTusChecksum(header); err != nil {
+			tusAbort(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	// 同一上传同一时刻只允许一个 PATCH
+	uploadID := c.Param("id")
+	mu := lockSession(uploadID)
+	if !mu.TryLock() {
+		tusAbort(c, http.StatusLocked, "upload is locked by another request")
+		return
+	}
+	defer unlockAndCleanup(uploadID, mu)
+
+	session, ok := tusLoadSession(c)
+	if !ok {
+		return
+	}
+	current := tusOffset(session)
+	if offset != current {
+		tusAbort(c, http.StatusConflict, fmt.Sprintf("Upload-Offset mismatch: expect %d, got %d", current, offset))
+		return
+	}
+	if session.Status == "completed" {
+		tusSetUploadHeaders(c, session, current)
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	f, err := os.OpenFile(tusDataPath(uploadID), os.O_WRONLY|os.O_CREATE, 0o644)
+	if err == nil {
+		_, err = f.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		ylog.Errorf("TusPatch", "open data file: %v", err)
+		tusAbort(c, http.StatusInternalServerError, "failed to write data")
+		return
+	}
+
+	var w io.Writer = f
+	if hasher != nil {
+		w = io.MultiWriter(f, hasher)
+	}
+	remaining := session.FileSize - offset
+	n, copyErr := io.Copy(w, io.LimitReader(c.Request.Body, remaining))
+	overflow := false
+	if copyErr == nil && n == remaining {
+		var probe [1]byte
+		k, _ := c.Request.Body.Read(probe[:])
+		overflow = k > 0
+	}
+
+	// 带校验和或超出 Upload-Length 的请求必须整体接收，否则丢弃本次写入；
+	// 不带校验和时保留已收到的部分，客户端 HEAD 后从断点继续
+	discard := overflow || (hasher != nil && (copyErr != nil || !bytes.Equal(hasher.Sum(nil), expectSum)))
+	if discard {
+		_ = f.Truncate(offset)
+		n = 0
+	}
+	if err := f.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+
+	switch {
+	case overflow:
+		tusAbort(c, http.StatusRequestEntityTooLarge, "request body exceeds Upload-Length")
+		return
+	case discard && copyErr == nil:
+		tusAbort(c, statusChecksumMismatch, "checksum mismatch")
+		return
+	case copyErr != nil:
+		ylog.Warnf("TusPatch", "upload %s interrupted at %d: %v", uploadID, offset+n, copyErr)
+		tusAbort(c, http.StatusInternalServerError, "failed to receive data")
+		return
+	}
+
+	// 有进展即顺延过期时间
+	db, err := common.GetDB()
+	if err != nil {
+		tusAbort(c, http.StatusInternalServerError, "database unavailable")
+		return
+	}
+	now := time.Now()
+	session.ExpireAt = now.Add(DefaultSessionTTL)
+	db.Model(&models.UploadSessionModel{}).
+		Where("upload_id = ?", uploadID).
+		Updates(map[string]interface{}{
+			"expire_at":  session.ExpireAt,
+			"updated_at": now,
+		})
+
+	newOffset := offset + n
+	if newOffset == session.FileSize {
+		if err := tusFinalize(c, session, policy); err != nil {
+			tusAbortFinalize(c, err)
+			return
+		}
+	}
+	tusSetUploadHeaders(c, session, newOffset)
+	c.Status(http.StatusNoContent)
+}
+
+// TusDelete 终止上传
+// DELETE /api/v1/file/tus/:id
+func TusDelete(c *gin.Context) {
+	if !tusCheckVersion(c) {
+		return
+	}
+	uploadID := c.Param("id")
+	mu := lockSession(uploadID)
+	if !mu.TryLock() {
+		tusAbort(c, http.StatusLocked, "upload is locked by another request")
+		return
+	}
+	defer unlockAndCleanup(uploadID, mu)
+
+	session, ok := tusLoadSession(c)
+	if !ok {
+		return
+	}
+	if session.Status == "completed" {
+		tusAbort(c, http.StatusBadRequest, "upload already completed")
+		return
+	}
+
+	if err := os.RemoveAll(common.ChunkSessionDir(uploadID)); err != nil {
+		ylog.Errorf("TusDelete", "remove chunk dir: %v", err)
+	}
+	if db, err := common.GetDB(); err == nil {
+		db.Model(&models.UploadSessionModel{}).
+			Where("upload_id = ?", uploadID).
+			Updates(map[string]interface{}{
+				"status":     "aborted",
+				"updated_at": time.Now(),
+			})
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// TusMethodOverride 兼容不支持 PATCH / DELETE 的环境（X-HTTP-Method-Override）
+// POST /api/v1/file/tus/:id
+func TusMethodOverride(c *gin.Context) {
+	switch strings.ToUpper(c.GetHeader("X-HTTP-Method-Override")) {
+	case http.MethodPatch:
+		TusPatch(c)
+	case http.MethodDelete:
+		TusDelete(c)
+	case http.MethodHead:
+		TusHead(c)
+	default:
+		c.Header("Tus-Resumable", tusVersion)
+		tusAbort(c, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// tusFinalize 按上传策略校验后将已接收的数据写入存储驱动（先写临时路径再 rename），更新会话并记录日志
+func tusFinalize(c *gin.Context, session *models.UploadSessionModel, policy *storage.UploadPolicy) error {
+	start := time.Now()
+	fsys := storage.UploadFS()
+	finalPath := session.FinalPath
+	tmpFinal := fmt.Sprintf("%s.%s.merging", finalPath, session.UploadID)
+
+	f, err := os.Open(tusDataPath(session.UploadID))
+	if err != nil {
+		ylog.Errorf("TusFinalize", "open data file: %v", err)
+		return err
+	}
+	// v0.8.0：内容类型与是否覆盖按完成上传的请求所带凭证的上传策略校验
+	if policy.NeedMime() {
+		mime, err := sniffMime(f)
+		if err == nil {
+			err = policy.CheckMime(mime)
+		}
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+	if _, err := fsys.Stat(finalPath); err == nil {
+		if err := policy.CheckOverwrite(true); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+	checksums := storage.NewChecksumWriter()
+	written, err := fsys.Put(tmpFinal, io.TeeReader(f, checksums), session.FileSize)
+	_ = f.Close()
+	if err == nil && written != session.FileSize {
+		err = fmt.Errorf("final size mismatch: expect %d, got %d", session.FileSize, written)
+	}
+	// v0.8.0：覆盖已有文件（overwrite）前保存旧版本
+	var version *models.FileVersionModel
+	if err == nil {
+		version, err = storage.SaveVersion(finalPath, requestOperator(c, session.Appkey), "tus_upload")
+	}
+	if err == nil {
+		err = fsys.Rename(tmpFinal, finalPath)
+	}
+	if err != nil {
+		storage.DiscardVersion(version)
+		_ = fsys.Remove(tmpFinal)
+		ylog.Errorf("TusFinalize", "finalize %s: %v", finalPath, err)
+		return err
+	}
+	logVersionCreated(c, session.Appkey, version)
+	storage.SetFileOwner(finalPath, requestOperator(c, session.Appkey))
+
+	sums := checksums.Sums()
+	finalMD5 := sums.MD5
+	if info, err := fsys.Stat(finalPath); err == nil {
+		storage.RememberChecksums(storage.UploadPath(finalPath), info, sums)
+	}
+	session.Status = "completed"
+	if db, err := common.GetDB(); err == nil {
+		db.Model(&models.UploadSessionModel{}).
+			Where("upload_id = ?", session.UploadID).
+			Updates(map[string]interface{}{
+				"status":        "completed",
+				"file_md5":      finalMD5,
+				"uploaded_bits": "1",
+				"uploaded_num":  1,
+				"updated_at":    time.Now(),
+			})
+	}
+
+	go func() {
+		if err := os.RemoveAll(common.ChunkSessionDir(session.UploadID)); err != nil {
+			ylog.Errorf("TusFinalize", "remove chunk dir: %v", err)
+		}
+	}()
+
+	if common.EnableSqlite {
+		go insertUploadLog(c.ClientIP(), session.Appkey,
+			time.Now().Format("2006-01-02 15:04:05"),
+			session.FileName, utils.FormatSize(session.FileSize),
+			finalMD5, time.Now().Unix(), time.Now().Unix())
+
+		// 完成发生在某次 PATCH 中，单独记录一条操作日志
+		username := ""
+		if user, exists := c.Get("user"); exists {
+			username = fmt.Sprintf("%v", user)
+		}
+		go saveOperationLog(models.OperationLogModel{
+			Username:  username,
+			IP:        c.ClientIP(),
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Action:    "tus_upload_complete",
+			Detail:    fmt.Sprintf("tus 上传完成: %s (%s)", finalPath, utils.FormatSize(session.FileSize)),
+			Status:    http.StatusOK,
+			Latency:   time.Since(start).Milliseconds(),
+			UserAgent: truncateString(c.Request.UserAgent(), 512),
+			CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	if common.PersistentNotifyURL != "" {
+		go utils.SendNotify(common.PersistentNotifyURL, fmt.Sprintf(
+			">tus 上传完成：\n- IP地址：%s\n- 文件名：%s\n- 大小：%s\n- MD5：%s",
+			c.ClientIP(), session.FileName, utils.FormatSize(session.FileSize), finalMD5))
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package v1
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"httpcat/internal/common"
+	"httpcat/internal/models"
+	"httpcat/internal/storage"
+	"httpcat/internal/storage/auth"
+)
+
+func b64(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func TestParseTusMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"empty", "", map[string]string{}, false},
+		{"single pair", "filename " + b64("report.pdf"), map[string]string{"filename": "report.pdf"}, false},
+		{
+			name:   "several pairs with spaces",
+			header: " filename " + b64("a b.txt") + " , dir " + b64("docs/2024") + ",overwrite " + b64("true"),
+			want:   map[string]string{"filename": "a b.txt", "dir": "docs/2024", "overwrite": "true"},
+		},
+		{"key without value", "filename " + b64("a.txt") + ",is_confidential", map[string]string{"filename": "a.txt", "is_confidential": ""}, false},
+		{"unicode value", "filename " + b64("报告.pdf"), map[string]string{"filename": "报告.pdf"}, false},
+		{"empty pairs skipped", ",,filename " + b64("a") + ",", map[string]string{"filename": "a"}, false},
+		{"invalid base64", "filename not-base64!", nil, true},
+		{"url-safe base64 rejected", "filename " + base64.URLEncoding.EncodeToString([]byte{0xfb, 0xff}), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTusMetadata(tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTusMetadata(%q) error = %v, wantErr %v", tt.header, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTusMetadata(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTusChecksum(t *testing.T) {
+	data := []byte("hello tus")
+	md5Sum := md5.Sum(data)
+	sha1Sum := sha1.Sum(data)
+	sha256Sum := sha256.Sum256(data)
+	enc := base64.StdEncoding.EncodeToString
+
+	tests := []struct {
+		name    string
+		header  string
+		want    []byte
+		wantErr bool
+	}{
+		{"md5", "md5 " + enc(md5Sum[:]), md5Sum[:], false},
+		{"sha1", "sha1 " + enc(sha1Sum[:]), sha1Sum[:], false},
+		{"sha256 upper case", "SHA256 " + enc(sha256Sum[:]), sha256Sum[:], false},
+		{"surrounding spaces", "  sha1   " + enc(sha1Sum[:]) + " ", sha1Sum[:], false},
+		{"unsupported algorithm", "crc32 AAAAAA==", nil, true},
+		{"missing digest", "sha1", nil, true},
+		{"invalid base64", "sha1 ***", nil, true},
+		{"empty", "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, sum, err := parseTusChecksum(tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTusChecksum(%q) error = %v, wantErr %v", tt.header, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			h.Write(data)
+			if got := h.Sum(nil); !reflect.DeepEqual(got, tt.want) || !reflect.DeepEqual(sum, tt.want) {
+				t.Errorf("parseTusChecksum(%q): hash of data = %x, expected digest = %x, want %x", tt.header, got, sum, tt.want)
+			}
+		})
+	}
+}
+
+func TestTusOwnsSession(t *testing.T) {
+	oldTable := common.UploadTokenTable
+	t.Cleanup(func() { common.UploadTokenTable = oldTable })
+	common.UploadTokenTable = map[string]*common.UploadTokenItem{
+		"app1": {Appkey: "app1", Appsecret: "secret1", State: "open"},
+		"app2": {Appkey: "app2", Appsecret: "secret2", State: "open"},
+	}
+	uploadToken := func(appkey, secret string) string {
+		policy, _ := json.Marshal(storage.UploadPolicy{Deadline: uint64(time.Now().Add(time.Hour).Unix())})
+		return auth.New(appkey, secret).SignWithData(policy)
+	}
+
+	tests := []struct {
+		name    string
+		session models.UploadSessionModel
+		user    string
+		token   string
+		want    bool
+	}{
+		{"same user", models.UploadSessionModel{Username: "bob"}, "bob", "", true},
+		{"other user", models.UploadSessionModel{Username: "bob"}, "alice", "", false},
+		{"anonymous caller", models.UploadSessionModel{Username: "bob"}, "", "", false},
+		{"user session with matching token only", models.UploadSessionModel{Username: "bob", Appkey: "app1"}, "", uploadToken("app1", "secret1"), false},
+		{"same appkey", models.UploadSessionModel{Appkey: "app1"}, "", uploadToken("app1", "secret1"), true},
+		{"other appkey", models.UploadSessionModel{Appkey: "app1"}, "", uploadToken("app2", "secret2"), false},
+		{"forged token", models.UploadSessionModel{Appkey: "app1"}, "", uploadToken("app1", "wrong"), false},
+		{"token session without token", models.UploadSessionModel{Appkey: "app1"}, "bob", "", false},
+		{"unauthenticated session", models.UploadSessionModel{}, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("HEAD", "/api/v1/file/tus/x", nil)
+			if tt.user != "" {
+				c.Set("user", tt.user)
+			}
+			if tt.token != "" {
+				c.Request.Header.Set("UploadToken", tt.token)
+			}
+			if got := tusOwnsSession(c, &tt.session); got != tt.want {
+				t.Errorf("tusOwnsSession() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
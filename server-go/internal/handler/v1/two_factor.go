@@ -0,0 +1,475 @@
+package v1
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"image/png"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/midware"
+	"httpcat/internal/models"
+)
+
+// ========== v0.8.0 新增：TOTP 两步验证 ==========
+//
+//   GET  /api/v1/user/2fa/status          当前用户的两步验证状态
+//   POST /api/v1/user/2fa/enroll          生成密钥，返回 otpauth URI 与二维码（尚未启用）
+//   POST /api/v1/user/2fa/activate        用验证码确认绑定并启用，返回恢复码  body: { "code": "123456" }
+//   POST /api/v1/user/2fa/disable         关闭两步验证  body: { "code": "123456 或恢复码" }
+//   POST /api/v1/user/2fa/recoveryCodes   重新生成恢复码（旧恢复码作废）  body: { "code": "123456" }
+//   POST /api/v1/user/2fa/reset           管理员清除用户的两步验证  body: { "username": "bob" }
+//   POST /api/v1/user/login/2fa           登录第二步  body: { "twoFactorToken": "...", "code": "123456 或恢复码" }
+// 启用后 /login/account 校验密码通过时不再直接签发 JWT，而是返回 status=2fa_required 与 5 分钟内有效的 twoFactorToken。
+// 恢复码只在生成时返回一次，每个只能使用一次。单点登录由 IdP 负责多因素认证，WebDAV / SFTP 仍只校验密码。
+
+const (
+	twoFactorIssuer        = "HttpCat"
+	twoFactorPeriod        = 30 // TOTP 时间步长，秒
+	twoFactorChallengeTTL  = 5 * time.Minute
+	twoFactorMaxAttempts   = 5 // 每个 twoFactorToken 允许的错误次数
+	twoFactorRecoveryCount = 10
+)
+
+// twoFactorChallenge 密码校验通过、等待验证码的登录
+type twoFactorChallenge struct {
+	Username  string
+	Attempts  int
+	ExpiresAt time.Time
+}
+
+var (
+	// twoFactorMu 保护验证码校验与 LastUsedStep / 恢复码的更新，防止并发重放
+	twoFactorMu         sync.Mutex
+	twoFactorChallenges = make(map[string]*twoFactorChallenge)
+)
+
+// loadTwoFactor 查询用户的两步验证记录，不存在时返回 nil
+func loadTwoFactor(username string) *models.TwoFactorModel {
+	if !common.EnableSqlite {
+		return nil
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		return nil
+	}
+	var rec models.TwoFactorModel
+	if db.Where("username = ?", username).Limit(1).Find(&rec).RowsAffected == 0 {
+		return nil
+	}
+	return &rec
+}
+
+// twoFactorEnabled 用户是否启用了两步验证
+func twoFactorEnabled(username string) bool {
+	return common.TwoFactorEnabled(username)
+}
+
+// normalizeCode 去掉验证码 / 恢复码中的空格与连字符
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code)))
+}
+
+// hashRecoveryCode 恢复码的存储形式
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// validateTOTP 校验 TOTP 验证码（允许前后各一个时间步的偏差），返回通过的时间步；不接受已使用过的时间步
+func validateTOTP(rec *models.TwoFactorModel, code string) (int64, bool) {
+	if len(code) != 6 {
+		return 0, false
+	}
+	now := time.Now()
+	for skew := -1; skew <= 1; skew++ {
+		t := now.Add(time.Duration(skew*twoFactorPeriod) * time.Second)
+		step := t.Unix() / twoFactorPeriod
+		if step <= rec.LastUsedStep {
+			continue
+		}
+		expected, err := totp.GenerateCodeCustom(rec.Secret, t, totp.ValidateOpts{
+			Period:    twoFactorPeriod,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err == nil && subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// verifyTwoFactorCode 校验验证码或（allowRecovery 时）恢复码，通过后记录时间步或作废恢复码
+func verifyTwoFactorCode(rec *models.TwoFactorModel, code string, allowRecovery bool) bool {
+	code = normalizeCode(code)
+	if code == "" {
+		return false
+	}
+	twoFactorMu.Lock()
+	defer twoFactorMu.Unlock()
+
+	db, err := common.GetDB()
+	if err != nil {
+		return false
+	}
+	// 重新读取，拿到最新的 LastUsedStep 与恢复码
+	if err := db.First(rec, rec.ID).Error; err != nil {
+		return false
+	}
+	if step, ok := validateTOTP(rec, code); ok {
+		rec.LastUsedStep = step
+		return db.Model(rec).Update("last_used_step", step).Error == nil
+	}
+	if !allowRecovery || !rec.Enabled {
+		return false
+	}
+	var hashes []string
+	_ = json.Unmarshal([]byte(rec.RecoveryCodes), &hashes)
+	target := hashRecoveryCode(code)
+	for i, h := range hashes {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(target)) != 1 {
+			continue
+		}
+		hashes = append(hashes[:i], hashes[i+1:]...)
+		data, _ := json.Marshal(hashes)
+		rec.RecoveryCodes = string(data)
+		if err := db.Model(rec).Update("recovery_codes", rec.RecoveryCodes).Error; err != nil {
+			return false
+		}
+		ylog.Warnf("twoFactor", "user %s used a recovery code, %d left", rec.Username, len(hashes))
+		return true
+	}
+	return false
+}
+
+// newRecoveryCodes 生成一组恢复码，返回明文与存储用的 JSON
+func newRecoveryCodes() ([]string, string) {
+	codes := make([]string, 0, twoFactorRecoveryCount)
+	hashes := make([]string, 0, twoFactorRecoveryCount)
+	for i := 0; i < twoFactorRecoveryCount; i++ {
+		raw := randomToken(5)
+		code := raw[:5] + "-" + raw[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	data, _ := json.Marshal(hashes)
+	return codes, string(data)
+}
+
+// recoveryCodesLeft 剩余可用的恢复码数量
+func recoveryCodesLeft(rec *models.TwoFactorModel) int {
+	var hashes []string
+	_ = json.Unmarshal([]byte(rec.RecoveryCodes), &hashes)
+	return len(hashes)
+}
+
+// beginTwoFactorLogin 为已通过密码校验的用户创建登录挑战，返回 twoFactorToken
+func beginTwoFactorLogin(username string) string {
+	token := randomToken(24)
+	twoFactorMu.Lock()
+	defer twoFactorMu.Unlock()
+	now := time.Now()
+	for k, v := range twoFactorChallenges {
+		if now.After(v.ExpiresAt) {
+			delete(twoFactorChallenges, k)
+		}
+	}
+	twoFactorChallenges[token] = &twoFactorChallenge{
+		Username:  username,
+		ExpiresAt: now.Add(twoFactorChallengeTTL),
+	}
+	return token
+}
+
+// lookupTwoFactorChallenge 返回有效的登录挑战
+func lookupTwoFactorChallenge(token string) (twoFactorChallenge, bool) {
+	twoFactorMu.Lock()
+	defer twoFactorMu.Unlock()
+	ch, ok := twoFactorChallenges[token]
+	if !ok || time.Now().After(ch.ExpiresAt) || ch.Attempts >= twoFactorMaxAttempts {
+		delete(twoFactorChallenges, token)
+		return twoFactorChallenge{}, false
+	}
+	return *ch, true
+}
+
+// finishTwoFactorChallenge 验证成功时作废挑战，失败时累计错误次数
+func finishTwoFactorChallenge(token string, success bool) {
+	twoFactorMu.Lock()
+	defer twoFactorMu.Unlock()
+	if success {
+		delete(twoFactorChallenges, token)
+		return
+	}
+	if ch, ok := twoFactorChallenges[token]; ok {
+		ch.Attempts++
+	}
+}
+
+// UserLoginTwoFactor 登录第二步：校验验证码或恢复码后签发 JWT
+// POST /api/v1/user/login/2fa
+func UserLoginTwoFactor(c *gin.Context) {
+	var req struct {
+		TwoFactorToken string `json:"twoFactorToken" binding:"required"`
+		Code           string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(c, "invalid request body")
+		return
+	}
+	clientIP := c.ClientIP()
+	ch, ok := lookupTwoFactorChallenge(req.TwoFactorToken)
+	if !ok {
+		common.Unauthorized(c, "two-factor login expired, please sign in again")
+		return
+	}
+	user := common.GetUser(ch.Username)
+	rec := loadTwoFactor(ch.Username)
+	if user == nil || user.Disabled || rec == nil || !rec.Enabled {
+		finishTwoFactorChallenge(req.TwoFactorToken, true)
+		common.Unauthorized(c, "two-factor login expired, please sign in again")
+		return
+	}
+	if !verifyTwoFactorCode(rec, req.Code, true) {
+		finishTwoFactorChallenge(req.TwoFactorToken, false)
+		midware.RecordLoginFailure(clientIP)
+		ylog.Warnf("UserLoginTwoFactor", "invalid two-factor code for user=%s ip=%s", ch.Username, clientIP)
+		common.Unauthorized(c, "invalid verification code")
+		return
+	}
+	finishTwoFactorChallenge(req.TwoFactorToken, true)
+	// 供操作日志中间件记录登录用户
+	c.Set("user", user.Username)
+	respondLoginSuccess(c, user)
+}
+
+// GetTwoFactorStatus 当前用户的两步验证状态
+// GET /api/v1/user/2fa/status
+func GetTwoFactorStatus(c *gin.Context) {
+	rec := loadTwoFactor(c.GetString("user"))
+	if rec == nil {
+		common.CreateResponse(c, common.SuccessCode, gin.H{"enabled": false, "pending": false, "recoveryCodesLeft": 0})
+		return
+	}
+	common.CreateResponse(c, common.SuccessCode, gin.H{
+		"enabled":           rec.Enabled,
+		"pending":           !rec.Enabled,
+		"enabledAt":         rec.EnabledAt,
+		"recoveryCodesLeft": recoveryCodesLeft(rec),
+	})
+}
+
+// EnrollTwoFactor 生成新的 TOTP 密钥（覆盖未完成的绑定），启用前需调用 activate 确认
+// POST /api/v1/user/2fa/enroll
+func EnrollTwoFactor(c *gin.Context) {
+	username := c.GetString("user")
+	if username == "" || common.GetUser(username) == nil {
+		common.CreateResponse(c, common.ErrorCode, "Failed to get user information")
+		return
+	}
+	rec := loadTwoFactor(username)
+	if rec != nil && rec.Enabled {
+		common.CreateResponse(c, common.ErrorCode, "two-factor authentication is already enabled")
+		return
+	}
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      twoFactorIssuer,
+		AccountName: username,
+		Period:      twoFactorPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		ylog.Errorf("EnrollTwoFactor", "generate totp key failed: %v", err)
+		common.CreateResponse(c, common.ErrorCode, "failed to generate secret")
+		return
+	}
+	qrCode := ""
+	if img, err := key.Image(256, 256); err == nil {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err == nil {
+			qrCode = "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+		}
+	}
+
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return
+	}
+	now := time.Now()
+	if rec == nil {
+		rec = &models.TwoFactorModel{Username: username, CreatedAt: now}
+	}
+	rec.Secret = key.Secret()
+	rec.RecoveryCodes = ""
+	rec.LastUsedStep = 0
+	rec.UpdatedAt = now
+	if err := db.Save(rec).Error; err != nil {
+		ylog.Errorf("EnrollTwoFactor", "save totp secret of %s failed: %v", username, err)
+		common.CreateResponse(c, common.ErrorCode, "failed to save secret")
+		return
+	}
+	common.CreateResponse(c, common.SuccessCode, gin.H{
+		"secret":     key.Secret(),
+		"otpauthUrl": key.URL(),
+		"qrCode":     qrCode,
+	})
+}
+
+// ActivateTwoFactor 用验证码确认绑定并启用两步验证，返回恢复码
+// POST /api/v1/user/2fa/activate
+func ActivateTwoFactor(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(c, "invalid request body")
+		return
+	}
+	username := c.GetString("user")
+	rec := loadTwoFactor(username)
+	if rec == nil {
+		common.CreateResponse(c, common.ErrorCode, "please enroll first")
+		return
+	}
+	if rec.Enabled {
+		common.CreateResponse(c, common.ErrorCode, "two-factor authentication is already enabled")
+		return
+	}
+	if !verifyTwoFactorCode(rec, req.Code, false) {
+		common.CreateResponse(c, common.ErrorCode, "invalid verification code")
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return
+	}
+	codes, hashes := newRecoveryCodes()
+	now := time.Now()
+	if err := db.Model(rec).Updates(map[string]interface{}{
+		"enabled":        true,
+		"enabled_at":     now,
+		"recovery_codes": hashes,
+		"updated_at":     now,
+	}).Error; err != nil {
+		ylog.Errorf("ActivateTwoFactor", "enable 2fa of %s failed: %v", username, err)
+		common.CreateResponse(c, common.ErrorCode, "failed to enable two-factor authentication")
+		return
+	}
+	ylog.Infof("ActivateTwoFactor", "two-factor authentication enabled for %s", username)
+	common.CreateResponse(c, common.SuccessCode, gin.H{"recoveryCodes": codes})
+}
+
+// DisableTwoFactor 关闭两步验证，需要验证码或恢复码
+// POST /api/v1/user/2fa/disable
+func DisableTwoFactor(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(c, "invalid request body")
+		return
+	}
+	username := c.GetString("user")
+	rec := loadTwoFactor(username)
+	if rec == nil {
+		common.CreateResponse(c, common.ErrorCode, "two-factor authentication is not enabled")
+		return
+	}
+	// 未完成绑定时直接删除
+	if rec.Enabled && !verifyTwoFactorCode(rec, req.Code, true) {
+		common.CreateResponse(c, common.ErrorCode, "invalid verification code")
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return
+	}
+	if err := db.Delete(rec).Error; err != nil {
+		ylog.Errorf("DisableTwoFactor", "disable 2fa of %s failed: %v", username, err)
+		common.CreateResponse(c, common.ErrorCode, "failed to disable two-factor authentication")
+		return
+	}
+	ylog.Infof("DisableTwoFactor", "two-factor authentication disabled for %s", username)
+	common.CreateResponse(c, common.SuccessCode, gin.H{"enabled": false})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，需要 TOTP 验证码
+// POST /api/v1/user/2fa/recoveryCodes
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(c, "invalid request body")
+		return
+	}
+	username := c.GetString("user")
+	rec := loadTwoFactor(username)
+	if rec == nil || !rec.Enabled {
+		common.CreateResponse(c, common.ErrorCode, "two-factor authentication is not enabled")
+		return
+	}
+	if !verifyTwoFactorCode(rec, req.Code, false) {
+		common.CreateResponse(c, common.ErrorCode, "invalid verification code")
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return
+	}
+	codes, hashes := newRecoveryCodes()
+	if err := db.Model(rec).Updates(map[string]interface{}{
+		"recovery_codes": hashes,
+		"updated_at":     time.Now(),
+	}).Error; err != nil {
+		ylog.Errorf("RegenerateRecoveryCodes", "save recovery codes of %s failed: %v", username, err)
+		common.CreateResponse(c, common.ErrorCode, "failed to generate recovery codes")
+		return
+	}
+	common.CreateResponse(c, common.SuccessCode, gin.H{"recoveryCodes": codes})
+}
+
+// ResetUserTwoFactor 管理员清除用户的两步验证（用户丢失设备与恢复码时）
+// POST /api/v1/user/2fa/reset
+func ResetUserTwoFactor(c *gin.Context) {
+	var req struct {
+		Username string `json:"username" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(c, "invalid request body")
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return
+	}
+	user, ok := findManagedUser(c, db, req.Username)
+	if !ok {
+		return
+	}
+	if err := db.Where("username = ?", user.Username).Delete(&models.TwoFactorModel{}).Error; err != nil {
+		ylog.Errorf("ResetUserTwoFactor", "reset 2fa of %s failed: %v", user.Username, err)
+		common.CreateResponse(c, common.ErrorCode, "failed to reset two-factor authentication")
+		return
+	}
+	ylog.Infof("ResetUserTwoFactor", "two-factor authentication of %s reset by %s", user.Username, c.GetString("user"))
+	common.CreateResponse(c, common.SuccessCode, gin.H{"username": user.Username})
+}
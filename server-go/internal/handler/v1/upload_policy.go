@@ -0,0 +1,92 @@
+package v1
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/storage"
+)
+
+// ========== v0.8.0 新增：上传凭证的上传策略 ==========
+//
+// 启用 UploadToken 时，凭证中签名的上传策略（见 storage.UploadPolicy）即为本次上传的约束：
+// 有效期、目标位置（scope）、文件大小、扩展名与 MIME 类型、是否允许覆盖。
+// 普通上传、分片上传、tus、图片上传与 MCP 上传工具使用同一套校验；过期返回 401，超出大小上限返回 413，其余违反策略返回 403。
+
+// uploadPolicyStatus 违反上传策略时对应的 HTTP 状态码，err 不是上传策略错误时返回 0
+func uploadPolicyStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrUploadTokenExpired):
+		return http.StatusUnauthorized
+	case errors.Is(err, storage.ErrUploadTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, storage.ErrUploadPolicyDenied):
+		return http.StatusForbidden
+	}
+	return 0
+}
+
+// respondUploadPolicy 违反上传策略时写入响应体并返回 true
+func respondUploadPolicy(c *gin.Context, err error) bool {
+	status := uploadPolicyStatus(err)
+	if status == 0 {
+		return false
+	}
+	ylog.Warnf("uploadPolicy", "reject upload from %s: %v", c.ClientIP(), err)
+	c.JSON(status, gin.H{
+		"errorCode": common.UploadPolicyViolation,
+		"msg":       err.Error(),
+		"data":      nil,
+	})
+	return true
+}
+
+// checkUploadPolicy 按上传策略校验目标位置与文件大小（dir 为按用户授权翻译前的目录），未通过时已写入响应体
+func checkUploadPolicy(c *gin.Context, policy *storage.UploadPolicy, dir, name string, size int64) bool {
+	err := policy.CheckTarget(dir, name)
+	if err == nil {
+		err = policy.CheckSize(size)
+	}
+	return !respondUploadPolicy(c, err)
+}
+
+// checkUploadOverwrite insertOnly 策略下目标文件已存在时拒绝，未通过时已写入响应体
+func checkUploadOverwrite(c *gin.Context, policy *storage.UploadPolicy, fsys storage.Driver, name string) bool {
+	if policy == nil || policy.InsertOnly == 0 {
+		return true
+	}
+	_, err := fsys.Stat(name)
+	return !respondUploadPolicy(c, policy.CheckOverwrite(err == nil))
+}
+
+// sniffMime 按开头 512 字节识别内容类型，并将读取位置恢复到开头
+func sniffMime(r io.ReadSeeker) (string, error) {
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// checkUploadMime 按上传策略校验文件内容的 MIME 类型，未通过时已写入响应体
+func checkUploadMime(c *gin.Context, policy *storage.UploadPolicy, r io.ReadSeeker) bool {
+	if !policy.NeedMime() {
+		return true
+	}
+	mime, err := sniffMime(r)
+	if err != nil {
+		ylog.Errorf("uploadPolicy", "sniff content type failed: %v", err)
+		common.CreateResponse(c, common.ErrorCode, "failed to read uploaded file")
+		return false
+	}
+	return !respondUploadPolicy(c, policy.CheckMime(mime))
+}
@@ -36,47 +36,48 @@ func UserLogin(c *gin.Context) {
 		return
 	}
 
-	if user.Username == common.DefaultAdminUsername {
-		//使用jwt token
-		userInfo, err := midware.CheckUser(user.Username, user.Password)
-		if err != nil {
-			midware.RecordLoginFailure(clientIP)
-			common.Unauthorized(c, err.Error())
-			return
-		}
-		token, err := midware.GeneralJwtToken(user.Username)
-		if err != nil {
-			common.Unauthorized(c, err.Error())
-			return
-		}
-
-		midware.RecordLoginSuccess(clientIP)
-
-		c.JSON(
-			http.StatusOK,
-			bson.M{"token": token,
-				"currentAuthority": "access", "type": "account", "status": "ok", "mustChangePassword": common.MustChangePassword(userInfo)},
-		)
-		return
-	}
-
+	// v0.8.0: 所有用户均使用 jwt token，权限由角色决定
 	userInfo, err := midware.CheckUser(user.Username, user.Password)
-	//密码校验
 	if err != nil {
+		// v0.8.0: 具体原因（用户不存在、已禁用、需单点登录等）只记日志，统一返回同一提示，避免被用来枚举账号
+		ylog.Warnf("UserLogin", "login failed for %q from %s: %v", user.Username, clientIP, err)
 		midware.RecordLoginFailure(clientIP)
-		common.Unauthorized(c, "verify password failed")
-	} else {
-		midware.RecordLoginSuccess(clientIP)
-		token := midware.GeneralSession()
-		//todo token存储到reids
-		//err = infra.Grds.Set(context.Background(), token, user.Username, time.Duration(login.GetLoginSessionTimeoutMinute())*time.Minute).Err()
-		common.CreateResponse(c, common.SuccessCode, bson.M{"token": token, "mustChangePassword": common.MustChangePassword(userInfo)})
+		common.Unauthorized(c, "invalid username or password")
+		return
+	}
+	// v0.8.0: 启用两步验证的用户需再提交验证码（/login/2fa）才签发 token
+	if twoFactorEnabled(userInfo.Username) {
+		c.JSON(http.StatusOK, bson.M{
+			"status":         "2fa_required",
+			"type":           "account",
+			"twoFactorToken": beginTwoFactorLogin(userInfo.Username),
+		})
+		return
 	}
+	respondLoginSuccess(c, userInfo)
 
 	ylog.Infof("UserLogin", "UserLogin function completed")
 
 }
 
+// respondLoginSuccess 创建会话并返回登录成功响应（v0.8.0: 附带 refresh token）
+func respondLoginSuccess(c *gin.Context, userInfo *common.User) {
+	tokens, err := midware.IssueSession(userInfo.Username, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		ylog.Errorf("UserLogin", "create session for %s failed: %v", userInfo.Username, err)
+		common.Unauthorized(c, err.Error())
+		return
+	}
+
+	midware.RecordLoginSuccess(c.ClientIP())
+
+	c.JSON(
+		http.StatusOK,
+		bson.M{"token": tokens.AccessToken, "refreshToken": tokens.RefreshToken, "expiresIn": tokens.ExpiresIn,
+			"currentAuthority": userInfo.Role, "type": "account", "status": "ok", "mustChangePassword": common.MustChangePassword(userInfo)},
+	)
+}
+
 func ChangePasswd(c *gin.Context) {
 
 	var params struct {
@@ -106,6 +107,11 @@ func ChangePasswd(c *gin.Context) {
 	}
 
 	db.Where("username = ?", username.(string)).First(&user)
+	if user.AuthSource != common.AuthSourceLocal {
+		// v0.8.0: 单点登录 / LDAP 用户的密码由外部身份源管理
+		common.BadRequest(c, "password is managed by the external identity provider")
+		return
+	}
 
 	valid, _, err := common.VerifyPassword(&user, params.OldPassword)
 	if err != nil {
@@ -143,6 +149,10 @@ func ChangePasswd(c *gin.Context) {
 		return
 	}
 	common.UpdateUserPasswordCache(user.Username, user.Password, user.Salt, user.PasswordUpdateTime)
+	// v0.8.0: 修改密码后吊销其他会话，当前会话保留
+	if _, err := midware.RevokeUserSessions(user.Username, c.GetString("session")); err != nil {
+		ylog.Errorf("ChangePasswd", "revoke sessions of %s failed: %v", user.Username, err)
+	}
 
 	// 返回成功响应
 	common.CreateResponse(c, common.SuccessCode, "Password changed successfully")
@@ -165,6 +175,7 @@ type UserInfoVO struct {
 	Access             string `json:"access"`
 	Phone              string `json:"phone"`
 	MustChangePassword bool   `json:"mustChangePassword"`
+	Role               string `json:"role"` // v0.8.0 新增
 
 	Level int `json:"level"`
 }
@@ -179,6 +190,10 @@ func UserInfo(c *gin.Context) {
 	}
 
 	user := common.GetUser(username.(string))
+	if user == nil {
+		common.CreateResponse(c, common.ErrorCode, "Failed to get user information")
+		return
+	}
 
 	// 构建包含需要保留字段的新结构体
 	info := UserInfoVO{
@@ -194,9 +209,10 @@ func UserInfo(c *gin.Context) {
 		UnreadCount:        user.UnreadCount,
 		Country:            user.Country,
 		Address:            user.Address,
-		Access:             "admin",
+		Access:             user.Role,
 		Phone:              user.Phone,
 		MustChangePassword: common.MustChangePassword(user),
+		Role:               user.Role,
 		Level:              user.Level,
 	}
 
@@ -204,17 +220,15 @@ func UserInfo(c *gin.Context) {
 }
 
 func UserLoginout(c *gin.Context) {
-	token := c.GetHeader("token")
-	ylog.Infof("UserLoginout", "delete token: %s", token)
-
-	// TODO token从redis中删除
-	//err := infra.Grds.Del(context.Background(), token).Err()
-
-	//if err != nil {
-	//	common.CreateResponse(c, common.SuccessCode, err.Error())
-	//} else {
-	//	common.CreateResponse(c, common.SuccessCode, nil)
-	//}
+	// v0.8.0: 吊销当前会话，access token 与 refresh token 立即失效
+	if sessionID := c.GetString("session"); sessionID != "" {
+		if err := midware.RevokeSession(sessionID); err != nil {
+			ylog.Errorf("UserLoginout", "revoke session %s failed: %v", sessionID, err)
+			common.CreateResponse(c, common.ErrorCode, "failed to sign out")
+			return
+		}
+		ylog.Infof("UserLoginout", "user %s signed out, session %s revoked", c.GetString("user"), sessionID)
+	}
 
 	common.CreateResponse(c, common.SuccessCode, nil)
 }
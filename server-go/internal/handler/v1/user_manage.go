@@ -0,0 +1,384 @@
+package v1
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/midware"
+	"httpcat/internal/models"
+)
+
+// ========== v0.8.0 新增：用户管理（仅管理员） ==========
+//
+//   GET    /api/v1/user/list              用户列表
+//   POST   /api/v1/user/create            创建用户  body: { "username": "bob", "password": "...", "role": "uploader" }
+//   POST   /api/v1/user/role              修改角色  body: { "username": "bob", "role": "readonly" }
+//   POST   /api/v1/user/group             修改用户组（共享目录可按组授权）  body: { "username": "bob", "group": "dev" }
+//   POST   /api/v1/user/disable           禁用 / 启用  body: { "username": "bob", "disabled": true }
+//   POST   /api/v1/user/resetPasswd       重置密码  body: { "username": "bob", "password": "..." }
+//   POST   /api/v1/user/2fa/reset         清除用户的两步验证  body: { "username": "bob" }（two_factor.go）
+//   DELETE /api/v1/user/delete?username=  删除用户（同时删除其 SSH 公钥、两步验证与会话）
+// 修改后立即刷新用户缓存，禁用或降级即时生效；禁用、重置密码与删除同时吊销该用户的全部登录会话；
+// 始终至少保留一个启用的管理员。
+
+// ManagedUserVO 用户列表项
+type ManagedUserVO struct {
+	ID                 uint   `json:"id"`
+	Username           string `json:"username"`
+	Email              string `json:"email"`
+	Role               string `json:"role"`
+	Group              string `json:"group"`
+	AuthSource         string `json:"authSource"` // 空串为本地用户，oidc 为单点登录用户，ldap 为目录用户
+	Disabled           bool   `json:"disabled"`
+	TwoFactor          bool   `json:"twoFactor"` // 是否已启用两步验证
+	PasswordUpdateTime int64  `json:"passwordUpdateTime"`
+}
+
+// findManagedUser 按用户名查询用户，不存在时写入响应体并返回 false
+func findManagedUser(c *gin.Context, db *gorm.DB, username string) (*common.User, bool) {
+	var user common.User
+	if db.Where("username = ?", username).Limit(1).Find(&user).RowsAffected == 0 {
+		common.CreateResponse(c, common.ErrorCode, "user not found")
+		return nil, false
+	}
+	return &user, true
+}
+
+// isLastAdmin 判断 user 是否为唯一启用的管理员
+func isLastAdmin(db *gorm.DB, user *common.User) bool {
+	if user.Role != common.RoleAdmin || user.Disabled {
+		return false
+	}
+	var count int64
+	db.Model(&common.User{}).Where("role = ? AND disabled = ? AND id <> ?", common.RoleAdmin, false, user.ID).Count(&count)
+	return count == 0
+}
+
+// ListUsers 用户列表
+// GET /api/v1/user/list
+func ListUsers(c *gin.Context) {
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return
+	}
+	var users []common.User
+	if err := db.Order("id").Find(&users).Error; err != nil {
+		ylog.Errorf("ListUsers", "query users failed: %v", err)
+		common.CreateResponse(c, common.ErrorCode, "failed to list users")
+		return
+	}
+	var twoFactorUsers []string
+	db.Model(&models.TwoFactorModel{}).Where("enabled = ?", true).Pluck("username", &twoFactorUsers)
+	twoFactor := make(map[string]bool, len(twoFactorUsers))
+	for _, name := range twoFactorUsers {
+		twoFactor[name] = true
+	}
+	list := make([]ManagedUserVO, 0, len(users))
+	for _, u := range users {
+		list = append(list, ManagedUserVO{
+			ID:                 u.ID,
+			Username:           u.Username,
+			Email:              u.Email,
+			Role:               u.Role,
+			Group:              u.Group,
+			AuthSource:         u.AuthSource,
+			Disabled:           u.Disabled,
+			TwoFactor:          twoFactor[u.Username],
+			PasswordUpdateTime: u.PasswordUpdateTime,
+		})
+	}
+	common.CreateResponse(c, common.SuccessCode, gin.H{
+		"list":  list,
+		"total": len(list),
+		"roles": common.Roles,
+	})
+}
+
+// CreateUser 创建用户
+// POST /api/v1/user/create
+func CreateUser(c *gin.Context) {
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+		Role     string `json:"role"`
+		Email    string `json:"email"`
+		Group    string `json:"group"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(c, "invalid request body")
+		return
+	}
+	username := strings.TrimSpace(req.Username)
+	if !common.ValidUsername(username) {
+		common.BadRequest(c, "invalid username")
+		return
+	}
+	if req.Role == "" {
+		req.Role = common.RoleReadOnly
+	}
+	if !common.ValidRole(req.Role) {
+		common.BadRequest(c, "invalid role")
+		return
+	}
+	hashed, err := common.HashPassword(strings.TrimSpace(req.Password))
+	if err != nil {
+		common.BadRequest(c, err.Error())
+		return
+	}
+
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return
+	}
+	var count int64
+	db.Model(&common.User{}).Where("username = ?", username).Count(&count)
+	if count > 0 {
+		common.CreateResponse(c, common.DuplicateFieldErrorCode, "username already exists")
+		return
+	}
+	user := common.User{
+		Username:           username,
+		Email:              req.Email,
+		Password:           hashed,
+		PasswordUpdateTime: time.Now().Unix(),
+		Role:               req.Role,
+		Group:              strings.TrimSpace(req.Group),
+	}
+	if err := db.Create(&user).Error; err != nil {
+		ylog.Errorf("CreateUser", "create user %s failed: %v", username, err)
+		common.CreateResponse(c, common.ErrorCode, "failed to create user")
+		return
+	}
+	common.ReloadUsers()
+	ylog.Infof("CreateUser", "user %s created with role %s", username, req.Role)
+	common.CreateResponse(c, common.SuccessCode, ManagedUserVO{
+		ID:                 user.ID,
+		Username:           user.Username,
+		Email:              user.Email,
+		Role:               user.Role,
+		Group:              user.Group,
+		PasswordUpdateTime: user.PasswordUpdateTime,
+	})
+}
+
+// SetUserRole 修改用户角色
+// POST /api/v1/user/role
+func SetUserRole(c *gin.Context) {
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Role     string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(c, "invalid request body")
+		return
+	}
+	if !common.ValidRole(req.Role) {
+		common.BadRequest(c, "invalid role")
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return
+	}
+	user, ok := findManagedUser(c, db, req.Username)
+	if !ok {
+		return
+	}
+	if req.Role != common.RoleAdmin && isLastAdmin(db, user) {
+		common.CreateResponse(c, common.ErrorCode, "cannot demote the last admin")
+		return
+	}
+	if err := db.Model(user).Update("role", req.Role).Error; err != nil {
+		ylog.Errorf("SetUserRole", "update role of %s failed: %v", user.Username, err)
+		common.CreateResponse(c, common.ErrorCode, "failed to update role")
+		return
+	}
+	common.ReloadUsers()
+	ylog.Infof("SetUserRole", "role of %s changed to %s", user.Username, req.Role)
+	common.CreateResponse(c, common.SuccessCode, gin.H{"username": user.Username, "role": req.Role})
+}
+
+// SetUserGroup 修改用户组，空串表示不属于任何组
+// POST /api/v1/user/group
+func SetUserGroup(c *gin.Context) {
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Group    string `json:"group"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(c, "invalid request body")
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return
+	}
+	user, ok := findManagedUser(c, db, req.Username)
+	if !ok {
+		return
+	}
+	group := strings.TrimSpace(req.Group)
+	if err := db.Model(user).Update("group", group).Error; err != nil {
+		ylog.Errorf("SetUserGroup", "update group of %s failed: %v", user.Username, err)
+		common.CreateResponse(c, common.ErrorCode, "failed to update group")
+		return
+	}
+	common.ReloadUsers()
+	ylog.Infof("SetUserGroup", "group of %s changed to %q", user.Username, group)
+	common.CreateResponse(c, common.SuccessCode, gin.H{"username": user.Username, "group": group})
+}
+
+// DisableUser 禁用或启用用户
+// POST /api/v1/user/disable
+func DisableUser(c *gin.Context) {
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Disabled bool   `json:"disabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(c, "invalid request body")
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return
+	}
+	user, ok := findManagedUser(c, db, req.Username)
+	if !ok {
+		return
+	}
+	if req.Disabled {
+		if user.Username == c.GetString("user") {
+			common.CreateResponse(c, common.ErrorCode, "cannot disable yourself")
+			return
+		}
+		if isLastAdmin(db, user) {
+			common.CreateResponse(c, common.ErrorCode, "cannot disable the last admin")
+			return
+		}
+	}
+	if err := db.Model(user).Update("disabled", req.Disabled).Error; err != nil {
+		ylog.Errorf("DisableUser", "update user %s failed: %v", user.Username, err)
+		common.CreateResponse(c, common.ErrorCode, "failed to update user")
+		return
+	}
+	common.ReloadUsers()
+	if req.Disabled {
+		if _, err := midware.RevokeUserSessions(user.Username, ""); err != nil {
+			ylog.Errorf("DisableUser", "revoke sessions of %s failed: %v", user.Username, err)
+		}
+	}
+	ylog.Infof("DisableUser", "user %s disabled=%v", user.Username, req.Disabled)
+	common.CreateResponse(c, common.SuccessCode, gin.H{"username": user.Username, "disabled": req.Disabled})
+}
+
+// ResetUserPassword 管理员重置用户密码
+// POST /api/v1/user/resetPasswd
+func ResetUserPassword(c *gin.Context) {
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.BadRequest(c, "invalid request body")
+		return
+	}
+	hashed, err := common.HashPassword(strings.TrimSpace(req.Password))
+	if err != nil {
+		common.BadRequest(c, err.Error())
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return
+	}
+	user, ok := findManagedUser(c, db, req.Username)
+	if !ok {
+		return
+	}
+	if user.AuthSource != common.AuthSourceLocal {
+		common.CreateResponse(c, common.ErrorCode, "password is managed by the external identity provider")
+		return
+	}
+	updateTime := time.Now().Unix()
+	if err := db.Model(user).Updates(map[string]interface{}{
+		"password":             hashed,
+		"salt":                 "",
+		"password_update_time": updateTime,
+	}).Error; err != nil {
+		ylog.Errorf("ResetUserPassword", "reset password of %s failed: %v", user.Username, err)
+		common.CreateResponse(c, common.ErrorCode, "failed to reset password")
+		return
+	}
+	common.UpdateUserPasswordCache(user.Username, hashed, "", updateTime)
+	if _, err := midware.RevokeUserSessions(user.Username, ""); err != nil {
+		ylog.Errorf("ResetUserPassword", "revoke sessions of %s failed: %v", user.Username, err)
+	}
+	ylog.Infof("ResetUserPassword", "password of %s reset by %s", user.Username, c.GetString("user"))
+	common.CreateResponse(c, common.SuccessCode, "Password reset successfully")
+}
+
+// DeleteUser 删除用户及其 SSH 公钥
+// DELETE /api/v1/user/delete?username=
+func DeleteUser(c *gin.Context) {
+	username := c.Query("username")
+	if username == "" {
+		common.BadRequest(c, "username is required")
+		return
+	}
+	if username == c.GetString("user") {
+		common.CreateResponse(c, common.ErrorCode, "cannot delete yourself")
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		common.CreateResponse(c, common.ErrorCode, "database unavailable")
+		return
+	}
+	user, ok := findManagedUser(c, db, username)
+	if !ok {
+		return
+	}
+	if isLastAdmin(db, user) {
+		common.CreateResponse(c, common.ErrorCode, "cannot delete the last admin")
+		return
+	}
+	// 先吊销会话（同步内存缓存），避免同名用户重建后旧令牌复活
+	if _, err := midware.RevokeUserSessions(user.Username, ""); err != nil {
+		ylog.Errorf("DeleteUser", "revoke sessions of %s failed: %v", user.Username, err)
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("username = ?", user.Username).Delete(&models.SSHKeyModel{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("username = ?", user.Username).Delete(&models.TwoFactorModel{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("username = ?", user.Username).Delete(&models.SessionModel{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("username = ?", user.Username).Delete(&models.APIKeyModel{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(user).Error
+	})
+	if err != nil {
+		ylog.Errorf("DeleteUser", "delete user %s failed: %v", user.Username, err)
+		common.CreateResponse(c, common.ErrorCode, "failed to delete user")
+		return
+	}
+	common.ReloadUsers()
+	ylog.Infof("DeleteUser", "user %s deleted", user.Username)
+	common.CreateResponse(c, common.SuccessCode, gin.H{"username": user.Username})
+}
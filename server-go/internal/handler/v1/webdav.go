@@ -0,0 +1,397 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/utils"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/webdav"
+)
+
+// ========== v0.8.0 新增：WebDAV ==========
+//
+// 将文件管理目录（DownloadFS，与 Web 界面的文件列表一致）以 WebDAV 暴露在 server.webdav.prefix 下，
+// 支持 PROPFIND / GET / PUT / MKCOL / MOVE / COPY / DELETE / LOCK / UNLOCK，可直接挂载为网络盘。
+// 所有路径经由存储驱动访问（本地驱动内部使用 ResolvePathWithinBase 防止穿越与符号链接逃逸）。
+// 开启 home_dirs 后按登录用户的目录视图（storage.ScopeFor）访问，与 Web 界面看到的目录一致。
+
+// davLockSystem 进程内锁表（LOCK/UNLOCK），重启后失效
+var davLockSystem = webdav.NewMemLS()
+
+type davCtxKey struct{}
+
+// davRequestInfo 随 context 传给文件系统，用于目录视图、上传日志与 PUT 完整性校验
+type davRequestInfo struct {
+	user          string
+	scope         *storage.UserScope // 登录用户的目录视图，nil 表示不受限
+	ip            string
+	contentLength int64 // 仅 PUT 有效，-1 表示未知
+}
+
+// WebDAV 处理 WebDAV 请求（需配合 midware.WebDAVAuth 使用）
+func WebDAV() gin.HandlerFunc {
+	handler := &webdav.Handler{
+		Prefix:     common.WebDAVPrefix,
+		FileSystem: davFS{},
+		LockSystem: davLockSystem,
+		Logger: func(r *http.Request, err error) {
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				ylog.Warnf("WebDAV", "%s %s: %v", r.Method, r.URL.Path, err)
+			}
+		},
+	}
+	return func(c *gin.Context) {
+		info := &davRequestInfo{ip: c.ClientIP(), contentLength: -1}
+		if user, ok := c.Get("user"); ok {
+			info.user = fmt.Sprintf("%v", user)
+		}
+		info.scope = callerScope(c)
+		if c.Request.Method == http.MethodPut {
+			info.contentLength = c.Request.ContentLength
+			// v0.8.0：声明了长度的 PUT 先按长度校验配额，超出时返回 507；分块传输在写入过程中校验（见 newDavWriteFile）
+			if info.contentLength >= 0 && !davCheckQuota(c, info) {
+				return
+			}
+		}
+		r := c.Request.WithContext(context.WithValue(c.Request.Context(), davCtxKey{}, info))
+		handler.ServeHTTP(c.Writer, r)
+	}
+}
+
+// davCheckQuota 按 PUT 声明的长度校验配额，超出时返回 507 Insufficient Storage 并返回 false
+func davCheckQuota(c *gin.Context, info *davRequestInfo) bool {
+	p, err := davPath(strings.TrimPrefix(c.Request.URL.Path, common.WebDAVPrefix))
+	if err != nil || p == "" {
+		return true
+	}
+	realPath, err := info.scope.Resolve(p, true)
+	if err != nil {
+		// 越权的路径交给文件系统返回 403
+		return true
+	}
+	err = storage.CheckQuotaKey(storage.DownloadPath(realPath), info.user, info.contentLength)
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, storage.ErrQuotaExceeded) {
+		ylog.Warnf("WebDAV", "reject PUT %s: %v", p, err)
+		c.String(http.StatusInsufficientStorage, err.Error())
+	} else {
+		ylog.Errorf("WebDAV", "check quota for %s failed: %v", p, err)
+		c.Status(http.StatusInternalServerError)
+	}
+	return false
+}
+
+// davFS 基于存储驱动的 webdav.FileSystem
+type davFS struct{}
+
+func davPath(name string) (string, error) {
+	return storage.CleanPath(strings.TrimPrefix(name, "/"))
+}
+
+// davRequest 取出 context 中的请求信息
+func davRequest(ctx context.Context) *davRequestInfo {
+	if info, ok := ctx.Value(davCtxKey{}).(*davRequestInfo); ok {
+		return info
+	}
+	return &davRequestInfo{contentLength: -1}
+}
+
+// davDriver 返回当前用户视图下的文件管理目录
+func davDriver(ctx context.Context) storage.Driver {
+	return davRequest(ctx).scope.FS(storage.DownloadFS())
+}
+
+func (davFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	p, err := davPath(name)
+	if err != nil {
+		return err
+	}
+	if p == "" {
+		return fs.ErrExist
+	}
+	fsys := davDriver(ctx)
+	if _, err := fsys.Stat(p); err == nil {
+		return fs.ErrExist
+	}
+	// MKCOL 不自动创建父目录（RFC 4918：父目录不存在返回 409）
+	if dir := path.Dir(p); dir != "." {
+		if parent, err := fsys.Stat(dir); err != nil || !parent.IsDir() {
+			return fs.ErrNotExist
+		}
+	}
+	return fsys.Mkdir(p)
+}
+
+func (davFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	p, err := davPath(name)
+	if err != nil {
+		return nil, err
+	}
+	fsys := davDriver(ctx)
+
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC) != 0 {
+		if !common.FileUploadEnable {
+			return nil, fs.ErrPermission
+		}
+		if p == "" {
+			return nil, fs.ErrInvalid
+		}
+		if info, err := fsys.Stat(p); err == nil && info.IsDir() {
+			return nil, fs.ErrInvalid
+		}
+		if dir := path.Dir(p); dir != "." {
+			if parent, err := fsys.Stat(dir); err != nil || !parent.IsDir() {
+				return nil, fs.ErrNotExist
+			}
+		}
+		return newDavWriteFile(ctx, fsys, p), nil
+	}
+
+	info, err := fsys.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &davDirFile{fsys: fsys, name: p, info: info}, nil
+	}
+	f, err := fsys.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	return &davReadFile{File: f, info: info}, nil
+}
+
+func (davFS) RemoveAll(ctx context.Context, name string) error {
+	p, err := davPath(name)
+	if err != nil {
+		return err
+	}
+	if p == "" {
+		return fs.ErrPermission
+	}
+	return davDriver(ctx).RemoveAll(p)
+}
+
+func (davFS) Rename(ctx context.Context, oldName, newName string) error {
+	oldPath, err := davPath(oldName)
+	if err != nil {
+		return err
+	}
+	newPath, err := davPath(newName)
+	if err != nil {
+		return err
+	}
+	if oldPath == "" || newPath == "" {
+		return fs.ErrPermission
+	}
+	return davDriver(ctx).Rename(oldPath, newPath)
+}
+
+func (davFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	p, err := davPath(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := davDriver(ctx).Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	return davFileInfo{info}, nil
+}
+
+// davFileInfo 按扩展名提供 Content-Type，避免 PROPFIND 时逐个打开文件嗅探
+type davFileInfo struct {
+	fs.FileInfo
+}
+
+func (i davFileInfo) ContentType(ctx context.Context) (string, error) {
+	if t := mime.TypeByExtension(path.Ext(i.Name())); t != "" {
+		return t, nil
+	}
+	return "", webdav.ErrNotImplemented
+}
+
+// davReadFile 只读文件
+type davReadFile struct {
+	storage.File
+	info fs.FileInfo
+}
+
+func (f *davReadFile) Readdir(count int) ([]fs.FileInfo, error) {
+	return nil, fs.ErrInvalid
+}
+
+func (f *davReadFile) Stat() (fs.FileInfo, error) {
+	return davFileInfo{f.info}, nil
+}
+
+func (f *davReadFile) Write(p []byte) (int, error) {
+	return 0, fs.ErrPermission
+}
+
+// davDirFile 目录
+type davDirFile struct {
+	fsys    storage.Driver
+	name    string
+	info    fs.FileInfo
+	entries []fs.FileInfo
+	loaded  bool
+}
+
+func (d *davDirFile) Readdir(count int) ([]fs.FileInfo, error) {
+	if !d.loaded {
+		entries, err := d.fsys.List(d.name)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			d.entries = append(d.entries, davFileInfo{e})
+		}
+		d.loaded = true
+	}
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(d.entries) {
+		count = len(d.entries)
+	}
+	entries := d.entries[:count]
+	d.entries = d.entries[count:]
+	return entries, nil
+}
+
+func (d *davDirFile) Stat() (fs.FileInfo, error)                   { return davFileInfo{d.info}, nil }
+func (d *davDirFile) Read(p []byte) (int, error)                   { return 0, fs.ErrInvalid }
+func (d *davDirFile) Seek(offset int64, whence int) (int64, error) { return 0, fs.ErrInvalid }
+func (d *davDirFile) Write(p []byte) (int, error)                  { return 0, fs.ErrInvalid }
+func (d *davDirFile) Close() error                                 { return nil }
+
+// davWriteFile 写入文件：内容经管道流式写入存储驱动，Close 时提交。
+// 请求中断或实际长度与 Content-Length 不符时放弃写入，不会留下半截文件。
+type davWriteFile struct {
+	ctx     context.Context
+	fsys    storage.Driver
+	name    string
+	info    *davRequestInfo
+	pw      *io.PipeWriter
+	done    chan error
+	sums    *storage.ChecksumWriter
+	written int64
+	closed  bool
+}
+
+func newDavWriteFile(ctx context.Context, fsys storage.Driver, name string) *davWriteFile {
+	info := davRequest(ctx)
+	pr, pw := io.Pipe()
+	f := &davWriteFile{
+		ctx:    ctx,
+		fsys:   fsys,
+		name:   name,
+		info:   info,
+		pw:     pw,
+		done:   make(chan error, 1),
+		sums:   storage.NewChecksumWriter(),
+	}
+	go func() {
+		// v0.8.0：按配额限制写入的字节数，超出时写入失败
+		realPath, err := info.scope.Resolve(name, true)
+		var body io.Reader
+		if err == nil {
+			body, err = storage.LimitQuota(storage.DownloadPath(realPath), info.user, pr)
+		}
+		if err == nil {
+			_, err = fsys.Put(name, body, info.contentLength)
+		}
+		pr.CloseWithError(err)
+		f.done <- err
+	}()
+	return f
+}
+
+func (f *davWriteFile) Write(p []byte) (int, error) {
+	n, err := f.pw.Write(p)
+	f.sums.Write(p[:n])
+	f.written += int64(n)
+	return n, err
+}
+
+func (f *davWriteFile) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+
+	switch {
+	case f.ctx.Err() != nil:
+		f.pw.CloseWithError(f.ctx.Err())
+	case f.info.contentLength >= 0 && f.written != f.info.contentLength:
+		f.pw.CloseWithError(io.ErrUnexpectedEOF)
+	default:
+		f.pw.Close()
+	}
+	if err := <-f.done; err != nil {
+		return err
+	}
+	if f.ctx.Err() != nil || (f.info.contentLength >= 0 && f.written != f.info.contentLength) {
+		return io.ErrUnexpectedEOF
+	}
+
+	sums := f.sums.Sums()
+	realPath, err := f.info.scope.Resolve(f.name, true)
+	if err != nil {
+		return err
+	}
+	if info, err := f.fsys.Stat(f.name); err == nil {
+		storage.RememberChecksums(storage.DownloadPath(realPath), info, sums)
+	}
+	storage.SetFileOwnerKey(storage.DownloadPath(realPath), f.info.user)
+	if common.EnableSqlite {
+		now := time.Now()
+		go insertUploadLog(f.info.ip, f.info.user, now.Format("2006-01-02 15:04:05"), f.name,
+			utils.FormatSize(f.written), sums.MD5, now.Unix(), now.Unix())
+	}
+	return nil
+}
+
+func (f *davWriteFile) Stat() (fs.FileInfo, error) {
+	return davWriteInfo{name: path.Base(f.name), size: f.written, modTime: time.Now()}, nil
+}
+
+func (f *davWriteFile) Read(p []byte) (int, error)                   { return 0, fs.ErrInvalid }
+func (f *davWriteFile) Seek(offset int64, whence int) (int64, error) { return 0, fs.ErrInvalid }
+func (f *davWriteFile) Readdir(count int) ([]fs.FileInfo, error)     { return nil, fs.ErrInvalid }
+
+// davWriteInfo 写入中文件的 FileInfo（PUT 在 Close 之前取 Stat 生成 ETag）
+type davWriteInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (i davWriteInfo) Name() string       { return i.name }
+func (i davWriteInfo) Size() int64        { return i.size }
+func (i davWriteInfo) Mode() fs.FileMode  { return 0o644 }
+func (i davWriteInfo) ModTime() time.Time { return i.modTime }
+func (i davWriteInfo) IsDir() bool        { return false }
+func (i davWriteInfo) Sys() interface{}   { return nil }
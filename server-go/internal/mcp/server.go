@@ -1,20 +1,24 @@
 package mcp
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"httpcat/internal/common"
 	"httpcat/internal/common/utils"
 	"httpcat/internal/common/ylog"
+	"httpcat/internal/midware"
 	"httpcat/internal/models"
+	"httpcat/internal/storage"
 	"httpcat/internal/storage/auth"
+	"io/fs"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -74,7 +78,7 @@ func NewMCPServer() *MCPServer {
 // GetHandler 获取 Gin 路由处理器（带认证）
 func (m *MCPServer) GetHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 验证 MCP Auth Token（如果配置了）
+		// 验证 MCP Auth Token（如果配置了）；v0.8.0：客户端证书只用于识别调用用户，不能代替 Token
 		if common.McpAuthToken != "" {
 			authHeader := c.GetHeader("Authorization")
 			expectedToken := "Bearer " + common.McpAuthToken
@@ -89,10 +93,44 @@ func (m *MCPServer) GetHandler() gin.HandlerFunc {
 			}
 		}
 
+		// v0.8.0：X-User-Token 携带登录 JWT（或使用客户端证书）时，工具按该用户的主目录 / 共享目录视图解析路径
+		username := tokenUser(c.GetHeader("X-User-Token"))
+		if username == "" {
+			username = midware.ClientCertUser(c.Request)
+		}
+		if username != "" {
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), callerKey{}, username))
+		}
+
 		m.sseServer.ServeHTTP(c.Writer, c.Request)
 	}
 }
 
+// callerKey 请求 context 中保存调用用户名的 key
+type callerKey struct{}
+
+// tokenUser 校验用户 JWT，返回未被禁用的用户名（无效时为空串）
+func tokenUser(token string) string {
+	token = strings.TrimSpace(strings.TrimPrefix(token, "Bearer "))
+	if token == "" {
+		return ""
+	}
+	name, _, err := midware.ParseAccessToken(token)
+	if err != nil {
+		return ""
+	}
+	if user := common.GetUser(name); user == nil || user.Disabled {
+		return ""
+	}
+	return name
+}
+
+// callerScope 调用用户的目录视图，未携带 X-User-Token 时不受限
+func callerScope(ctx context.Context) *storage.UserScope {
+	username, _ := ctx.Value(callerKey{}).(string)
+	return storage.ScopeFor(username)
+}
+
 // ServeHTTP 实现 http.Handler 接口
 func (m *MCPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	m.sseServer.ServeHTTP(w, r)
@@ -161,9 +199,9 @@ func verifyConfirmToken(token, filename string) bool {
 	return true
 }
 
-// validateAndResolvePath 安全地验证和解析路径（防止路径遍历和符号链接攻击）
-func validateAndResolvePath(baseDir, userPath string) (string, error) {
-	return common.ResolvePathWithinBase(baseDir, userPath)
+// validatePath 安全地验证和规范化相对路径（防止路径遍历；符号链接逃逸由存储驱动在访问时拦截）
+func validatePath(userPath string) (string, error) {
+	return storage.CleanPath(userPath)
 }
 
 // registerTools 注册所有 Tools
@@ -376,22 +414,19 @@ func handleListFiles(ctx context.Context, request mcp.CallToolRequest) (*mcp.Cal
 	}
 
 	// 使用安全的路径验证
-	var dirPath string
-	var err error
-	if dir == "" {
-		dirPath = common.GetDownloadDir()
-	} else {
-		dirPath, err = validateAndResolvePath(common.GetDownloadDir(), dir)
-		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Invalid directory path: %v", err)), nil
-		}
+	dirPath, err := validatePath(dir)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid directory path: %v", err)), nil
 	}
 
-	files, err := os.ReadDir(dirPath)
+	files, err := callerScope(ctx).FS(storage.DownloadFS()).List(dirPath)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if storage.IsNotExist(err) {
 			return mcp.NewToolResultError("Directory does not exist"), nil
 		}
+		if errors.Is(err, storage.ErrAccessDenied) {
+			return mcp.NewToolResultError("Permission denied"), nil
+		}
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to read directory: %v", err)), nil
 	}
 
@@ -408,17 +443,13 @@ func handleListFiles(ctx context.Context, request mcp.CallToolRequest) (*mcp.Cal
 		modTime time.Time
 	}
 	var sortableFiles []sortableFile
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-		info, err := file.Info()
-		if err != nil {
+	for _, info := range files {
+		if info.IsDir() {
 			continue
 		}
 		sortableFiles = append(sortableFiles, sortableFile{
 			info: fileInfo{
-				Name:         file.Name(),
+				Name:         info.Name(),
 				Size:         utils.FormatSize(info.Size()),
 				SizeBytes:    info.Size(),
 				LastModified: info.ModTime().Format("2006-01-02 15:04:05"),
@@ -460,23 +491,28 @@ func handleGetFileInfo(ctx context.Context, request mcp.CallToolRequest) (*mcp.C
 	}
 
 	// 使用安全的路径验证
-	filePath, err := validateAndResolvePath(common.GetDownloadDir(), filename)
+	filePath, err := validatePath(filename)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid filename: %v", err)), nil
 	}
 
-	fileInfo, err := os.Stat(filePath)
+	fsys := storage.DownloadFS()
+	fileInfo, err := fsys.Stat(filePath)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if storage.IsNotExist(err) {
 			return mcp.NewToolResultError("File not found"), nil
 		}
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to get file info: %v", err)), nil
 	}
 
-	// 计算 MD5
-	md5Hash, err := utils.CalculateMD5(filePath)
-	if err != nil {
-		md5Hash = "N/A"
+	// 校验和（v0.8.0：优先取自文件索引 / 缓存）
+	md5Hash := "N/A"
+	var sums storage.Checksums
+	if !fileInfo.IsDir() {
+		if s, err := storage.FileChecksums(fsys, filePath, storage.DownloadPath(filePath), fileInfo); err == nil {
+			sums = s
+			md5Hash = s.MD5
+		}
 	}
 
 	type FileDetail struct {
@@ -485,6 +521,8 @@ func handleGetFileInfo(ctx context.Context, request mcp.CallToolRequest) (*mcp.C
 		SizeBytes    int64  `json:"size_bytes"`
 		LastModified string `json:"last_modified"`
 		MD5          string `json:"md5"`
+		SHA256       string `json:"sha256,omitempty"`
+		MimeType     string `json:"mime_type,omitempty"`
 		IsDir        bool   `json:"is_dir"`
 	}
 
@@ -494,6 +532,8 @@ func handleGetFileInfo(ctx context.Context, request mcp.CallToolRequest) (*mcp.C
 		SizeBytes:    fileInfo.Size(),
 		LastModified: fileInfo.ModTime().Format("2006-01-02 15:04:05"),
 		MD5:          md5Hash,
+		SHA256:       sums.SHA256,
+		MimeType:     sums.MimeType,
 		IsDir:        fileInfo.IsDir(),
 	}
 
@@ -607,7 +647,7 @@ func queryUploadHistory(page, pageSize int, filename, fileMD5, ip string) ([]map
 
 // handleGetDiskUsage 处理磁盘使用查询
 func handleGetDiskUsage(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	usage, err := getDiskUsage(common.GetUploadDir())
+	usage, err := getDiskUsage(storage.UploadFS(), common.GetUploadDir())
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to get disk usage: %v", err)), nil
 	}
@@ -626,14 +666,14 @@ func handleRequestDeleteFile(ctx context.Context, request mcp.CallToolRequest) (
 	}
 
 	// 使用安全的路径验证
-	filePath, err := validateAndResolvePath(common.GetDownloadDir(), filename)
+	filePath, err := validatePath(filename)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid filename: %v", err)), nil
 	}
 
 	// 检查文件是否存在
-	fileInfo, err := os.Stat(filePath)
-	if os.IsNotExist(err) {
+	fileInfo, err := storage.DownloadFS().Stat(filePath)
+	if storage.IsNotExist(err) {
 		return mcp.NewToolResultError("File not found"), nil
 	}
 	if err != nil {
@@ -685,18 +725,26 @@ func handleConfirmDeleteFile(ctx context.Context, request mcp.CallToolRequest) (
 	}
 
 	// 使用安全的路径验证
-	filePath, err := validateAndResolvePath(common.GetDownloadDir(), filename)
+	filePath, err := validatePath(filename)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid filename: %v", err)), nil
 	}
 
 	// 再次检查文件是否存在
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+	fsys := storage.DownloadFS()
+	if _, err := fsys.Stat(filePath); storage.IsNotExist(err) {
 		return mcp.NewToolResultError("File not found"), nil
 	}
 
-	// 执行删除
-	if err := os.Remove(filePath); err != nil {
+	// 执行删除（v0.8.0：开启回收站时移入回收站）
+	if storage.TrashEnabled() {
+		if _, err := storage.MoveToTrash(filePath, "mcp", "mcp", ""); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to move file to recycle bin: %v", err)), nil
+		}
+		ylog.Infof("MCP", "File moved to recycle bin via MCP (confirmed): %s", filename)
+		return mcp.NewToolResultText(fmt.Sprintf("File '%s' moved to recycle bin", filename)), nil
+	}
+	if err := fsys.Remove(filePath); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to delete file: %v", err)), nil
 	}
 
@@ -742,7 +790,9 @@ func handleUploadFile(ctx context.Context, request mcp.CallToolRequest) (*mcp.Ca
 		return mcp.NewToolResultError("Invalid filename: hidden files not allowed"), nil
 	}
 
-	// 验证 UploadToken
+	// 验证 UploadToken（v0.8.0：校验通过的 appkey 作为配额归属，凭证中的上传策略约束本次上传）
+	owner := "mcp"
+	var policy *storage.UploadPolicy
 	if common.EnableUploadToken {
 		parts := strings.Split(uploadToken, ":")
 		if len(parts) != 3 {
@@ -750,6 +800,7 @@ func handleUploadFile(ctx context.Context, request mcp.CallToolRequest) (*mcp.Ca
 		}
 
 		appkey := parts[0]
+		owner = appkey
 
 		// 从数据库查询 appsecret
 		db, err := common.GetDB()
@@ -777,28 +828,31 @@ func handleUploadFile(ctx context.Context, request mcp.CallToolRequest) (*mcp.Ca
 		if !mac.VerifyUploadToken(uploadToken) {
 			return mcp.NewToolResultError("Invalid UploadToken"), nil
 		}
+		if policy, err = storage.ParseUploadPolicy(uploadToken); err != nil {
+			return mcp.NewToolResultError("Invalid UploadToken"), nil
+		}
 	}
 
-	// 确保上传目录存在
-	if err := os.MkdirAll(common.GetUploadDir(), 0755); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to create upload directory: %v", err)), nil
+	filePath := filename
+	if err := checkUploadPolicy(policy, storage.UploadFS(), "", filePath, content); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Upload rejected: %v", err)), nil
 	}
 
-	filePath, err := validateAndResolvePath(common.GetUploadDir(), filename)
-	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid filename: %v", err)), nil
+	// v0.8.0：校验用户 / 目录配额
+	if err := storage.CheckQuota(filePath, owner, int64(len(content))); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Upload rejected: %v", err)), nil
 	}
 
-	// 写入文件
-	err = os.WriteFile(filePath, content, 0644)
-	if err != nil {
+	// 写入文件（存储驱动负责创建目录与路径校验）
+	if _, err := storage.UploadFS().Put(filePath, bytes.NewReader(content), int64(len(content))); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to write file: %v", err)), nil
 	}
+	storage.SetFileOwner(filePath, owner)
 
 	// 获取文件信息
-	fileInfo, _ := os.Stat(filePath)
-	fileSize := utils.FormatSize(fileInfo.Size())
-	fileMD5, _ := utils.CalculateMD5(filePath)
+	fileSize := utils.FormatSize(int64(len(content)))
+	md5Sum := md5.Sum(content)
+	fileMD5 := hex.EncodeToString(md5Sum[:])
 
 	// 记录到数据库（如果启用）
 	if common.EnableSqlite {
@@ -839,6 +893,25 @@ func handleUploadFile(ctx context.Context, request mcp.CallToolRequest) (*mcp.Ca
 	return mcp.NewToolResultText(string(data)), nil
 }
 
+// checkUploadPolicy 按上传凭证的上传策略校验（v0.8.0），policy 为 nil 时不限制；dir 为上传目录下的目标目录
+func checkUploadPolicy(policy *storage.UploadPolicy, fsys storage.Driver, dir, name string, content []byte) error {
+	err := policy.CheckDeadline()
+	if err == nil {
+		err = policy.CheckTarget(dir, name)
+	}
+	if err == nil {
+		err = policy.CheckSize(int64(len(content)))
+	}
+	if err == nil {
+		err = policy.CheckMime(http.DetectContentType(content))
+	}
+	if err == nil && policy != nil && policy.InsertOnly != 0 {
+		_, statErr := fsys.Stat(name)
+		err = policy.CheckOverwrite(statErr == nil)
+	}
+	return err
+}
+
 // handleUploadImage 处理图片上传（写入图片管理，生成缩略图）
 func handleUploadImage(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	if !common.FileUploadEnable {
@@ -876,7 +949,9 @@ func handleUploadImage(ctx context.Context, request mcp.CallToolRequest) (*mcp.C
 		return mcp.NewToolResultError("Invalid filename: hidden files not allowed"), nil
 	}
 
-	// 验证 UploadToken
+	// 验证 UploadToken（v0.8.0：校验通过的 appkey 作为配额归属，凭证中的上传策略约束本次上传）
+	owner := "mcp"
+	var policy *storage.UploadPolicy
 	if common.EnableUploadToken {
 		parts := strings.Split(uploadToken, ":")
 		if len(parts) != 3 {
@@ -884,6 +959,7 @@ func handleUploadImage(ctx context.Context, request mcp.CallToolRequest) (*mcp.C
 		}
 
 		appkey := parts[0]
+		owner = appkey
 
 		db, err := common.GetDB()
 		if err != nil {
@@ -909,49 +985,42 @@ func handleUploadImage(ctx context.Context, request mcp.CallToolRequest) (*mcp.C
 		if !mac.VerifyUploadToken(uploadToken) {
 			return mcp.NewToolResultError("Invalid UploadToken"), nil
 		}
+		if policy, err = storage.ParseUploadPolicy(uploadToken); err != nil {
+			return mcp.NewToolResultError("Invalid UploadToken"), nil
+		}
 	}
 
-	imagesDir, err := validateAndResolvePath(common.GetUploadDir(), "images")
-	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid images directory: %v", err)), nil
+	imagesFS := storage.Sub(storage.UploadFS(), imagesDirName)
+	if _, err := imagesFS.Stat(filename); err == nil {
+		return mcp.NewToolResultError("File already exists"), nil
 	}
-	if err := os.MkdirAll(imagesDir, 0755); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to create images directory: %v", err)), nil
+	if err := checkUploadPolicy(policy, imagesFS, imagesDirName, filename, content); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Upload rejected: %v", err)), nil
 	}
 
-	filePath, err := validateAndResolvePath(imagesDir, filename)
-	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid filename: %v", err)), nil
-	}
-	if _, err := os.Stat(filePath); err == nil {
-		return mcp.NewToolResultError("File already exists"), nil
+	// v0.8.0：校验用户 / 目录配额
+	if err := storage.CheckQuota(storage.Join(imagesDirName, filename), owner, int64(len(content))); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Upload rejected: %v", err)), nil
 	}
 
 	// 写入图片文件
-	if err := os.WriteFile(filePath, content, 0644); err != nil {
+	if _, err := imagesFS.Put(filename, bytes.NewReader(content), int64(len(content))); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to write file: %v", err)), nil
 	}
 
 	// 生成缩略图
-	thumbFilePath, err := validateAndResolvePath(imagesDir, "thumb_"+filename)
-	if err != nil {
-		os.Remove(filePath)
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid thumbnail filename: %v", err)), nil
-	}
-	thumbImage, err := imaging.Open(filePath)
-	if err != nil {
-		os.Remove(filePath) // 清理无效文件
+	thumbName := "thumb_" + filename
+	if err := generateThumbnail(imagesFS, filename, thumbName, content); err != nil {
+		_ = imagesFS.Remove(filename) // 清理无效文件
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid image file, failed to parse: %v", err)), nil
 	}
-	thumbImage = imaging.Resize(thumbImage, 250, 150, imaging.Lanczos)
-	if err := imaging.Save(thumbImage, thumbFilePath); err != nil {
-		os.Remove(filePath)
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to generate thumbnail: %v", err)), nil
-	}
+	storage.SetFileOwner(storage.Join(imagesDirName, filename), owner)
+	storage.SetFileOwner(storage.Join(imagesDirName, thumbName), owner)
 
 	// 获取文件信息
-	fileInfo, _ := os.Stat(filePath)
-	fileMD5, _ := utils.CalculateMD5(filePath)
+	fileSize := int64(len(content))
+	md5Sum := md5.Sum(content)
+	fileMD5 := hex.EncodeToString(md5Sum[:])
 	fileUUID := uuid.NewV4().String()
 
 	// 写入 SQLite 数据库
@@ -964,10 +1033,10 @@ func handleUploadImage(ctx context.Context, request mcp.CallToolRequest) (*mcp.C
 			}
 			image := models.UploadImageModel{
 				FileUUID:      fileUUID,
-				Size:          fileInfo.Size(),
+				Size:          fileSize,
 				FileName:      filename,
-				FilePath:      filePath,
-				ThumbFilePath: thumbFilePath,
+				FilePath:      storage.Join(imagesDirName, filename),
+				ThumbFilePath: storage.Join(imagesDirName, thumbName),
 				FileMD5:       fileMD5,
 				DownloadCount: 0,
 				Sort:          1000,
@@ -980,7 +1049,7 @@ func handleUploadImage(ctx context.Context, request mcp.CallToolRequest) (*mcp.C
 		}()
 	}
 
-	ylog.Infof("MCP", "Image uploaded via MCP: %s (%s)", filename, utils.FormatSize(fileInfo.Size()))
+	ylog.Infof("MCP", "Image uploaded via MCP: %s (%s)", filename, utils.FormatSize(fileSize))
 
 	type UploadImageResult struct {
 		FileUUID string `json:"file_uuid"`
@@ -991,10 +1060,11 @@ func handleUploadImage(ctx context.Context, request mcp.CallToolRequest) (*mcp.C
 		ThumbURL string `json:"thumb_url"`
 	}
 
+	// 下载地址在 public_download 开启（默认）时可直接访问，关闭后需附带 token
 	uploadResult := UploadImageResult{
 		FileUUID: fileUUID,
 		Filename: filename,
-		Size:     utils.FormatSize(fileInfo.Size()),
+		Size:     utils.FormatSize(fileSize),
 		MD5:      fileMD5,
 		URL:      "/api/v1/imageManage/download?filename=" + filename,
 		ThumbURL: "/api/v1/imageManage/download?filename=thumb_" + filename,
@@ -1023,12 +1093,11 @@ func handleGetStatistics(ctx context.Context, request mcp.CallToolRequest) (*mcp
 
 	if statsType == "all" || statsType == "upload" {
 		// 获取上传统计
-		uploadDir := common.GetUploadDir()
 		var totalSize int64
 		var fileCount int
 
-		filepath.Walk(uploadDir, func(path string, info os.FileInfo, err error) error {
-			if err == nil && !info.IsDir() {
+		_ = storage.Walk(storage.UploadFS(), "", func(name string, info fs.FileInfo) error {
+			if !info.IsDir() {
 				totalSize += info.Size()
 				fileCount++
 			}
@@ -1042,7 +1111,7 @@ func handleGetStatistics(ctx context.Context, request mcp.CallToolRequest) (*mcp
 	}
 
 	if statsType == "all" || statsType == "disk" {
-		usage, _ := getDiskUsage(common.GetUploadDir())
+		usage, _ := getDiskUsage(storage.UploadFS(), common.GetUploadDir())
 		stats.DiskUsage = usage
 	}
 
@@ -1065,16 +1134,22 @@ func handleVerifyFileMD5(ctx context.Context, request mcp.CallToolRequest) (*mcp
 	}
 
 	// 使用安全的路径验证
-	filePath, err := validateAndResolvePath(common.GetDownloadDir(), filename)
+	filePath, err := validatePath(filename)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid filename: %v", err)), nil
 	}
 
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+	fsys := storage.DownloadFS()
+	info, err := fsys.Stat(filePath)
+	if storage.IsNotExist(err) {
 		return mcp.NewToolResultError("File not found"), nil
 	}
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get file info: %v", err)), nil
+	}
 
-	actualMD5, err := utils.CalculateMD5(filePath)
+	// v0.8.0：文件未变化时直接使用文件索引中的 MD5
+	actualMD5, err := storage.CachedMD5(fsys, filePath, storage.DownloadPath(filePath), info)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to calculate MD5: %v", err)), nil
 	}
@@ -1101,9 +1176,7 @@ func handleVerifyFileMD5(ctx context.Context, request mcp.CallToolRequest) (*mcp
 
 // handleFileListResource 文件列表资源处理器
 func handleFileListResource(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
-	dirPath := common.GetDownloadDir()
-
-	files, err := os.ReadDir(dirPath)
+	files, err := storage.DownloadFS().List("")
 	if err != nil {
 		return nil, err
 	}
@@ -1115,13 +1188,12 @@ func handleFileListResource(ctx context.Context, request mcp.ReadResourceRequest
 	}
 
 	var fileList []fileInfo
-	for _, file := range files {
-		info, err := file.Info()
-		if err != nil || file.IsDir() {
+	for _, info := range files {
+		if info.IsDir() {
 			continue
 		}
 		fileList = append(fileList, fileInfo{
-			Name:         file.Name(),
+			Name:         info.Name(),
 			Size:         utils.FormatSize(info.Size()),
 			LastModified: info.ModTime().Format("2006-01-02 15:04:05"),
 		})
@@ -1139,7 +1211,7 @@ func handleFileListResource(ctx context.Context, request mcp.ReadResourceRequest
 
 // handleDiskUsageResource 磁盘使用资源处理器
 func handleDiskUsageResource(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
-	usage, err := getDiskUsage(common.GetUploadDir())
+	usage, err := getDiskUsage(storage.UploadFS(), common.GetUploadDir())
 	if err != nil {
 		return nil, err
 	}
@@ -1186,13 +1258,13 @@ func handleSystemInfoResource(ctx context.Context, request mcp.ReadResourceReque
 
 // ==================== Helper Functions ====================
 
-// getDiskUsage 获取磁盘使用情况
-func getDiskUsage(path string) (map[string]interface{}, error) {
+// getDiskUsage 获取存储使用情况（path 仅用于展示）
+func getDiskUsage(fsys storage.Driver, path string) (map[string]interface{}, error) {
 	// 这里简化处理，实际应该使用 gopsutil
 	var totalSize, fileCount int64
 
-	err := filepath.Walk(path, func(filepath string, info os.FileInfo, err error) error {
-		if err == nil && !info.IsDir() {
+	err := storage.Walk(fsys, "", func(name string, info fs.FileInfo) error {
+		if !info.IsDir() {
 			totalSize += info.Size()
 			fileCount++
 		}
@@ -1211,6 +1283,29 @@ func getDiskUsage(path string) (map[string]interface{}, error) {
 	}, nil
 }
 
+// imagesDirName 图片在上传目录下的子目录名（与图片管理接口保持一致）
+const imagesDirName = "images"
+
+// generateThumbnail 根据原图内容生成缩略图并写入存储
+func generateThumbnail(fsys storage.Driver, srcName, thumbName string, content []byte) error {
+	format, err := imaging.FormatFromFilename(srcName)
+	if err != nil {
+		return err
+	}
+	img, err := imaging.Decode(bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	img = imaging.Resize(img, 250, 150, imaging.Lanczos)
+
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, img, format); err != nil {
+		return err
+	}
+	_, err = fsys.Put(thumbName, &buf, int64(buf.Len()))
+	return err
+}
+
 // formatBytes 格式化字节数为人类可读格式
 func formatBytes(bytes int64) string {
 	const (
@@ -1253,25 +1348,23 @@ func handleCreateFolder(ctx context.Context, request mcp.CallToolRequest) (*mcp.
 	}
 
 	// 解析父目录
-	basePath := common.GetDownloadDir()
+	basePath := ""
 	if dirVal, ok := args["dir"].(string); ok && dirVal != "" {
-		basePath, err = validateAndResolvePath(common.GetDownloadDir(), dirVal)
+		basePath, err = validatePath(dirVal)
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("Invalid directory path: %v", err)), nil
 		}
 	}
 
-	folderPath, err := validateAndResolvePath(basePath, safeName)
-	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid folder path: %v", err)), nil
-	}
+	folderPath := storage.Join(basePath, safeName)
 
 	// 检查是否已存在
-	if _, err := os.Stat(folderPath); err == nil {
+	fsys := storage.DownloadFS()
+	if _, err := fsys.Stat(folderPath); err == nil {
 		return mcp.NewToolResultError("Folder already exists"), nil
 	}
 
-	if err := os.MkdirAll(folderPath, 0755); err != nil {
+	if err := fsys.Mkdir(folderPath); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to create folder: %v", err)), nil
 	}
 
@@ -1321,34 +1414,29 @@ func handleRenameFile(ctx context.Context, request mcp.CallToolRequest) (*mcp.Ca
 	}
 
 	// 解析目录
-	basePath := common.GetDownloadDir()
+	basePath := ""
 	if dirVal, ok := args["dir"].(string); ok && dirVal != "" {
-		basePath, err = validateAndResolvePath(common.GetDownloadDir(), dirVal)
+		basePath, err = validatePath(dirVal)
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("Invalid directory path: %v", err)), nil
 		}
 	}
 
-	oldPath, err := validateAndResolvePath(basePath, safeOldName)
-	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid old path: %v", err)), nil
-	}
-	newPath, err := validateAndResolvePath(basePath, safeNewName)
-	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid new path: %v", err)), nil
-	}
+	oldPath := storage.Join(basePath, safeOldName)
+	newPath := storage.Join(basePath, safeNewName)
 
 	// 检查源文件是否存在
-	if _, err := os.Stat(oldPath); os.IsNotExist(err) {
+	fsys := storage.DownloadFS()
+	if _, err := fsys.Stat(oldPath); storage.IsNotExist(err) {
 		return mcp.NewToolResultError("Source file not found"), nil
 	}
 
 	// 检查目标是否已存在
-	if _, err := os.Stat(newPath); err == nil {
+	if _, err := fsys.Stat(newPath); err == nil {
 		return mcp.NewToolResultError("Target name already exists"), nil
 	}
 
-	if err := os.Rename(oldPath, newPath); err != nil {
+	if err := fsys.Rename(oldPath, newPath); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to rename: %v", err)), nil
 	}
 
@@ -1390,14 +1478,15 @@ func handleBatchDeleteFiles(ctx context.Context, request mcp.CallToolRequest) (*
 	}
 
 	// 解析目录
-	basePath := common.GetDownloadDir()
+	basePath := ""
 	if dirVal, ok := args["dir"].(string); ok && dirVal != "" {
 		var err error
-		basePath, err = validateAndResolvePath(common.GetDownloadDir(), dirVal)
+		basePath, err = validatePath(dirVal)
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("Invalid directory path: %v", err)), nil
 		}
 	}
+	fsys := storage.DownloadFS()
 
 	type deleteResult struct {
 		Deleted []string            `json:"deleted"`
@@ -1413,15 +1502,12 @@ func handleBatchDeleteFiles(ctx context.Context, request mcp.CallToolRequest) (*
 			continue
 		}
 
-		filePath, err := validateAndResolvePath(basePath, safeName)
+		filePath := storage.Join(basePath, safeName)
+		info, err := fsys.Stat(filePath)
 		if err != nil {
-			result.Failed = append(result.Failed, map[string]string{"file": fileName, "error": "invalid path"})
-			continue
-		}
-
-		info, err := os.Stat(filePath)
-		if err != nil {
-			if os.IsNotExist(err) {
+			if errors.Is(err, storage.ErrInvalidPath) {
+				result.Failed = append(result.Failed, map[string]string{"file": fileName, "error": "invalid path"})
+			} else if storage.IsNotExist(err) {
 				result.Failed = append(result.Failed, map[string]string{"file": fileName, "error": "file not found"})
 			} else {
 				result.Failed = append(result.Failed, map[string]string{"file": fileName, "error": err.Error()})
@@ -1429,14 +1515,20 @@ func handleBatchDeleteFiles(ctx context.Context, request mcp.CallToolRequest) (*
 			continue
 		}
 
-		if info.IsDir() {
+		if storage.TrashEnabled() {
+			// v0.8.0：开启回收站时移入回收站（文件夹无需为空）
+			if _, err := storage.MoveToTrash(filePath, "mcp", "mcp", ""); err != nil {
+				result.Failed = append(result.Failed, map[string]string{"file": fileName, "error": fmt.Sprintf("failed to move to recycle bin: %v", err)})
+				continue
+			}
+		} else if info.IsDir() {
 			// 删除目录需要目录为空
-			if err := os.Remove(filePath); err != nil {
+			if err := fsys.Remove(filePath); err != nil {
 				result.Failed = append(result.Failed, map[string]string{"file": fileName, "error": "directory is not empty or cannot be removed"})
 				continue
 			}
 		} else {
-			if err := os.Remove(filePath); err != nil {
+			if err := fsys.Remove(filePath); err != nil {
 				result.Failed = append(result.Failed, map[string]string{"file": fileName, "error": err.Error()})
 				continue
 			}
@@ -1452,17 +1544,12 @@ func handleBatchDeleteFiles(ctx context.Context, request mcp.CallToolRequest) (*
 
 // handleGetFileOverview 处理文件总览统计
 func handleGetFileOverview(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	baseDir := common.GetDownloadDir()
-
 	var totalFiles int64
 	var totalDirs int64
 	var totalSize int64
 
 	// 递归统计
-	filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // 跳过无法访问的文件
-		}
+	_ = storage.Walk(storage.DownloadFS(), "", func(name string, info fs.FileInfo) error {
 		if info.IsDir() {
 			totalDirs++
 		} else {
@@ -0,0 +1,130 @@
+package midware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+)
+
+// ========== v0.8.0 新增：个人 API Key ==========
+//
+// 用户可自行创建 API Key（t_api_key），以与 Open API 相同的 AK/SK 签名方式（AccessKey / Signature / TimeStamp 头）
+// 调用接口。与 server.http.auth.aksk 中的全局密钥不同，个人 Key 以所属用户的身份访问：
+//   - 权限为用户角色与 Key 授权范围（read / upload / delete / share / admin）的交集，见 RBACAuth；
+//   - 设置了目录限制时只能访问该目录及其子路径（storage.UserScope.Within）；
+//   - 过期、被吊销或所属用户被禁用后立即失效；每次使用记录最近使用时间与来源 IP。
+// 签名校验需要 SecretKey 原文，因此库中保存原文，接口只在创建时返回一次。
+
+// apiKeyContextKey gin.Context 中保存当前请求所用 API Key 的键
+const apiKeyContextKey = "apiKey"
+
+// apiKeyTouchInterval 最近使用时间的最小更新间隔，避免每个请求都写库
+const apiKeyTouchInterval = time.Minute
+
+// GenerateAPIKeyPair 生成一对 AccessKey / SecretKey
+func GenerateAPIKeyPair() (string, string) {
+	ak := make([]byte, 10)
+	sk := make([]byte, 24)
+	_, _ = rand.Read(ak)
+	_, _ = rand.Read(sk)
+	return "hck_" + hex.EncodeToString(ak), hex.EncodeToString(sk)
+}
+
+// lookupAPIKey 查找有效（未吊销、未过期）的个人 API Key，不存在时返回 nil
+func lookupAPIKey(accessKey string) *models.APIKeyModel {
+	if !common.EnableSqlite {
+		return nil
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		return nil
+	}
+	var key models.APIKeyModel
+	if db.Where("access_key = ? AND revoked_at IS NULL", accessKey).Limit(1).Find(&key).RowsAffected == 0 {
+		return nil
+	}
+	if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
+		return nil
+	}
+	return &key
+}
+
+// touchAPIKey 记录 Key 的最近使用时间与来源 IP
+func touchAPIKey(key *models.APIKeyModel, ip string) {
+	now := time.Now()
+	if key.LastUsedAt != nil && now.Sub(*key.LastUsedAt) < apiKeyTouchInterval && key.LastUsedIP == ip {
+		return
+	}
+	go func() {
+		db, err := common.GetDB()
+		if err != nil {
+			return
+		}
+		if err := db.Model(&models.APIKeyModel{}).Where("id = ?", key.ID).
+			Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip}).Error; err != nil {
+			ylog.Errorf("apiKey", "update last used of api key %s failed: %v", key.AccessKey, err)
+		}
+	}()
+}
+
+// APIKeyFromContext 返回当前请求使用的个人 API Key，JWT 或全局 AK/SK 请求返回 nil
+func APIKeyFromContext(c *gin.Context) *models.APIKeyModel {
+	v, ok := c.Get(apiKeyContextKey)
+	if !ok {
+		return nil
+	}
+	key, _ := v.(*models.APIKeyModel)
+	return key
+}
+
+// APIKeyHasScope 判断 Key 是否具备授权范围，scope 为空表示无需授权
+func APIKeyHasScope(key *models.APIKeyModel, scope string) bool {
+	if scope == "" {
+		return true
+	}
+	for _, s := range common.ParseAPIKeyScopes(key.Scopes) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// apiKeyOpenRoutes API Key 无需授权范围即可访问的路由
+var apiKeyOpenRoutes = map[string]bool{
+	"GET /api/v1/conf/getVersion":  true,
+	"GET /api/v1/user/currentUser": true,
+}
+
+// apiKeyDeleteRoutes 需要 delete 授权范围的路由（其余写操作需要 upload）
+var apiKeyDeleteRoutes = map[string]bool{
+	"POST /api/v1/file/delete":          true,
+	"POST /api/v1/trash/purge":          true,
+	"DELETE /api/v1/imageManage/delete": true,
+}
+
+// apiKeyRouteScope 返回 API Key 访问路由所需的授权范围；
+// 账号相关的接口（permAny，如修改密码、会话、SSH 公钥）需要 admin
+func apiKeyRouteScope(method, fullPath string, perm common.Permission) string {
+	route := method + " " + fullPath
+	switch {
+	case apiKeyOpenRoutes[route]:
+		return ""
+	case apiKeyDeleteRoutes[route]:
+		return common.APIKeyScopeDelete
+	case perm == permAny || perm&common.PermAdmin != 0:
+		return common.APIKeyScopeAdmin
+	case perm&common.PermWrite != 0:
+		return common.APIKeyScopeUpload
+	case perm&common.PermShare != 0:
+		return common.APIKeyScopeShare
+	default:
+		return common.APIKeyScopeRead
+	}
+}
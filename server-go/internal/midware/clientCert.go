@@ -0,0 +1,63 @@
+package midware
+
+import (
+	"crypto/x509"
+	"net/http"
+
+	"httpcat/internal/common"
+)
+
+// ========== v0.8.0 新增：客户端证书（mTLS）认证 ==========
+//
+// 开启 server.http.ssl.client_auth 后，HTTPS 握手时以 server.ssl.cafile 校验客户端证书（见 server.clientCertTLSConfig）。
+// 未携带 JWT 或 AK/SK 签名的请求，以证书身份（client_auth.identity 指定的字段）经 client_auth.mapping 映射为用户，
+// 未登记映射的证书默认拒绝，开启 client_auth.passthrough 后才以身份本身作为用户名；
+// 用户须存在且未被禁用，权限仍按其角色校验（RBACAuth）。
+// 适用于 /api/v1 与 WebDAV，机器只需持有证书即可调用，无需登录；MCP 端点仍须 Auth Token，证书只用于识别调用用户。
+
+// ClientCertUser 返回请求中已校验的客户端证书对应的用户；未开启、未提供证书或无法映射到有效用户时返回空串
+func ClientCertUser(r *http.Request) string {
+	if !common.ClientCertEnabled() || r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	for _, identity := range clientCertIdentities(r.TLS.VerifiedChains[0][0]) {
+		username, ok := clientCertMapping(identity)
+		if !ok {
+			continue
+		}
+		if user := common.GetUser(username); user != nil && !user.Disabled {
+			return user.Username
+		}
+	}
+	return ""
+}
+
+// clientCertMapping 返回证书身份映射的用户名；未登记映射且未开启 passthrough 时返回 false
+func clientCertMapping(identity string) (string, bool) {
+	for _, m := range common.ClientCertMappings {
+		if m.Identity == identity {
+			return m.Username, true
+		}
+	}
+	return identity, common.ClientCertPassthrough
+}
+
+// clientCertIdentities 按 client_auth.identity 提取证书身份，SAN 可能有多个，按出现顺序尝试
+func clientCertIdentities(cert *x509.Certificate) []string {
+	var identities []string
+	switch common.ClientCertIdentity {
+	case common.ClientCertIdentityEmail:
+		identities = cert.EmailAddresses
+	case common.ClientCertIdentityDNS:
+		identities = cert.DNSNames
+	case common.ClientCertIdentityURI:
+		for _, u := range cert.URIs {
+			identities = append(identities, u.String())
+		}
+	default:
+		if cert.Subject.CommonName != "" {
+			identities = []string{cert.Subject.CommonName}
+		}
+	}
+	return identities
+}
@@ -0,0 +1,135 @@
+package midware
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"sync"
+	"time"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ========== v0.8.0 新增：WebDAV 认证 ==========
+//
+// 系统自带的 WebDAV 客户端（Windows 资源管理器、macOS Finder、davfs2 等）只支持 Basic 认证，
+// 因此 WebDAV 路由在 JWT / AK-SK（TokenOrAKSKAuth）之外额外接受 Basic（用户名 + 密码）：
+//   1. Basic 认证走 CheckUser，失败计入登录限流，IP 被锁定时直接拒绝
+//   2. 挂载后客户端每个请求都会带上密码，校验成功的凭据缓存一段时间，避免每次都做 bcrypt
+//   3. 未携带任何凭据时返回 401 + WWW-Authenticate，让客户端弹出登录框
+//   4. 客户端无法输入两步验证码，启用了两步验证的用户不能凭密码登录，改用个人 API Key：
+//      用户名填 AccessKey、密码填 SecretKey，权限与目录限制同 AK/SK 签名
+
+const (
+	// davCredentialTTL Basic 凭据校验结果缓存时长
+	davCredentialTTL = 5 * time.Minute
+	davRealm         = `Basic realm="httpcat", charset="UTF-8"`
+)
+
+type davCredential struct {
+	digest     [32]byte // sha256(password)
+	storedHash string   // 校验时用户的密码哈希，修改密码后缓存自动失效
+	expireAt   time.Time
+}
+
+var (
+	davCredentialCache = make(map[string]davCredential)
+	davCredentialLock  sync.Mutex
+)
+
+// checkDavCredential 校验 Basic 凭据（优先命中缓存）
+func checkDavCredential(username, password string) bool {
+	u := common.GetUser(username)
+	if u == nil || u.Disabled {
+		return false
+	}
+	digest := sha256.Sum256([]byte(password))
+
+	davCredentialLock.Lock()
+	cached, ok := davCredentialCache[username]
+	davCredentialLock.Unlock()
+	if ok && cached.digest == digest && cached.storedHash == u.Password && time.Now().Before(cached.expireAt) {
+		return true
+	}
+
+	if _, err := CheckUser(username, password); err != nil {
+		return false
+	}
+	// CheckUser 可能升级了旧版密码哈希，重新读取
+	if u = common.GetUser(username); u == nil {
+		return false
+	}
+
+	davCredentialLock.Lock()
+	now := time.Now()
+	for name, c := range davCredentialCache {
+		if now.After(c.expireAt) {
+			delete(davCredentialCache, name)
+		}
+	}
+	davCredentialCache[username] = davCredential{digest: digest, storedHash: u.Password, expireAt: now.Add(davCredentialTTL)}
+	davCredentialLock.Unlock()
+	return true
+}
+
+func davChallenge(c *gin.Context) {
+	c.Header("WWW-Authenticate", davRealm)
+	c.AbortWithStatus(http.StatusUnauthorized)
+}
+
+// WebDAVAuth WebDAV 认证中间件：Basic 认证，或回退到 TokenOrAKSKAuth
+func WebDAVAuth() gin.HandlerFunc {
+	initLoginRateLimitGC()
+	tokenAuth := TokenOrAKSKAuth()
+	return func(c *gin.Context) {
+		username, password, ok := c.Request.BasicAuth()
+		if !ok {
+			if c.GetHeader("Authorization") == "" && c.GetHeader("AccessKey") == "" && ClientCertUser(c.Request) == "" {
+				davChallenge(c)
+				return
+			}
+			tokenAuth(c)
+			return
+		}
+
+		ip := c.ClientIP()
+		if locked, _ := LoginRateLimitCheck(ip); locked {
+			c.AbortWithStatus(http.StatusTooManyRequests)
+			return
+		}
+		if key := lookupAPIKey(username); key != nil {
+			if !signEqual(key.SecretKey, password) {
+				RecordLoginFailure(ip)
+				ylog.Warnf("WebDAVAuth", "basic auth failed for api key=%s ip=%s", username, ip)
+				davChallenge(c)
+				return
+			}
+			RecordLoginSuccess(ip)
+			c.Set("user", key.Username)
+			c.Set(apiKeyContextKey, key)
+			touchAPIKey(key, ip)
+			c.Next()
+			return
+		}
+		if !checkDavCredential(username, password) {
+			RecordLoginFailure(ip)
+			ylog.Warnf("WebDAVAuth", "basic auth failed for user=%s ip=%s", username, ip)
+			davChallenge(c)
+			return
+		}
+		if common.TwoFactorEnabled(username) {
+			ylog.Warnf("WebDAVAuth", "reject password login of user=%s ip=%s: two-factor authentication is enabled", username, ip)
+			davChallenge(c)
+			return
+		}
+		RecordLoginSuccess(ip)
+
+		if enforcePasswordChange(c, username) {
+			return
+		}
+		c.Set("user", username)
+		c.Next()
+	}
+}
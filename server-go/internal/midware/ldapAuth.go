@@ -0,0 +1,245 @@
+package midware
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+)
+
+// ========== v0.8.0 新增：LDAP / Active Directory 认证 ==========
+//
+// 开启 server.http.auth.ldap 后，CheckUser 对 t_user 中不存在或来源为 ldap 的用户走目录服务校验：
+//   1. 以服务账号（bind_dn，留空为匿名）绑定，按 user_filter 在 base_dn 下搜索唯一的用户条目；
+//   2. 以该条目的 DN 与用户输入的密码重新绑定，成功即认证通过；
+//   3. 用户组取条目的 group_attr（默认 memberOf），设置 group_base_dn 时再按 group_filter 搜索组条目，
+//      组的 DN 与其 CN 均可用于 role_mapping。
+// 首次登录自动创建用户（auth_source 为 ldap，本地不保存密码），之后每次登录同步邮箱与映射到的角色。
+// 本地用户（包括内置管理员）不受影响，目录服务不可用时仍可登录。
+
+// errLDAPInvalidCredentials 用户不存在或密码错误
+var errLDAPInvalidCredentials = errors.New("verify password failed")
+
+// ldapEntry 认证通过的目录用户
+type ldapEntry struct {
+	DN     string
+	Email  string
+	Groups []string // 组 DN 及其 CN
+}
+
+// ldapUseDirectory 判断用户是否应由目录服务认证
+func ldapUseDirectory(u *common.User) bool {
+	return common.LDAPEnable && (u == nil || u.AuthSource == common.AuthSourceLDAP)
+}
+
+// checkLDAPUser 通过目录服务校验用户名密码，成功后创建或同步本地用户；u 为 t_user 中已有的用户（可能为 nil）
+func checkLDAPUser(username, password string, u *common.User) (*common.User, error) {
+	if u != nil && u.Disabled {
+		ylog.Warnf("CheckUser", "user %s is disabled", username)
+		return u, errors.New("user is disabled")
+	}
+	if u == nil && !common.LDAPAutoProvision {
+		ylog.Errorf("CheckUser", "user not found")
+		return nil, errors.New("user not found")
+	}
+	if u == nil && !common.ValidUsername(username) {
+		return nil, errors.New("user not found")
+	}
+	// 空密码在多数目录服务上会被当作匿名绑定而“成功”，必须拒绝
+	if password == "" {
+		return u, errLDAPInvalidCredentials
+	}
+
+	entry, err := ldapAuthenticate(username, password)
+	if err != nil {
+		if errors.Is(err, errLDAPInvalidCredentials) {
+			ylog.Warnf("CheckUser", "ldap authentication failed for user=%s", username)
+		} else {
+			ylog.Errorf("CheckUser", "ldap authentication error for user=%s: %v", username, err)
+		}
+		return u, errLDAPInvalidCredentials
+	}
+	user, err := syncLDAPUser(username, entry, u)
+	if err != nil {
+		ylog.Errorf("CheckUser", "sync ldap user %s failed: %v", username, err)
+		return u, errors.New("verify password failed")
+	}
+	return user, nil
+}
+
+// ldapDial 连接目录服务，按配置使用 LDAPS 或 StartTLS
+func ldapDial() (*ldap.Conn, error) {
+	timeout := time.Duration(common.LDAPTimeout) * time.Second
+	u, err := url.Parse(common.LDAPURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ldap url: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: common.LDAPInsecureSkipVerify,
+	}
+	if common.LDAPCAFile != "" {
+		pem, err := os.ReadFile(common.LDAPCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ldap ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in ldap ca_file %s", common.LDAPCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	conn, err := ldap.DialURL(common.LDAPURL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: timeout}),
+		ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(timeout)
+	if common.LDAPStartTLS && u.Scheme != "ldaps" {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("starttls: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// ldapServiceBind 以服务账号绑定，未配置时匿名绑定
+func ldapServiceBind(conn *ldap.Conn) error {
+	if common.LDAPBindDN == "" {
+		return conn.UnauthenticatedBind("")
+	}
+	return conn.Bind(common.LDAPBindDN, common.LDAPBindPassword)
+}
+
+// ldapAuthenticate 搜索用户条目并以其 DN 绑定校验密码
+func ldapAuthenticate(username, password string) (*ldapEntry, error) {
+	conn, err := ldapDial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := ldapServiceBind(conn); err != nil {
+		return nil, fmt.Errorf("service bind: %w", err)
+	}
+	filter := strings.NewReplacer("{username}", ldap.EscapeFilter(username)).Replace(common.LDAPUserFilter)
+	result, err := conn.Search(ldap.NewSearchRequest(
+		common.LDAPBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, common.LDAPTimeout, false,
+		filter, []string{common.LDAPEmailAttr, common.LDAPGroupAttr}, nil))
+	if err != nil {
+		return nil, fmt.Errorf("search user: %w", err)
+	}
+	if len(result.Entries) != 1 {
+		// 不存在或不唯一都按认证失败处理
+		return nil, errLDAPInvalidCredentials
+	}
+	e := result.Entries[0]
+
+	if err := conn.Bind(e.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, errLDAPInvalidCredentials
+		}
+		return nil, fmt.Errorf("user bind: %w", err)
+	}
+
+	entry := &ldapEntry{DN: e.DN, Email: e.GetAttributeValue(common.LDAPEmailAttr)}
+	groupDNs := e.GetAttributeValues(common.LDAPGroupAttr)
+	if common.LDAPGroupBaseDN != "" {
+		// 用户自身可能无权搜索组，切回服务账号
+		if err := ldapServiceBind(conn); err != nil {
+			return nil, fmt.Errorf("service bind: %w", err)
+		}
+		groupFilter := strings.NewReplacer(
+			"{dn}", ldap.EscapeFilter(e.DN),
+			"{username}", ldap.EscapeFilter(username),
+		).Replace(common.LDAPGroupFilter)
+		groups, err := conn.Search(ldap.NewSearchRequest(
+			common.LDAPGroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, common.LDAPTimeout, false,
+			groupFilter, []string{"cn"}, nil))
+		if err != nil {
+			return nil, fmt.Errorf("search groups: %w", err)
+		}
+		for _, g := range groups.Entries {
+			groupDNs = append(groupDNs, g.DN)
+		}
+	}
+	for _, g := range groupDNs {
+		entry.Groups = append(entry.Groups, g)
+		if dn, err := ldap.ParseDN(g); err == nil && len(dn.RDNs) > 0 && len(dn.RDNs[0].Attributes) > 0 {
+			entry.Groups = append(entry.Groups, dn.RDNs[0].Attributes[0].Value)
+		}
+	}
+	return entry, nil
+}
+
+// syncLDAPUser 创建目录用户或同步已有用户的邮箱、角色，返回刷新后的用户
+func syncLDAPUser(username string, entry *ldapEntry, u *common.User) (*common.User, error) {
+	db, err := common.GetDB()
+	if err != nil {
+		return nil, err
+	}
+	role := common.MapGroupsToRole(common.LDAPRoleMappings, entry.Groups)
+
+	if u != nil {
+		updates := map[string]interface{}{}
+		if entry.Email != "" && entry.Email != u.Email {
+			updates["email"] = entry.Email
+		}
+		if role != "" && role != u.Role {
+			updates["role"] = role
+		}
+		if entry.DN != u.ExternalID {
+			updates["external_id"] = entry.DN
+		}
+		if len(updates) == 0 {
+			return u, nil
+		}
+		if err := db.Model(&common.User{}).Where("id = ?", u.ID).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	} else {
+		if role == "" {
+			role = common.LDAPDefaultRole
+		}
+		// 目录用户不使用本地密码，填入随机哈希
+		secret := make([]byte, 32)
+		_, _ = rand.Read(secret)
+		hashed, err := common.HashPassword(hex.EncodeToString(secret))
+		if err != nil {
+			return nil, err
+		}
+		user := common.User{
+			Username:           username,
+			Email:              entry.Email,
+			Password:           hashed,
+			PasswordUpdateTime: time.Now().Unix(),
+			Role:               role,
+			AuthSource:         common.AuthSourceLDAP,
+			ExternalID:         entry.DN,
+		}
+		if err := db.Create(&user).Error; err != nil {
+			return nil, err
+		}
+		ylog.Infof("CheckUser", "provisioned ldap user %s (%s) with role %s", username, entry.DN, role)
+	}
+	common.ReloadUsers()
+	if user := common.GetUser(username); user != nil {
+		return user, nil
+	}
+	return nil, errors.New("user not found after sync")
+}
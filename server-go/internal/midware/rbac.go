@@ -0,0 +1,261 @@
+package midware
+
+import (
+	"net/http"
+	"strings"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ========== v0.8.0 新增：基于角色的访问控制 ==========
+//
+// RBACAuth 在 TokenOrAKSKAuth 之后执行，按路由模板（c.FullPath()）查 routePermissions 得到所需权限，
+// 当前用户的角色（common/role.go）不具备该权限时返回 403。
+//   - 未登记的路由只允许管理员访问（新增接口默认最严格）；
+//   - Open API（AK/SK，user 为 "openapi:<ak>"）视为管理员；
+//   - 个人 API Key 以所属用户的角色校验，并且 Key 须具备路由对应的授权范围（apiKeyRouteScope）；
+//   - 白名单内的上传接口允许只凭 UploadToken 访问，开启 public_download 时下载接口允许匿名访问，
+//     但携带了有效 JWT 或客户端证书时同样按角色与目录视图校验。
+
+// permAny 任何已登录且未被禁用的用户均可访问
+const permAny common.Permission = 0
+
+// routePermissions "METHOD 路由模板" -> 所需权限
+var routePermissions = map[string]common.Permission{
+	// 系统配置
+	"GET /api/v1/conf/getVersion": permAny,
+	"GET /api/v1/conf/getConf":    common.PermBrowse,
+	"GET /api/v1/conf/sysConfig":  common.PermAdmin,
+	"PUT /api/v1/conf/sysConfig":  common.PermAdmin,
+	"POST /api/v1/conf/restart":   common.PermAdmin,
+
+	// 当前用户
+	"POST /api/v1/user/login/account":          permAny,
+	"POST /api/v1/user/login/2fa":              permAny,
+	"POST /api/v1/user/login/refresh":          permAny,
+	"GET /api/v1/user/oidc/config":             permAny,
+	"GET /api/v1/user/oidc/login":              permAny,
+	"GET /api/v1/user/oidc/callback":           permAny,
+	"GET /api/v1/user/currentUser":             permAny,
+	"POST /api/v1/user/login/outLogin":         permAny,
+	"POST /api/v1/user/changePasswd":           permAny,
+	"POST /api/v1/user/uploadAvatar":           permAny,
+	"GET /api/v1/user/sshKeys":                 permAny,
+	"POST /api/v1/user/sshKeys":                permAny,
+	"DELETE /api/v1/user/sshKeys/:id":          permAny,
+	"GET /api/v1/user/2fa/status":              permAny,
+	"POST /api/v1/user/2fa/enroll":             permAny,
+	"POST /api/v1/user/2fa/activate":           permAny,
+	"POST /api/v1/user/2fa/disable":            permAny,
+	"POST /api/v1/user/2fa/recoveryCodes":      permAny,
+	"GET /api/v1/user/sessions":                permAny,
+	"DELETE /api/v1/user/sessions/:id":         permAny,
+	"POST /api/v1/user/sessions/revokeAll":     permAny,
+	"GET /api/v1/user/apiKeys":                 permAny,
+	"POST /api/v1/user/apiKeys":                permAny,
+	"DELETE /api/v1/user/apiKeys/:id":          permAny,
+	"GET /api/v1/user/dataOverview":            common.PermBrowse,
+	"GET /api/v1/user/getUploadAvailableSpace": common.PermBrowse,
+
+	// 统计
+	"GET /api/v1/statistics/getUploadStatistics":   common.PermBrowse,
+	"GET /api/v1/statistics/getDownloadStatistics": common.PermBrowse,
+	"GET /api/v1/statistics/getFileOverview":       common.PermBrowse,
+	"GET /api/v1/statistics/downloadHistoryLogs":   common.PermBrowse,
+
+	// 文件
+	"GET /api/v1/file/getDirConf":           common.PermBrowse,
+	"GET /api/v1/file/listFiles":            common.PermBrowse,
+	"GET /api/v1/file/getFileInfo":          common.PermBrowse,
+	"GET /api/v1/file/uploadHistoryLogs":    common.PermBrowse,
+	"GET /api/v1/file/previewInfo":          common.PermBrowse,
+	"GET /api/v1/file/versions":             common.PermBrowse,
+	"GET /api/v1/file/download":             common.PermDownload,
+	"GET /api/v1/file/preview":              common.PermDownload,
+	"POST /api/v1/file/downloadZip":         common.PermDownload,
+	"GET /api/v1/file/versions/download":    common.PermDownload,
+	"POST /api/v1/file/presign":             common.PermDownload,
+	"POST /api/v1/file/presignUpload":       common.PermWrite,
+	"POST /api/v1/file/upload":              common.PermWrite,
+	"POST /api/v1/file/delete":              common.PermWrite,
+	"POST /api/v1/file/mkdir":               common.PermWrite,
+	"POST /api/v1/file/rename":              common.PermWrite,
+	"POST /api/v1/file/upload/init":         common.PermWrite,
+	"GET /api/v1/file/upload/status":        common.PermWrite,
+	"POST /api/v1/file/upload/chunk":        common.PermWrite,
+	"POST /api/v1/file/upload/complete":     common.PermWrite,
+	"POST /api/v1/file/upload/abort":        common.PermWrite,
+	"POST /api/v1/file/tus":                 common.PermWrite,
+	"HEAD /api/v1/file/tus/:id":             common.PermWrite,
+	"PATCH /api/v1/file/tus/:id":            common.PermWrite,
+	"DELETE /api/v1/file/tus/:id":           common.PermWrite,
+	"POST /api/v1/file/tus/:id":             common.PermWrite,
+	"POST /api/v1/file/versions/restore":    common.PermWrite,
+	"DELETE /api/v1/file/uploadHistoryLogs": common.PermAdmin,
+
+	// 回收站
+	"GET /api/v1/trash/list":     common.PermBrowse,
+	"POST /api/v1/trash/restore": common.PermWrite,
+	"POST /api/v1/trash/purge":   common.PermWrite,
+
+	// 存储配额
+	"GET /api/v1/quota/usage": common.PermBrowse,
+
+	// 用户主目录与共享目录
+	"GET /api/v1/acl/mine": common.PermBrowse,
+
+	// 图片管理
+	"GET /api/v1/imageManage/listThumbImages": common.PermBrowse,
+	"GET /api/v1/imageManage/download":        common.PermDownload,
+	"POST /api/v1/imageManage/upload":         common.PermWrite,
+	"POST /api/v1/imageManage/rename":         common.PermWrite,
+	"DELETE /api/v1/imageManage/delete":       common.PermWrite,
+
+	// 分享管理
+	"POST /api/v1/share":         common.PermShare,
+	"GET /api/v1/share/list":     common.PermShare,
+	"DELETE /api/v1/share/:code": common.PermShare,
+	"GET /api/v1/share/stats":    common.PermShare,
+	"GET /api/v1/share/config":   common.PermShare,
+}
+
+// RoutePermission 返回路由所需权限，未登记的路由需要管理员权限
+func RoutePermission(method, fullPath string) common.Permission {
+	if perm, ok := routePermissions[method+" "+fullPath]; ok {
+		return perm
+	}
+	return common.PermAdmin
+}
+
+// abortPermissionDenied 返回 403
+func abortPermissionDenied(c *gin.Context, msg string) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+		"errorCode": common.PermissionDenied,
+		"msg":       msg,
+		"data":      nil,
+	})
+}
+
+// bearerUser 校验 Authorization 头中的 JWT，返回用户名（无效时为空串）
+func bearerUser(c *gin.Context) string {
+	token := c.GetHeader("Authorization")
+	if len(token) > 7 && strings.ToUpper(token[0:7]) == "BEARER " {
+		token = token[7:]
+	}
+	if token == "" || strings.HasPrefix(token, "seesion-") {
+		return ""
+	}
+	name, sessionID, err := ParseAccessToken(token)
+	if err != nil {
+		return ""
+	}
+	c.Set("session", sessionID)
+	return name
+}
+
+// RBACAuth 角色权限校验中间件
+func RBACAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := c.GetString("user")
+		if username == "" && isWhiteListed(c.Request.URL.Path) {
+			// 白名单接口：未携带有效 JWT 或客户端证书时由 handler 校验 UploadToken
+			if username = bearerUser(c); username == "" {
+				username = ClientCertUser(c.Request)
+			}
+			if username == "" {
+				c.Next()
+				return
+			}
+			c.Set("user", username)
+		}
+
+		// Open API（AK/SK）拥有全部权限
+		if strings.HasPrefix(username, "openapi:") {
+			c.Next()
+			return
+		}
+
+		user := common.GetUser(username)
+		if user == nil || user.Disabled {
+			ylog.Warnf("RBACAuth", "reject %s %s: user %q not found or disabled", c.Request.Method, c.Request.URL.Path, username)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		perm := RoutePermission(c.Request.Method, c.FullPath())
+		if !user.Can(perm) {
+			ylog.Warnf("RBACAuth", "reject %s %s: role %q of user %s lacks permission", c.Request.Method, c.Request.URL.Path, user.Role, username)
+			abortPermissionDenied(c, "permission denied for role "+user.Role)
+			return
+		}
+		if key := APIKeyFromContext(c); key != nil {
+			if scope := apiKeyRouteScope(c.Request.Method, c.FullPath(), perm); !APIKeyHasScope(key, scope) {
+				ylog.Warnf("RBACAuth", "reject %s %s: api key %s of user %s lacks scope %q", c.Request.Method, c.Request.URL.Path, key.AccessKey, username, scope)
+				abortPermissionDenied(c, "permission denied: api key lacks scope "+scope)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// davMethodPermission WebDAV 方法所需权限
+func davMethodPermission(method string) common.Permission {
+	switch method {
+	case http.MethodOptions:
+		return permAny
+	case http.MethodGet, http.MethodHead:
+		return common.PermDownload
+	case "PROPFIND":
+		return common.PermBrowse
+	default:
+		// PUT、DELETE、MKCOL、COPY、MOVE、PROPPATCH、LOCK、UNLOCK
+		return common.PermWrite
+	}
+}
+
+// davMethodScope WebDAV 方法所需的 API Key 授权范围
+func davMethodScope(method string) string {
+	switch method {
+	case http.MethodOptions:
+		return ""
+	case http.MethodGet, http.MethodHead, "PROPFIND":
+		return common.APIKeyScopeRead
+	case http.MethodDelete:
+		return common.APIKeyScopeDelete
+	default:
+		return common.APIKeyScopeUpload
+	}
+}
+
+// WebDAVRBAC WebDAV 的角色权限校验，在 WebDAVAuth 之后执行
+func WebDAVRBAC() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := c.GetString("user")
+		if strings.HasPrefix(username, "openapi:") {
+			c.Next()
+			return
+		}
+		user := common.GetUser(username)
+		if user == nil || user.Disabled {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		if !user.Can(davMethodPermission(c.Request.Method)) {
+			ylog.Warnf("WebDAVRBAC", "reject %s %s: role %q of user %s lacks permission", c.Request.Method, c.Request.URL.Path, user.Role, username)
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		if key := APIKeyFromContext(c); key != nil {
+			if scope := davMethodScope(c.Request.Method); !APIKeyHasScope(key, scope) {
+				ylog.Warnf("WebDAVRBAC", "reject %s %s: api key %s of user %s lacks scope %q", c.Request.Method, c.Request.URL.Path, key.AccessKey, username, scope)
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,300 @@
+package midware
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rs/xid"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+)
+
+// ========== v0.8.0 新增：登录会话 ==========
+//
+// 每次登录（账号密码、两步验证、单点登录）在 t_session 中创建一个会话，返回一对令牌：
+//   - access token：JWT，携带 sid，有效期 server.http.auth.session.access_token_ttl（分钟）；
+//   - refresh token：随机串，库中只保存 SHA-256，有效期 refresh_token_ttl（小时），每次续期都会轮换。
+// 校验 access token 时同时确认其会话未被吊销，因此登出、修改 / 重置密码、禁用或删除用户后令牌立即失效。
+// 会话状态缓存在内存中，吊销时同步更新，只有缓存未命中时查询数据库。
+
+// ErrSessionRevoked 会话不存在、已过期或已被吊销
+var ErrSessionRevoked = errors.New("session revoked or expired")
+
+// SessionTokens 登录 / 续期返回的令牌
+type SessionTokens struct {
+	SessionID    string
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64 // access token 剩余有效期，秒
+}
+
+// sessionState 缓存的会话状态
+type sessionState struct {
+	username  string
+	revoked   bool
+	expiresAt time.Time
+}
+
+var (
+	sessionMu        sync.Mutex
+	sessionCache     = make(map[string]*sessionState)
+	sessionLastPurge time.Time
+)
+
+// tokenSecret JWT 签名密钥（server.http.jwt_secret）
+func tokenSecret() []byte {
+	return []byte(common.JwtSecret)
+}
+
+// hashRefreshToken refresh token 的存储形式
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newRefreshToken 生成 refresh token
+func newRefreshToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func accessTTL() time.Duration {
+	return time.Duration(common.SessionAccessTTLMinutes) * time.Minute
+}
+
+func refreshTTL() time.Duration {
+	return time.Duration(common.SessionRefreshTTLHours) * time.Hour
+}
+
+// IssueSession 为登录成功的用户创建会话并签发令牌
+func IssueSession(username, ip, userAgent string) (*SessionTokens, error) {
+	db, err := common.GetDB()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	refresh := newRefreshToken()
+	session := models.SessionModel{
+		SessionID:    xid.New().String(),
+		Username:     username,
+		RefreshHash:  hashRefreshToken(refresh),
+		IP:           ip,
+		UserAgent:    truncate(userAgent, 512),
+		CreatedAt:    now,
+		LastActiveAt: now,
+		ExpiresAt:    now.Add(refreshTTL()),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return nil, err
+	}
+	access, err := GeneralJwtToken(username, session.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionMu.Lock()
+	sessionCache[session.SessionID] = &sessionState{username: username, expiresAt: session.ExpiresAt}
+	sessionMu.Unlock()
+	purgeSessions()
+
+	return &SessionTokens{
+		SessionID:    session.SessionID,
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(accessTTL().Seconds()),
+	}, nil
+}
+
+// RefreshSession 凭 refresh token 续期，轮换 refresh token 并签发新的 access token，返回会话所属用户
+func RefreshSession(refreshToken, ip, userAgent string) (*SessionTokens, string, error) {
+	if refreshToken == "" {
+		return nil, "", ErrSessionRevoked
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	var session models.SessionModel
+	if db.Where("refresh_hash = ? AND revoked_at IS NULL", hashRefreshToken(refreshToken)).
+		Limit(1).Find(&session).RowsAffected == 0 || now.After(session.ExpiresAt) {
+		return nil, "", ErrSessionRevoked
+	}
+	if user := common.GetUser(session.Username); user == nil || user.Disabled {
+		_ = RevokeSession(session.SessionID)
+		return nil, "", ErrSessionRevoked
+	}
+
+	refresh := newRefreshToken()
+	expiresAt := now.Add(refreshTTL())
+	// 以旧哈希为条件更新，并发续期时只有一个成功
+	result := db.Model(&models.SessionModel{}).
+		Where("id = ? AND refresh_hash = ?", session.ID, session.RefreshHash).
+		Updates(map[string]interface{}{
+			"refresh_hash":   hashRefreshToken(refresh),
+			"ip":             ip,
+			"user_agent":     truncate(userAgent, 512),
+			"last_active_at": now,
+			"expires_at":     expiresAt,
+		})
+	if result.Error != nil {
+		return nil, "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, "", ErrSessionRevoked
+	}
+	access, err := GeneralJwtToken(session.Username, session.SessionID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sessionMu.Lock()
+	sessionCache[session.SessionID] = &sessionState{username: session.Username, expiresAt: expiresAt}
+	sessionMu.Unlock()
+
+	return &SessionTokens{
+		SessionID:    session.SessionID,
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(accessTTL().Seconds()),
+	}, session.Username, nil
+}
+
+// ParseAccessToken 校验 access token 的签名、有效期与会话状态，返回用户名与会话 ID
+func ParseAccessToken(token string) (string, string, error) {
+	payload, err := VerifyToken(token, tokenSecret())
+	if err != nil {
+		return "", "", err
+	}
+	username, _ := (*payload)["username"].(string)
+	sessionID, _ := (*payload)["sid"].(string)
+	if username == "" || sessionID == "" {
+		// 升级前签发的令牌没有会话，需重新登录
+		return "", "", ErrSessionRevoked
+	}
+	if !sessionActive(sessionID, username) {
+		return "", "", ErrSessionRevoked
+	}
+	return username, sessionID, nil
+}
+
+// sessionActive 判断会话是否有效，缓存未命中时查询数据库
+func sessionActive(sessionID, username string) bool {
+	sessionMu.Lock()
+	state, ok := sessionCache[sessionID]
+	sessionMu.Unlock()
+	if !ok {
+		db, err := common.GetDB()
+		if err != nil {
+			return false
+		}
+		var session models.SessionModel
+		if db.Where("session_id = ?", sessionID).Limit(1).Find(&session).RowsAffected == 0 {
+			return false
+		}
+		state = &sessionState{
+			username:  session.Username,
+			revoked:   session.RevokedAt != nil,
+			expiresAt: session.ExpiresAt,
+		}
+		sessionMu.Lock()
+		sessionCache[sessionID] = state
+		sessionMu.Unlock()
+	}
+	return !state.revoked && state.username == username && time.Now().Before(state.expiresAt)
+}
+
+// RevokeSession 吊销指定会话
+func RevokeSession(sessionID string) error {
+	db, err := common.GetDB()
+	if err != nil {
+		return err
+	}
+	if err := db.Model(&models.SessionModel{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+	sessionMu.Lock()
+	if state, ok := sessionCache[sessionID]; ok {
+		state.revoked = true
+	}
+	sessionMu.Unlock()
+	return nil
+}
+
+// RevokeUserSessions 吊销用户的全部会话（exceptSessionID 除外，通常为当前会话），返回吊销的数量
+func RevokeUserSessions(username, exceptSessionID string) (int64, error) {
+	db, err := common.GetDB()
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	query := db.Model(&models.SessionModel{}).Where("username = ? AND revoked_at IS NULL", username)
+	if exceptSessionID != "" {
+		query = query.Where("session_id <> ?", exceptSessionID)
+	}
+	if err := query.Pluck("session_id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if err := db.Model(&models.SessionModel{}).
+		Where("session_id IN ?", ids).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return 0, err
+	}
+	sessionMu.Lock()
+	for _, id := range ids {
+		if state, ok := sessionCache[id]; ok {
+			state.revoked = true
+		}
+	}
+	sessionMu.Unlock()
+	ylog.Infof("session", "revoked %d session(s) of user %s", len(ids), username)
+	return int64(len(ids)), nil
+}
+
+// purgeSessions 每小时清理一次过期或已吊销超过一天的会话
+func purgeSessions() {
+	sessionMu.Lock()
+	now := time.Now()
+	if now.Sub(sessionLastPurge) < time.Hour {
+		sessionMu.Unlock()
+		return
+	}
+	sessionLastPurge = now
+	for id, state := range sessionCache {
+		if state.revoked || now.After(state.expiresAt) {
+			delete(sessionCache, id)
+		}
+	}
+	sessionMu.Unlock()
+
+	db, err := common.GetDB()
+	if err != nil {
+		return
+	}
+	result := db.Where("expires_at < ? OR revoked_at < ?", now, now.Add(-24*time.Hour)).Delete(&models.SessionModel{})
+	if result.Error != nil {
+		ylog.Errorf("session", "purge sessions failed: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		ylog.Infof("session", "purged %d expired session(s)", result.RowsAffected)
+	}
+}
+
+// truncate 截断过长的字符串
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -20,11 +20,15 @@ import (
 )
 
 var whiteUrlList = []string{
-	"/api/v1/file/upload",          //上传文件，我们需要开放出来，使用ak、sk方式生成专门的上传token，不支持界面操作上传
-	"/api/v1/file/download",        // 下载文件，我们需要开放出来
-	"/api/v1/imageManage/upload",   // 上传图片，使用 UploadToken 校验
-	"/api/v1/imageManage/download", // 下载图片
+	"/api/v1/file/upload",        //上传文件，我们需要开放出来，使用ak、sk方式生成专门的上传token，不支持界面操作上传
+	"/api/v1/imageManage/upload", // 上传图片，使用 UploadToken 校验
 	"/api/v1/user/login/account",
+	"/api/v1/user/login/2fa",     // v0.8.0: 两步验证（凭 twoFactorToken）
+	"/api/v1/user/login/refresh", // v0.8.0: 续期（凭 refresh token）
+	// v0.8.0: OpenID Connect 单点登录
+	"/api/v1/user/oidc/config",
+	"/api/v1/user/oidc/login",
+	"/api/v1/user/oidc/callback",
 	// v0.7.0: 分片上传相关接口（使用 UploadToken 在 handler 内部校验，和 /upload 保持一致）
 	"/api/v1/file/upload/init",
 	"/api/v1/file/upload/status",
@@ -33,22 +37,32 @@ var whiteUrlList = []string{
 	"/api/v1/file/upload/abort",
 }
 
+// publicDownloadUrlList 下载接口（v0.8.0）：server.http.file.public_download 开启（默认，与旧版一致）时免认证；
+// 关闭后须登录，不带 token 不能绕过角色与主目录限制，对外公开的下载改用分享链接或预签名链接
+var publicDownloadUrlList = []string{
+	"/api/v1/file/download",
+	"/api/v1/imageManage/download",
+}
+
+// isWhiteListed 路径是否免认证
+func isWhiteListed(path string) bool {
+	return utils.Contains(whiteUrlList, path) || (common.FilePublicDownload && utils.Contains(publicDownloadUrlList, path))
+}
+
 // queryTokenUrlList 允许从 URL query 参数获取 token 的路径（v0.6.0：用于文件预览 img/video/audio 标签）
+// v0.8.0: 浏览器直接打开的下载链接同样可经 query 携带 token
 var queryTokenUrlList = []string{
 	"/api/v1/file/preview",
+	"/api/v1/file/download",
+	"/api/v1/imageManage/download",
 }
 
 type AuthClaims struct {
-	Username string `json:"username"`
+	Username  string `json:"username"`
+	SessionID string `json:"sid"` // v0.8.0: 所属会话（t_session），会话吊销后令牌失效
 	jwt.StandardClaims
 }
 
-const (
-	JWTExpireMinute = 60
-)
-
-var APITokenSecret = []byte(common.JwtSecret)
-
 func CreateToken(payload jwt.Claims, secret []byte) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
 
@@ -91,10 +105,25 @@ func VerifyToken(tokenString string, secret []byte) (*jwt.MapClaims, error) {
 
 func CheckUser(username, password string) (*common.User, error) {
 	u := common.GetUser(username)
+	if ldapUseDirectory(u) {
+		return checkLDAPUser(username, password, u)
+	}
 	if u == nil {
 		ylog.Errorf("CheckUser", "user not found")
 		return nil, errors.New("user not found")
 	}
+	if u.Disabled {
+		ylog.Warnf("CheckUser", "user %s is disabled", username)
+		return u, errors.New("user is disabled")
+	}
+	if u.AuthSource == common.AuthSourceOIDC {
+		// 单点登录用户没有本地密码
+		return u, errors.New("please sign in with single sign-on")
+	}
+	if u.AuthSource == common.AuthSourceLDAP {
+		// 目录用户没有本地密码，关闭 LDAP 后无法登录
+		return u, errors.New("ldap authentication is not enabled")
+	}
 
 	valid, legacyHash, err := common.VerifyPassword(u, password)
 	if err != nil {
@@ -143,13 +172,15 @@ func enforcePasswordChange(c *gin.Context, userName string) bool {
 	return true
 }
 
-func GeneralJwtToken(userName string) (string, error) {
+// GeneralJwtToken 签发会话 sessionID 的 access token，应通过 IssueSession / RefreshSession 调用
+func GeneralJwtToken(userName, sessionID string) (string, error) {
 	return CreateToken(AuthClaims{
-		Username: userName,
+		Username:  userName,
+		SessionID: sessionID,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(JWTExpireMinute * time.Minute).Unix(),
+			ExpiresAt: time.Now().Add(accessTTL()).Unix(),
 		},
-	}, APITokenSecret)
+	}, tokenSecret())
 }
 
 func GeneralSession() string {
@@ -166,7 +197,7 @@ func TokenAuth() gin.HandlerFunc {
 		//}
 
 		//url_whitelist
-		if utils.Contains(whiteUrlList, c.Request.URL.Path) {
+		if isWhiteListed(c.Request.URL.Path) {
 			c.Next()
 			return
 		}
@@ -204,25 +235,15 @@ func TokenAuth() gin.HandlerFunc {
 			//	ylog.Errorf("TokenAuth", "Expire error %s", err.Error())
 			//}
 		} else { // 否则使用 JWT 验证 Token 的有效性，并从负载中获取用户名信息
-			//jwt
-			payload, err := VerifyToken(token, APITokenSecret)
+			//jwt（v0.8.0: 同时校验会话未被吊销）
+			currentUser, sessionID, err := ParseAccessToken(token)
 			if err != nil {
 				ylog.Errorf("AuthRequired", err.Error())
 				c.AbortWithStatus(http.StatusUnauthorized)
 				return
 			}
-
-			if payload == nil {
-				c.AbortWithStatus(http.StatusUnauthorized)
-				return
-			}
-
-			currentUser, ok := (*payload)["username"]
-			if currentUser == "" || !ok {
-				c.AbortWithStatus(http.StatusUnauthorized)
-				return
-			}
-			userName = currentUser.(string)
+			userName = currentUser
+			c.Set("session", sessionID)
 		}
 
 		if enforcePasswordChange(c, userName) {
@@ -247,6 +268,20 @@ func tryAKSKAuth(c *gin.Context) bool {
 		return false
 	}
 
+	// v0.8.0 优先匹配个人 API Key（不受 open_api_enable 限制），其次为配置文件中的全局 AK/SK
+	user := fmt.Sprintf("openapi:%s", akHeader)
+	apiKey := lookupAPIKey(akHeader)
+	var sk string
+	if apiKey != nil {
+		sk = apiKey.SecretKey
+		user = apiKey.Username
+	} else if !common.OpenAPIEnable {
+		// 未开启 Open API 时全局 AK/SK 不可用，回退到 JWT
+		return false
+	} else {
+		sk = getSecKey(akHeader)
+	}
+
 	// 校验时间戳
 	iTime, err := strconv.ParseInt(timeStamp, 10, 64)
 	if err != nil {
@@ -259,8 +294,6 @@ func tryAKSKAuth(c *gin.Context) bool {
 		return true
 	}
 
-	// 校验签名
-	sk := getSecKey(akHeader)
 	if sk == "" {
 		abort(c, "invalid AccessKey")
 		return true
@@ -281,8 +314,12 @@ func tryAKSKAuth(c *gin.Context) bool {
 		return true
 	}
 
-	// AK/SK 认证成功，设置用户为 ak 标识
-	c.Set("user", fmt.Sprintf("openapi:%s", akHeader))
+	// AK/SK 认证成功：全局密钥的用户为 ak 标识，个人 API Key 为所属用户（权限由 RBACAuth 校验）
+	c.Set("user", user)
+	if apiKey != nil {
+		c.Set(apiKeyContextKey, apiKey)
+		touchAPIKey(apiKey, c.ClientIP())
+	}
 	c.Next()
 	return true
 }
@@ -290,17 +327,18 @@ func tryAKSKAuth(c *gin.Context) bool {
 // TokenOrAKSKAuth 合并认证中间件：先尝试 AK/SK，不满足则走 JWT
 func TokenOrAKSKAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 白名单豁免
-		if utils.Contains(whiteUrlList, c.Request.URL.Path) {
+		// 白名单豁免；v0.8.0: 携带 AK/SK 签名时仍校验，使个人 API Key 的授权范围与目录限制生效
+		if isWhiteListed(c.Request.URL.Path) {
+			if c.GetHeader("AccessKey") != "" && tryAKSKAuth(c) {
+				return
+			}
 			c.Next()
 			return
 		}
 
-		// 如果开启了 Open API，优先尝试 AK/SK 认证
-		if common.OpenAPIEnable {
-			if tryAKSKAuth(c) {
-				return // AK/SK 已处理（成功或失败都已响应）
-			}
+		// 优先尝试 AK/SK 认证（个人 API Key 始终可用，全局 AK/SK 需开启 Open API）
+		if tryAKSKAuth(c) {
+			return // AK/SK 已处理（成功或失败都已响应）
 		}
 
 		// 回退到 JWT Token 认证
@@ -312,6 +350,12 @@ func TokenOrAKSKAuth() gin.HandlerFunc {
 			}
 		}
 		if token == "" {
+			// v0.8.0: 未携带 JWT 时使用已校验的客户端证书
+			if certUser := ClientCertUser(c.Request); certUser != "" {
+				c.Set("user", certUser)
+				c.Next()
+				return
+			}
 			ylog.Errorf("AuthRequired", "token is empty")
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
@@ -325,22 +369,14 @@ func TokenOrAKSKAuth() gin.HandlerFunc {
 		if strings.HasPrefix(token, "seesion-") {
 			// session token (Redis, 当前已注释)
 		} else {
-			payload, err := VerifyToken(token, APITokenSecret)
+			currentUser, sessionID, err := ParseAccessToken(token)
 			if err != nil {
 				ylog.Errorf("AuthRequired", err.Error())
 				c.AbortWithStatus(http.StatusUnauthorized)
 				return
 			}
-			if payload == nil {
-				c.AbortWithStatus(http.StatusUnauthorized)
-				return
-			}
-			currentUser, ok := (*payload)["username"]
-			if currentUser == "" || !ok {
-				c.AbortWithStatus(http.StatusUnauthorized)
-				return
-			}
-			userName = currentUser.(string)
+			userName = currentUser
+			c.Set("session", sessionID)
 		}
 
 		if enforcePasswordChange(c, userName) {
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// APIKeyModel 用户个人 API Key（v0.8.0 新增）
+// 以 AK/SK 签名认证（与 Open API 相同的签名算法），以所属用户的身份访问接口，
+// 权限为用户角色与 Key 授权范围（scopes）的交集，可限制在某个目录下。
+type APIKeyModel struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	AccessKey  string     `gorm:"column:access_key;not null;uniqueIndex" json:"accessKey"`
+	SecretKey  string     `gorm:"column:secret_key;not null" json:"-"` // 签名校验需要原文，只在创建时返回一次
+	Username   string     `gorm:"column:username;not null;index" json:"username"`
+	Name       string     `gorm:"column:name" json:"name"`
+	Scopes     string     `gorm:"column:scopes;not null" json:"-"`    // 逗号分隔：read,upload,delete,share,admin
+	PathPrefix string     `gorm:"column:path_prefix" json:"path"`     // 限制访问的目录（所属用户视图内的路径），为空不限制
+	ExpiresAt  *time.Time `gorm:"column:expires_at" json:"expiresAt"` // 为空永不过期
+	LastUsedAt *time.Time `gorm:"column:last_used_at" json:"lastUsedAt"`
+	LastUsedIP string     `gorm:"column:last_used_ip" json:"lastUsedIp"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at" json:"revokedAt,omitempty"`
+	CreatedAt  time.Time  `gorm:"column:created_at" json:"createdAt"`
+}
+
+func (APIKeyModel) TableName() string {
+	return "t_api_key"
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// BlobModel 内容寻址 blob 表（v0.8.0 新增，去重存储）
+// 相同内容（SHA-256 相同）的文件在磁盘上只保存一份，RefCount 为引用它的文件数。
+type BlobModel struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Hash      string    `gorm:"column:hash;not null;uniqueIndex" json:"hash"` // 内容 SHA-256（十六进制小写）
+	MD5       string    `gorm:"column:md5;index" json:"md5"`                  // 内容 MD5
+	Size      int64     `gorm:"column:size" json:"size"`
+	RefCount  int64     `gorm:"column:ref_count" json:"refCount"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"column:updated_at" json:"updatedAt"`
+}
+
+func (BlobModel) TableName() string {
+	return "t_blob"
+}
+
+// BlobRefModel 文件路径到 blob 的引用（v0.8.0 新增）
+type BlobRefModel struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Path      string    `gorm:"column:path;not null;uniqueIndex" json:"path"` // 相对于 base_dir 的存储路径
+	Hash      string    `gorm:"column:hash;not null;index" json:"hash"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"createdAt"`
+}
+
+func (BlobRefModel) TableName() string {
+	return "t_blob_ref"
+}
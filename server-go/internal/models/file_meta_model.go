@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// FileMetaModel 文件索引（v0.8.0 新增）
+// 由存储变更事件增量维护，并定期与实际存储对账；用于配额用量统计、文件校验和等无需遍历目录树或重读文件的查询。
+type FileMetaModel struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Path      string    `gorm:"column:path;not null;uniqueIndex" json:"path"` // 相对于存储根目录（base_dir）的路径
+	Size      int64     `gorm:"column:size" json:"size"`
+	Owner     string    `gorm:"column:owner;index" json:"owner"` // 上传者（用户名或 appkey），未知时为空
+	ModTime   time.Time `gorm:"column:mod_time" json:"modTime"`
+	MD5       string    `gorm:"column:md5;size:32;index" json:"md5"`       // 内容 MD5，未计算或文件变化后为空
+	SHA256    string    `gorm:"column:sha256;size:64;index" json:"sha256"` // 内容 SHA-256，同上
+	MimeType  string    `gorm:"column:mime_type;size:128" json:"mimeType"` // 按文件内容识别的 MIME 类型，同上
+	UpdatedAt time.Time `gorm:"column:updated_at" json:"updatedAt"`
+}
+
+func (FileMetaModel) TableName() string {
+	return "t_file_meta"
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// FileVersionModel 文件历史版本表（v0.8.0 新增）
+// 上传覆盖已有文件前，旧内容被拷贝到 base_dir/.versions/<version_id>（本地驱动为硬链接，不额外占用空间）。
+type FileVersionModel struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	VersionID   string    `gorm:"column:version_id;not null;uniqueIndex" json:"versionId"`
+	Path        string    `gorm:"column:path;not null;index" json:"path"` // 相对于上传目录的文件路径
+	StoragePath string    `gorm:"column:storage_path;not null" json:"-"`  // 版本内容在全局驱动中的路径
+	Size        int64     `gorm:"column:size" json:"size"`
+	MD5         string    `gorm:"column:md5" json:"md5"`
+	ModTime     time.Time `gorm:"column:mod_time" json:"modTime"`  // 被覆盖前文件的修改时间
+	Operator    string    `gorm:"column:operator" json:"operator"` // 触发覆盖（或恢复）的用户 / appkey
+	Reason      string    `gorm:"column:reason" json:"reason"`     // upload / chunk_upload / tus_upload / restore
+	CreatedAt   time.Time `gorm:"column:created_at;index" json:"createdAt"`
+}
+
+func (FileVersionModel) TableName() string {
+	return "t_file_version"
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// 共享目录授权对象类型
+const (
+	ACLGranteeUser  = "user"  // 按用户名授权
+	ACLGranteeGroup = "group" // 按用户组（t_user.group）授权
+)
+
+// 共享目录授权权限
+const (
+	ACLPermRead      = "read" // 只读：浏览、下载、预览、分享
+	ACLPermReadWrite = "rw"   // 读写：另可上传、新建、重命名、删除
+)
+
+// PathACLModel 共享目录授权（v0.8.0 新增）
+type PathACLModel struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Path        string    `gorm:"column:path;not null;uniqueIndex:idx_acl_path_grantee" json:"path"` // 相对于文件管理目录的共享目录
+	GranteeType string    `gorm:"column:grantee_type;not null;uniqueIndex:idx_acl_path_grantee" json:"granteeType"`
+	Grantee     string    `gorm:"column:grantee;not null;uniqueIndex:idx_acl_path_grantee" json:"grantee"`
+	Permission  string    `gorm:"column:permission;not null" json:"permission"`
+	CreatedBy   string    `gorm:"column:created_by" json:"createdBy"`
+	CreatedAt   time.Time `gorm:"column:created_at" json:"createdAt"`
+	UpdatedAt   time.Time `gorm:"column:updated_at" json:"updatedAt"`
+}
+
+func (PathACLModel) TableName() string {
+	return "t_path_acl"
+}
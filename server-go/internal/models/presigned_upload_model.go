@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// PresignedUploadModel 已使用的预签名上传链接（v0.8.0 新增）
+// 链接本身无状态，第一次上传（单次上传或分片上传 init）时按签名中的随机串登记，
+// 同一链接再次上传即被拒绝；分片上传的后续请求只能操作登记的会话。
+type PresignedUploadModel struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Nonce     string    `gorm:"column:nonce;not null;uniqueIndex" json:"-"`
+	Username  string    `gorm:"column:username;not null;index" json:"username"` // 签发用户
+	Path      string    `gorm:"column:path;size:1024" json:"path"`              // 保存路径（签发用户视图内）
+	UploadID  string    `gorm:"column:upload_id;size:64" json:"uploadId"`       // 分片上传会话，单次上传为空
+	IP        string    `gorm:"column:ip" json:"ip"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"createdAt"`
+	ExpiresAt time.Time `gorm:"column:expires_at;index" json:"expiresAt"` // 链接截止时间，之后记录可清理
+}
+
+func (PresignedUploadModel) TableName() string {
+	return "t_presigned_upload"
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// 配额作用范围
+const (
+	QuotaScopeUser = "user" // 按上传者（用户名或 appkey）限制
+	QuotaScopeDir  = "dir"  // 按目录（相对于上传目录）限制
+)
+
+// QuotaModel 存储配额（v0.8.0 新增）
+type QuotaModel struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	Scope      string    `gorm:"column:scope;not null;uniqueIndex:idx_quota_scope_target" json:"scope"`
+	Target     string    `gorm:"column:target;not null;uniqueIndex:idx_quota_scope_target" json:"target"` // 用户名 / appkey，或相对于上传目录的目录
+	LimitBytes int64     `gorm:"column:limit_bytes" json:"limitBytes"`                                    // 0 表示不限
+	CreatedBy  string    `gorm:"column:created_by" json:"createdBy"`
+	CreatedAt  time.Time `gorm:"column:created_at" json:"createdAt"`
+	UpdatedAt  time.Time `gorm:"column:updated_at" json:"updatedAt"`
+}
+
+func (QuotaModel) TableName() string {
+	return "t_quota"
+}
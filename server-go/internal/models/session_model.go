@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// SessionModel 登录会话（v0.8.0 新增）
+// 每次登录创建一个会话：access token（JWT，sid 指向本会话）有效期短，过期后凭 refresh token 续期；
+// 吊销会话后其 access token 与 refresh token 立即失效。
+type SessionModel struct {
+	ID           uint       `gorm:"primarykey" json:"-"`
+	SessionID    string     `gorm:"column:session_id;not null;uniqueIndex" json:"id"`
+	Username     string     `gorm:"column:username;not null;index" json:"username"`
+	RefreshHash  string     `gorm:"column:refresh_hash;not null;index" json:"-"` // refresh token 的 SHA-256，每次续期轮换
+	IP           string     `gorm:"column:ip" json:"ip"`
+	UserAgent    string     `gorm:"column:user_agent" json:"userAgent"`
+	CreatedAt    time.Time  `gorm:"column:created_at" json:"createdAt"`
+	LastActiveAt time.Time  `gorm:"column:last_active_at" json:"lastActiveAt"` // 登录或最近一次续期的时间
+	ExpiresAt    time.Time  `gorm:"column:expires_at;index" json:"expiresAt"`  // refresh token 到期时间
+	RevokedAt    *time.Time `gorm:"column:revoked_at" json:"revokedAt,omitempty"`
+}
+
+func (SessionModel) TableName() string {
+	return "t_session"
+}
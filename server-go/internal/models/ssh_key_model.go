@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// SSHKeyModel 用户 SSH 公钥表（v0.8.0 新增，用于 SFTP 公钥登录）
+type SSHKeyModel struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	Username    string     `gorm:"column:username;not null;uniqueIndex:idx_ssh_key_user_fp" json:"username"`       // 所属用户
+	Name        string     `gorm:"column:name" json:"name"`                                                        // 备注名（默认取公钥注释）
+	Fingerprint string     `gorm:"column:fingerprint;not null;uniqueIndex:idx_ssh_key_user_fp" json:"fingerprint"` // SHA256 指纹
+	PublicKey   string     `gorm:"column:public_key;type:text;not null" json:"publicKey"`                          // authorized_keys 格式的公钥
+	LastUsedAt  *time.Time `gorm:"column:last_used_at" json:"lastUsedAt"`                                          // 最近一次登录时间
+	CreatedAt   time.Time  `gorm:"column:created_at" json:"createdAt"`
+}
+
+func (SSHKeyModel) TableName() string {
+	return "t_ssh_key"
+}
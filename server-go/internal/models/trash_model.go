@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// TrashModel 回收站条目（v0.8.0 新增）
+// 删除的文件 / 文件夹被移动到 base_dir/.trash/<trash_id>，保留期满后由后台任务彻底删除。
+type TrashModel struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	TrashID      string    `gorm:"column:trash_id;not null;uniqueIndex" json:"trashId"`
+	OriginalPath string    `gorm:"column:original_path;not null;index" json:"originalPath"` // 相对于下载（文件管理）目录的原路径
+	StoragePath  string    `gorm:"column:storage_path;not null" json:"-"`                   // 在全局驱动中的存放路径
+	IsDir        bool      `gorm:"column:is_dir" json:"isDir"`
+	Size         int64     `gorm:"column:size" json:"size"` // 文件夹为其下文件总大小
+	DeletedBy    string    `gorm:"column:deleted_by" json:"deletedBy"`
+	Source       string    `gorm:"column:source" json:"source"` // web / mcp
+	IP           string    `gorm:"column:ip" json:"ip"`
+	DeletedAt    time.Time `gorm:"column:deleted_at;index" json:"deletedAt"`
+}
+
+func (TrashModel) TableName() string {
+	return "t_trash"
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// TwoFactorModel 用户的 TOTP 两步验证（v0.8.0 新增）
+// 绑定流程中先生成密钥（Enabled 为 false），用户用验证码确认后才启用。
+type TwoFactorModel struct {
+	ID            uint       `gorm:"primarykey" json:"id"`
+	Username      string     `gorm:"column:username;not null;uniqueIndex" json:"username"`
+	Secret        string     `gorm:"column:secret;not null" json:"-"` // base32 TOTP 密钥
+	Enabled       bool       `gorm:"column:enabled;not null;default:false" json:"enabled"`
+	RecoveryCodes string     `gorm:"column:recovery_codes;type:text" json:"-"` // 未使用的恢复码 SHA-256，JSON 数组
+	LastUsedStep  int64      `gorm:"column:last_used_step" json:"-"`           // 最近一次通过校验的时间步，防止验证码重放
+	EnabledAt     *time.Time `gorm:"column:enabled_at" json:"enabledAt"`
+	CreatedAt     time.Time  `gorm:"column:created_at" json:"createdAt"`
+	UpdatedAt     time.Time  `gorm:"column:updated_at" json:"updatedAt"`
+}
+
+func (TwoFactorModel) TableName() string {
+	return "t_user_2fa"
+}
@@ -22,8 +22,15 @@ type UploadSessionModel struct {
 	UploadedBits string `gorm:"column:uploaded_bits;type:text" json:"uploadedBits"`            // 已上传分片 bitmap（"01011..." 字符串，每位对应一个分片）
 	UploadedNum  int    `gorm:"column:uploaded_num;default:0" json:"uploadedNum"`              // 已上传分片数（缓存，避免扫描 bitmap）
 	Status       string `gorm:"column:status;size:16;index;default:active" json:"status"`      // active / completed / aborted
-	FinalPath    string `gorm:"column:final_path;size:1024" json:"finalPath"`                  // 完成后的最终文件绝对路径
+	FinalPath    string `gorm:"column:final_path;size:1024" json:"finalPath"`                  // 完成后的最终文件路径（v0.8.0 起为相对上传目录的存储路径）
 	IP           string `gorm:"column:ip;size:64" json:"ip"`
+	// v0.8.0：存储驱动支持分段上传（S3）时，会话直接映射为一次分段上传，分片不再暂存本地
+	StorageUploadID string `gorm:"column:storage_upload_id;size:1024" json:"-"` // 驱动侧分段上传 ID，为空表示分片暂存在 ChunkSessionDir
+	StorageKey      string `gorm:"column:storage_key;size:1024" json:"-"`       // 分段上传的目标存储路径（声明了 MD5 时为临时路径，校验后再移动到 FinalPath）
+	// v0.8.0：S3 网关的 multipart 上传同样以会话形式记录，分片暂存在 ChunkSessionDir
+	Source string `gorm:"column:source;size:16;index" json:"source"` // 会话来源：空=分片上传 API，s3=S3 网关
+	// v0.8.0：首个分片识别出的 MIME 类型，complete 时按上传凭证的上传策略校验
+	MimeType string `gorm:"column:mime_type;size:128" json:"mimeType"`
 	CreatedAt    time.Time `gorm:"column:created_at" json:"createdAt"`
 	UpdatedAt    time.Time `gorm:"column:updated_at" json:"updatedAt"`
 	ExpireAt     time.Time `gorm:"column:expire_at;index" json:"expireAt"` // 会话过期时间（默认 24h，过期后分片目录会被清理）
@@ -0,0 +1,83 @@
+package s3api
+
+import (
+	"encoding/xml"
+	"net/http"
+)
+
+// apiError S3 错误响应（<Error><Code>...</Code></Error>）
+type apiError struct {
+	Code       string
+	Message    string
+	HTTPStatus int
+}
+
+func (e *apiError) Error() string { return e.Code + ": " + e.Message }
+
+var (
+	errAccessDenied                 = &apiError{"AccessDenied", "Access Denied.", http.StatusForbidden}
+	errAccessDeniedMissingDate      = &apiError{"AccessDenied", "AWS authentication requires a valid Date or x-amz-date header.", http.StatusForbidden}
+	errSignatureDoesNotMatch        = &apiError{"SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided.", http.StatusForbidden}
+	errSignatureVersionNotSupported = &apiError{"AccessDenied", "Only AWS4-HMAC-SHA256 is supported.", http.StatusForbidden}
+	errAuthorizationHeaderMalformed = &apiError{"AuthorizationHeaderMalformed", "The authorization header is malformed.", http.StatusBadRequest}
+	errHostNotSigned                = &apiError{"AccessDenied", "The host header must be included in SignedHeaders.", http.StatusForbidden}
+	errInvalidAccessKeyID           = &apiError{"InvalidAccessKeyId", "The access key ID you provided does not exist in our records.", http.StatusForbidden}
+	errRequestTimeTooSkewed         = &apiError{"RequestTimeTooSkewed", "The difference between the request time and the server's time is too large.", http.StatusForbidden}
+	errExpiredPresignRequest        = &apiError{"AccessDenied", "Request has expired.", http.StatusForbidden}
+	errXAmzContentSHA256Mismatch    = &apiError{"XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed.", http.StatusBadRequest}
+	errBadDigestErr                 = &apiError{"BadDigest", "The Content-MD5 or checksum you specified did not match what we received.", http.StatusBadRequest}
+	errInvalidDigest                = &apiError{"InvalidDigest", "The Content-MD5 or checksum you specified is not valid.", http.StatusBadRequest}
+	errIncompleteBody               = &apiError{"IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header.", http.StatusBadRequest}
+	errNoSuchBucket                 = &apiError{"NoSuchBucket", "The specified bucket does not exist.", http.StatusNotFound}
+	errNoSuchKey                    = &apiError{"NoSuchKey", "The specified key does not exist.", http.StatusNotFound}
+	errNoSuchUpload                 = &apiError{"NoSuchUpload", "The specified multipart upload does not exist.", http.StatusNotFound}
+	errInvalidPart                  = &apiError{"InvalidPart", "One or more of the specified parts could not be found or the ETag did not match.", http.StatusBadRequest}
+	errInvalidPartOrder             = &apiError{"InvalidPartOrder", "The list of parts was not in ascending order.", http.StatusBadRequest}
+	errEntityTooSmall               = &apiError{"EntityTooSmall", "Your proposed upload is smaller than the minimum allowed object size.", http.StatusBadRequest}
+	errEntityTooLarge               = &apiError{"EntityTooLarge", "Your proposed upload exceeds the maximum allowed object size.", http.StatusBadRequest}
+	errMalformedXML                 = &apiError{"MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema.", http.StatusBadRequest}
+	errMethodNotAllowed             = &apiError{"MethodNotAllowed", "The specified method is not allowed against this resource.", http.StatusMethodNotAllowed}
+	errNotImplemented               = &apiError{"NotImplemented", "A header or query you provided implies functionality that is not implemented.", http.StatusNotImplemented}
+	errQuotaExceeded                = &apiError{"QuotaExceeded", "The upload would exceed the storage quota.", http.StatusForbidden}
+	errUploadDisabled               = &apiError{"AccessDenied", "File upload is disabled on this server.", http.StatusForbidden}
+	errInternal                     = &apiError{"InternalError", "We encountered an internal error. Please try again.", http.StatusInternalServerError}
+)
+
+func errInvalidArgument(msg string) *apiError {
+	return &apiError{"InvalidArgument", msg, http.StatusBadRequest}
+}
+
+type errorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	Resource  string   `xml:"Resource,omitempty"`
+	RequestID string   `xml:"RequestId"`
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, e *apiError) {
+	resp := errorResponse{
+		Code:      e.Code,
+		Message:   e.Message,
+		Resource:  r.URL.Path,
+		RequestID: w.Header().Get("x-amz-request-id"),
+	}
+	if r.Method == http.MethodHead {
+		// HEAD 响应不能带 body
+		w.WriteHeader(e.HTTPStatus)
+		return
+	}
+	writeXML(w, e.HTTPStatus, resp)
+}
+
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	out, err := xml.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(out)
+}
@@ -0,0 +1,541 @@
+package s3api
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+	"httpcat/internal/storage"
+)
+
+// multipart 上传复用分片上传会话表（t_upload_session，source=s3），
+// 分片暂存在 ChunkSessionDir(uploadID)/<6 位分片号>，旁边的 .etag 文件记录分片 MD5；
+// 过期会话由分片上传的清理任务统一回收。
+
+const (
+	sessionSource  = "s3"
+	minPartSize    = 5 * 1024 * 1024
+	maxPartNumber  = 10000
+	sessionTTL     = 24 * time.Hour
+	maxListUploads = 1000
+)
+
+func partPath(uploadID string, partNumber int) string {
+	return filepath.Join(common.ChunkSessionDir(uploadID), fmt.Sprintf("%06d", partNumber))
+}
+
+// loadSession 读取仍处于 active 状态、且属于当前对象的 S3 会话
+func loadSession(req *request, uploadID string) (*models.UploadSessionModel, *apiError) {
+	db, err := common.GetDB()
+	if err != nil {
+		return nil, errNotImplemented
+	}
+	name, _, apiErr := objectPath(req.key)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	var session models.UploadSessionModel
+	if err := db.Where("upload_id = ? AND source = ?", uploadID, sessionSource).First(&session).Error; err != nil {
+		return nil, errNoSuchUpload
+	}
+	if session.Status != "active" || session.FinalPath != name {
+		return nil, errNoSuchUpload
+	}
+	return &session, nil
+}
+
+// ---------- CreateMultipartUpload ----------
+
+func (s *Server) createMultipartUpload(req *request) {
+	w, r := req.w, req.r
+	if !common.FileUploadEnable {
+		writeError(w, r, errUploadDisabled)
+		return
+	}
+	name, isDir, apiErr := objectPath(req.key)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	if isDir {
+		writeError(w, r, errInvalidArgument("object key must not end with '/'"))
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		// 会话依赖 SQLite
+		writeError(w, r, errNotImplemented)
+		return
+	}
+
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	uploadID := hex.EncodeToString(b)
+	relDir := path.Dir(name)
+	if relDir == "." {
+		relDir = ""
+	}
+	now := time.Now()
+	session := models.UploadSessionModel{
+		UploadID:  uploadID,
+		Appkey:    req.auth.accessKey,
+		Username:  req.user(),
+		FileName:  path.Base(name),
+		RelDir:    relDir,
+		Status:    "active",
+		FinalPath: name,
+		IP:        req.clientIP(),
+		Source:    sessionSource,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpireAt:  now.Add(sessionTTL),
+	}
+	if err := os.MkdirAll(common.ChunkSessionDir(uploadID), 0o755); err != nil {
+		ylog.Errorf("S3API", "create chunk dir failed: %v", err)
+		writeError(w, r, errInternal)
+		return
+	}
+	if err := db.Create(&session).Error; err != nil {
+		_ = os.RemoveAll(common.ChunkSessionDir(uploadID))
+		ylog.Errorf("S3API", "create upload session failed: %v", err)
+		writeError(w, r, errInternal)
+		return
+	}
+
+	writeXML(w, http.StatusOK, struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Bucket   string   `xml:"Bucket"`
+		Key      string   `xml:"Key"`
+		UploadID string   `xml:"UploadId"`
+	}{Xmlns: s3Namespace, Bucket: s.bucket, Key: req.key, UploadID: uploadID})
+}
+
+// ---------- UploadPart ----------
+
+func (s *Server) uploadPart(req *request, uploadID string) {
+	w, r := req.w, req.r
+	if r.Header.Get("X-Amz-Copy-Source") != "" {
+		// UploadPartCopy 暂不支持
+		writeError(w, r, errNotImplemented)
+		return
+	}
+	partNumber, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || partNumber < 1 || partNumber > maxPartNumber {
+		writeError(w, r, errInvalidArgument("Part number must be an integer between 1 and 10000, inclusive"))
+		return
+	}
+	session, apiErr := loadSession(req, uploadID)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+
+	dst := partPath(uploadID, partNumber)
+	tmp := fmt.Sprintf("%s.%d.part", dst, time.Now().UnixNano())
+	f, err := os.Create(tmp)
+	if err != nil {
+		ylog.Errorf("S3API", "create part file failed: %v", err)
+		writeError(w, r, errInternal)
+		return
+	}
+	hasher := md5.New()
+	written, err := io.Copy(io.MultiWriter(f, hasher), r.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && r.ContentLength >= 0 && written != r.ContentLength {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		writeError(w, r, storageError("write part", err))
+		return
+	}
+
+	etag := hex.EncodeToString(hasher.Sum(nil))
+	// 先删旧的 .etag 再替换分片，避免重传同一分片时 ETag 与内容短暂不一致
+	_ = os.Remove(dst + ".etag")
+	if err := os.Rename(tmp, dst); err != nil {
+		_ = os.Remove(tmp)
+		ylog.Errorf("S3API", "rename part file failed: %v", err)
+		writeError(w, r, errInternal)
+		return
+	}
+	if err := os.WriteFile(dst+".etag", []byte(etag), 0o644); err != nil {
+		ylog.Errorf("S3API", "write part etag failed: %v", err)
+		writeError(w, r, errInternal)
+		return
+	}
+
+	if db, err := common.GetDB(); err == nil {
+		db.Model(&models.UploadSessionModel{}).
+			Where("upload_id = ?", session.UploadID).
+			Update("updated_at", time.Now())
+	}
+	w.Header().Set("ETag", `"`+etag+`"`)
+	w.WriteHeader(http.StatusOK)
+}
+
+// ---------- ListParts ----------
+
+type partInfo struct {
+	PartNumber   int    `xml:"PartNumber"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+}
+
+// listSessionParts 读取会话目录下已上传的分片，按分片号升序
+func listSessionParts(uploadID string) ([]partInfo, error) {
+	entries, err := os.ReadDir(common.ChunkSessionDir(uploadID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var parts []partInfo
+	for _, e := range entries {
+		if e.IsDir() || len(e.Name()) != 6 {
+			continue
+		}
+		n, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		etag, err := os.ReadFile(partPath(uploadID, n) + ".etag")
+		if err != nil {
+			continue
+		}
+		parts = append(parts, partInfo{
+			PartNumber:   n,
+			LastModified: formatTime(info.ModTime()),
+			ETag:         `"` + string(etag) + `"`,
+			Size:         info.Size(),
+		})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	return parts, nil
+}
+
+func (s *Server) listParts(req *request, uploadID string) {
+	w, r := req.w, req.r
+	if _, apiErr := loadSession(req, uploadID); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	q := r.URL.Query()
+	maxParts := maxListKeys
+	if v := q.Get("max-parts"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, r, errInvalidArgument("invalid max-parts"))
+			return
+		}
+		if n < maxParts {
+			maxParts = n
+		}
+	}
+	marker, _ := strconv.Atoi(q.Get("part-number-marker"))
+
+	parts, err := listSessionParts(uploadID)
+	if err != nil {
+		writeError(w, r, storageError("list parts", err))
+		return
+	}
+
+	result := struct {
+		XMLName              xml.Name   `xml:"ListPartsResult"`
+		Xmlns                string     `xml:"xmlns,attr"`
+		Bucket               string     `xml:"Bucket"`
+		Key                  string     `xml:"Key"`
+		UploadID             string     `xml:"UploadId"`
+		Initiator            owner      `xml:"Initiator"`
+		Owner                owner      `xml:"Owner"`
+		StorageClass         string     `xml:"StorageClass"`
+		PartNumberMarker     int        `xml:"PartNumberMarker"`
+		NextPartNumberMarker int        `xml:"NextPartNumberMarker"`
+		MaxParts             int        `xml:"MaxParts"`
+		IsTruncated          bool       `xml:"IsTruncated"`
+		Parts                []partInfo `xml:"Part"`
+	}{
+		Xmlns:            s3Namespace,
+		Bucket:           s.bucket,
+		Key:              req.key,
+		UploadID:         uploadID,
+		Initiator:        defaultOwner,
+		Owner:            defaultOwner,
+		StorageClass:     "STANDARD",
+		PartNumberMarker: marker,
+		MaxParts:         maxParts,
+	}
+	for _, p := range parts {
+		if p.PartNumber <= marker {
+			continue
+		}
+		if len(result.Parts) >= maxParts {
+			result.IsTruncated = true
+			break
+		}
+		result.Parts = append(result.Parts, p)
+		result.NextPartNumberMarker = p.PartNumber
+	}
+	writeXML(w, http.StatusOK, result)
+}
+
+// ---------- CompleteMultipartUpload ----------
+
+// partsReader 按顺序依次读取各分片文件
+type partsReader struct {
+	files []string
+	cur   *os.File
+}
+
+func (p *partsReader) Read(b []byte) (int, error) {
+	for {
+		if p.cur == nil {
+			if len(p.files) == 0 {
+				return 0, io.EOF
+			}
+			f, err := os.Open(p.files[0])
+			if err != nil {
+				return 0, err
+			}
+			p.cur, p.files = f, p.files[1:]
+		}
+		n, err := p.cur.Read(b)
+		if err == io.EOF {
+			p.cur.Close()
+			p.cur = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (p *partsReader) Close() {
+	if p.cur != nil {
+		p.cur.Close()
+	}
+}
+
+func (s *Server) completeMultipartUpload(req *request, uploadID string) {
+	w, r := req.w, req.r
+	start := time.Now()
+	session, apiErr := loadSession(req, uploadID)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxXMLBody))
+	if err != nil {
+		writeError(w, r, storageError("read body", err))
+		return
+	}
+	var in struct {
+		Parts []struct {
+			PartNumber int    `xml:"PartNumber"`
+			ETag       string `xml:"ETag"`
+		} `xml:"Part"`
+	}
+	if err := xml.Unmarshal(body, &in); err != nil || len(in.Parts) == 0 {
+		writeError(w, r, errMalformedXML)
+		return
+	}
+
+	var (
+		files     []string
+		total     int64
+		md5Concat []byte
+	)
+	for i, p := range in.Parts {
+		if i > 0 && p.PartNumber <= in.Parts[i-1].PartNumber {
+			writeError(w, r, errInvalidPartOrder)
+			return
+		}
+		file := partPath(uploadID, p.PartNumber)
+		info, err := os.Stat(file)
+		if err != nil {
+			writeError(w, r, errInvalidPart)
+			return
+		}
+		etag, err := os.ReadFile(file + ".etag")
+		if err != nil || string(etag) != strings.Trim(p.ETag, `"`) {
+			writeError(w, r, errInvalidPart)
+			return
+		}
+		if i < len(in.Parts)-1 && info.Size() < minPartSize {
+			writeError(w, r, errEntityTooSmall)
+			return
+		}
+		sum, _ := hex.DecodeString(string(etag))
+		md5Concat = append(md5Concat, sum...)
+		files = append(files, file)
+		total += info.Size()
+	}
+	if common.UploadPolicyFSizeLimit > 0 && total > common.UploadPolicyFSizeLimit {
+		writeError(w, r, errEntityTooLarge)
+		return
+	}
+
+	// v0.8.0：按合并后的大小校验配额
+	realPath, err := req.realName(session.FinalPath)
+	if err == nil {
+		err = storage.CheckQuota(realPath, req.user(), total)
+	}
+	if err != nil {
+		writeError(w, r, storageError("check quota", err))
+		return
+	}
+	reader := &partsReader{files: files}
+	written, err := req.fsys.Put(session.FinalPath, reader, total)
+	reader.Close()
+	if err != nil {
+		writeError(w, r, storageError("merge parts", err))
+		return
+	}
+	if written != total {
+		ylog.Errorf("S3API", "merge parts size mismatch: want %d, got %d", total, written)
+		writeError(w, r, errInternal)
+		return
+	}
+
+	if db, err := common.GetDB(); err == nil {
+		db.Model(&models.UploadSessionModel{}).
+			Where("upload_id = ?", uploadID).
+			Updates(map[string]interface{}{
+				"status":     "completed",
+				"file_size":  total,
+				"updated_at": time.Now(),
+			})
+	}
+	go func() {
+		if err := os.RemoveAll(common.ChunkSessionDir(uploadID)); err != nil {
+			ylog.Errorf("S3API", "remove chunk dir failed: %v", err)
+		}
+	}()
+
+	sum := md5.Sum(md5Concat)
+	etag := fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(sum[:]), len(files))
+	writeXML(w, http.StatusOK, struct {
+		XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Location string   `xml:"Location"`
+		Bucket   string   `xml:"Bucket"`
+		Key      string   `xml:"Key"`
+		ETag     string   `xml:"ETag"`
+	}{Xmlns: s3Namespace, Location: r.URL.Path, Bucket: s.bucket, Key: req.key, ETag: etag})
+
+	modTime := time.Now()
+	if info, err := req.fsys.Stat(session.FinalPath); err == nil {
+		modTime = info.ModTime()
+	}
+	storage.SetFileOwner(realPath, req.user())
+	go insertUploadLog(req, session.FinalPath, total, "", modTime)
+	go saveOperationLog(req, "s3_multipart_complete",
+		fmt.Sprintf("S3 分段上传完成: %s (%d parts)", session.FinalPath, len(files)), http.StatusOK, start)
+}
+
+// ---------- AbortMultipartUpload ----------
+
+func (s *Server) abortMultipartUpload(req *request, uploadID string) {
+	w, r := req.w, req.r
+	if _, apiErr := loadSession(req, uploadID); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	if db, err := common.GetDB(); err == nil {
+		db.Model(&models.UploadSessionModel{}).
+			Where("upload_id = ?", uploadID).
+			Updates(map[string]interface{}{"status": "aborted", "updated_at": time.Now()})
+	}
+	if err := os.RemoveAll(common.ChunkSessionDir(uploadID)); err != nil {
+		ylog.Errorf("S3API", "remove chunk dir failed: %v", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ---------- ListMultipartUploads ----------
+
+type uploadInfo struct {
+	Key          string `xml:"Key"`
+	UploadID     string `xml:"UploadId"`
+	Initiator    owner  `xml:"Initiator"`
+	Owner        owner  `xml:"Owner"`
+	StorageClass string `xml:"StorageClass"`
+	Initiated    string `xml:"Initiated"`
+}
+
+func (s *Server) listMultipartUploads(req *request) {
+	w, r := req.w, req.r
+	db, err := common.GetDB()
+	if err != nil {
+		writeError(w, r, errNotImplemented)
+		return
+	}
+	prefix := r.URL.Query().Get("prefix")
+
+	var sessions []models.UploadSessionModel
+	if err := db.Where("source = ? AND status = ?", sessionSource, "active").
+		Order("final_path, created_at").
+		Find(&sessions).Error; err != nil {
+		ylog.Errorf("S3API", "query upload sessions failed: %v", err)
+		writeError(w, r, errInternal)
+		return
+	}
+
+	result := struct {
+		XMLName            xml.Name     `xml:"ListMultipartUploadsResult"`
+		Xmlns              string       `xml:"xmlns,attr"`
+		Bucket             string       `xml:"Bucket"`
+		KeyMarker          string       `xml:"KeyMarker"`
+		UploadIDMarker     string       `xml:"UploadIdMarker"`
+		NextKeyMarker      string       `xml:"NextKeyMarker"`
+		NextUploadIDMarker string       `xml:"NextUploadIdMarker"`
+		Prefix             string       `xml:"Prefix"`
+		MaxUploads         int          `xml:"MaxUploads"`
+		IsTruncated        bool         `xml:"IsTruncated"`
+		Uploads            []uploadInfo `xml:"Upload"`
+	}{Xmlns: s3Namespace, Bucket: s.bucket, Prefix: prefix, MaxUploads: maxListUploads}
+	for _, session := range sessions {
+		if !strings.HasPrefix(session.FinalPath, prefix) {
+			continue
+		}
+		if len(result.Uploads) >= maxListUploads {
+			// 超出部分不再分页，清理任务会在 24h 后回收过期会话
+			break
+		}
+		result.Uploads = append(result.Uploads, uploadInfo{
+			Key:          session.FinalPath,
+			UploadID:     session.UploadID,
+			Initiator:    defaultOwner,
+			Owner:        defaultOwner,
+			StorageClass: "STANDARD",
+			Initiated:    formatTime(session.CreatedAt),
+		})
+	}
+	writeXML(w, http.StatusOK, result)
+}
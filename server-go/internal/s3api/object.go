@@ -0,0 +1,616 @@
+package s3api
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/storage"
+)
+
+const (
+	// maxXMLBody 请求体为 XML 时（DeleteObjects / CompleteMultipartUpload）的大小上限
+	maxXMLBody = 4 * 1024 * 1024
+	// maxListKeys ListObjects 单页最大条数
+	maxListKeys = 1000
+	// emptyETag 空内容的 MD5，用于目录标记对象
+	emptyETag = `"d41d8cd98f00b204e9800998ecf8427e"`
+)
+
+// objectPath 将对象 key 转换为存储路径；以 "/" 结尾的 key 表示目录
+func objectPath(key string) (string, bool, *apiError) {
+	isDir := strings.HasSuffix(key, "/")
+	name, err := storage.CleanPath(strings.TrimSuffix(key, "/"))
+	if err != nil || name == "" {
+		return "", false, errInvalidArgument("invalid object key")
+	}
+	return name, isDir, nil
+}
+
+// objectETag 未保存内容 MD5 的对象使用由大小与修改时间派生的稳定 ETag。
+// 带 "-1" 后缀（与 multipart 对象的 ETag 格式一致），提示客户端不要把它当作内容 MD5 校验。
+func objectETag(info fs.FileInfo) string {
+	sum := md5.Sum([]byte(fmt.Sprintf("%d-%d", info.Size(), info.ModTime().UnixNano())))
+	return `"` + hex.EncodeToString(sum[:]) + `-1"`
+}
+
+// storageError 将存储驱动错误转换为 S3 错误
+func storageError(op string, err error) *apiError {
+	if be := bodyError(err); be != nil {
+		return be
+	}
+	if errors.Is(err, storage.ErrInvalidPath) {
+		return errInvalidArgument("invalid object key")
+	}
+	if errors.Is(err, storage.ErrQuotaExceeded) {
+		ylog.Warnf("S3API", "%s rejected: %v", op, err)
+		return errQuotaExceeded
+	}
+	if errors.Is(err, storage.ErrAccessDenied) {
+		return errAccessDenied
+	}
+	if storage.IsNotExist(err) {
+		return errNoSuchKey
+	}
+	ylog.Errorf("S3API", "%s failed: %v", op, err)
+	return errInternal
+}
+
+// ---------- GetObject / HeadObject ----------
+
+func (s *Server) getObject(req *request) {
+	w, r := req.w, req.r
+	name, isDir, apiErr := objectPath(req.key)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+
+	info, err := req.fsys.Stat(name)
+	if err != nil {
+		writeError(w, r, storageError("stat", err))
+		return
+	}
+	if info.IsDir() != isDir {
+		// 目录只能以 "dir/" 形式访问，文件不能带 "/" 后缀
+		writeError(w, r, errNoSuchKey)
+		return
+	}
+	if isDir {
+		w.Header().Set("Content-Type", "application/x-directory")
+		w.Header().Set("Content-Length", "0")
+		w.Header().Set("ETag", emptyETag)
+		w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	f, err := req.fsys.Open(name)
+	if err != nil {
+		writeError(w, r, storageError("open", err))
+		return
+	}
+	defer f.Close()
+
+	h := w.Header()
+	h.Set("ETag", objectETag(info))
+	h.Set("Accept-Ranges", "bytes")
+	h.Set("Content-Type", contentType(name))
+	// 预签名下载常用的响应头覆盖参数
+	q := r.URL.Query()
+	for param, header := range map[string]string{
+		"response-content-type":        "Content-Type",
+		"response-content-disposition": "Content-Disposition",
+		"response-content-encoding":    "Content-Encoding",
+		"response-content-language":    "Content-Language",
+		"response-cache-control":       "Cache-Control",
+		"response-expires":             "Expires",
+	} {
+		if v := q.Get(param); v != "" {
+			h.Set(header, v)
+		}
+	}
+
+	// ServeContent 负责 Range / If-Match / If-None-Match / If-Modified-Since 等条件请求，HEAD 时不写 body
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+// ---------- PutObject ----------
+
+func (s *Server) putObject(req *request) {
+	w, r := req.w, req.r
+	start := time.Now()
+	if !common.FileUploadEnable {
+		writeError(w, r, errUploadDisabled)
+		return
+	}
+	name, isDir, apiErr := objectPath(req.key)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	size := r.ContentLength
+	if common.UploadPolicyFSizeLimit > 0 && size > common.UploadPolicyFSizeLimit {
+		writeError(w, r, errEntityTooLarge)
+		return
+	}
+
+	if isDir {
+		// 客户端以 0 字节的 "dir/" 对象表示文件夹
+		if _, err := io.Copy(io.Discard, r.Body); err != nil {
+			writeError(w, r, storageError("read body", err))
+			return
+		}
+		if err := req.fsys.Mkdir(name); err != nil {
+			writeError(w, r, storageError("mkdir", err))
+			return
+		}
+		w.Header().Set("ETag", emptyETag)
+		w.WriteHeader(http.StatusOK)
+		go saveOperationLog(req, "s3_mkdir", "S3 创建目录: "+name, http.StatusOK, start)
+		return
+	}
+
+	if info, err := req.fsys.Stat(name); err == nil && info.IsDir() {
+		writeError(w, r, errInvalidArgument("a directory with the same name already exists"))
+		return
+	}
+
+	// v0.8.0：按声明的长度校验配额；长度未知（aws-chunked 未携带解码后长度）时在读取过程中校验
+	var body io.Reader = r.Body
+	realName, err := req.realName(name)
+	if err == nil && size >= 0 {
+		err = storage.CheckQuota(realName, req.user(), size)
+	} else if err == nil {
+		body, err = storage.LimitQuota(storage.UploadPath(realName), req.user(), r.Body)
+	}
+	if err != nil {
+		writeError(w, r, storageError("check quota", err))
+		return
+	}
+	checksums := storage.NewChecksumWriter()
+	written, err := req.fsys.Put(name, io.TeeReader(body, checksums), size)
+	if err != nil {
+		writeError(w, r, storageError("put", err))
+		return
+	}
+	if size >= 0 && written != size {
+		_ = req.fsys.Remove(name)
+		writeError(w, r, errIncompleteBody)
+		return
+	}
+
+	sums := checksums.Sums()
+	fileMD5 := sums.MD5
+	w.Header().Set("ETag", `"`+fileMD5+`"`)
+	w.WriteHeader(http.StatusOK)
+
+	modTime := time.Now()
+	if info, err := req.fsys.Stat(name); err == nil {
+		modTime = info.ModTime()
+		storage.RememberChecksums(storage.UploadPath(realName), info, sums)
+	}
+	storage.SetFileOwner(realName, req.user())
+	go insertUploadLog(req, name, written, fileMD5, modTime)
+	go saveOperationLog(req, "s3_put", "S3 上传对象: "+name, http.StatusOK, start)
+}
+
+// ---------- CopyObject ----------
+
+func (s *Server) copyObject(req *request) {
+	w, r := req.w, req.r
+	start := time.Now()
+	if !common.FileUploadEnable {
+		writeError(w, r, errUploadDisabled)
+		return
+	}
+
+	// x-amz-copy-source: [/]<bucket>/<key>[?versionId=...]（URL 编码）
+	source, _, _ := strings.Cut(r.Header.Get("X-Amz-Copy-Source"), "?")
+	source, err := url.PathUnescape(source)
+	if err != nil {
+		writeError(w, r, errInvalidArgument("invalid x-amz-copy-source"))
+		return
+	}
+	srcBucket, srcKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	if srcBucket != s.bucket {
+		writeError(w, r, errNoSuchBucket)
+		return
+	}
+	srcName, srcIsDir, apiErr := objectPath(srcKey)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	dstName, dstIsDir, apiErr := objectPath(req.key)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	if srcIsDir || dstIsDir {
+		writeError(w, r, errInvalidArgument("copying directories is not supported"))
+		return
+	}
+
+	info, err := req.fsys.Stat(srcName)
+	if err != nil {
+		writeError(w, r, storageError("stat", err))
+		return
+	}
+	if info.IsDir() {
+		writeError(w, r, errNoSuchKey)
+		return
+	}
+	// 源与目标相同（仅替换元数据）时无需拷贝
+	if srcName != dstName {
+		realDst, err := req.realName(dstName)
+		if err == nil {
+			err = storage.CheckQuota(realDst, req.user(), info.Size())
+		}
+		if err != nil {
+			writeError(w, r, storageError("check quota", err))
+			return
+		}
+		if err := storage.Copy(req.fsys, srcName, dstName); err != nil {
+			writeError(w, r, storageError("copy", err))
+			return
+		}
+	}
+	dstInfo, err := req.fsys.Stat(dstName)
+	if err != nil {
+		writeError(w, r, storageError("stat", err))
+		return
+	}
+
+	writeXML(w, http.StatusOK, struct {
+		XMLName      xml.Name `xml:"CopyObjectResult"`
+		Xmlns        string   `xml:"xmlns,attr"`
+		LastModified string   `xml:"LastModified"`
+		ETag         string   `xml:"ETag"`
+	}{Xmlns: s3Namespace, LastModified: formatTime(dstInfo.ModTime()), ETag: objectETag(dstInfo)})
+	go saveOperationLog(req, "s3_copy", fmt.Sprintf("S3 拷贝对象: %s -> %s", srcName, dstName), http.StatusOK, start)
+}
+
+// ---------- DeleteObject / DeleteObjects ----------
+
+// deleteKey 删除单个对象；与 S3 一致，对象不存在时视为成功。
+// "dir/" 形式的 key 仅删除空目录，非空目录保持不变（相当于删除了目录标记）。
+// 删除后逐级清理变空的父目录，使其不再作为前缀出现在列举结果中（与 MinIO 行为一致）。
+func deleteKey(fsys storage.Driver, key string) *apiError {
+	name, isDir, apiErr := objectPath(key)
+	if apiErr != nil {
+		return apiErr
+	}
+	info, err := fsys.Stat(name)
+	if err != nil {
+		if storage.IsNotExist(err) {
+			return nil
+		}
+		return storageError("stat", err)
+	}
+	if info.IsDir() != isDir {
+		return nil
+	}
+	if err := fsys.Remove(name); err != nil {
+		if isDir {
+			return nil
+		}
+		return storageError("remove", err)
+	}
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		items, err := fsys.List(dir)
+		if err != nil || len(items) > 0 || fsys.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *Server) deleteObject(req *request) {
+	start := time.Now()
+	if apiErr := deleteKey(req.fsys, req.key); apiErr != nil {
+		writeError(req.w, req.r, apiErr)
+		return
+	}
+	req.w.WriteHeader(http.StatusNoContent)
+	go saveOperationLog(req, "s3_delete", "S3 删除对象: "+req.key, http.StatusNoContent, start)
+}
+
+type deletedObject struct {
+	Key string `xml:"Key"`
+}
+
+type deleteError struct {
+	Key     string `xml:"Key"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (s *Server) deleteObjects(req *request) {
+	w, r := req.w, req.r
+	start := time.Now()
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxXMLBody))
+	if err != nil {
+		writeError(w, r, storageError("read body", err))
+		return
+	}
+	var in struct {
+		Quiet   bool `xml:"Quiet"`
+		Objects []struct {
+			Key string `xml:"Key"`
+		} `xml:"Object"`
+	}
+	if err := xml.Unmarshal(body, &in); err != nil || len(in.Objects) == 0 || len(in.Objects) > maxListKeys {
+		writeError(w, r, errMalformedXML)
+		return
+	}
+
+	result := struct {
+		XMLName xml.Name        `xml:"DeleteResult"`
+		Xmlns   string          `xml:"xmlns,attr"`
+		Deleted []deletedObject `xml:"Deleted"`
+		Errors  []deleteError   `xml:"Error"`
+	}{Xmlns: s3Namespace}
+	for _, obj := range in.Objects {
+		if apiErr := deleteKey(req.fsys, obj.Key); apiErr != nil {
+			result.Errors = append(result.Errors, deleteError{Key: obj.Key, Code: apiErr.Code, Message: apiErr.Message})
+			continue
+		}
+		if !in.Quiet {
+			result.Deleted = append(result.Deleted, deletedObject{Key: obj.Key})
+		}
+	}
+	writeXML(w, http.StatusOK, result)
+	go saveOperationLog(req, "s3_delete", fmt.Sprintf("S3 批量删除 %d 个对象", len(in.Objects)), http.StatusOK, start)
+}
+
+// ---------- ListObjects / ListObjectsV2 ----------
+
+type listEntry struct {
+	key    string
+	info   fs.FileInfo // 为 nil 表示 CommonPrefix
+	prefix bool
+}
+
+type objectXML struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+	Owner        *owner `xml:"Owner,omitempty"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type listBucketResult struct {
+	XMLName               xml.Name       `xml:"ListBucketResult"`
+	Xmlns                 string         `xml:"xmlns,attr"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	Marker                *string        `xml:"Marker,omitempty"`
+	NextMarker            string         `xml:"NextMarker,omitempty"`
+	StartAfter            string         `xml:"StartAfter,omitempty"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	KeyCount              *int           `xml:"KeyCount,omitempty"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	EncodingType          string         `xml:"EncodingType,omitempty"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	Contents              []objectXML    `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+func (s *Server) listObjects(req *request) {
+	w, r := req.w, req.r
+	q := r.URL.Query()
+	v2 := q.Get("list-type") == "2"
+	prefix := q.Get("prefix")
+	delimiter := q.Get("delimiter")
+
+	maxKeys := maxListKeys
+	if v := q.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, r, errInvalidArgument("invalid max-keys"))
+			return
+		}
+		if n < maxKeys {
+			maxKeys = n
+		}
+	}
+
+	result := listBucketResult{
+		Xmlns:     s3Namespace,
+		Name:      s.bucket,
+		MaxKeys:   maxKeys,
+		Delimiter: delimiter,
+	}
+	encode := func(s string) string { return s }
+	if q.Get("encoding-type") == "url" {
+		result.EncodingType = "url"
+		encode = func(s string) string { return awsURIEncode(s, false) }
+	}
+	result.Prefix = encode(prefix)
+
+	// 分页游标：V2 为 continuation-token（上一页最后一个 key 的 base64）或 start-after，V1 为 marker
+	var marker string
+	if v2 {
+		if token := q.Get("continuation-token"); token != "" {
+			decoded, err := base64.StdEncoding.DecodeString(token)
+			if err != nil {
+				writeError(w, r, errInvalidArgument("invalid continuation-token"))
+				return
+			}
+			marker = string(decoded)
+			result.ContinuationToken = token
+		} else {
+			marker = q.Get("start-after")
+			result.StartAfter = encode(marker)
+		}
+	} else {
+		marker = q.Get("marker")
+		m := encode(marker)
+		result.Marker = &m
+	}
+
+	entries, err := collectEntries(req.fsys, prefix, delimiter)
+	if err != nil {
+		writeError(w, r, storageError("list", err))
+		return
+	}
+
+	fetchOwner := !v2 || q.Get("fetch-owner") == "true"
+	count := 0
+	last := ""
+	for _, e := range entries {
+		if e.key <= marker {
+			continue
+		}
+		if count >= maxKeys {
+			result.IsTruncated = true
+			break
+		}
+		count++
+		last = e.key
+		if e.prefix {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: encode(e.key)})
+			continue
+		}
+		obj := objectXML{
+			Key:          encode(e.key),
+			LastModified: formatTime(e.info.ModTime()),
+			ETag:         objectETag(e.info),
+			Size:         e.info.Size(),
+			StorageClass: "STANDARD",
+		}
+		if e.info.IsDir() {
+			obj.ETag, obj.Size = emptyETag, 0
+		}
+		if fetchOwner {
+			obj.Owner = &defaultOwner
+		}
+		result.Contents = append(result.Contents, obj)
+	}
+
+	if v2 {
+		result.KeyCount = &count
+		if result.IsTruncated {
+			result.NextContinuationToken = base64.StdEncoding.EncodeToString([]byte(last))
+		}
+	} else if result.IsTruncated {
+		result.NextMarker = encode(last)
+	}
+	writeXML(w, http.StatusOK, result)
+}
+
+// collectEntries 收集 prefix 下的对象，按 delimiter 折叠为 CommonPrefixes，结果按 key 字典序排列。
+// delimiter 为 "/" 时只列出 prefix 所在目录（不递归），其它情况递归遍历后再折叠。
+// 空目录以 "dir/" 目录标记对象的形式列出，与客户端创建文件夹的方式对应。
+func collectEntries(fsys storage.Driver, prefix, delimiter string) ([]listEntry, error) {
+	baseDir := ""
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		baseDir = prefix[:i]
+	}
+	dir, err := storage.CleanPath(baseDir)
+	if err != nil || dir != baseDir {
+		// 非规范路径（如 "a//b"、"./a"）不可能对应任何对象
+		return nil, nil
+	}
+
+	var entries []listEntry
+	seen := make(map[string]bool)
+	add := func(key string, info fs.FileInfo) {
+		if !strings.HasPrefix(key, prefix) {
+			return
+		}
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				cp := key[:len(prefix)+i+len(delimiter)]
+				if !seen[cp] {
+					seen[cp] = true
+					entries = append(entries, listEntry{key: cp, prefix: true})
+				}
+				return
+			}
+		}
+		entries = append(entries, listEntry{key: key, info: info})
+	}
+
+	if delimiter == "/" {
+		items, err := fsys.List(dir)
+		if err != nil {
+			if storage.IsNotExist(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		for _, item := range items {
+			key := storage.Join(dir, item.Name())
+			if item.IsDir() {
+				key += "/"
+			}
+			add(key, item)
+		}
+		if len(items) == 0 && dir != "" {
+			if info, err := fsys.Stat(dir); err == nil {
+				add(dir+"/", info)
+			}
+		}
+	} else {
+		// Walk 深度优先且目录先于子项回调：某个目录之后紧跟的不是它的子项，说明该目录为空
+		var pendingDir string
+		var pendingInfo fs.FileInfo
+		flush := func(next string) {
+			if pendingInfo != nil && !strings.HasPrefix(next, pendingDir+"/") {
+				add(pendingDir+"/", pendingInfo)
+			}
+			pendingInfo = nil
+		}
+		err := storage.Walk(fsys, dir, func(name string, info fs.FileInfo) error {
+			flush(name)
+			if info.IsDir() {
+				if name != "" {
+					pendingDir, pendingInfo = name, info
+				}
+				return nil
+			}
+			add(name, info)
+			return nil
+		})
+		flush("")
+		if err != nil && !storage.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	return entries, nil
+}
+
+// contentType 按扩展名推断 Content-Type
+func contentType(name string) string {
+	if t := mime.TypeByExtension(path.Ext(name)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
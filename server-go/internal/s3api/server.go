@@ -0,0 +1,336 @@
+package s3api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/utils"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+	"httpcat/internal/storage"
+)
+
+// ========== v0.8.0 新增：S3 兼容网关 ==========
+//
+// 在独立端口（server.s3.port）上提供 S3 兼容 API，供 aws-cli / 各语言 SDK 直接访问：
+//   - 仅暴露一个桶（server.s3.bucket），桶根目录即上传目录（UploadDir）
+//   - 对象 key 即相对上传目录的路径，以 "/" 结尾的 key 视为目录
+//   - 支持 path-style（http://host:port/bucket/key）与 virtual-hosted-style（http://bucket.host:port/key）
+//   - 鉴权为 AWS SigV4，密钥对复用 server.http.auth.aksk
+//   - 对象经调用者的目录视图（storage.ScopeFor）访问；全局 AK/SK 与 Open API 一样不受主目录限制
+//
+// 已实现的操作：ListBuckets、HeadBucket、GetBucketLocation、ListObjects(V1/V2)、
+// GetObject、HeadObject、PutObject、CopyObject、DeleteObject、DeleteObjects、
+// CreateMultipartUpload、UploadPart、CompleteMultipartUpload、AbortMultipartUpload、
+// ListParts、ListMultipartUploads。
+
+const s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// Server S3 兼容网关
+type Server struct {
+	bucket string
+	region string
+}
+
+// NewServer 创建 S3 网关
+func NewServer() *Server {
+	return &Server{
+		bucket: common.S3ApiBucket,
+		region: common.S3ApiRegion,
+	}
+}
+
+// Run 在独立端口上启动 S3 网关（TLS 配置与主服务一致）
+func Run(port int, enableSSL bool, certFile, keyFile string) {
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%d", port),
+		Handler:      NewServer(),
+		ReadTimeout:  time.Duration(common.HttpReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(common.HttpWriteTimeout) * time.Second,
+		IdleTimeout:  time.Duration(common.HttpIdleTimeout) * time.Second,
+	}
+
+	ylog.Infof("S3API", "####S3_API_LISTEN_ON:%d bucket=%s", port, common.S3ApiBucket)
+	var err error
+	if enableSSL {
+		err = srv.ListenAndServeTLS(certFile, keyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		ylog.Errorf("S3API", "s3 api server run error: %v", err)
+	}
+}
+
+// request 单次请求上下文
+type request struct {
+	w      http.ResponseWriter
+	r      *http.Request
+	auth   *authContext
+	bucket string
+	key    string
+	scope  *storage.UserScope // 调用者的目录视图，nil 表示不受限
+	fsys   storage.Driver
+}
+
+// user 操作日志中的用户标识（与 Open API 的 "openapi:<ak>" 对应）
+func (req *request) user() string {
+	return "s3:" + req.auth.accessKey
+}
+
+// realName 把对象 key 翻译为相对于上传目录的真实路径（配额、校验和与归属按真实路径登记）
+func (req *request) realName(name string) (string, error) {
+	return req.scope.Resolve(name, true)
+}
+
+func (req *request) clientIP() string {
+	host, _, err := net.SplitHostPort(req.r.RemoteAddr)
+	if err != nil {
+		return req.r.RemoteAddr
+	}
+	return host
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("x-amz-request-id", newRequestID())
+	w.Header().Set("Server", "httpcat")
+
+	auth, apiErr := authenticate(r)
+	if apiErr != nil {
+		ylog.Warnf("S3API", "auth failed: %s %s from %s: %s", r.Method, r.URL.Path, r.RemoteAddr, apiErr.Code)
+		writeError(w, r, apiErr)
+		return
+	}
+
+	bucket, key := s.splitPath(r)
+	scope := storage.ScopeFor(auth.username())
+	req := &request{w: w, r: r, auth: auth, bucket: bucket, key: key, scope: scope, fsys: scope.FS(storage.UploadFS())}
+
+	if bucket == "" {
+		if r.Method == http.MethodGet {
+			s.listBuckets(req)
+			return
+		}
+		writeError(w, r, errMethodNotAllowed)
+		return
+	}
+	if bucket != s.bucket {
+		writeError(w, r, errNoSuchBucket)
+		return
+	}
+
+	query := r.URL.Query()
+	if key == "" {
+		s.serveBucket(req, query.Has("location"), query.Has("uploads"), query.Has("delete"), query.Has("versioning"))
+		return
+	}
+
+	uploadID := query.Get("uploadId")
+	switch r.Method {
+	case http.MethodGet:
+		if uploadID != "" {
+			s.listParts(req, uploadID)
+			return
+		}
+		s.getObject(req)
+	case http.MethodHead:
+		s.getObject(req)
+	case http.MethodPut:
+		if uploadID != "" {
+			s.uploadPart(req, uploadID)
+			return
+		}
+		if r.Header.Get("X-Amz-Copy-Source") != "" {
+			s.copyObject(req)
+			return
+		}
+		s.putObject(req)
+	case http.MethodPost:
+		if query.Has("uploads") {
+			s.createMultipartUpload(req)
+			return
+		}
+		if uploadID != "" {
+			s.completeMultipartUpload(req, uploadID)
+			return
+		}
+		writeError(w, r, errNotImplemented)
+	case http.MethodDelete:
+		if uploadID != "" {
+			s.abortMultipartUpload(req, uploadID)
+			return
+		}
+		s.deleteObject(req)
+	default:
+		writeError(w, r, errMethodNotAllowed)
+	}
+}
+
+func (s *Server) serveBucket(req *request, location, uploads, del, versioning bool) {
+	w, r := req.w, req.r
+	switch r.Method {
+	case http.MethodHead:
+		w.Header().Set("x-amz-bucket-region", s.region)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPut:
+		// 桶固定存在，重复创建按 us-east-1 语义直接返回成功
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		switch {
+		case location:
+			s.getBucketLocation(req)
+		case uploads:
+			s.listMultipartUploads(req)
+		case versioning:
+			writeXML(w, http.StatusOK, struct {
+				XMLName xml.Name `xml:"VersioningConfiguration"`
+				Xmlns   string   `xml:"xmlns,attr"`
+			}{Xmlns: s3Namespace})
+		default:
+			s.listObjects(req)
+		}
+	case http.MethodPost:
+		if del {
+			s.deleteObjects(req)
+			return
+		}
+		writeError(w, r, errNotImplemented)
+	default:
+		// 不允许删除桶
+		writeError(w, r, errMethodNotAllowed)
+	}
+}
+
+// splitPath 解析桶名与对象 key：Host 以 "<bucket>." 开头时为 virtual-hosted-style，否则为 path-style
+func (s *Server) splitPath(r *http.Request) (string, string) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	p := strings.TrimPrefix(r.URL.Path, "/")
+	if strings.HasPrefix(host, s.bucket+".") {
+		return s.bucket, p
+	}
+	bucket, key, _ := strings.Cut(p, "/")
+	return bucket, key
+}
+
+// ---------- 桶级操作 ----------
+
+type bucketInfo struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+type owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+var defaultOwner = owner{ID: "httpcat", DisplayName: "httpcat"}
+
+func (s *Server) listBuckets(req *request) {
+	created := time.Unix(0, 0)
+	if info, err := req.fsys.Stat(""); err == nil && !info.ModTime().IsZero() {
+		created = info.ModTime()
+	}
+	writeXML(req.w, http.StatusOK, struct {
+		XMLName xml.Name     `xml:"ListAllMyBucketsResult"`
+		Xmlns   string       `xml:"xmlns,attr"`
+		Owner   owner        `xml:"Owner"`
+		Buckets []bucketInfo `xml:"Buckets>Bucket"`
+	}{
+		Xmlns:   s3Namespace,
+		Owner:   defaultOwner,
+		Buckets: []bucketInfo{{Name: s.bucket, CreationDate: formatTime(created)}},
+	})
+}
+
+func (s *Server) getBucketLocation(req *request) {
+	location := s.region
+	if location == "us-east-1" {
+		// us-east-1 按 S3 约定返回空
+		location = ""
+	}
+	writeXML(req.w, http.StatusOK, struct {
+		XMLName  xml.Name `xml:"LocationConstraint"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Location string   `xml:",chardata"`
+	}{Xmlns: s3Namespace, Location: location})
+}
+
+// ---------- 日志 ----------
+
+// saveOperationLog 记录写操作到 t_operation_log（与 HTTP API 的操作日志共用一张表）
+func saveOperationLog(req *request, action, detail string, status int, start time.Time) {
+	db, err := common.GetDB()
+	if err != nil {
+		return
+	}
+	entry := models.OperationLogModel{
+		Username:  req.user(),
+		IP:        req.clientIP(),
+		Method:    req.r.Method,
+		Path:      req.r.URL.Path,
+		Action:    action,
+		Detail:    detail,
+		Status:    status,
+		Latency:   time.Since(start).Milliseconds(),
+		UserAgent: truncate(req.r.UserAgent(), 512),
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		ylog.Errorf("S3API", "save operation log failed: %v", err)
+	}
+}
+
+// insertUploadLog 记录上传日志到 t_upload_log（appkey 记为 AK）
+func insertUploadLog(req *request, fileName string, size int64, fileMD5 string, modTime time.Time) {
+	if !common.EnableSqlite {
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		return
+	}
+	log := models.UploadLogModel{
+		IP:               req.clientIP(),
+		Appkey:           req.auth.accessKey,
+		UploadTime:       time.Now().Format("2006-01-02 15:04:05"),
+		FileName:         fileName,
+		FileSize:         utils.FormatSize(size),
+		FileMD5:          fileMD5,
+		FileCreatedTime:  modTime.Unix(),
+		FileModifiedTime: modTime.Unix(),
+	}
+	if err := db.Create(&log).Error; err != nil {
+		ylog.Errorf("S3API", "insert upload log failed: %v", err)
+	}
+}
+
+// ---------- 辅助函数 ----------
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return strings.ToUpper(hex.EncodeToString(b))
+}
+
+// formatTime S3 XML 中的时间格式
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+func truncate(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	return s[:maxLen]
+}
@@ -0,0 +1,628 @@
+package s3api
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"httpcat/internal/common"
+)
+
+// ========== AWS Signature Version 4 校验 ==========
+//
+// 支持三种方式：
+//   - Authorization 头签名（aws-cli / SDK 默认）
+//   - 预签名 URL（X-Amz-Algorithm 等 query 参数）
+//   - aws-chunked 流式上传（STREAMING-AWS4-HMAC-SHA256-PAYLOAD 逐块签名，
+//     以及 STREAMING-UNSIGNED-PAYLOAD-TRAILER 尾部校验和，带签名的 trailer 同时校验 x-amz-trailer-signature）
+//
+// 同 AWS，SignedHeaders 必须包含 host。
+//
+// 密钥对来自 server.http.auth.aksk（common.HttpAkSkMap），与 Open API 共用。
+
+const (
+	signV4Algorithm = "AWS4-HMAC-SHA256"
+	iso8601Format   = "20060102T150405Z"
+
+	unsignedPayload          = "UNSIGNED-PAYLOAD"
+	streamingPayload         = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingPayloadTrailer  = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	streamingUnsignedTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+	emptySHA256              = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	maxClockSkew      = 15 * time.Minute
+	maxPresignExpires = 7 * 24 * time.Hour
+)
+
+var (
+	errContentSHA256Mismatch = errors.New("x-amz-content-sha256 mismatch")
+	errChunkSignature        = errors.New("chunk signature mismatch")
+	errTrailerSignature      = errors.New("trailer signature mismatch")
+	errBadDigest             = errors.New("checksum mismatch")
+	errMalformedChunk        = errors.New("malformed aws-chunked body")
+)
+
+// authContext 签名校验通过后的请求身份
+type authContext struct {
+	accessKey   string
+	secretKey   string
+	amzDate     string
+	scope       string
+	signature   string
+	payloadHash string
+}
+
+// username 调用者的登录身份：S3 密钥对即全局 AK/SK，与 Open API 相同（"openapi:<ak>"）
+func (a *authContext) username() string {
+	return "openapi:" + a.accessKey
+}
+
+// lookupSecretKey 查找 AK 对应的 SK。viper 会把 map 的 key 转为小写，因此这里忽略大小写匹配
+func lookupSecretKey(accessKey string) (string, bool) {
+	if sk, ok := common.HttpAkSkMap[accessKey]; ok {
+		return sk, true
+	}
+	for ak, sk := range common.HttpAkSkMap {
+		if strings.EqualFold(ak, accessKey) {
+			return sk, true
+		}
+	}
+	return "", false
+}
+
+// authenticate 校验请求签名，并按 payload 类型替换 r.Body 以便在读取时校验内容
+func authenticate(r *http.Request) (*authContext, *apiError) {
+	var (
+		ac            *authContext
+		signedHeaders []string
+		presigned     bool
+		err           *apiError
+	)
+	if r.URL.Query().Get("X-Amz-Algorithm") != "" {
+		presigned = true
+		ac, signedHeaders, err = parsePresigned(r)
+	} else {
+		ac, signedHeaders, err = parseAuthHeader(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !containsHost(signedHeaders) {
+		return nil, errHostNotSigned
+	}
+
+	canonical := canonicalRequest(r, signedHeaders, ac.payloadHash, presigned)
+	expected := ac.sign(canonical)
+	if !hmac.Equal([]byte(expected), []byte(ac.signature)) {
+		return nil, errSignatureDoesNotMatch
+	}
+
+	if err := wrapBody(r, ac); err != nil {
+		return nil, err
+	}
+	return ac, nil
+}
+
+func parseAuthHeader(r *http.Request) (*authContext, []string, *apiError) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return nil, nil, errAccessDenied
+	}
+	if !strings.HasPrefix(header, signV4Algorithm+" ") {
+		return nil, nil, errSignatureVersionNotSupported
+	}
+
+	fields := make(map[string]string)
+	for _, part := range strings.Split(strings.TrimPrefix(header, signV4Algorithm+" "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) == 2 {
+			fields[kv[0]] = kv[1]
+		}
+	}
+	if fields["Credential"] == "" || fields["SignedHeaders"] == "" || fields["Signature"] == "" {
+		return nil, nil, errAuthorizationHeaderMalformed
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	if amzDate == "" {
+		if d, err := http.ParseTime(r.Header.Get("Date")); err == nil {
+			amzDate = d.UTC().Format(iso8601Format)
+		}
+	}
+	t, err := time.Parse(iso8601Format, amzDate)
+	if err != nil {
+		return nil, nil, errAccessDeniedMissingDate
+	}
+	if skew := time.Since(t); skew > maxClockSkew || skew < -maxClockSkew {
+		return nil, nil, errRequestTimeTooSkewed
+	}
+
+	ac, apiErr := newAuthContext(fields["Credential"], amzDate)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+	ac.signature = fields["Signature"]
+	ac.payloadHash = r.Header.Get("X-Amz-Content-Sha256")
+	if ac.payloadHash == "" {
+		// 未携带时按 AWS 规则视为对空 body 签名
+		ac.payloadHash = emptySHA256
+	}
+	return ac, strings.Split(fields["SignedHeaders"], ";"), nil
+}
+
+func parsePresigned(r *http.Request) (*authContext, []string, *apiError) {
+	q := r.URL.Query()
+	if q.Get("X-Amz-Algorithm") != signV4Algorithm {
+		return nil, nil, errSignatureVersionNotSupported
+	}
+	amzDate := q.Get("X-Amz-Date")
+	t, err := time.Parse(iso8601Format, amzDate)
+	if err != nil {
+		return nil, nil, errAccessDeniedMissingDate
+	}
+	expires, err := strconv.ParseInt(q.Get("X-Amz-Expires"), 10, 64)
+	if err != nil || expires < 0 || time.Duration(expires)*time.Second > maxPresignExpires {
+		return nil, nil, errInvalidArgument("X-Amz-Expires must be between 0 and 604800 seconds")
+	}
+	now := time.Now()
+	if t.After(now.Add(maxClockSkew)) {
+		return nil, nil, errRequestTimeTooSkewed
+	}
+	if now.After(t.Add(time.Duration(expires) * time.Second)) {
+		return nil, nil, errExpiredPresignRequest
+	}
+
+	ac, apiErr := newAuthContext(q.Get("X-Amz-Credential"), amzDate)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+	ac.signature = q.Get("X-Amz-Signature")
+	ac.payloadHash = unsignedPayload
+	if h := q.Get("X-Amz-Content-Sha256"); h != "" {
+		ac.payloadHash = h
+	}
+	return ac, strings.Split(q.Get("X-Amz-SignedHeaders"), ";"), nil
+}
+
+// containsHost SignedHeaders 是否包含 host（不签 host 的请求可被转发到其他主机重放）
+func containsHost(signedHeaders []string) bool {
+	for _, h := range signedHeaders {
+		if h == "host" {
+			return true
+		}
+	}
+	return false
+}
+
+// newAuthContext 解析 Credential：<AK>/<yyyymmdd>/<region>/s3/aws4_request
+func newAuthContext(credential, amzDate string) (*authContext, *apiError) {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[3] != "s3" || parts[4] != "aws4_request" {
+		return nil, errAuthorizationHeaderMalformed
+	}
+	if !strings.HasPrefix(amzDate, parts[1]) {
+		return nil, errAuthorizationHeaderMalformed
+	}
+	sk, ok := lookupSecretKey(parts[0])
+	if !ok {
+		return nil, errInvalidAccessKeyID
+	}
+	return &authContext{
+		accessKey: parts[0],
+		secretKey: sk,
+		amzDate:   amzDate,
+		scope:     strings.Join(parts[1:], "/"),
+	}, nil
+}
+
+// signingKey 派生签名密钥：HMAC("AWS4"+SK, date) → region → service → "aws4_request"
+func (ac *authContext) signingKey() []byte {
+	parts := strings.Split(ac.scope, "/")
+	key := []byte("AWS4" + ac.secretKey)
+	for _, p := range parts {
+		key = hmacSHA256(key, p)
+	}
+	return key
+}
+
+func (ac *authContext) sign(canonical string) string {
+	stringToSign := strings.Join([]string{
+		signV4Algorithm,
+		ac.amzDate,
+		ac.scope,
+		sha256Hex([]byte(canonical)),
+	}, "\n")
+	return hex.EncodeToString(hmacSHA256(ac.signingKey(), stringToSign))
+}
+
+// chunkSignature aws-chunked 单块签名，prevSignature 为上一块（首块为请求头）的签名
+func (ac *authContext) chunkSignature(prevSignature, chunkSHA256 string) string {
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256-PAYLOAD",
+		ac.amzDate,
+		ac.scope,
+		prevSignature,
+		emptySHA256,
+		chunkSHA256,
+	}, "\n")
+	return hex.EncodeToString(hmacSHA256(ac.signingKey(), stringToSign))
+}
+
+// trailerSignature 带签名 trailer 的签名，prevSignature 为最后一个（0 长度）块的签名，trailers 为规范化的 trailer 头
+func (ac *authContext) trailerSignature(prevSignature, trailers string) string {
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256-TRAILER",
+		ac.amzDate,
+		ac.scope,
+		prevSignature,
+		sha256Hex([]byte(trailers)),
+	}, "\n")
+	return hex.EncodeToString(hmacSHA256(ac.signingKey(), stringToSign))
+}
+
+func canonicalRequest(r *http.Request, signedHeaders []string, payloadHash string, presigned bool) string {
+	// Query：按 key、value 排序，key/value 均按 AWS 规则编码
+	query := r.URL.Query()
+	if presigned {
+		query.Del("X-Amz-Signature")
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, awsURIEncode(k, true)+"="+awsURIEncode(v, true))
+		}
+	}
+
+	var headers strings.Builder
+	for _, h := range signedHeaders {
+		var value string
+		if h == "host" {
+			value = r.Host
+		} else {
+			vals := r.Header.Values(h)
+			for i := range vals {
+				vals[i] = strings.Join(strings.Fields(vals[i]), " ")
+			}
+			value = strings.Join(vals, ",")
+		}
+		headers.WriteString(h + ":" + value + "\n")
+	}
+
+	uri := r.URL.Path
+	if uri == "" {
+		uri = "/"
+	}
+	return strings.Join([]string{
+		r.Method,
+		awsURIEncode(uri, false),
+		strings.Join(pairs, "&"),
+		headers.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+// awsURIEncode 按 SigV4 规则编码：仅保留 A-Z a-z 0-9 - _ . ~，encodeSlash=false 时保留 "/"
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// ---------- 请求体校验 ----------
+
+// wrapBody 根据 payload 类型包装请求体：读取过程中校验 SHA-256 / 块签名 / 校验和，
+// 不通过时 Read 返回错误，存储驱动写入失败后不会留下不完整的文件
+func wrapBody(r *http.Request, ac *authContext) *apiError {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	var body io.Reader = r.Body
+	switch ac.payloadHash {
+	case unsignedPayload:
+	case streamingPayload, streamingPayloadTrailer, streamingUnsignedTrailer:
+		cr := &chunkedReader{
+			r:          bufio.NewReader(r.Body),
+			signed:     ac.payloadHash != streamingUnsignedTrailer,
+			sigTrailer: ac.payloadHash == streamingPayloadTrailer,
+			ac:         ac,
+			prevSig:    ac.signature,
+			chunkHash:  sha256.New(),
+		}
+		if trailer := strings.ToLower(strings.TrimSpace(r.Header.Get("X-Amz-Trailer"))); trailer != "" {
+			cr.trailer = trailer
+			cr.checksum = checksumHash(strings.TrimPrefix(trailer, "x-amz-checksum-"))
+		}
+		body = cr
+		if n, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64); err == nil {
+			r.ContentLength = n
+		} else {
+			r.ContentLength = -1
+		}
+	default:
+		expected, err := hex.DecodeString(ac.payloadHash)
+		if err != nil || len(expected) != sha256.Size {
+			return errInvalidArgument("invalid x-amz-content-sha256")
+		}
+		body = &verifyingReader{r: body, h: sha256.New(), expected: expected, mismatch: errContentSHA256Mismatch}
+	}
+
+	// Content-MD5 与 x-amz-checksum-* 头（非 trailer 方式）
+	if v := r.Header.Get("Content-Md5"); v != "" {
+		expected, err := base64.StdEncoding.DecodeString(v)
+		if err != nil || len(expected) != md5.Size {
+			return errInvalidDigest
+		}
+		body = &verifyingReader{r: body, h: md5.New(), expected: expected, mismatch: errBadDigest}
+	}
+	for _, alg := range []string{"crc32", "crc32c", "sha1", "sha256"} {
+		v := r.Header.Get("X-Amz-Checksum-" + alg)
+		if v == "" {
+			continue
+		}
+		expected, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return errInvalidDigest
+		}
+		body = &verifyingReader{r: body, h: checksumHash(alg), expected: expected, mismatch: errBadDigest}
+	}
+
+	r.Body = readCloser{Reader: body, Closer: r.Body}
+	return nil
+}
+
+func checksumHash(alg string) hash.Hash {
+	switch alg {
+	case "crc32":
+		return crc32.NewIEEE()
+	case "crc32c":
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case "sha1":
+		return sha1.New()
+	case "sha256":
+		return sha256.New()
+	default:
+		// crc64nvme 等暂不校验
+		return nil
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// verifyingReader 读到 EOF 时比较摘要
+type verifyingReader struct {
+	r        io.Reader
+	h        hash.Hash
+	expected []byte
+	mismatch error
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	v.h.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(v.h.Sum(nil), v.expected) {
+		return n, v.mismatch
+	}
+	return n, err
+}
+
+// chunkedReader 解码 aws-chunked 编码：
+//
+//	<hex-size>[;chunk-signature=<sig>]\r\n<data>\r\n ... 0[;chunk-signature=<sig>]\r\n[<trailer>:<value>\r\n]...\r\n
+type chunkedReader struct {
+	r          *bufio.Reader
+	signed     bool
+	sigTrailer bool // trailer 是否带签名（STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER）
+	ac         *authContext
+	prevSig    string
+	chunkSig   string
+	chunkHash  hash.Hash
+	remaining  int64
+	trailer    string
+	checksum   hash.Hash
+	err        error
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	if c.remaining == 0 {
+		size, err := c.readChunkHeader()
+		if err != nil {
+			c.err = err
+			return 0, err
+		}
+		if size == 0 {
+			c.err = c.finish()
+			return 0, c.err
+		}
+		c.remaining = size
+	}
+
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+	c.chunkHash.Write(p[:n])
+	if c.checksum != nil {
+		c.checksum.Write(p[:n])
+	}
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		c.err = err
+		return n, err
+	}
+	if c.remaining == 0 {
+		if err := c.endChunk(); err != nil {
+			c.err = err
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func (c *chunkedReader) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		if err == io.EOF && line == "" {
+			return "", io.EOF
+		}
+		if err != io.EOF {
+			return "", err
+		}
+	}
+	if len(line) > 4096 {
+		return "", errMalformedChunk
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (c *chunkedReader) readChunkHeader() (int64, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return 0, errMalformedChunk
+	}
+	sizeStr, ext, _ := strings.Cut(line, ";")
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeStr), 16, 64)
+	if err != nil || size < 0 {
+		return 0, errMalformedChunk
+	}
+	if c.signed {
+		sig, ok := strings.CutPrefix(strings.TrimSpace(ext), "chunk-signature=")
+		if !ok {
+			return 0, errMalformedChunk
+		}
+		c.chunkSig = sig
+	}
+	c.chunkHash.Reset()
+	return size, nil
+}
+
+// endChunk 读取块尾 \r\n 并校验块签名
+func (c *chunkedReader) endChunk() error {
+	line, err := c.readLine()
+	if err != nil || line != "" {
+		return errMalformedChunk
+	}
+	return c.verifyChunk()
+}
+
+func (c *chunkedReader) verifyChunk() error {
+	if !c.signed {
+		return nil
+	}
+	expected := c.ac.chunkSignature(c.prevSig, hex.EncodeToString(c.chunkHash.Sum(nil)))
+	if !hmac.Equal([]byte(expected), []byte(c.chunkSig)) {
+		return errChunkSignature
+	}
+	c.prevSig = c.chunkSig
+	return nil
+}
+
+// finish 处理最后的 0 长度块与 trailer，全部通过时返回 io.EOF
+func (c *chunkedReader) finish() error {
+	if err := c.verifyChunk(); err != nil {
+		return err
+	}
+	trailers := make(map[string]string)
+	var canonical strings.Builder
+	for {
+		line, err := c.readLine()
+		if err == io.EOF || line == "" {
+			break
+		}
+		if err != nil {
+			return errMalformedChunk
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return errMalformedChunk
+		}
+		name, value = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(value)
+		trailers[name] = value
+		if name != "x-amz-trailer-signature" {
+			canonical.WriteString(name + ":" + value + "\n")
+		}
+	}
+	if c.sigTrailer {
+		expected := c.ac.trailerSignature(c.prevSig, canonical.String())
+		if !hmac.Equal([]byte(expected), []byte(trailers["x-amz-trailer-signature"])) {
+			return errTrailerSignature
+		}
+	}
+	if c.trailer != "" && c.checksum != nil {
+		expected, err := base64.StdEncoding.DecodeString(trailers[c.trailer])
+		if err != nil || !bytes.Equal(expected, c.checksum.Sum(nil)) {
+			return errBadDigest
+		}
+	}
+	return io.EOF
+}
+
+// bodyError 将读取请求体时的校验错误转换为 S3 错误
+func bodyError(err error) *apiError {
+	switch {
+	case errors.Is(err, errContentSHA256Mismatch):
+		return errXAmzContentSHA256Mismatch
+	case errors.Is(err, errChunkSignature), errors.Is(err, errTrailerSignature):
+		return errSignatureDoesNotMatch
+	case errors.Is(err, errBadDigest):
+		return errBadDigestErr
+	case errors.Is(err, errMalformedChunk), errors.Is(err, io.ErrUnexpectedEOF):
+		return errIncompleteBody
+	default:
+		return nil
+	}
+}
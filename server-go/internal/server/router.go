@@ -40,6 +40,9 @@ func RegisterRouter(r *gin.Engine) {
 	// 注册分享公开路由（无需认证，必须在前端 NoRoute 之前）
 	registerSharePublicRoutes(r)
 
+	// v0.8.0: 注册 WebDAV 路由（自带认证，且不经过 Cors 中间件：OPTIONS 需要返回 DAV 能力头）
+	registerWebDAVRoutes(r)
+
 	registerForFrontEnd(r)
 
 	r.Use(midware.Metrics())
@@ -50,10 +53,14 @@ func RegisterRouter(r *gin.Engine) {
 	)
 	r.Use(Cors())
 
+	// v0.8.0: 注册预签名 URL 公开路由（凭签名访问，无需认证；须在 Cors 之后注册，供浏览器跨域直传 / 下载）
+	registerPresignedRoutes(r)
+
 	apiv1Group = r.Group("/api/v1")
 	{
 		apiv1Group.Use(midware.TokenOrAKSKAuth())
-		//apiv1Group.Use(midware.RBACAuth())
+		// v0.8.0: 按角色校验接口权限（路由与权限的对应关系见 midware/rbac.go）
+		apiv1Group.Use(midware.RBACAuth())
 
 		// v0.6.0: 操作日志记录中间件（在认证之后）
 		apiv1Group.Use(v1.OperationLogger())
@@ -72,6 +79,12 @@ func RegisterRouter(r *gin.Engine) {
 		{
 			// v0.7.0: 登录接口加限流（防止密码爆破）
 			userRouter.POST("/login/account", midware.LoginRateLimit(), v1.UserLogin)
+			userRouter.POST("/login/2fa", midware.LoginRateLimit(), v1.UserLoginTwoFactor)
+			userRouter.POST("/login/refresh", midware.LoginRateLimit(), v1.RefreshToken)
+			// v0.8.0: OpenID Connect 单点登录
+			userRouter.GET("/oidc/config", v1.GetOIDCConfig)
+			userRouter.GET("/oidc/login", v1.OIDCLogin)
+			userRouter.GET("/oidc/callback", midware.LoginRateLimit(), v1.OIDCCallback)
 			userRouter.GET("/currentUser", v1.UserInfo)
 			userRouter.POST("/login/outLogin", v1.UserLoginout)
 			userRouter.POST("/changePasswd", v1.ChangePasswd)
@@ -86,6 +99,38 @@ func RegisterRouter(r *gin.Engine) {
 			userRouter.POST("/createUploadToken", v1.CreateUploadToken)
 			userRouter.POST("/checkUploadToken", v1.CheckUploadToken)
 
+			// v0.8.0: SSH 公钥（SFTP 公钥登录）
+			userRouter.GET("/sshKeys", v1.ListSSHKeys)
+			userRouter.POST("/sshKeys", v1.AddSSHKey)
+			userRouter.DELETE("/sshKeys/:id", v1.DeleteSSHKey)
+
+			// v0.8.0: TOTP 两步验证
+			userRouter.GET("/2fa/status", v1.GetTwoFactorStatus)
+			userRouter.POST("/2fa/enroll", v1.EnrollTwoFactor)
+			userRouter.POST("/2fa/activate", v1.ActivateTwoFactor)
+			userRouter.POST("/2fa/disable", v1.DisableTwoFactor)
+			userRouter.POST("/2fa/recoveryCodes", v1.RegenerateRecoveryCodes)
+			userRouter.POST("/2fa/reset", v1.ResetUserTwoFactor)
+
+			// v0.8.0: 登录会话
+			userRouter.GET("/sessions", v1.ListSessions)
+			userRouter.DELETE("/sessions/:id", v1.RevokeUserSession)
+			userRouter.POST("/sessions/revokeAll", v1.RevokeAllSessions)
+
+			// v0.8.0: 个人 API Key（AK/SK 签名）
+			userRouter.GET("/apiKeys", v1.ListAPIKeys)
+			userRouter.POST("/apiKeys", v1.CreateAPIKey)
+			userRouter.DELETE("/apiKeys/:id", v1.RevokeAPIKey)
+
+			// v0.8.0: 用户管理（仅管理员）
+			userRouter.GET("/list", v1.ListUsers)
+			userRouter.POST("/create", v1.CreateUser)
+			userRouter.POST("/role", v1.SetUserRole)
+			userRouter.POST("/group", v1.SetUserGroup)
+			userRouter.POST("/disable", v1.DisableUser)
+			userRouter.POST("/resetPasswd", v1.ResetUserPassword)
+			userRouter.DELETE("/delete", v1.DeleteUser)
+
 			// 统计信息
 			// 数据概览 Data Overview
 			userRouter.GET("/dataOverview", v1.DataOverview)
@@ -139,6 +184,55 @@ func RegisterRouter(r *gin.Engine) {
 			fileRouter.POST("/upload/chunk", v1.UploadChunk)
 			fileRouter.POST("/upload/complete", v1.CompleteChunkUpload)
 			fileRouter.POST("/upload/abort", v1.AbortChunkUpload)
+
+			// v0.8.0: tus 1.0 断点续传协议（creation / termination / checksum / expiration）
+			fileRouter.POST("/tus", v1.TusCreate)
+			fileRouter.HEAD("/tus/:id", v1.TusHead)
+			fileRouter.PATCH("/tus/:id", v1.TusPatch)
+			fileRouter.DELETE("/tus/:id", v1.TusDelete)
+			fileRouter.POST("/tus/:id", v1.TusMethodOverride)
+
+			// v0.8.0: 文件历史版本（上传覆盖前自动保存）
+			fileRouter.GET("/versions", v1.ListFileVersions)
+			fileRouter.GET("/versions/download", v1.DownloadFileVersion)
+			fileRouter.POST("/versions/restore", v1.RestoreFileVersion)
+
+			// v0.8.0: 预签名下载链接（凭链接下载的公开路由见 registerPresignedRoutes）
+			fileRouter.POST("/presign", v1.CreatePresignedDownload)
+			fileRouter.POST("/presignUpload", v1.CreatePresignedUpload)
+		}
+
+		// v0.8.0: 回收站
+		trashRouter := apiv1Group.Group("/trash")
+		{
+			trashRouter.GET("/list", v1.ListTrash)
+			trashRouter.POST("/restore", v1.RestoreTrash)
+			trashRouter.POST("/purge", v1.PurgeTrash)
+		}
+
+		// v0.8.0: 存储配额
+		quotaRouter := apiv1Group.Group("/quota")
+		{
+			quotaRouter.GET("/usage", v1.GetQuotaUsage)
+			quotaRouter.GET("/list", v1.ListQuotas)
+			quotaRouter.POST("", v1.SetQuota)
+			quotaRouter.DELETE("/:id", v1.DeleteQuota)
+		}
+
+		// v0.8.0: 用户主目录与共享目录授权
+		aclRouter := apiv1Group.Group("/acl")
+		{
+			aclRouter.GET("/mine", v1.GetMyScope)
+			aclRouter.GET("/list", v1.ListPathACLs)
+			aclRouter.POST("", v1.SetPathACL)
+			aclRouter.DELETE("/:id", v1.DeletePathACL)
+		}
+
+		// v0.8.0: 静态加密
+		encryptionRouter := apiv1Group.Group("/storage/encryption")
+		{
+			encryptionRouter.GET("/status", v1.GetEncryptionStatus)
+			encryptionRouter.POST("/rotate", v1.RotateEncryptionKey)
 		}
 
 		imageManageRouter := apiv1Group.Group("/imageManage")
@@ -203,6 +297,49 @@ func registerSharePublicRoutes(r *gin.Engine) {
 	ylog.Infof("RegisterRouter", "Share public routes registered at /s/* (anonymous_access=%v)", common.ShareAnonymousAccess)
 }
 
+// registerPresignedRoutes 注册预签名 URL 的公开路由（签名校验在 handler 内完成）
+func registerPresignedRoutes(r *gin.Engine) {
+	presigned := r.Group("/api/v1/presigned")
+	{
+		presigned.GET("/download", v1.DownloadPresigned)
+		presigned.HEAD("/download", v1.DownloadPresigned)
+
+		// 上传链接：校验后以签发用户身份复用普通上传与分片上传的 handler
+		upload := presigned.Group("/upload", v1.PresignedUploadAuth)
+		{
+			upload.PUT("", v1.PutPresignedUpload)
+			upload.POST("", v1.UploadFile)
+			upload.POST("/init", v1.InitChunkUpload)
+			upload.GET("/status", v1.GetChunkUploadStatus)
+			upload.POST("/chunk", v1.UploadChunk)
+			upload.POST("/complete", v1.CompleteChunkUpload)
+			upload.POST("/abort", v1.AbortChunkUpload)
+		}
+	}
+}
+
+// registerWebDAVRoutes 注册 WebDAV 路由（server.webdav.prefix）
+func registerWebDAVRoutes(r *gin.Engine) {
+	if !common.WebDAVEnable {
+		ylog.Infof("RegisterRouter", "WebDAV is disabled")
+		return
+	}
+
+	davHandler := v1.WebDAV()
+	davGroup := r.Group(common.WebDAVPrefix)
+	davGroup.Use(midware.WebDAVAuth(), midware.WebDAVRBAC(), v1.OperationLogger())
+	{
+		for _, method := range []string{
+			http.MethodOptions, http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete,
+			"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK",
+		} {
+			davGroup.Handle(method, "", davHandler)
+			davGroup.Handle(method, "/*path", davHandler)
+		}
+	}
+	ylog.Infof("RegisterRouter", "WebDAV registered at %s/*", common.WebDAVPrefix)
+}
+
 // registerMCPRoutes 注册 MCP Server 路由
 func registerMCPRoutes(r *gin.Engine) {
 	// 检查是否启用 MCP
@@ -244,15 +381,19 @@ func Cors() gin.HandlerFunc {
 
 			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 			c.Header("Access-Control-Allow-Origin", "*")
-			c.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE,UPDATE")
-			c.Header("Access-Control-Allow-Headers", "Authorization, Content-Length, X-CSRF-Token, Token,session,X_Requested_With,Accept, Origin, Host, Connection, Accept-Encoding, Accept-Language,DNT, X-CustomHeader, Keep-Alive, User-Agent, X-Requested-With, If-Modified-Since, Cache-Control, Content-Type, Pragma, AccessKey, Signature, TimeStamp, UploadToken")
-			c.Header("Access-Control-Expose-Headers", "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers,Cache-Control,Content-Language,Content-Type,Expires,Last-Modified,Pragma,FooBar,Content-Disposition, token")
+			c.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE,UPDATE, HEAD, PATCH")
+			c.Header("Access-Control-Allow-Headers", "Authorization, Content-Length, X-CSRF-Token, Token,session,X_Requested_With,Accept, Origin, Host, Connection, Accept-Encoding, Accept-Language,DNT, X-CustomHeader, Keep-Alive, User-Agent, X-Requested-With, If-Modified-Since, Cache-Control, Content-Type, Pragma, AccessKey, Signature, TimeStamp, UploadToken, Tus-Resumable, Upload-Length, Upload-Offset, Upload-Metadata, Upload-Checksum, X-HTTP-Method-Override")
+			c.Header("Access-Control-Expose-Headers", "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers,Cache-Control,Content-Language,Content-Type,Expires,Last-Modified,Pragma,FooBar,Content-Disposition, token, Location, Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size, Tus-Checksum-Algorithm, Upload-Offset, Upload-Length, Upload-Expires, Upload-Metadata")
 			c.Header("Access-Control-Max-Age", "172800")
 			c.Header("Access-Control-Allow-Credentials", "false")
 			c.Set("content-type", "application/json")
 		}
 
 		if method == "OPTIONS" {
+			// v0.8.0: tus 客户端通过 OPTIONS 探测服务端能力
+			if strings.HasPrefix(c.Request.URL.Path, "/api/v1/file/tus") {
+				v1.TusDiscoveryHeaders(c)
+			}
 			c.JSON(http.StatusOK, "")
 		}
 		c.Next()
@@ -2,6 +2,8 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net/http"
 	"os"
@@ -9,6 +11,10 @@ import (
 
 	"httpcat/internal/common"
 	"httpcat/internal/common/ylog"
+	v1 "httpcat/internal/handler/v1"
+	"httpcat/internal/s3api"
+	"httpcat/internal/sftpd"
+	"httpcat/internal/storage"
 
 	"github.com/gin-gonic/gin"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
@@ -47,6 +53,23 @@ func GracefulShutdown() {
 	os.Exit(0)
 }
 
+// clientCertTLSConfig 以 server.ssl.cafile 校验客户端证书的 TLS 配置；optional 模式下未提供证书的连接照常建立
+func clientCertTLSConfig() (*tls.Config, error) {
+	pem, err := os.ReadFile(common.SSLCaFile)
+	if err != nil {
+		return nil, fmt.Errorf("read ca file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in ca file %s", common.SSLCaFile)
+	}
+	clientAuth := tls.VerifyClientCertIfGiven
+	if common.ClientCertMode == common.ClientCertModeRequire {
+		clientAuth = tls.RequireAndVerifyClientCert
+	}
+	return &tls.Config{ClientCAs: pool, ClientAuth: clientAuth}, nil
+}
+
 func RunAPIServer(port int, enableSSL, enableAuth bool, certFile, keyFile string) {
 	//生成一个 Engine，这是 gin 的核心，默认带有 Logger 和 Recovery 两个中间件
 	router := gin.Default()
@@ -63,13 +86,37 @@ func RunAPIServer(port int, enableSSL, enableAuth bool, certFile, keyFile string
 		// 一个连接在空闲状态下可以存在的最长时间。
 		IdleTimeout: time.Duration(common.HttpIdleTimeout) * time.Second,
 	}
+	// v0.8.0 新增：客户端证书（mTLS）认证
+	if enableSSL && common.ClientCertEnabled() {
+		tlsConfig, err := clientCertTLSConfig()
+		if err != nil {
+			ylog.Fatalf("RunServer", "client certificate auth: %v", err)
+		}
+		httpSrv.TLSConfig = tlsConfig
+		ylog.Infof("RunServer", "client certificate auth enabled, mode=%s identity=%s", common.ClientCertMode, common.ClientCertIdentity)
+	}
 	ctx := context.Background()
 
+	// v0.8.0 新增：外部改动（文件系统监听 / 对账发现）同步到上传日志、图片表与分享，须在初始化存储驱动前订阅
+	v1.SubscribeExternalChanges()
+	// v0.8.0 新增：启动时初始化存储驱动，文件索引对账等后台任务随服务启动
+	storage.Backend()
+
 	enableP2P := common.P2pEnable
 	if enableP2P {
 		go runP2PServer(ctx, router)
 	}
 
+	// v0.8.0 新增：S3 兼容网关（独立端口）
+	if common.S3ApiEnable {
+		go s3api.Run(common.S3ApiPort, enableSSL, certFile, keyFile)
+	}
+
+	// v0.8.0 新增：内置 SFTP 服务（独立端口）
+	if common.SftpEnable {
+		go sftpd.Run(common.SftpPort)
+	}
+
 	var err error
 	ylog.Infof("RunServer", "####HTTP_LISTEN_ON:%d", port)
 	if enableSSL {
@@ -0,0 +1,293 @@
+package sftpd
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/utils"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/midware"
+	"httpcat/internal/models"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ========== v0.8.0 新增：内置 SFTP 服务 ==========
+//
+// 在独立端口提供 SFTP（仅 sftp 子系统，不提供 shell / exec / 端口转发），
+// 根目录与 Web 界面的文件管理目录（DownloadFS）一致，所有访问都经由存储驱动：
+//   1. 用户与 t_user 共用：密码走 CheckUser（计入登录限流），或使用用户在 t_ssh_key 中登记的公钥
+//   2. 上传写入 t_upload_log，上传 / 删除 / 重命名 / 建目录写入 t_operation_log，与 HTTP 接口一致
+//   3. 主机密钥不存在时自动生成 ed25519 密钥并持久化，保证客户端 known_hosts 稳定
+//   4. 开启 home_dirs 后按登录用户的目录视图（storage.ScopeFor）访问，与 Web 界面一致
+
+const (
+	// handshakeTimeout SSH 握手（含认证）最长耗时
+	handshakeTimeout = 30 * time.Second
+	logTag           = "SFTP"
+)
+
+var errAuthFailed = errors.New("authentication failed")
+
+// Run 启动 SFTP 服务（阻塞）
+func Run(port int) {
+	config, err := newServerConfig()
+	if err != nil {
+		ylog.Errorf(logTag, "init sftp server failed: %v", err)
+		return
+	}
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		ylog.Errorf(logTag, "sftp server listen error: %v", err)
+		return
+	}
+	ylog.Infof(logTag, "####SFTP_LISTEN_ON:%d", port)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			ylog.Errorf(logTag, "accept error: %v", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		go handleConn(conn, config)
+	}
+}
+
+func newServerConfig() (*ssh.ServerConfig, error) {
+	signer, err := loadHostKey(common.SftpHostKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback:  passwordCallback,
+		PublicKeyCallback: publicKeyCallback,
+		MaxAuthTries:      6,
+		ServerVersion:     "SSH-2.0-httpcat",
+	}
+	config.AddHostKey(signer)
+	return config, nil
+}
+
+// loadHostKey 读取主机私钥，文件不存在时生成 ed25519 密钥并写入
+func loadHostKey(file string) (ssh.Signer, error) {
+	data, err := os.ReadFile(file)
+	if err == nil {
+		return ssh.ParsePrivateKey(data)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "httpcat sftp host key")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(file, pem.EncodeToMemory(block), 0600); err != nil {
+		return nil, err
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	ylog.Infof(logTag, "generated sftp host key %s (%s)", file, ssh.FingerprintSHA256(signer.PublicKey()))
+	return signer, nil
+}
+
+func remoteIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+func passwordCallback(meta ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+	ip := remoteIP(meta.RemoteAddr())
+	if locked, _ := midware.LoginRateLimitCheck(ip); locked {
+		return nil, errAuthFailed
+	}
+	user, err := midware.CheckUser(meta.User(), string(password))
+	if err != nil {
+		midware.RecordLoginFailure(ip)
+		ylog.Warnf(logTag, "password auth failed for user=%s ip=%s", meta.User(), ip)
+		return nil, errAuthFailed
+	}
+	if common.TwoFactorEnabled(user.Username) {
+		// SFTP 无法输入验证码，启用两步验证的用户只能使用公钥登录
+		ylog.Warnf(logTag, "reject password auth of user=%s ip=%s: two-factor authentication is enabled", meta.User(), ip)
+		return nil, errAuthFailed
+	}
+	midware.RecordLoginSuccess(ip)
+	if common.MustChangePassword(user) {
+		// 需先在 Web 界面修改初始密码
+		ylog.Warnf(logTag, "user=%s must change password before using sftp", meta.User())
+		return nil, errAuthFailed
+	}
+	return &ssh.Permissions{Extensions: map[string]string{"auth": "password"}}, nil
+}
+
+// publicKeyCallback 公钥认证；客户端探测公钥时也会调用，此时不计入登录失败
+func publicKeyCallback(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	ip := remoteIP(meta.RemoteAddr())
+	if locked, _ := midware.LoginRateLimitCheck(ip); locked {
+		return nil, errAuthFailed
+	}
+	user := common.GetUser(meta.User())
+	if user == nil || user.Disabled || common.MustChangePassword(user) {
+		return nil, errAuthFailed
+	}
+	record, err := common.FindSSHKey(meta.User(), ssh.FingerprintSHA256(key))
+	if err != nil {
+		return nil, errAuthFailed
+	}
+	stored, _, _, _, err := ssh.ParseAuthorizedKey([]byte(record.PublicKey))
+	if err != nil || !bytes.Equal(stored.Marshal(), key.Marshal()) {
+		return nil, errAuthFailed
+	}
+	return &ssh.Permissions{Extensions: map[string]string{
+		"auth":   "publickey",
+		"key-id": strconv.FormatUint(uint64(record.ID), 10),
+	}}, nil
+}
+
+// session 一个已认证的 SSH 连接
+type session struct {
+	user          string
+	ip            string
+	clientVersion string
+}
+
+func handleConn(nConn net.Conn, config *ssh.ServerConfig) {
+	defer nConn.Close()
+
+	_ = nConn.SetDeadline(time.Now().Add(handshakeTimeout))
+	sconn, chans, reqs, err := ssh.NewServerConn(nConn, config)
+	if err != nil {
+		ylog.Debugf(logTag, "handshake from %s failed: %v", nConn.RemoteAddr(), err)
+		return
+	}
+	_ = nConn.SetDeadline(time.Time{})
+	defer sconn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	sess := &session{
+		user:          sconn.User(),
+		ip:            remoteIP(sconn.RemoteAddr()),
+		clientVersion: string(sconn.ClientVersion()),
+	}
+	auth := sconn.Permissions.Extensions["auth"]
+	if id, err := strconv.ParseUint(sconn.Permissions.Extensions["key-id"], 10, 64); err == nil {
+		common.TouchSSHKey(uint(id))
+	}
+	sess.saveOperationLog("sftp_login", "/", fmt.Sprintf("SFTP 登录（%s）", auth), http.StatusOK, time.Now())
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			ylog.Errorf(logTag, "accept channel failed: %v", err)
+			continue
+		}
+		go sess.serveChannel(channel, requests)
+	}
+}
+
+// serveChannel 只接受 "subsystem sftp" 请求，拒绝 shell / exec / pty 等
+func (s *session) serveChannel(channel ssh.Channel, requests <-chan *ssh.Request) {
+	started := false
+	for req := range requests {
+		ok := false
+		if req.Type == "subsystem" && !started {
+			var payload struct{ Name string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err == nil && payload.Name == "sftp" {
+				ok = true
+			}
+		}
+		if req.WantReply {
+			_ = req.Reply(ok, nil)
+		}
+		if ok {
+			started = true
+			go func() {
+				newServer(s, channel).serve()
+				_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+				_ = channel.Close()
+			}()
+		}
+	}
+	if !started {
+		_ = channel.Close()
+	}
+}
+
+// saveOperationLog 记录 SFTP 操作日志到 t_operation_log（Method 固定为 SFTP，Path 为文件路径）
+func (s *session) saveOperationLog(action, filePath, detail string, status int, start time.Time) {
+	if !common.EnableSqlite {
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		return
+	}
+	entry := models.OperationLogModel{
+		Username:  s.user,
+		IP:        s.ip,
+		Method:    "SFTP",
+		Path:      filePath,
+		Action:    action,
+		Detail:    detail,
+		Status:    status,
+		Latency:   time.Since(start).Milliseconds(),
+		UserAgent: s.clientVersion,
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		ylog.Errorf(logTag, "save operation log failed: %v", err)
+	}
+}
+
+// insertUploadLog 记录上传日志到 t_upload_log（appkey 记为用户名）
+func (s *session) insertUploadLog(fileName string, size int64, fileMD5 string, modTime time.Time) {
+	if !common.EnableSqlite {
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		return
+	}
+	log := models.UploadLogModel{
+		IP:               s.ip,
+		Appkey:           s.user,
+		UploadTime:       time.Now().Format("2006-01-02 15:04:05"),
+		FileName:         fileName,
+		FileSize:         utils.FormatSize(size),
+		FileMD5:          fileMD5,
+		FileCreatedTime:  modTime.Unix(),
+		FileModifiedTime: modTime.Unix(),
+	}
+	if err := db.Create(&log).Error; err != nil {
+		ylog.Errorf(logTag, "insert upload log failed: %v", err)
+	}
+}
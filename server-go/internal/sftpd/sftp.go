@@ -0,0 +1,982 @@
+package sftpd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/utils"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/storage"
+)
+
+// SFTP 协议版本 3（draft-ietf-secsh-filexfer-02），OpenSSH / WinSCP / FileZilla 等客户端均以此为基线。
+// 请求按顺序处理；符号链接与扩展请求返回 OP_UNSUPPORTED。
+
+const (
+	fxpInit     = 1
+	fxpVersion  = 2
+	fxpOpen     = 3
+	fxpClose    = 4
+	fxpRead     = 5
+	fxpWrite    = 6
+	fxpLstat    = 7
+	fxpFstat    = 8
+	fxpSetstat  = 9
+	fxpFsetstat = 10
+	fxpOpendir  = 11
+	fxpReaddir  = 12
+	fxpRemove   = 13
+	fxpMkdir    = 14
+	fxpRmdir    = 15
+	fxpRealpath = 16
+	fxpStat     = 17
+	fxpRename   = 18
+	fxpStatus   = 101
+	fxpHandle   = 102
+	fxpData     = 103
+	fxpName     = 104
+	fxpAttrs    = 105
+)
+
+const (
+	fxOK               = 0
+	fxEOF              = 1
+	fxNoSuchFile       = 2
+	fxPermissionDenied = 3
+	fxFailure          = 4
+	fxBadMessage       = 5
+	fxOpUnsupported    = 8
+)
+
+const (
+	fxfRead   = 0x01
+	fxfWrite  = 0x02
+	fxfAppend = 0x04
+	fxfCreat  = 0x08
+	fxfTrunc  = 0x10
+	fxfExcl   = 0x20
+)
+
+const (
+	attrSize        = 0x01
+	attrUIDGID      = 0x02
+	attrPermissions = 0x04
+	attrACModTime   = 0x08
+	attrExtended    = 0x80000000
+)
+
+const (
+	sftpVersion = 3
+	// maxPacketSize 单个请求包上限（客户端写入块一般为 32KB，WinSCP 等最大 256KB）
+	maxPacketSize = 1 << 20
+	// maxReadSize 单次 READ 返回的最大字节数
+	maxReadSize = 256 << 10
+	// readdirBatch 每次 READDIR 返回的条目数
+	readdirBatch = 100
+	// maxHandles 单个会话同时打开的句柄数上限
+	maxHandles = 256
+)
+
+// statusError 携带 SFTP 状态码的错误
+type statusError struct {
+	code uint32
+	msg  string
+}
+
+func (e *statusError) Error() string { return e.msg }
+
+var (
+	errBadMessage    = &statusError{fxBadMessage, "bad message"}
+	errNoSuchFile    = &statusError{fxNoSuchFile, "no such file"}
+	errPermission    = &statusError{fxPermissionDenied, "permission denied"}
+	errUnsupported   = &statusError{fxOpUnsupported, "operation unsupported"}
+	errInvalidHandle = &statusError{fxFailure, "invalid handle"}
+	errIsDir         = &statusError{fxFailure, "is a directory"}
+	errNotDir        = &statusError{fxFailure, "not a directory"}
+	errExist         = &statusError{fxFailure, "file already exists"}
+	errTooLarge      = &statusError{fxFailure, "file too large"}
+	errTooManyFiles  = &statusError{fxFailure, "too many open handles"}
+)
+
+// toStatus 将错误映射为 SFTP 状态码；驱动内部错误不回传细节（可能包含服务器本地路径）
+func toStatus(err error) (uint32, string) {
+	var se *statusError
+	switch {
+	case err == nil:
+		return fxOK, "OK"
+	case errors.As(err, &se):
+		return se.code, se.msg
+	case errors.Is(err, io.EOF):
+		return fxEOF, "EOF"
+	case storage.IsNotExist(err):
+		return fxNoSuchFile, "no such file"
+	case errors.Is(err, fs.ErrPermission), errors.Is(err, storage.ErrInvalidPath):
+		return fxPermissionDenied, "permission denied"
+	case errors.Is(err, storage.ErrQuotaExceeded):
+		return fxFailure, err.Error()
+	default:
+		return fxFailure, "failure"
+	}
+}
+
+// ---------- 编解码 ----------
+
+type packetReader struct {
+	b   []byte
+	err error
+}
+
+func (r *packetReader) u32() uint32 {
+	if len(r.b) < 4 {
+		r.err = errBadMessage
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.b)
+	r.b = r.b[4:]
+	return v
+}
+
+func (r *packetReader) u64() uint64 {
+	if len(r.b) < 8 {
+		r.err = errBadMessage
+		return 0
+	}
+	v := binary.BigEndian.Uint64(r.b)
+	r.b = r.b[8:]
+	return v
+}
+
+func (r *packetReader) bytes() []byte {
+	n := r.u32()
+	if r.err != nil || uint64(n) > uint64(len(r.b)) {
+		r.err = errBadMessage
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *packetReader) str() string { return string(r.bytes()) }
+
+// fileAttrs 客户端传入的属性，仅关心 size（FSETSTAT 截断）
+type fileAttrs struct {
+	flags uint32
+	size  uint64
+}
+
+func (r *packetReader) attrs() fileAttrs {
+	var a fileAttrs
+	a.flags = r.u32()
+	if a.flags&attrSize != 0 {
+		a.size = r.u64()
+	}
+	if a.flags&attrUIDGID != 0 {
+		r.u32()
+		r.u32()
+	}
+	if a.flags&attrPermissions != 0 {
+		r.u32()
+	}
+	if a.flags&attrACModTime != 0 {
+		r.u32()
+		r.u32()
+	}
+	if a.flags&attrExtended != 0 {
+		for n := r.u32(); n > 0 && r.err == nil; n-- {
+			r.str()
+			r.str()
+		}
+	}
+	return a
+}
+
+// packet 响应包，前 4 字节为长度（发送时回填）
+type packet []byte
+
+func newPacket(typ byte, id uint32) packet {
+	return packet{0, 0, 0, 0, typ}.u32(id)
+}
+
+func (p packet) u32(v uint32) packet { return binary.BigEndian.AppendUint32(p, v) }
+func (p packet) u64(v uint64) packet { return binary.BigEndian.AppendUint64(p, v) }
+func (p packet) str(s string) packet { return append(p.u32(uint32(len(s))), s...) }
+
+func (p packet) attrs(info fs.FileInfo) packet {
+	mode := uint32(0o100644)
+	if info.IsDir() {
+		mode = 0o40755
+	}
+	mtime := uint32(modTime(info).Unix())
+	return p.u32(attrSize | attrPermissions | attrACModTime).
+		u64(uint64(info.Size())).
+		u32(mode).
+		u32(mtime).
+		u32(mtime)
+}
+
+// modTime 对象存储中的目录没有修改时间（零值），按 Unix 纪元返回
+func modTime(info fs.FileInfo) time.Time {
+	if t := info.ModTime(); !t.IsZero() {
+		return t
+	}
+	return time.Unix(0, 0)
+}
+
+// longName `ls -l` 风格的展示名（READDIR 返回，客户端直接展示）
+func longName(info fs.FileInfo, owner string) string {
+	mode := "-rw-r--r--"
+	if info.IsDir() {
+		mode = "drwxr-xr-x"
+	}
+	mtime := modTime(info)
+	layout := "Jan _2 15:04"
+	if time.Since(mtime) > 180*24*time.Hour || mtime.After(time.Now()) {
+		layout = "Jan _2  2006"
+	}
+	return fmt.Sprintf("%s    1 %-8s %-8s %8d %s %s", mode, owner, owner, info.Size(), mtime.Format(layout), info.Name())
+}
+
+// ---------- 句柄 ----------
+
+// readHandle 只读打开的文件
+type readHandle struct {
+	f    storage.File
+	info fs.FileInfo
+	pos  int64
+}
+
+func (h *readHandle) readAt(p []byte, offset int64) (int, error) {
+	if offset >= h.info.Size() {
+		return 0, io.EOF
+	}
+	if offset != h.pos {
+		if _, err := h.f.Seek(offset, io.SeekStart); err != nil {
+			return 0, err
+		}
+		h.pos = offset
+	}
+	n, err := io.ReadFull(h.f, p)
+	h.pos += int64(n)
+	if n > 0 {
+		return n, nil
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return 0, err
+}
+
+// writeHandle 写入打开的文件：内容先写本地临时文件（客户端可乱序、并发写块），CLOSE 时整体提交到存储驱动
+type writeHandle struct {
+	name   string
+	tmp    *os.File
+	size   int64
+	append bool
+	dirty  bool // 是否需要提交（新建、截断或写入过）
+	start  time.Time
+}
+
+func (h *writeHandle) writeAt(p []byte, offset int64) error {
+	if h.append {
+		offset = h.size
+	}
+	end := offset + int64(len(p))
+	if common.UploadPolicyFSizeLimit > 0 && end > common.UploadPolicyFSizeLimit {
+		return errTooLarge
+	}
+	if _, err := h.tmp.WriteAt(p, offset); err != nil {
+		return err
+	}
+	if end > h.size {
+		h.size = end
+	}
+	h.dirty = true
+	return nil
+}
+
+func (h *writeHandle) discard() {
+	_ = h.tmp.Close()
+	_ = os.Remove(h.tmp.Name())
+}
+
+// writeInfo 写入中文件的 FileInfo
+type writeInfo struct {
+	name string
+	size int64
+}
+
+func (i writeInfo) Name() string       { return i.name }
+func (i writeInfo) Size() int64        { return i.size }
+func (i writeInfo) Mode() fs.FileMode  { return 0o644 }
+func (i writeInfo) ModTime() time.Time { return time.Now() }
+func (i writeInfo) IsDir() bool        { return false }
+func (i writeInfo) Sys() interface{}   { return nil }
+
+// dirHandle OPENDIR 打开的目录，entries 为尚未返回的条目
+type dirHandle struct {
+	info    fs.FileInfo
+	entries []fs.FileInfo
+}
+
+// ---------- 服务端 ----------
+
+type server struct {
+	sess    *session
+	ch      io.ReadWriter
+	scope   *storage.UserScope // 登录用户的目录视图，nil 表示不受限
+	fsys    storage.Driver
+	handles map[string]interface{}
+	nextID  uint64
+}
+
+func newServer(sess *session, ch io.ReadWriter) *server {
+	scope := storage.ScopeFor(sess.user)
+	return &server{
+		sess:    sess,
+		ch:      ch,
+		scope:   scope,
+		fsys:    scope.FS(storage.DownloadFS()),
+		handles: make(map[string]interface{}),
+	}
+}
+
+func (s *server) serve() {
+	defer s.closeAll()
+	r := bufio.NewReaderSize(s.ch, 64<<10)
+	var header [4]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return
+		}
+		length := binary.BigEndian.Uint32(header[:])
+		if length == 0 || length > maxPacketSize {
+			ylog.Warnf(logTag, "user=%s invalid packet length %d", s.sess.user, length)
+			return
+		}
+		pkt := make([]byte, length)
+		if _, err := io.ReadFull(r, pkt); err != nil {
+			return
+		}
+		if err := s.dispatch(pkt); err != nil {
+			return
+		}
+	}
+}
+
+// closeAll 连接断开时释放句柄；未 CLOSE 的写入视为中断，不提交
+func (s *server) closeAll() {
+	for id, h := range s.handles {
+		switch h := h.(type) {
+		case *readHandle:
+			_ = h.f.Close()
+		case *writeHandle:
+			h.discard()
+		}
+		delete(s.handles, id)
+	}
+}
+
+func (s *server) send(p packet) error {
+	binary.BigEndian.PutUint32(p, uint32(len(p)-4))
+	_, err := s.ch.Write(p)
+	return err
+}
+
+func (s *server) sendStatus(id uint32, err error) error {
+	code, msg := toStatus(err)
+	return s.send(newPacket(fxpStatus, id).u32(code).str(msg).str(""))
+}
+
+// resolve 将客户端路径转换为驱动内的相对路径；客户端看到的根目录 "/" 即文件管理目录
+func resolve(p string) (string, error) {
+	return storage.CleanPath(strings.TrimPrefix(path.Clean("/"+p), "/"))
+}
+
+func (s *server) addHandle(h interface{}) (string, error) {
+	if len(s.handles) >= maxHandles {
+		return "", errTooManyFiles
+	}
+	s.nextID++
+	id := strconv.FormatUint(s.nextID, 10)
+	s.handles[id] = h
+	return id, nil
+}
+
+func (s *server) dispatch(pkt []byte) error {
+	typ := pkt[0]
+	r := &packetReader{b: pkt[1:]}
+	if typ == fxpInit {
+		return s.send(packet{0, 0, 0, 0, fxpVersion}.u32(sftpVersion))
+	}
+	id := r.u32()
+	if r.err != nil {
+		return r.err
+	}
+
+	switch typ {
+	case fxpRealpath:
+		p := r.str()
+		if r.err != nil {
+			return s.sendStatus(id, r.err)
+		}
+		name, err := resolve(p)
+		if err != nil {
+			return s.sendStatus(id, err)
+		}
+		abs := "/" + name
+		return s.send(newPacket(fxpName, id).u32(1).str(abs).str(abs).u32(0))
+
+	case fxpStat, fxpLstat:
+		p := r.str()
+		if r.err != nil {
+			return s.sendStatus(id, r.err)
+		}
+		info, err := s.stat(p)
+		if err != nil {
+			return s.sendStatus(id, err)
+		}
+		return s.send(newPacket(fxpAttrs, id).attrs(info))
+
+	case fxpFstat:
+		handle := r.str()
+		if r.err != nil {
+			return s.sendStatus(id, r.err)
+		}
+		var info fs.FileInfo
+		switch h := s.handles[handle].(type) {
+		case *readHandle:
+			info = h.info
+		case *writeHandle:
+			info = writeInfo{name: path.Base(h.name), size: h.size}
+		case *dirHandle:
+			info = h.info
+		default:
+			return s.sendStatus(id, errInvalidHandle)
+		}
+		return s.send(newPacket(fxpAttrs, id).attrs(info))
+
+	case fxpSetstat:
+		// 权限、属主、时间等属性由服务端统一管理，忽略客户端设置
+		p := r.str()
+		r.attrs()
+		if r.err != nil {
+			return s.sendStatus(id, r.err)
+		}
+		_, err := s.stat(p)
+		return s.sendStatus(id, err)
+
+	case fxpFsetstat:
+		handle := r.str()
+		attrs := r.attrs()
+		if r.err != nil {
+			return s.sendStatus(id, r.err)
+		}
+		return s.sendStatus(id, s.fsetstat(handle, attrs))
+
+	case fxpOpen:
+		p := r.str()
+		pflags := r.u32()
+		r.attrs()
+		if r.err != nil {
+			return s.sendStatus(id, r.err)
+		}
+		handle, err := s.open(p, pflags)
+		if err != nil {
+			return s.sendStatus(id, err)
+		}
+		return s.send(newPacket(fxpHandle, id).str(handle))
+
+	case fxpRead:
+		handle := r.str()
+		offset := r.u64()
+		length := r.u32()
+		if r.err != nil {
+			return s.sendStatus(id, r.err)
+		}
+		return s.read(id, handle, int64(offset), length)
+
+	case fxpWrite:
+		handle := r.str()
+		offset := r.u64()
+		data := r.bytes()
+		if r.err != nil {
+			return s.sendStatus(id, r.err)
+		}
+		h, ok := s.handles[handle].(*writeHandle)
+		if !ok {
+			return s.sendStatus(id, errInvalidHandle)
+		}
+		return s.sendStatus(id, h.writeAt(data, int64(offset)))
+
+	case fxpClose:
+		handle := r.str()
+		if r.err != nil {
+			return s.sendStatus(id, r.err)
+		}
+		return s.sendStatus(id, s.close(handle))
+
+	case fxpOpendir:
+		p := r.str()
+		if r.err != nil {
+			return s.sendStatus(id, r.err)
+		}
+		handle, err := s.opendir(p)
+		if err != nil {
+			return s.sendStatus(id, err)
+		}
+		return s.send(newPacket(fxpHandle, id).str(handle))
+
+	case fxpReaddir:
+		handle := r.str()
+		if r.err != nil {
+			return s.sendStatus(id, r.err)
+		}
+		h, ok := s.handles[handle].(*dirHandle)
+		if !ok {
+			return s.sendStatus(id, errInvalidHandle)
+		}
+		if len(h.entries) == 0 {
+			return s.sendStatus(id, io.EOF)
+		}
+		n := len(h.entries)
+		if n > readdirBatch {
+			n = readdirBatch
+		}
+		p := newPacket(fxpName, id).u32(uint32(n))
+		for _, e := range h.entries[:n] {
+			p = p.str(e.Name()).str(longName(e, s.sess.user)).attrs(e)
+		}
+		h.entries = h.entries[n:]
+		return s.send(p)
+
+	case fxpRemove:
+		p := r.str()
+		if r.err != nil {
+			return s.sendStatus(id, r.err)
+		}
+		return s.sendStatus(id, s.remove(p))
+
+	case fxpMkdir:
+		p := r.str()
+		r.attrs()
+		if r.err != nil {
+			return s.sendStatus(id, r.err)
+		}
+		return s.sendStatus(id, s.mkdir(p))
+
+	case fxpRmdir:
+		p := r.str()
+		if r.err != nil {
+			return s.sendStatus(id, r.err)
+		}
+		return s.sendStatus(id, s.rmdir(p))
+
+	case fxpRename:
+		oldPath := r.str()
+		newPath := r.str()
+		if r.err != nil {
+			return s.sendStatus(id, r.err)
+		}
+		return s.sendStatus(id, s.rename(oldPath, newPath))
+
+	default:
+		// READLINK / SYMLINK / EXTENDED 等
+		return s.sendStatus(id, errUnsupported)
+	}
+}
+
+// allow 校验当前用户的角色权限（v0.8.0，每次读取用户缓存，禁用 / 改角色后即时生效）
+func (s *server) allow(p common.Permission) error {
+	if !common.UserCan(s.sess.user, p) {
+		return errPermission
+	}
+	return nil
+}
+
+func (s *server) stat(p string) (fs.FileInfo, error) {
+	name, err := resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	return s.fsys.Stat(name)
+}
+
+// checkParent 父目录必须存在且为目录（不自动创建）
+func (s *server) checkParent(name string) error {
+	dir := path.Dir(name)
+	if dir == "." {
+		return nil
+	}
+	info, err := s.fsys.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errNotDir
+	}
+	return nil
+}
+
+func (s *server) open(p string, pflags uint32) (string, error) {
+	name, err := resolve(p)
+	if err != nil {
+		return "", err
+	}
+	if pflags&(fxfWrite|fxfAppend|fxfCreat|fxfTrunc) == 0 {
+		return s.openRead(name)
+	}
+	return s.openWrite(name, pflags)
+}
+
+func (s *server) openRead(name string) (string, error) {
+	if err := s.allow(common.PermDownload); err != nil {
+		return "", err
+	}
+	info, err := s.fsys.Stat(name)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", errIsDir
+	}
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	handle, err := s.addHandle(&readHandle{f: f, info: info})
+	if err != nil {
+		_ = f.Close()
+	}
+	return handle, err
+}
+
+func (s *server) openWrite(name string, pflags uint32) (string, error) {
+	if err := s.allow(common.PermWrite); err != nil {
+		return "", err
+	}
+	if !common.FileUploadEnable {
+		return "", errPermission
+	}
+	if name == "" {
+		return "", errIsDir
+	}
+	info, err := s.fsys.Stat(name)
+	exists := err == nil
+	if err != nil && !storage.IsNotExist(err) {
+		return "", err
+	}
+	switch {
+	case exists && info.IsDir():
+		return "", errIsDir
+	case exists && pflags&fxfCreat != 0 && pflags&fxfExcl != 0:
+		return "", errExist
+	case !exists && pflags&fxfCreat == 0:
+		return "", errNoSuchFile
+	}
+	if err := s.checkParent(name); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(common.ChunkTempDir(), 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(common.ChunkTempDir(), "sftp-*")
+	if err != nil {
+		return "", err
+	}
+	h := &writeHandle{
+		name:   name,
+		tmp:    tmp,
+		append: pflags&fxfAppend != 0,
+		dirty:  !exists || pflags&fxfTrunc != 0,
+		start:  time.Now(),
+	}
+	// 未截断地打开已有文件：先载入原内容，之后的写入在其基础上修改
+	if exists && pflags&fxfTrunc == 0 {
+		f, err := s.fsys.Open(name)
+		if err != nil {
+			h.discard()
+			return "", err
+		}
+		h.size, err = io.Copy(tmp, f)
+		_ = f.Close()
+		if err != nil {
+			h.discard()
+			return "", err
+		}
+	}
+
+	handle, err := s.addHandle(h)
+	if err != nil {
+		h.discard()
+	}
+	return handle, err
+}
+
+func (s *server) read(id uint32, handle string, offset int64, length uint32) error {
+	if length > maxReadSize {
+		length = maxReadSize
+	}
+	// 直接读入响应包：长度(4) + 类型(1) + id(4) + 数据长度(4) + 数据
+	p := newPacket(fxpData, id).u32(0)
+	p = append(p, make([]byte, length)...)
+	data := p[13:]
+
+	var n int
+	var err error
+	switch h := s.handles[handle].(type) {
+	case *readHandle:
+		n, err = h.readAt(data, offset)
+	case *writeHandle:
+		n, err = h.tmp.ReadAt(data, offset)
+		if n > 0 {
+			err = nil
+		}
+	default:
+		err = errInvalidHandle
+	}
+	if err != nil {
+		return s.sendStatus(id, err)
+	}
+	binary.BigEndian.PutUint32(p[9:], uint32(n))
+	return s.send(p[:13+n])
+}
+
+func (s *server) fsetstat(handle string, attrs fileAttrs) error {
+	switch h := s.handles[handle].(type) {
+	case *writeHandle:
+		if attrs.flags&attrSize == 0 {
+			return nil
+		}
+		size := int64(attrs.size)
+		if common.UploadPolicyFSizeLimit > 0 && size > common.UploadPolicyFSizeLimit {
+			return errTooLarge
+		}
+		if err := h.tmp.Truncate(size); err != nil {
+			return err
+		}
+		h.size = size
+		h.dirty = true
+		return nil
+	case *readHandle:
+		if attrs.flags&attrSize != 0 {
+			return errPermission
+		}
+		return nil
+	case *dirHandle:
+		return nil
+	default:
+		return errInvalidHandle
+	}
+}
+
+func (s *server) close(handle string) error {
+	h, ok := s.handles[handle]
+	if !ok {
+		return errInvalidHandle
+	}
+	delete(s.handles, handle)
+	switch h := h.(type) {
+	case *readHandle:
+		return h.f.Close()
+	case *writeHandle:
+		return s.commit(h)
+	}
+	return nil
+}
+
+// commit 将临时文件写入存储驱动，并记录上传日志与操作日志
+func (s *server) commit(h *writeHandle) error {
+	defer h.discard()
+	if !h.dirty {
+		return nil
+	}
+	if _, err := h.tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	realPath, err := s.scope.Resolve(h.name, true)
+	if err != nil {
+		s.logOp("sftp_upload", h.name, fmt.Sprintf("SFTP 上传: /%s", h.name), err, h.start)
+		return err
+	}
+	// v0.8.0：提交前按最终大小校验配额
+	if err := storage.CheckQuotaKey(storage.DownloadPath(realPath), s.sess.user, h.size); err != nil {
+		ylog.Warnf(logTag, "user=%s upload %s rejected: %v", s.sess.user, h.name, err)
+		s.logOp("sftp_upload", h.name, fmt.Sprintf("SFTP 上传: /%s", h.name), err, h.start)
+		return err
+	}
+	checksums := storage.NewChecksumWriter()
+	n, err := s.fsys.Put(h.name, io.TeeReader(io.LimitReader(h.tmp, h.size), checksums), h.size)
+	if err != nil {
+		ylog.Errorf(logTag, "user=%s upload %s failed: %v", s.sess.user, h.name, err)
+		s.logOp("sftp_upload", h.name, fmt.Sprintf("SFTP 上传: /%s", h.name), err, h.start)
+		return err
+	}
+	sums := checksums.Sums()
+	if info, err := s.fsys.Stat(h.name); err == nil {
+		storage.RememberChecksums(storage.DownloadPath(realPath), info, sums)
+	}
+	storage.SetFileOwnerKey(storage.DownloadPath(realPath), s.sess.user)
+	s.sess.insertUploadLog(h.name, n, sums.MD5, time.Now())
+	s.logOp("sftp_upload", h.name, fmt.Sprintf("SFTP 上传: /%s (%s)", h.name, utils.FormatSize(n)), nil, h.start)
+	return nil
+}
+
+func (s *server) opendir(p string) (string, error) {
+	if err := s.allow(common.PermBrowse); err != nil {
+		return "", err
+	}
+	name, err := resolve(p)
+	if err != nil {
+		return "", err
+	}
+	info, err := s.fsys.Stat(name)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", errNotDir
+	}
+	entries, err := s.fsys.List(name)
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return s.addHandle(&dirHandle{info: info, entries: entries})
+}
+
+func (s *server) remove(p string) error {
+	if err := s.allow(common.PermWrite); err != nil {
+		return err
+	}
+	start := time.Now()
+	name, err := resolve(p)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return errPermission
+	}
+	info, err := s.fsys.Stat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return errIsDir
+	}
+	err = s.fsys.Remove(name)
+	s.logOp("sftp_delete", name, fmt.Sprintf("SFTP 删除: /%s", name), err, start)
+	return err
+}
+
+func (s *server) mkdir(p string) error {
+	if err := s.allow(common.PermWrite); err != nil {
+		return err
+	}
+	start := time.Now()
+	name, err := resolve(p)
+	if err != nil {
+		return err
+	}
+	if name == "" || storage.Exists(s.fsys, name) {
+		return errExist
+	}
+	if err := s.checkParent(name); err != nil {
+		return err
+	}
+	err = s.fsys.Mkdir(name)
+	s.logOp("sftp_mkdir", name, fmt.Sprintf("SFTP 创建目录: /%s", name), err, start)
+	return err
+}
+
+func (s *server) rmdir(p string) error {
+	if err := s.allow(common.PermWrite); err != nil {
+		return err
+	}
+	start := time.Now()
+	name, err := resolve(p)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return errPermission
+	}
+	info, err := s.fsys.Stat(name)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errNotDir
+	}
+	// 与 rmdir(2) 一致，只删除空目录
+	entries, err := s.fsys.List(name)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return &statusError{fxFailure, "directory not empty"}
+	}
+	err = s.fsys.Remove(name)
+	s.logOp("sftp_rmdir", name, fmt.Sprintf("SFTP 删除目录: /%s", name), err, start)
+	return err
+}
+
+func (s *server) rename(oldPath, newPath string) error {
+	if err := s.allow(common.PermWrite); err != nil {
+		return err
+	}
+	start := time.Now()
+	oldName, err := resolve(oldPath)
+	if err != nil {
+		return err
+	}
+	newName, err := resolve(newPath)
+	if err != nil {
+		return err
+	}
+	if oldName == "" || newName == "" {
+		return errPermission
+	}
+	if _, err := s.fsys.Stat(oldName); err != nil {
+		return err
+	}
+	// SFTP v3 的 RENAME 不覆盖已有目标
+	if storage.Exists(s.fsys, newName) {
+		return errExist
+	}
+	if err := s.checkParent(newName); err != nil {
+		return err
+	}
+	err = s.fsys.Rename(oldName, newName)
+	s.logOp("sftp_rename", oldName, fmt.Sprintf("SFTP 重命名: /%s -> /%s", oldName, newName), err, start)
+	return err
+}
+
+// logOp 记录写操作日志，失败时按错误类型记录状态码
+func (s *server) logOp(action, name, detail string, err error, start time.Time) {
+	status := http.StatusOK
+	if err != nil {
+		switch code, _ := toStatus(err); code {
+		case fxNoSuchFile:
+			status = http.StatusNotFound
+		case fxPermissionDenied:
+			status = http.StatusForbidden
+		default:
+			status = http.StatusInternalServerError
+		}
+	}
+	s.sess.saveOperationLog(action, "/"+name, detail, status, start)
+}
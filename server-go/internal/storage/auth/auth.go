@@ -42,6 +42,8 @@ func (ath *Credentials) SignWithData(b []byte) (token string) {
 	return fmt.Sprintf("%s:%s", sign, encodedData)
 }
 
+// VerifyUploadToken 校验上传凭证的签名（签名对象为 base64 编码后的上传策略）。
+// 上传策略的内容（有效期、目标位置、大小与类型限制）由 storage.ParseUploadPolicy 解析后校验。
 func (ath *Credentials) VerifyUploadToken(token string) bool {
 	parts := strings.Split(token, ":")
 	if len(parts) != 3 {
@@ -49,32 +51,15 @@ func (ath *Credentials) VerifyUploadToken(token string) bool {
 		return false
 	}
 
-	accessKey := parts[0]
-	signature := parts[1]
-	putPolicyBase64 := parts[2]
-
-	if accessKey != ath.AccessKey {
-		fmt.Println(accessKey)
-		fmt.Println(ath.AccessKey)
-		ylog.Errorf("[AUTH]", "accessKey: %s", ath.AccessKey)
-		ylog.Errorf("[AUTH]", "ath.AccessKey: %s", ath.AccessKey)
-
-		ylog.Errorf("[AUTH]", "accessKey 不匹配")
+	if parts[0] != ath.AccessKey {
+		ylog.Errorf("[AUTH]", "accessKey 不匹配: %s", parts[0])
 		return false
 	}
 
-	// 解析上传策略
-	putPolicyJSON, err := base64.StdEncoding.DecodeString(putPolicyBase64)
-	if err != nil {
-		ylog.Errorf("[AUTH]", "上传策略解析失败")
-		return false
-	}
+	return ath.VerifySign([]byte(parts[2]), parts[0]+":"+parts[1])
+}
 
-	// 使用 AccessKey 对上传策略进行签名
-	expectedSignature := ath.SignWithData(putPolicyJSON)
-	ylog.Infof("[AUTH]", "expectedSignature: %s", expectedSignature)
-	ylog.Infof("[AUTH]", "signature: %s", signature)
-	ylog.Infof("[AUTH]", "token: %s", token)
-	// 比较签名
-	return token == expectedSignature
+// VerifySign 校验 Sign 生成的签名（常数时间比较）
+func (ath *Credentials) VerifySign(data []byte, token string) bool {
+	return hmac.Equal([]byte(ath.Sign(data)), []byte(token))
 }
@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm/clause"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+)
+
+// ========== v0.8.0 新增：文件校验和缓存 ==========
+//
+// 文件的 MD5、SHA-256 与 MIME 类型（下称校验和）按相对于存储根目录的路径缓存，连同计算时的大小与修改时间一起保存，
+// 大小或修改时间变化后视为失效并重新计算；同一文件的并发计算合并为一次，三者在同一次读取中算出。
+// 缓存分两层：进程内缓存，以及开启文件索引时持久化在 t_file_meta 中（重启后仍然有效）。
+// 上传时已顺带算出的校验和通过 RememberChecksums / RememberMD5 直接写入缓存，之后的查询无需再读一遍文件。
+
+// checksumCacheSize 进程内缓存的文件数上限，超出时随机淘汰
+const checksumCacheSize = 4096
+
+// sniffLen 识别 MIME 类型读取的字节数，同 http.DetectContentType
+const sniffLen = 512
+
+// Checksums 文件内容的校验和，未知的字段为空
+type Checksums struct {
+	MD5      string `json:"md5"`
+	SHA256   string `json:"sha256"`
+	MimeType string `json:"mimeType"`
+}
+
+// complete 三项是否齐全（RememberMD5 只记录 MD5）
+func (s Checksums) complete() bool {
+	return s.MD5 != "" && s.SHA256 != "" && s.MimeType != ""
+}
+
+type checksumEntry struct {
+	size    int64
+	modTime time.Time
+	sums    Checksums
+}
+
+var (
+	checksumMu    sync.Mutex
+	checksumCache = make(map[string]checksumEntry)
+	checksumGroup singleflight.Group
+)
+
+// ChecksumWriter 边写边算校验和，用于上传时在写入存储的同时计算
+type ChecksumWriter struct {
+	md5    hash.Hash
+	sha256 hash.Hash
+	head   []byte
+}
+
+// NewChecksumWriter 创建 ChecksumWriter
+func NewChecksumWriter() *ChecksumWriter {
+	return &ChecksumWriter{md5: md5.New(), sha256: sha256.New(), head: make([]byte, 0, sniffLen)}
+}
+
+func (w *ChecksumWriter) Write(p []byte) (int, error) {
+	w.md5.Write(p)
+	w.sha256.Write(p)
+	if n := sniffLen - len(w.head); n > 0 {
+		if n > len(p) {
+			n = len(p)
+		}
+		w.head = append(w.head, p[:n]...)
+	}
+	return len(p), nil
+}
+
+// Sums 返回已写入内容的校验和
+func (w *ChecksumWriter) Sums() Checksums {
+	return Checksums{
+		MD5:      hex.EncodeToString(w.md5.Sum(nil)),
+		SHA256:   hex.EncodeToString(w.sha256.Sum(nil)),
+		MimeType: http.DetectContentType(w.head),
+	}
+}
+
+// FileChecksums 返回 d 中文件 name 的校验和；key 为该文件相对于存储根目录的路径（如 DownloadPath(name)），info 为文件当前的状态
+func FileChecksums(d Driver, name, key string, info fs.FileInfo) (Checksums, error) {
+	if sums, ok := lookupChecksums(key, info); ok && sums.complete() {
+		return sums, nil
+	}
+	v, err, _ := checksumGroup.Do(key, func() (interface{}, error) {
+		if sums, ok := lookupChecksums(key, info); ok && sums.complete() {
+			return sums, nil
+		}
+		f, err := d.Open(name)
+		if err != nil {
+			return Checksums{}, err
+		}
+		defer f.Close()
+		w := NewChecksumWriter()
+		if _, err := io.Copy(w, f); err != nil {
+			return Checksums{}, err
+		}
+		sums := w.Sums()
+		RememberChecksums(key, info, sums)
+		return sums, nil
+	})
+	if err != nil {
+		return Checksums{}, err
+	}
+	return v.(Checksums), nil
+}
+
+// CachedMD5 返回 d 中文件 name 的 MD5，参数同 FileChecksums
+func CachedMD5(d Driver, name, key string, info fs.FileInfo) (string, error) {
+	if sums, ok := lookupChecksums(key, info); ok {
+		return sums.MD5, nil
+	}
+	sums, err := FileChecksums(d, name, key, info)
+	return sums.MD5, err
+}
+
+// RememberChecksums 记录已知的校验和（如上传时边写边算的结果），info 为写入后的文件状态
+func RememberChecksums(key string, info fs.FileInfo, sums Checksums) {
+	if key == "" || sums.MD5 == "" || info == nil {
+		return
+	}
+	cacheChecksums(key, info, sums)
+	if !FileIndexEnabled() {
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		return
+	}
+	// 只更新校验和与其对应的文件状态，上传者等其他字段保持不变
+	meta := models.FileMetaModel{
+		Path:      key,
+		Size:      info.Size(),
+		ModTime:   info.ModTime(),
+		MD5:       sums.MD5,
+		SHA256:    sums.SHA256,
+		MimeType:  sums.MimeType,
+		UpdatedAt: time.Now(),
+	}
+	err = db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "path"}},
+		DoUpdates: clause.AssignmentColumns([]string{"size", "mod_time", "md5", "sha256", "mime_type", "updated_at"}),
+	}).Create(&meta).Error
+	if err != nil {
+		ylog.Errorf("fileIndex", "save checksums of %s failed: %v", key, err)
+	}
+}
+
+// RememberMD5 只记录已知的 MD5，SHA-256 与 MIME 类型在首次需要时计算
+func RememberMD5(key string, info fs.FileInfo, sum string) {
+	RememberChecksums(key, info, Checksums{MD5: sum})
+}
+
+func cacheChecksums(key string, info fs.FileInfo, sums Checksums) {
+	checksumMu.Lock()
+	defer checksumMu.Unlock()
+	if _, ok := checksumCache[key]; !ok && len(checksumCache) >= checksumCacheSize {
+		for k := range checksumCache {
+			delete(checksumCache, k)
+			break
+		}
+	}
+	checksumCache[key] = checksumEntry{size: info.Size(), modTime: info.ModTime(), sums: sums}
+}
+
+// lookupChecksums 先查进程内缓存，再查文件索引；文件大小与修改时间未变时返回已知的校验和（至少含 MD5）
+func lookupChecksums(key string, info fs.FileInfo) (Checksums, bool) {
+	checksumMu.Lock()
+	entry, ok := checksumCache[key]
+	checksumMu.Unlock()
+	if ok && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) && entry.sums.complete() {
+		return entry.sums, true
+	}
+
+	meta, _ := LookupFileMeta(key)
+	if sums, valid := IndexedChecksums(meta, info); valid {
+		cacheChecksums(key, info, sums)
+		return sums, true
+	}
+	if ok && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+		return entry.sums, true
+	}
+	return Checksums{}, false
+}
+
+// checksumsValid 索引中的校验和是否对应文件的当前状态
+func checksumsValid(meta *models.FileMetaModel, info fs.FileInfo) bool {
+	return meta.MD5 != "" && meta.Size == info.Size() && meta.ModTime.Equal(info.ModTime())
+}
+
+// IndexedChecksums 返回索引条目中仍对应文件当前状态（info）的校验和，已失效或未计算时返回 false
+func IndexedChecksums(meta *models.FileMetaModel, info fs.FileInfo) (Checksums, bool) {
+	if meta == nil || info == nil || !checksumsValid(meta, info) {
+		return Checksums{}, false
+	}
+	return Checksums{MD5: meta.MD5, SHA256: meta.SHA256, MimeType: meta.MimeType}, true
+}
+
+// LookupFileMeta 按相对于存储根目录的路径查询文件索引条目；未开启文件索引或未登记时返回 false
+func LookupFileMeta(key string) (*models.FileMetaModel, bool) {
+	if !FileIndexEnabled() || key == "" {
+		return nil, false
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		return nil, false
+	}
+	var meta models.FileMetaModel
+	if db.Where("path = ?", key).Limit(1).Find(&meta).RowsAffected == 0 {
+		return nil, false
+	}
+	return &meta, true
+}
+
+// LookupFileMetas 批量查询 dir（相对于存储根目录）下直接子项的索引条目，按文件名返回
+func LookupFileMetas(dir string, names []string) map[string]*models.FileMetaModel {
+	result := make(map[string]*models.FileMetaModel, len(names))
+	if !FileIndexEnabled() || len(names) == 0 {
+		return result
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		return result
+	}
+	keys := make(map[string]string, len(names))
+	paths := make([]string, 0, len(names))
+	for _, name := range names {
+		key := Join(dir, name)
+		keys[key] = name
+		paths = append(paths, key)
+	}
+	for i := 0; i < len(paths); i += 500 {
+		end := i + 500
+		if end > len(paths) {
+			end = len(paths)
+		}
+		var rows []models.FileMetaModel
+		if err := db.Where("path IN ?", paths[i:end]).Find(&rows).Error; err != nil {
+			ylog.Errorf("fileIndex", "load file index of %s failed: %v", dir, err)
+			return result
+		}
+		for j := range rows {
+			result[keys[rows[j].Path]] = &rows[j]
+		}
+	}
+	return result
+}
+
+// FindByChecksum 在文件索引中查找内容 MD5 与大小都相同、且 scope 可读取（见 UserScope.CanReadKey）的文件
+// （不含历史版本、回收站与去重 blob），返回相对于存储根目录的路径；只返回校验和仍对应文件当前状态的条目
+func FindByChecksum(sum string, size int64, scope *UserScope) (string, bool) {
+	if !FileIndexEnabled() || sum == "" {
+		return "", false
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		return "", false
+	}
+	var rows []models.FileMetaModel
+	excludeReserved(db.Where("md5 = ? AND size = ?", strings.ToLower(sum), size)).
+		Order("updated_at DESC").Limit(20).Find(&rows)
+	for i := range rows {
+		if !scope.CanReadKey(rows[i].Path) {
+			continue
+		}
+		info, err := Backend().Stat(rows[i].Path)
+		if err == nil && !info.IsDir() && checksumsValid(&rows[i], info) {
+			return rows[i].Path, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,765 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+)
+
+// ========== v0.8.0 新增：静态加密 ==========
+//
+// 开启 server.storage.encryption 后，全局驱动被 cryptDriver 包装，经由驱动写入的文件以分块 AES-256-GCM 格式落盘：
+//
+//	文件头（96 字节）：magic "HCATENC1" | 分块大小 uint32 | 主密钥 ID [16] | 包装后的数据密钥 [60] | nonce 前缀 [8]
+//	数据块：每 64 KiB 明文一块，nonce = 前缀 || 块序号，附加数据标记是否为最后一块（防截断）
+//
+// 每个文件使用随机数据密钥（DEK），DEK 由主密钥包装后写在文件头中；读取时按块解密，Seek 只需解密目标块，
+// http.ServeContent 的 Range 请求无需读取整个文件。开启前写入的明文文件（无文件头）照常读取。
+// 轮换主密钥：在 keys 中加入新密钥并设为 active_key，新文件即使用新密钥；旧文件可继续用旧密钥解密，
+// 也可通过 RotateEncryption 只重写文件头（重新包装 DEK），无需重新上传或重新加密内容。
+// 改写与上传、改名、删除按路径互斥，不会覆盖轮换期间写入的新内容。
+
+const (
+	encMagic      = "HCATENC1"
+	encHeaderSize = 96
+	encChunkSize  = 64 * 1024
+	encTagSize    = 16
+	encKeyIDSize  = 16
+	encDEKSize    = 32
+	// 包装后的 DEK：nonce(12) + 密文(32) + tag(16)
+	encWrappedSize = 12 + encDEKSize + encTagSize
+	// infoCacheLimit 明文大小缓存的最大条目数，超出后整体清空
+	infoCacheLimit = 10000
+)
+
+var (
+	// ErrUnknownEncryptionKey 文件头中的主密钥 ID 不在配置中
+	ErrUnknownEncryptionKey = errors.New("unknown encryption key")
+	// ErrDecrypt 密文校验失败（文件损坏或被篡改）
+	ErrDecrypt = errors.New("decrypt failed: data corrupted or tampered")
+)
+
+// keyring 主密钥集合
+type keyring struct {
+	active string
+	keys   map[string]cipher.AEAD
+}
+
+// loadKeyring 按配置加载主密钥
+func loadKeyring() (*keyring, error) {
+	k := &keyring{active: common.StorageEncryptionActiveKey, keys: make(map[string]cipher.AEAD)}
+	for _, cfg := range common.StorageEncryptionKeys {
+		id := strings.TrimSpace(cfg.ID)
+		if id == "" || len(id) > encKeyIDSize {
+			return nil, fmt.Errorf("encryption key id %q must be 1-%d characters", cfg.ID, encKeyIDSize)
+		}
+		if _, dup := k.keys[id]; dup {
+			return nil, fmt.Errorf("duplicate encryption key id %q", id)
+		}
+		material := []byte(cfg.Key)
+		if cfg.KeyFile != "" {
+			data, err := os.ReadFile(cfg.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("read key file of %q: %w", id, err)
+			}
+			material = data
+		}
+		key, err := parseKeyMaterial(material)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %q: %w", id, err)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+	}
+	if len(k.keys) == 0 {
+		return nil, errors.New("no encryption key configured")
+	}
+	if _, ok := k.keys[k.active]; !ok {
+		return nil, fmt.Errorf("active_key %q is not configured", k.active)
+	}
+	return k, nil
+}
+
+// parseKeyMaterial 解析 32 字节密钥：base64 / hex 编码，或 32 字节原始内容（仅密钥文件）
+func parseKeyMaterial(material []byte) ([]byte, error) {
+	if len(material) == encDEKSize {
+		return material, nil
+	}
+	text := strings.TrimSpace(string(material))
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && len(key) == encDEKSize {
+		return key, nil
+	}
+	if key, err := hex.DecodeString(text); err == nil && len(key) == encDEKSize {
+		return key, nil
+	}
+	return nil, errors.New("key must be 32 bytes (base64 or hex encoded)")
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// headerAAD 包装 DEK 时的附加数据：magic | 分块大小 | 主密钥 ID
+func headerAAD(hdr []byte) []byte {
+	return hdr[:8+4+encKeyIDSize]
+}
+
+func isEncrypted(hdr []byte) bool {
+	return len(hdr) >= encHeaderSize && string(hdr[:8]) == encMagic
+}
+
+func headerKeyID(hdr []byte) string {
+	return strings.TrimRight(string(hdr[12:12+encKeyIDSize]), "\x00")
+}
+
+// sealHeader 用主密钥 keyID 包装 dek，写入 hdr（hdr 的 nonce 前缀保持不变）
+func (k *keyring) sealHeader(hdr []byte, keyID string, dek []byte) error {
+	kek, ok := k.keys[keyID]
+	if !ok {
+		return ErrUnknownEncryptionKey
+	}
+	copy(hdr[:8], encMagic)
+	binary.BigEndian.PutUint32(hdr[8:12], encChunkSize)
+	id := hdr[12 : 12+encKeyIDSize]
+	for i := range id {
+		id[i] = 0
+	}
+	copy(id, keyID)
+	wrapped := hdr[28 : 28+encWrappedSize]
+	if _, err := rand.Read(wrapped[:12]); err != nil {
+		return err
+	}
+	kek.Seal(wrapped[12:12], wrapped[:12], dek, headerAAD(hdr))
+	return nil
+}
+
+// newHeader 为新文件生成随机 DEK 与文件头
+func (k *keyring) newHeader() ([]byte, cipher.AEAD, error) {
+	hdr := make([]byte, encHeaderSize)
+	dek := make([]byte, encDEKSize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, nil, err
+	}
+	if _, err := rand.Read(hdr[88:96]); err != nil {
+		return nil, nil, err
+	}
+	if err := k.sealHeader(hdr, k.active, dek); err != nil {
+		return nil, nil, err
+	}
+	aead, err := newAEAD(dek)
+	return hdr, aead, err
+}
+
+// unwrapDEK 从文件头解出 DEK
+func (k *keyring) unwrapDEK(hdr []byte) ([]byte, error) {
+	kek, ok := k.keys[headerKeyID(hdr)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEncryptionKey, headerKeyID(hdr))
+	}
+	wrapped := hdr[28 : 28+encWrappedSize]
+	dek, err := kek.Open(nil, wrapped[:12], wrapped[12:], headerAAD(hdr))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return dek, nil
+}
+
+// chunkNonce 数据块 nonce：文件头中的 8 字节前缀 || 4 字节块序号
+func chunkNonce(hdr []byte, index int64) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, hdr[88:96])
+	binary.BigEndian.PutUint32(nonce[8:], uint32(index))
+	return nonce
+}
+
+func chunkAAD(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// encryptedSize 明文大小对应的密文文件大小（空文件也有一个空的最后块）
+func encryptedSize(plain int64) int64 {
+	chunks := (plain + encChunkSize - 1) / encChunkSize
+	if chunks == 0 {
+		chunks = 1
+	}
+	return encHeaderSize + plain + chunks*encTagSize
+}
+
+// plainSize 密文文件大小对应的明文大小
+func plainSize(encrypted int64) int64 {
+	body := encrypted - encHeaderSize
+	if body < encTagSize {
+		return 0
+	}
+	chunks := (body + encChunkSize + encTagSize - 1) / (encChunkSize + encTagSize)
+	return body - chunks*encTagSize
+}
+
+// ---------- 加密写入 ----------
+
+// encryptReader 将明文流转换为加密格式的流
+type encryptReader struct {
+	src   *bufio.Reader
+	hdr   []byte
+	aead  cipher.AEAD
+	buf   []byte
+	out   []byte
+	index int64
+	done  bool
+	read  int64 // 已读取的明文字节数
+}
+
+func (k *keyring) newEncryptReader(r io.Reader) (*encryptReader, error) {
+	hdr, aead, err := k.newHeader()
+	if err != nil {
+		return nil, err
+	}
+	return &encryptReader{
+		src:  bufio.NewReaderSize(r, encChunkSize),
+		hdr:  hdr,
+		aead: aead,
+		buf:  make([]byte, encChunkSize),
+		out:  hdr,
+	}, nil
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.sealNext(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func (r *encryptReader) sealNext() error {
+	n, err := io.ReadFull(r.src, r.buf)
+	last := false
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		return err
+	default:
+		// 整块读满时预读一个字节判断是否已到结尾
+		if _, perr := r.src.Peek(1); perr == io.EOF {
+			last = true
+		} else if perr != nil {
+			return perr
+		}
+	}
+	r.read += int64(n)
+	r.out = r.aead.Seal(r.out[:0], chunkNonce(r.hdr, r.index), r.buf[:n], chunkAAD(last))
+	r.index++
+	r.done = last
+	return nil
+}
+
+// ---------- 解密读取 ----------
+
+// decryptFile 按块解密的只读文件，支持 Seek
+type decryptFile struct {
+	f        File
+	hdr      []byte
+	aead     cipher.AEAD
+	info     fs.FileInfo
+	size     int64 // 明文大小
+	pos      int64
+	rawPos   int64 // 底层文件的读取位置
+	chunk    []byte
+	chunkIdx int64
+	raw      []byte
+}
+
+func (f *decryptFile) Read(p []byte) (int, error) {
+	if f.pos >= f.size {
+		return 0, io.EOF
+	}
+	idx := f.pos / encChunkSize
+	if idx != f.chunkIdx {
+		if err := f.load(idx); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, f.chunk[f.pos-idx*encChunkSize:])
+	f.pos += int64(n)
+	return n, nil
+}
+
+// load 读取并解密第 idx 块
+func (f *decryptFile) load(idx int64) error {
+	offset := encHeaderSize + idx*(encChunkSize+encTagSize)
+	if f.rawPos != offset {
+		if _, err := f.f.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		f.rawPos = offset
+	}
+	lastIdx := (f.size - 1) / encChunkSize
+	if f.size == 0 {
+		lastIdx = 0
+	}
+	length := int64(encChunkSize)
+	if idx == lastIdx {
+		length = f.size - idx*encChunkSize
+	}
+	raw := f.raw[:length+encTagSize]
+	if _, err := io.ReadFull(f.f, raw); err != nil {
+		f.rawPos = -1
+		return ErrDecrypt
+	}
+	f.rawPos += int64(len(raw))
+	chunk, err := f.aead.Open(f.chunk[:0], chunkNonce(f.hdr, idx), raw, chunkAAD(idx == lastIdx))
+	if err != nil {
+		f.chunkIdx = -1
+		return ErrDecrypt
+	}
+	f.chunk = chunk
+	f.chunkIdx = idx
+	return nil
+}
+
+func (f *decryptFile) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = f.pos + offset
+	case io.SeekEnd:
+		pos = f.size + offset
+	default:
+		return 0, errors.New("seek: invalid whence")
+	}
+	if pos < 0 {
+		return 0, errors.New("seek: negative position")
+	}
+	f.pos = pos
+	return pos, nil
+}
+
+func (f *decryptFile) Close() error { return f.f.Close() }
+
+func (f *decryptFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+// plainFileInfo 以明文大小覆盖底层 FileInfo
+type plainFileInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (i *plainFileInfo) Size() int64 { return i.size }
+
+// ---------- 驱动包装 ----------
+
+// cryptDriver 在任意驱动之上提供透明加解密，name 均为相对于存储根目录的路径
+type cryptDriver struct {
+	inner Driver
+	keys  *keyring
+
+	cacheMu sync.Mutex
+	cache   map[string]cryptInfo
+
+	// 按路径串行化写入、改名、删除与密钥轮换的改写，避免轮换用旧内容覆盖并发上传的文件
+	locksMu sync.Mutex
+	locks   map[string]*pathLock
+}
+
+// pathLock 单个路径的写锁，refs 为持有或等待的数量，归零后从表中删除
+type pathLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lockPath 锁定路径（已规范化），返回解锁函数
+func (d *cryptDriver) lockPath(name string) func() {
+	d.locksMu.Lock()
+	l, ok := d.locks[name]
+	if !ok {
+		l = &pathLock{}
+		d.locks[name] = l
+	}
+	l.refs++
+	d.locksMu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		d.locksMu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(d.locks, name)
+		}
+		d.locksMu.Unlock()
+	}
+}
+
+// lockPaths 按固定顺序锁定两个路径，避免互相等待
+func (d *cryptDriver) lockPaths(a, b string) func() {
+	if a == b {
+		return d.lockPath(a)
+	}
+	if a > b {
+		a, b = b, a
+	}
+	unlockA := d.lockPath(a)
+	unlockB := d.lockPath(b)
+	return func() {
+		unlockB()
+		unlockA()
+	}
+}
+
+// cryptInfo 缓存文件是否加密及其明文大小，以底层大小与修改时间判断是否失效
+type cryptInfo struct {
+	rawSize   int64
+	modTime   time.Time
+	encrypted bool
+	plainSize int64
+}
+
+// newCryptDriver 按配置为驱动开启静态加密，密钥无效时启动失败
+func newCryptDriver(d Driver) Driver {
+	keys, err := loadKeyring()
+	if err != nil {
+		ylog.Fatalf("storage", "init storage encryption failed: %v", err)
+	}
+	ylog.Infof("storage", "storage encryption enabled, active key: %s", keys.active)
+	return &cryptDriver{inner: d, keys: keys, cache: make(map[string]cryptInfo), locks: make(map[string]*pathLock)}
+}
+
+// EncryptionEnabled 是否启用了静态加密
+func EncryptionEnabled() bool {
+	_, ok := Backend().(*cryptDriver)
+	return ok
+}
+
+func (d *cryptDriver) Name() string { return d.inner.Name() }
+
+func (d *cryptDriver) Open(name string) (File, error) {
+	f, err := d.inner.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return f, err
+	}
+	hdr := make([]byte, encHeaderSize)
+	if _, err := io.ReadFull(f, hdr); err != nil || !isEncrypted(hdr) {
+		// 开启加密前写入的明文文件
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		return f, nil
+	}
+	dek, err := d.keys.unwrapDEK(hdr)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	size := plainSize(info.Size())
+	return &decryptFile{
+		f:        f,
+		hdr:      hdr,
+		aead:     aead,
+		info:     &plainFileInfo{FileInfo: info, size: size},
+		size:     size,
+		rawPos:   encHeaderSize,
+		chunk:    make([]byte, 0, encChunkSize),
+		chunkIdx: -1,
+		raw:      make([]byte, encChunkSize+encTagSize),
+	}, nil
+}
+
+// plainInfo 将底层 FileInfo 转换为明文大小（读取文件头判断是否加密，结果按大小与修改时间缓存）
+func (d *cryptDriver) plainInfo(name string, info fs.FileInfo) fs.FileInfo {
+	if info.IsDir() {
+		return info
+	}
+	d.cacheMu.Lock()
+	cached, ok := d.cache[name]
+	d.cacheMu.Unlock()
+	if !ok || cached.rawSize != info.Size() || !cached.modTime.Equal(info.ModTime()) {
+		cached = cryptInfo{rawSize: info.Size(), modTime: info.ModTime()}
+		if hdr, err := d.readHeader(name); err == nil && isEncrypted(hdr) {
+			cached.encrypted = true
+			cached.plainSize = plainSize(info.Size())
+		}
+		d.cacheMu.Lock()
+		if len(d.cache) >= infoCacheLimit {
+			d.cache = make(map[string]cryptInfo)
+		}
+		d.cache[name] = cached
+		d.cacheMu.Unlock()
+	}
+	if !cached.encrypted {
+		return info
+	}
+	return &plainFileInfo{FileInfo: info, size: cached.plainSize}
+}
+
+// readHeader 读取底层文件的前 encHeaderSize 字节
+func (d *cryptDriver) readHeader(name string) ([]byte, error) {
+	f, err := d.inner.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	hdr := make([]byte, encHeaderSize)
+	if _, err := io.ReadFull(f, hdr); err != nil {
+		return nil, err
+	}
+	return hdr, nil
+}
+
+func (d *cryptDriver) Stat(name string) (fs.FileInfo, error) {
+	info, err := d.inner.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	cleaned, _ := CleanPath(name)
+	return d.plainInfo(cleaned, info), nil
+}
+
+func (d *cryptDriver) List(dir string) ([]fs.FileInfo, error) {
+	infos, err := d.inner.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	cleaned, _ := CleanPath(dir)
+	for i, info := range infos {
+		infos[i] = d.plainInfo(Join(cleaned, info.Name()), info)
+	}
+	return infos, nil
+}
+
+// Put 加密后写入，返回写入的明文字节数
+func (d *cryptDriver) Put(name string, r io.Reader, size int64) (int64, error) {
+	cleaned, _ := CleanPath(name)
+	defer d.lockPath(cleaned)()
+	return d.put(name, r, size)
+}
+
+func (d *cryptDriver) put(name string, r io.Reader, size int64) (int64, error) {
+	er, err := d.keys.newEncryptReader(r)
+	if err != nil {
+		return 0, err
+	}
+	rawSize := int64(-1)
+	if size >= 0 {
+		rawSize = encryptedSize(size)
+	}
+	if _, err := d.inner.Put(name, er, rawSize); err != nil {
+		return er.read, err
+	}
+	return er.read, nil
+}
+
+func (d *cryptDriver) Rename(oldName, newName string) error {
+	oldPath, _ := CleanPath(oldName)
+	newPath, _ := CleanPath(newName)
+	defer d.lockPaths(oldPath, newPath)()
+	return d.inner.Rename(oldName, newName)
+}
+
+func (d *cryptDriver) Remove(name string) error {
+	cleaned, _ := CleanPath(name)
+	defer d.lockPath(cleaned)()
+	return d.inner.Remove(name)
+}
+
+func (d *cryptDriver) RemoveAll(name string) error {
+	return d.inner.RemoveAll(name)
+}
+
+func (d *cryptDriver) Mkdir(name string) error {
+	return d.inner.Mkdir(name)
+}
+
+// Copy 原样拷贝密文（文件头中带有包装后的 DEK，拷贝后仍可解密）
+func (d *cryptDriver) Copy(src, dst string) error {
+	cleaned, _ := CleanPath(dst)
+	defer d.lockPath(cleaned)()
+	return Copy(d.inner, src, dst)
+}
+
+// ---------- 密钥轮换 ----------
+
+// EncryptionRotation 密钥轮换任务的进度
+type EncryptionRotation struct {
+	Running          bool      `json:"running"`
+	EncryptPlaintext bool      `json:"encryptPlaintext"`
+	StartedAt        time.Time `json:"startedAt"`
+	FinishedAt       time.Time `json:"finishedAt"`
+	Scanned          int       `json:"scanned"`
+	Rewrapped        int       `json:"rewrapped"` // 重新包装 DEK 的文件数
+	Encrypted        int       `json:"encrypted"` // 加密的明文文件数
+	Failed           int       `json:"failed"`
+	Error            string    `json:"error,omitempty"`
+}
+
+var (
+	rotationMu sync.Mutex
+	rotation   EncryptionRotation
+)
+
+// ErrRotationRunning 已有轮换任务在执行
+var ErrRotationRunning = errors.New("key rotation is already running")
+
+// EncryptionStatus 返回当前主密钥、已配置的密钥 ID 与最近一次轮换任务的进度
+func EncryptionStatus() (active string, keyIDs []string, last EncryptionRotation) {
+	rotationMu.Lock()
+	last = rotation
+	rotationMu.Unlock()
+	d, ok := Backend().(*cryptDriver)
+	if !ok {
+		return "", nil, last
+	}
+	for id := range d.keys.keys {
+		keyIDs = append(keyIDs, id)
+	}
+	return d.keys.active, keyIDs, last
+}
+
+// RotateEncryption 在后台将上传 / 下载目录、历史版本与回收站中非当前主密钥加密的文件改用当前主密钥包装 DEK
+// （只重写 96 字节文件头，内容不重新加密）；encryptPlaintext 为 true 时顺带加密开启加密前写入的明文文件。
+func RotateEncryption(encryptPlaintext bool) error {
+	d, ok := Backend().(*cryptDriver)
+	if !ok {
+		return errors.New("storage encryption is not enabled")
+	}
+	rotationMu.Lock()
+	defer rotationMu.Unlock()
+	if rotation.Running {
+		return ErrRotationRunning
+	}
+	rotation = EncryptionRotation{Running: true, EncryptPlaintext: encryptPlaintext, StartedAt: time.Now()}
+	go d.rotate(encryptPlaintext)
+	return nil
+}
+
+func (d *cryptDriver) rotate(encryptPlaintext bool) {
+	update := func(fn func(r *EncryptionRotation)) {
+		rotationMu.Lock()
+		fn(&rotation)
+		rotationMu.Unlock()
+	}
+	for _, root := range indexRoots() {
+		err := Walk(d.inner, root, func(name string, info fs.FileInfo) error {
+			if info.IsDir() {
+				return nil
+			}
+			changed, encrypted, err := d.rotateFile(name, encryptPlaintext)
+			update(func(r *EncryptionRotation) {
+				r.Scanned++
+				switch {
+				case err != nil:
+					r.Failed++
+				case changed && encrypted:
+					r.Encrypted++
+				case changed:
+					r.Rewrapped++
+				}
+			})
+			if err != nil {
+				ylog.Errorf("encryption", "rotate %s failed: %v", name, err)
+			}
+			return nil
+		})
+		if err != nil && !IsNotExist(err) {
+			update(func(r *EncryptionRotation) { r.Error = err.Error() })
+			ylog.Errorf("encryption", "walk %s failed: %v", root, err)
+			break
+		}
+	}
+	update(func(r *EncryptionRotation) {
+		r.Running = false
+		r.FinishedAt = time.Now()
+		ylog.Infof("encryption", "key rotation finished: scanned %d, rewrapped %d, encrypted %d, failed %d",
+			r.Scanned, r.Rewrapped, r.Encrypted, r.Failed)
+	})
+}
+
+// rotateFile 处理单个文件，返回是否改写以及是否为明文加密；改写期间锁定路径，
+// 并在锁内重新读取文件，遍历之后被覆盖、改名或删除的文件按最新状态处理
+func (d *cryptDriver) rotateFile(name string, encryptPlaintext bool) (changed, encrypted bool, err error) {
+	defer d.lockPath(name)()
+	f, err := d.inner.Open(name)
+	if err != nil {
+		if IsNotExist(err) {
+			return false, false, nil
+		}
+		return false, false, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return false, false, err
+	}
+
+	hdr := make([]byte, encHeaderSize)
+	n, err := io.ReadFull(f, hdr)
+	if err != nil || !isEncrypted(hdr) {
+		if !encryptPlaintext {
+			return false, false, nil
+		}
+		// 明文文件：整体加密后写回
+		body := io.MultiReader(bytes.NewReader(hdr[:n]), f)
+		if _, err := d.put(name, body, info.Size()); err != nil {
+			return false, false, err
+		}
+		return true, true, nil
+	}
+	if headerKeyID(hdr) == d.keys.active {
+		return false, false, nil
+	}
+
+	dek, err := d.keys.unwrapDEK(hdr)
+	if err != nil {
+		return false, false, err
+	}
+	if err := d.keys.sealHeader(hdr, d.keys.active, dek); err != nil {
+		return false, false, err
+	}
+	if _, err := d.inner.Put(name, io.MultiReader(bytes.NewReader(hdr), f), info.Size()); err != nil {
+		return false, false, err
+	}
+	return true, false, nil
+}
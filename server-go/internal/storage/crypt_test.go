@@ -0,0 +1,263 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"httpcat/internal/common"
+)
+
+var (
+	testKeyA = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0xa1}, encDEKSize))
+	testKeyB = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0xb2}, encDEKSize))
+)
+
+// newTestCryptDriver 在临时目录上创建加密驱动，keys 为 ID 到 base64 密钥的映射
+func newTestCryptDriver(t *testing.T, root, active string, keys map[string]string) *cryptDriver {
+	t.Helper()
+	oldKeys, oldActive := common.StorageEncryptionKeys, common.StorageEncryptionActiveKey
+	t.Cleanup(func() {
+		common.StorageEncryptionKeys, common.StorageEncryptionActiveKey = oldKeys, oldActive
+	})
+	common.StorageEncryptionKeys = nil
+	for id, key := range keys {
+		common.StorageEncryptionKeys = append(common.StorageEncryptionKeys, common.EncryptionKey{ID: id, Key: key})
+	}
+	common.StorageEncryptionActiveKey = active
+	keyring, err := loadKeyring()
+	if err != nil {
+		t.Fatalf("loadKeyring: %v", err)
+	}
+	return &cryptDriver{inner: NewLocalDriver(root), keys: keyring, cache: make(map[string]cryptInfo), locks: make(map[string]*pathLock)}
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func readAllFrom(d Driver, name string) ([]byte, error) {
+	f, err := d.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func TestEncryptedSize(t *testing.T) {
+	tests := []struct {
+		plain int64
+		raw   int64
+	}{
+		{0, encHeaderSize + encTagSize},
+		{1, encHeaderSize + 1 + encTagSize},
+		{encChunkSize, encHeaderSize + encChunkSize + encTagSize},
+		{encChunkSize + 1, encHeaderSize + encChunkSize + 1 + 2*encTagSize},
+		{3 * encChunkSize, encHeaderSize + 3*encChunkSize + 3*encTagSize},
+	}
+	for _, tt := range tests {
+		if got := encryptedSize(tt.plain); got != tt.raw {
+			t.Errorf("encryptedSize(%d) = %d, want %d", tt.plain, got, tt.raw)
+		}
+		if got := plainSize(tt.raw); got != tt.plain {
+			t.Errorf("plainSize(%d) = %d, want %d", tt.raw, got, tt.plain)
+		}
+	}
+}
+
+func TestCryptRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	d := newTestCryptDriver(t, root, "a", map[string]string{"a": testKeyA})
+	sizes := []int{0, 1, encChunkSize - 1, encChunkSize, encChunkSize + 1, 3*encChunkSize + 17}
+	for _, size := range sizes {
+		data := randomBytes(t, size)
+		name := "dir/file"
+		n, err := d.Put(name, bytes.NewReader(data), int64(size))
+		if err != nil || n != int64(size) {
+			t.Fatalf("size %d: Put = %d, %v", size, n, err)
+		}
+
+		raw, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int64(len(raw)) != encryptedSize(int64(size)) || !isEncrypted(raw) {
+			t.Fatalf("size %d: raw file has %d bytes, want an encrypted file of %d bytes", size, len(raw), encryptedSize(int64(size)))
+		}
+		if size >= 16 && bytes.Contains(raw, data[:16]) {
+			t.Fatalf("size %d: raw file contains plaintext", size)
+		}
+
+		got, err := readAllFrom(d, name)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("size %d: read back %d bytes, err %v", size, len(got), err)
+		}
+		info, err := d.Stat(name)
+		if err != nil || info.Size() != int64(size) {
+			t.Fatalf("size %d: Stat size = %v, %v", size, info, err)
+		}
+	}
+}
+
+func TestCryptSeek(t *testing.T) {
+	d := newTestCryptDriver(t, t.TempDir(), "a", map[string]string{"a": testKeyA})
+	data := randomBytes(t, 2*encChunkSize+100)
+	if _, err := d.Put("f", bytes.NewReader(data), -1); err != nil {
+		t.Fatal(err)
+	}
+	f, err := d.Open("f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tests := []struct {
+		offset int64
+		length int
+	}{
+		{0, 10},
+		{encChunkSize - 5, 10},           // 跨越块边界
+		{2 * encChunkSize, 100},          // 最后一块
+		{10, 2 * encChunkSize},           // 跨越多块
+		{int64(len(data)) - 1, 1},        // 最后一个字节
+		{encChunkSize + 3, encChunkSize}, // 回到前面的块
+	}
+	for _, tt := range tests {
+		if _, err := f.Seek(tt.offset, io.SeekStart); err != nil {
+			t.Fatalf("Seek(%d): %v", tt.offset, err)
+		}
+		buf := make([]byte, tt.length)
+		if _, err := io.ReadFull(f, buf); err != nil {
+			t.Fatalf("read %d bytes at %d: %v", tt.length, tt.offset, err)
+		}
+		if !bytes.Equal(buf, data[tt.offset:tt.offset+int64(tt.length)]) {
+			t.Errorf("read %d bytes at %d: content mismatch", tt.length, tt.offset)
+		}
+	}
+}
+
+func TestCryptTamperAndTruncation(t *testing.T) {
+	size := 2*encChunkSize + 10
+	rawSize := int(encryptedSize(int64(size)))
+	tests := []struct {
+		name   string
+		modify func(raw []byte) []byte
+	}{
+		{"drop last chunk", func(raw []byte) []byte { return raw[:encHeaderSize+2*(encChunkSize+encTagSize)] }},
+		{"cut inside last chunk", func(raw []byte) []byte { return raw[:rawSize-5] }},
+		{"cut inside first chunk", func(raw []byte) []byte { return raw[:encHeaderSize+100] }},
+		{"append byte", func(raw []byte) []byte { return append(raw, 0) }},
+		{"flip data byte", func(raw []byte) []byte { raw[encHeaderSize+encChunkSize+encTagSize+1] ^= 1; return raw }},
+		{"flip tag byte", func(raw []byte) []byte { raw[rawSize-1] ^= 1; return raw }},
+		{"swap chunks", func(raw []byte) []byte {
+			first := append([]byte(nil), raw[encHeaderSize:encHeaderSize+encChunkSize+encTagSize]...)
+			copy(raw[encHeaderSize:], raw[encHeaderSize+encChunkSize+encTagSize:encHeaderSize+2*(encChunkSize+encTagSize)])
+			copy(raw[encHeaderSize+encChunkSize+encTagSize:], first)
+			return raw
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			d := newTestCryptDriver(t, root, "a", map[string]string{"a": testKeyA})
+			if _, err := d.Put("f", bytes.NewReader(randomBytes(t, size)), int64(size)); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(root, "f")
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, tt.modify(raw), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := readAllFrom(d, "f"); !errors.Is(err, ErrDecrypt) {
+				t.Errorf("read error = %v, want %v", err, ErrDecrypt)
+			}
+		})
+	}
+}
+
+func TestCryptWrongKey(t *testing.T) {
+	root := t.TempDir()
+	writer := newTestCryptDriver(t, root, "a", map[string]string{"a": testKeyA})
+	if _, err := writer.Put("f", bytes.NewReader([]byte("hello")), 5); err != nil {
+		t.Fatal(err)
+	}
+
+	other := newTestCryptDriver(t, root, "b", map[string]string{"b": testKeyB})
+	if _, err := readAllFrom(other, "f"); !errors.Is(err, ErrUnknownEncryptionKey) {
+		t.Errorf("read without the key: error = %v, want %v", err, ErrUnknownEncryptionKey)
+	}
+	// 同一 ID 换成另一把密钥：包装的 DEK 无法解开
+	swapped := newTestCryptDriver(t, root, "a", map[string]string{"a": testKeyB})
+	if _, err := readAllFrom(swapped, "f"); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("read with a different key under the same id: error = %v, want %v", err, ErrDecrypt)
+	}
+}
+
+func TestCryptRotateFile(t *testing.T) {
+	root := t.TempDir()
+	old := newTestCryptDriver(t, root, "a", map[string]string{"a": testKeyA})
+	data := randomBytes(t, encChunkSize+42)
+	if _, err := old.Put("enc", bytes.NewReader(data), -1); err != nil {
+		t.Fatal(err)
+	}
+	plain := []byte("written before encryption was enabled")
+	if err := os.WriteFile(filepath.Join(root, "plain"), plain, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := newTestCryptDriver(t, root, "b", map[string]string{"a": testKeyA, "b": testKeyB})
+	tests := []struct {
+		name             string
+		file             string
+		encryptPlaintext bool
+		changed          bool
+		encrypted        bool
+		want             []byte
+	}{
+		{"plaintext kept", "plain", false, false, false, plain},
+		{"rewrap to active key", "enc", false, true, false, data},
+		{"already on active key", "enc", false, false, false, data},
+		{"encrypt plaintext", "plain", true, true, true, plain},
+		{"missing file", "gone", true, false, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed, encrypted, err := d.rotateFile(tt.file, tt.encryptPlaintext)
+			if err != nil || changed != tt.changed || encrypted != tt.encrypted {
+				t.Fatalf("rotateFile(%q) = %v, %v, %v, want %v, %v, nil", tt.file, changed, encrypted, err, tt.changed, tt.encrypted)
+			}
+			if tt.want == nil {
+				return
+			}
+			got, err := readAllFrom(d, tt.file)
+			if err != nil || !bytes.Equal(got, tt.want) {
+				t.Errorf("read %q after rotation: %d bytes, err %v", tt.file, len(got), err)
+			}
+		})
+	}
+
+	// 改写后只用新密钥即可解密
+	onlyB := newTestCryptDriver(t, root, "b", map[string]string{"b": testKeyB})
+	for name, want := range map[string][]byte{"enc": data, "plain": plain} {
+		if got, err := readAllFrom(onlyB, name); err != nil || !bytes.Equal(got, want) {
+			t.Errorf("read %q with the new key only: %d bytes, err %v", name, len(got), err)
+		}
+	}
+	if len(d.locks) != 0 {
+		t.Errorf("%d path locks left after rotation", len(d.locks))
+	}
+}
@@ -0,0 +1,555 @@
+package storage
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ========== v0.8.0 新增：内容寻址去重存储 ==========
+//
+// 开启 server.storage.dedup 后，全局驱动被 dedupDriver 包装，所有经由驱动的写入（HTTP 上传、分片、tus、
+// WebDAV、SFTP、S3 网关、MCP）在落盘后计算 SHA-256：
+//   1. 内容首次出现：在 base_dir/.blobs/ab/<sha256> 建立指向该文件的硬链接，登记到 t_blob
+//   2. 内容已存在：用 blob 的硬链接替换刚写入的文件，重复内容只占用一份磁盘空间
+//   3. t_blob_ref 记录"路径 -> sha256"，删除 / 重命名 / 拷贝时同步维护引用计数，计数归零时删除 blob
+// 秒传按 SHA-256 命中调用方可读取的文件引用过的内容，见 LinkBlob。
+// 所有写入都是"临时文件 + rename"，共享 inode 的文件不会被后续覆盖写互相影响。
+// 驱动之外的改动（手工删除、替换文件）由 startBlobGC 定期校对。
+
+const (
+	// blobDir blob 根目录（相对于 base_dir），对驱动的使用方不可见
+	blobDir = ".blobs"
+	// blobGCInterval 引用校对间隔
+	blobGCInterval = time.Hour
+)
+
+// ErrBlobNotFound 秒传时未找到对应内容
+var ErrBlobNotFound = errors.New("blob not found")
+
+// blobMu 串行化 blob / 引用的元数据与硬链接操作
+var blobMu sync.Mutex
+
+// dedupDriver 在本地驱动之上提供内容去重，name 均为相对于 base_dir 的路径
+type dedupDriver struct {
+	inner *LocalDriver
+}
+
+// newDedupDriver 按配置决定是否为驱动开启去重
+func newDedupDriver(d Driver) Driver {
+	local, ok := d.(*LocalDriver)
+	if !ok {
+		ylog.Warnf("storage", "storage dedup requires the local driver, ignored for %s", d.Name())
+		return d
+	}
+	if !common.EnableSqlite {
+		ylog.Warnf("storage", "storage dedup requires sqlite, ignored")
+		return d
+	}
+	if err := local.Mkdir(blobDir); err != nil {
+		ylog.Errorf("storage", "create blob dir failed, dedup disabled: %v", err)
+		return d
+	}
+	dd := &dedupDriver{inner: local}
+	go dd.startBlobGC()
+	ylog.Infof("storage", "storage dedup enabled, blobs in %s", path.Join(local.Root(), blobDir))
+	return dd
+}
+
+// DedupEnabled 是否启用了内容去重
+func DedupEnabled() bool {
+	_, ok := Backend().(*dedupDriver)
+	return ok
+}
+
+// UploadPath 返回上传目录下 name 相对于 base_dir 的路径（与 UploadFS 的拼接规则一致）
+func UploadPath(name string) string {
+	return Join(strings.Trim(path.Clean("/"+common.UploadDir), "/"), name)
+}
+
+func blobPath(hash string) string {
+	return Join(blobDir, hash[:2], hash)
+}
+
+// isBlobPath 判断路径是否位于 blob 目录内（对使用方隐藏）
+func isBlobPath(name string) bool {
+	cleaned, err := CleanPath(name)
+	if err != nil {
+		return false
+	}
+	return cleaned == blobDir || strings.HasPrefix(cleaned, blobDir+"/")
+}
+
+func hiddenErr(op, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+func (d *dedupDriver) Name() string { return d.inner.Name() }
+
+func (d *dedupDriver) Open(name string) (File, error) {
+	if isBlobPath(name) {
+		return nil, hiddenErr("open", name)
+	}
+	return d.inner.Open(name)
+}
+
+func (d *dedupDriver) Stat(name string) (fs.FileInfo, error) {
+	if isBlobPath(name) {
+		return nil, hiddenErr("stat", name)
+	}
+	return d.inner.Stat(name)
+}
+
+func (d *dedupDriver) List(dir string) ([]fs.FileInfo, error) {
+	if isBlobPath(dir) {
+		return nil, hiddenErr("readdir", dir)
+	}
+	infos, err := d.inner.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	if cleaned, _ := CleanPath(dir); cleaned != "" {
+		return infos, nil
+	}
+	filtered := infos[:0]
+	for _, info := range infos {
+		if info.Name() != blobDir {
+			filtered = append(filtered, info)
+		}
+	}
+	return filtered, nil
+}
+
+// Put 写入时同时计算 SHA-256 / MD5，落盘后并入 blob 存储
+func (d *dedupDriver) Put(name string, r io.Reader, size int64) (int64, error) {
+	if isBlobPath(name) {
+		return 0, ErrInvalidPath
+	}
+	sha := sha256.New()
+	md := md5.New()
+	n, err := d.inner.Put(name, io.TeeReader(r, io.MultiWriter(sha, md)), size)
+	if err != nil {
+		return n, err
+	}
+	cleaned, _ := CleanPath(name)
+	if err := d.ingest(cleaned, hex.EncodeToString(sha.Sum(nil)), hex.EncodeToString(md.Sum(nil)), n); err != nil {
+		// 去重失败不影响写入本身，文件照常保存为独立副本
+		ylog.Errorf("storage", "dedup %s failed: %v", cleaned, err)
+	}
+	return n, nil
+}
+
+func (d *dedupDriver) Rename(oldName, newName string) error {
+	if isBlobPath(oldName) || isBlobPath(newName) {
+		return ErrInvalidPath
+	}
+	if err := d.inner.Rename(oldName, newName); err != nil {
+		return err
+	}
+	oldPath, _ := CleanPath(oldName)
+	newPath, _ := CleanPath(newName)
+	blobMu.Lock()
+	defer blobMu.Unlock()
+	if err := d.moveRefs(oldPath, newPath); err != nil {
+		ylog.Errorf("storage", "move blob refs %s -> %s failed: %v", oldPath, newPath, err)
+	}
+	return nil
+}
+
+func (d *dedupDriver) Remove(name string) error {
+	if isBlobPath(name) {
+		return ErrInvalidPath
+	}
+	if err := d.inner.Remove(name); err != nil {
+		return err
+	}
+	cleaned, _ := CleanPath(name)
+	blobMu.Lock()
+	defer blobMu.Unlock()
+	if err := d.releaseRefs(cleaned, false); err != nil {
+		ylog.Errorf("storage", "release blob ref %s failed: %v", cleaned, err)
+	}
+	return nil
+}
+
+func (d *dedupDriver) RemoveAll(name string) error {
+	if isBlobPath(name) {
+		return ErrInvalidPath
+	}
+	if err := d.inner.RemoveAll(name); err != nil {
+		return err
+	}
+	cleaned, _ := CleanPath(name)
+	blobMu.Lock()
+	defer blobMu.Unlock()
+	if err := d.releaseRefs(cleaned, true); err != nil {
+		ylog.Errorf("storage", "release blob refs under %s failed: %v", cleaned, err)
+	}
+	return nil
+}
+
+func (d *dedupDriver) Mkdir(name string) error {
+	if isBlobPath(name) {
+		return ErrInvalidPath
+	}
+	return d.inner.Mkdir(name)
+}
+
+// Copy 本地硬链接拷贝，源文件已登记时目标文件引用同一 blob
+func (d *dedupDriver) Copy(src, dst string) error {
+	if isBlobPath(src) || isBlobPath(dst) {
+		return ErrInvalidPath
+	}
+	if err := d.inner.Copy(src, dst); err != nil {
+		return err
+	}
+	srcPath, _ := CleanPath(src)
+	dstPath, _ := CleanPath(dst)
+	blobMu.Lock()
+	defer blobMu.Unlock()
+	db, err := common.GetDB()
+	if err != nil {
+		return nil
+	}
+	var ref models.BlobRefModel
+	if err := db.Where("path = ?", srcPath).First(&ref).Error; err != nil {
+		// 源文件不在 blob 存储中，目标的旧引用（若有）已失效
+		if err := d.releaseRefs(dstPath, false); err != nil {
+			ylog.Errorf("storage", "release blob ref %s failed: %v", dstPath, err)
+		}
+		return nil
+	}
+	if err := d.addRef(db, dstPath, ref.Hash); err != nil {
+		ylog.Errorf("storage", "add blob ref %s failed: %v", dstPath, err)
+	}
+	return nil
+}
+
+// ingest 将刚写入的文件并入 blob 存储
+func (d *dedupDriver) ingest(name, hash, md5sum string, size int64) error {
+	blobMu.Lock()
+	defer blobMu.Unlock()
+
+	db, err := common.GetDB()
+	if err != nil {
+		return err
+	}
+	if size == 0 {
+		// 空文件无需去重，仅清理旧引用
+		return d.releaseRefs(name, false)
+	}
+
+	var blob models.BlobModel
+	err = db.Where("hash = ?", hash).First(&blob).Error
+	switch {
+	case err == nil && Exists(d.inner, blobPath(hash)):
+		// 内容已存在：用 blob 替换刚写入的文件，释放其占用的空间
+		if err := d.inner.Copy(blobPath(hash), name); err != nil {
+			return err
+		}
+	case err == nil || errors.Is(err, gorm.ErrRecordNotFound):
+		// 内容首次出现（或 blob 文件丢失）：以刚写入的文件作为 blob
+		if err := d.inner.Copy(name, blobPath(hash)); err != nil {
+			return err
+		}
+		if blob.ID == 0 {
+			blob = models.BlobModel{Hash: hash, MD5: md5sum, Size: size, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+			if err := db.Create(&blob).Error; err != nil {
+				return err
+			}
+		}
+	default:
+		return err
+	}
+	return d.addRef(db, name, hash)
+}
+
+// addRef 登记 name -> hash 引用，覆盖 name 原有的引用
+func (d *dedupDriver) addRef(db *gorm.DB, name, hash string) error {
+	var ref models.BlobRefModel
+	err := db.Where("path = ?", name).First(&ref).Error
+	if err == nil {
+		if ref.Hash == hash {
+			return nil
+		}
+		if err := d.releaseRef(db, ref); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&models.BlobRefModel{Path: name, Hash: hash, CreatedAt: time.Now()}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.BlobModel{}).Where("hash = ?", hash).
+			Updates(map[string]interface{}{"ref_count": gorm.Expr("ref_count + 1"), "updated_at": time.Now()}).Error
+	})
+}
+
+// releaseRefs 释放 name（recursive 时包括其下所有文件）的引用
+func (d *dedupDriver) releaseRefs(name string, recursive bool) error {
+	db, err := common.GetDB()
+	if err != nil {
+		return err
+	}
+	var refs []models.BlobRefModel
+	query := db.Where("path = ?", name)
+	if recursive {
+		if name == "" {
+			query = db
+		} else {
+			prefix := name + "/"
+			query = db.Where("path = ? OR substr(path, 1, ?) = ?", name, len(prefix), prefix)
+		}
+	}
+	if err := query.Find(&refs).Error; err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if err := d.releaseRef(db, ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseRef 删除一条引用，blob 引用计数归零时删除 blob 文件
+func (d *dedupDriver) releaseRef(db *gorm.DB, ref models.BlobRefModel) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&ref).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.BlobModel{}).Where("hash = ?", ref.Hash).
+			Updates(map[string]interface{}{"ref_count": gorm.Expr("ref_count - 1"), "updated_at": time.Now()}).Error
+	})
+	if err != nil {
+		return err
+	}
+	var blob models.BlobModel
+	if err := db.Where("hash = ?", ref.Hash).First(&blob).Error; err == nil && blob.RefCount <= 0 {
+		d.dropBlob(db, blob)
+	}
+	return nil
+}
+
+func (d *dedupDriver) dropBlob(db *gorm.DB, blob models.BlobModel) {
+	if err := d.inner.Remove(blobPath(blob.Hash)); err != nil && !IsNotExist(err) {
+		ylog.Errorf("storage", "remove blob %s failed: %v", blob.Hash, err)
+		return
+	}
+	if err := db.Delete(&blob).Error; err != nil {
+		ylog.Errorf("storage", "delete blob %s failed: %v", blob.Hash, err)
+	}
+	// 分桶目录为空时一并删除，非空时 Remove 会失败，忽略即可
+	_ = d.inner.Remove(path.Dir(blobPath(blob.Hash)))
+}
+
+// moveRefs 重命名后更新引用路径（目录重命名时更新其下所有文件）
+func (d *dedupDriver) moveRefs(oldPath, newPath string) error {
+	db, err := common.GetDB()
+	if err != nil {
+		return err
+	}
+	// 被覆盖的目标文件的引用先释放
+	if err := d.releaseRefs(newPath, true); err != nil {
+		return err
+	}
+	var refs []models.BlobRefModel
+	prefix := oldPath + "/"
+	if err := db.Where("path = ? OR substr(path, 1, ?) = ?", oldPath, len(prefix), prefix).Find(&refs).Error; err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		moved := newPath + strings.TrimPrefix(ref.Path, oldPath)
+		if err := db.Model(&models.BlobRefModel{}).Where("id = ?", ref.ID).Update("path", moved).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LinkBlob 秒传：将已存在的内容链接到 dst（相对于 base_dir），按 SHA-256 查找。
+// 只有 scope 可读取的文件（见 UserScope.CanReadKey）引用的 blob 才能命中，避免凭摘要取得其他用户的文件；
+// 不接受 MD5，MD5 可以构造碰撞。size >= 0 时要求内容大小一致。返回命中的 blob。
+func LinkBlob(dst, hash string, size int64, scope *UserScope) (*models.BlobModel, error) {
+	d, ok := Backend().(*dedupDriver)
+	if !ok {
+		return nil, ErrBlobNotFound
+	}
+	if hash == "" {
+		return nil, ErrBlobNotFound
+	}
+	dstPath, err := CleanPath(dst)
+	if err != nil || dstPath == "" || isBlobPath(dstPath) {
+		return nil, ErrInvalidPath
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	blobMu.Lock()
+	defer blobMu.Unlock()
+
+	var blob models.BlobModel
+	if err := db.Where("ref_count > 0 AND hash = ?", strings.ToLower(hash)).First(&blob).Error; err != nil {
+		return nil, ErrBlobNotFound
+	}
+	if size >= 0 && blob.Size != size {
+		return nil, ErrBlobNotFound
+	}
+	var refs []string
+	db.Model(&models.BlobRefModel{}).Where("hash = ?", blob.Hash).Pluck("path", &refs)
+	readable := false
+	for _, ref := range refs {
+		if scope.CanReadKey(ref) {
+			readable = true
+			break
+		}
+	}
+	if !readable {
+		return nil, ErrBlobNotFound
+	}
+	if !Exists(d.inner, blobPath(blob.Hash)) {
+		return nil, ErrBlobNotFound
+	}
+	if err := d.inner.Copy(blobPath(blob.Hash), dstPath); err != nil {
+		return nil, err
+	}
+	if err := d.addRef(db, dstPath, blob.Hash); err != nil {
+		return nil, err
+	}
+	return &blob, nil
+}
+
+// DedupStats 去重统计：blob 数、引用数、逻辑总大小与实际占用大小
+type DedupStats struct {
+	Blobs        int64 `json:"blobs"`
+	Refs         int64 `json:"refs"`
+	LogicalSize  int64 `json:"logicalSize"`
+	PhysicalSize int64 `json:"physicalSize"`
+}
+
+// GetDedupStats 返回去重统计，未启用时返回 nil
+func GetDedupStats() *DedupStats {
+	if !DedupEnabled() {
+		return nil
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		return nil
+	}
+	var stats DedupStats
+	var row struct {
+		Blobs    int64
+		Refs     int64
+		Logical  int64
+		Physical int64
+	}
+	if err := db.Model(&models.BlobModel{}).
+		Select("COUNT(*) AS blobs, COALESCE(SUM(ref_count), 0) AS refs, COALESCE(SUM(size * ref_count), 0) AS logical, COALESCE(SUM(size), 0) AS physical").
+		Scan(&row).Error; err != nil {
+		ylog.Errorf("storage", "query dedup stats failed: %v", err)
+		return nil
+	}
+	stats.Blobs, stats.Refs, stats.LogicalSize, stats.PhysicalSize = row.Blobs, row.Refs, row.Logical, row.Physical
+	return &stats
+}
+
+// startBlobGC 定期校对引用与 blob：
+// 引用的文件已不存在或已被驱动之外的写入替换（不再与 blob 共享 inode）时释放引用；
+// blob 文件丢失但仍有引用时从引用文件重建；无人引用的 blob 与未登记的 blob 文件被删除。
+func (d *dedupDriver) startBlobGC() {
+	d.gcBlobs()
+	ticker := time.NewTicker(blobGCInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		d.gcBlobs()
+	}
+}
+
+func (d *dedupDriver) gcBlobs() {
+	db, err := common.GetDB()
+	if err != nil {
+		return
+	}
+	released := 0
+
+	var refs []models.BlobRefModel
+	if err := db.Order("id").Find(&refs).Error; err != nil {
+		ylog.Errorf("blobGC", "query blob refs failed: %v", err)
+		return
+	}
+	for _, ref := range refs {
+		blobMu.Lock()
+		if !d.refValid(db, ref) {
+			if err := d.releaseRef(db, ref); err != nil {
+				ylog.Errorf("blobGC", "release blob ref %s failed: %v", ref.Path, err)
+			} else {
+				released++
+			}
+		}
+		blobMu.Unlock()
+	}
+
+	blobMu.Lock()
+	defer blobMu.Unlock()
+
+	var orphans []models.BlobModel
+	if err := db.Where("ref_count <= 0").Find(&orphans).Error; err == nil {
+		for _, blob := range orphans {
+			d.dropBlob(db, blob)
+		}
+	}
+
+	// 删除未登记的 blob 文件（例如写入过程中进程退出）
+	_ = Walk(d.inner, blobDir, func(name string, info fs.FileInfo) error {
+		if info.IsDir() {
+			return nil
+		}
+		var count int64
+		if err := db.Model(&models.BlobModel{}).Where("hash = ?", info.Name()).Count(&count).Error; err == nil && count == 0 {
+			_ = d.inner.Remove(name)
+		}
+		return nil
+	})
+
+	if released > 0 || len(orphans) > 0 {
+		ylog.Infof("blobGC", "released %d stale refs, removed %d unreferenced blobs", released, len(orphans))
+	}
+}
+
+// refValid 判断引用是否仍然有效（调用方持有 blobMu）
+func (d *dedupDriver) refValid(db *gorm.DB, ref models.BlobRefModel) bool {
+	fileInfo, err := d.inner.Stat(ref.Path)
+	if err != nil || fileInfo.IsDir() {
+		return false
+	}
+	blobInfo, err := d.inner.Stat(blobPath(ref.Hash))
+	if err != nil {
+		if !IsNotExist(err) {
+			return true
+		}
+		// blob 文件丢失：从仍然存在的引用文件重建
+		if err := d.inner.Copy(ref.Path, blobPath(ref.Hash)); err != nil {
+			ylog.Errorf("blobGC", "restore blob %s from %s failed: %v", ref.Hash, ref.Path, err)
+		}
+		return true
+	}
+	return os.SameFile(fileInfo, blobInfo)
+}
@@ -0,0 +1,378 @@
+package storage
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+)
+
+// ========== v0.8.0 新增：可插拔存储驱动 ==========
+//
+// 所有文件类 handler 与 MCP 工具都通过 Driver 访问文件，不再直接依赖本地 POSIX 路径。
+// Driver 中的 name 统一为 "/" 分隔的相对路径（相对于驱动根目录），"" 或 "." 表示根目录；
+// 路径校验（禁止 ..、绝对路径、空字节等）由驱动内部完成，调用方无需再拼接绝对路径。
+
+// ErrInvalidPath 非法路径（路径穿越、绝对路径等）
+var ErrInvalidPath = errors.New("invalid path")
+
+// Driver 存储驱动接口
+type Driver interface {
+	// Name 驱动名称（local / s3）
+	Name() string
+	// Open 打开文件用于读取，返回的 File 支持 Seek（供 Range 下载使用）
+	Open(name string) (File, error)
+	// Stat 获取文件或目录信息，不存在时返回的 error 满足 errors.Is(err, fs.ErrNotExist)
+	Stat(name string) (fs.FileInfo, error)
+	// List 列出目录下的直接子项（不递归）
+	List(dir string) ([]fs.FileInfo, error)
+	// Put 写入文件（已存在则覆盖），size 未知时传 -1，返回实际写入的字节数
+	Put(name string, r io.Reader, size int64) (int64, error)
+	// Rename 重命名/移动文件或目录
+	Rename(oldName, newName string) error
+	// Remove 删除文件或空目录
+	Remove(name string) error
+	// RemoveAll 递归删除目录及其内容
+	RemoveAll(name string) error
+	// Mkdir 创建目录（含父目录），已存在时不报错
+	Mkdir(name string) error
+}
+
+// File 驱动打开的只读文件
+type File interface {
+	io.Reader
+	io.Seeker
+	io.Closer
+	Stat() (fs.FileInfo, error)
+}
+
+// Copier 可选接口：驱动原生支持服务端拷贝时实现（本地硬链接、S3 CopyObject 等）
+type Copier interface {
+	Copy(src, dst string) error
+}
+
+// MultipartUploader 可选接口：驱动原生支持分段上传时实现（S3 multipart upload）
+// 分片上传会话直接映射为一次分段上传，分片无需先落到本地磁盘再合并。
+// partNumber 从 1 开始；除最后一段外每段不得小于 MinPartSize。
+type MultipartUploader interface {
+	// MinPartSize 除最后一段外单段的最小字节数
+	MinPartSize() int64
+	// MaxParts 单次分段上传允许的最大段数
+	MaxParts() int
+	// InitMultipart 创建分段上传，返回驱动侧的上传 ID
+	InitMultipart(name string) (string, error)
+	// PutPart 上传（或覆盖）一段
+	PutPart(name, uploadID string, partNumber int, r io.Reader, size int64) error
+	// CompleteMultipart 按段号顺序合并 1..totalParts 段，缺段时返回错误
+	CompleteMultipart(name, uploadID string, totalParts int) error
+	// AbortMultipart 中止分段上传并释放已上传的段
+	AbortMultipart(name, uploadID string) error
+}
+
+// subber 可选接口：驱动原生支持以子目录为根创建新驱动
+type subber interface {
+	Sub(dir string) Driver
+}
+
+var (
+	backend     Driver
+	backendOnce sync.Once
+)
+
+// Backend 返回根目录为 FileBaseDir 的全局存储驱动（首次调用时按配置初始化）
+func Backend() Driver {
+	backendOnce.Do(func() {
+		d, err := newDriver(common.StorageDriver)
+		if err != nil {
+			ylog.Fatalf("storage", "init storage driver %q failed: %v", common.StorageDriver, err)
+		}
+		switch {
+		case common.StorageDedup && common.StorageEncryptionEnable:
+			// 加密后相同内容的密文各不相同，无法按内容去重
+			ylog.Warnf("storage", "storage dedup is disabled because encryption is enabled")
+		case common.StorageDedup:
+			d = newDedupDriver(d)
+		}
+		if common.StorageEncryptionEnable {
+			d = newCryptDriver(d)
+		}
+		backend = d
+		ylog.Infof("storage", "storage driver: %s", d.Name())
+		if VersioningEnabled() {
+			go startVersionCleanup()
+		}
+		if TrashEnabled() {
+			go startTrashCleanup()
+		}
+		if FileIndexEnabled() {
+			Subscribe(onFileChange)
+			go startFileIndexReconcile()
+		}
+		if FileWatchEnabled() {
+			go startFileWatcher()
+		}
+	})
+	return backend
+}
+
+// newDriver 根据驱动名创建存储驱动
+func newDriver(name string) (Driver, error) {
+	switch name {
+	case "", "local":
+		return NewLocalDriver(common.FileBaseDir), nil
+	case "s3":
+		d, err := NewS3Driver(S3Config{
+			Endpoint:  common.StorageS3Endpoint,
+			AccessKey: common.StorageS3AccessKey,
+			SecretKey: common.StorageS3SecretKey,
+			Bucket:    common.StorageS3Bucket,
+			Region:    common.StorageS3Region,
+			Prefix:    common.StorageS3Prefix,
+			UseSSL:    common.StorageS3UseSSL,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return d, nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %s", name)
+	}
+}
+
+// UploadFS 返回以上传目录为根的驱动
+func UploadFS() Driver {
+	return Sub(Backend(), common.UploadDir)
+}
+
+// DownloadFS 返回以下载目录为根的驱动
+func DownloadFS() Driver {
+	return Sub(Backend(), common.DownloadDir)
+}
+
+// Sub 返回以 dir 为根目录的驱动视图
+func Sub(d Driver, dir string) Driver {
+	if s, ok := d.(subber); ok {
+		return s.Sub(dir)
+	}
+	return &subDriver{parent: d, prefix: strings.Trim(path.Clean("/"+dir), "/")}
+}
+
+// CleanPath 规范化相对路径，根目录返回 ""；包含 ..、绝对路径、反斜杠或空字节时返回 ErrInvalidPath
+func CleanPath(name string) (string, error) {
+	trimmed := strings.TrimSpace(name)
+	if trimmed == "" || trimmed == "." || trimmed == "/" {
+		return "", nil
+	}
+	if strings.ContainsRune(trimmed, 0) || strings.Contains(trimmed, "\\") || strings.HasPrefix(trimmed, "/") {
+		return "", ErrInvalidPath
+	}
+	cleaned := path.Clean(trimmed)
+	if cleaned == "." {
+		return "", nil
+	}
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", ErrInvalidPath
+	}
+	return cleaned, nil
+}
+
+// Join 拼接相对路径（不做合法性校验，校验由驱动在访问时完成）
+func Join(elem ...string) string {
+	p := path.Join(elem...)
+	if p == "." {
+		return ""
+	}
+	return strings.TrimPrefix(p, "/")
+}
+
+// IsNotExist 判断错误是否为文件不存在
+func IsNotExist(err error) bool {
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+// Exists 判断文件或目录是否存在
+func Exists(d Driver, name string) bool {
+	_, err := d.Stat(name)
+	return err == nil
+}
+
+// Copy 在同一驱动内拷贝文件；驱动实现了 Copier 时走原生拷贝，否则读出后重新写入
+func Copy(d Driver, src, dst string) error {
+	if c, ok := d.(Copier); ok {
+		return c.Copy(src, dst)
+	}
+	in, err := d.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	size := int64(-1)
+	if info, err := in.Stat(); err == nil {
+		size = info.Size()
+	}
+	_, err = d.Put(dst, in, size)
+	return err
+}
+
+// FileMD5 计算文件 MD5（十六进制小写）
+func FileMD5(d Driver, name string) (string, error) {
+	f, err := d.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// WalkFunc Walk 的回调，name 为相对于 Walk 根目录所在驱动的路径
+type WalkFunc func(name string, info fs.FileInfo) error
+
+// SkipDir 回调返回该值时跳过当前目录
+var SkipDir = fs.SkipDir
+
+// Walk 递归遍历目录（目录先于其子项回调，同级按名称排序）
+func Walk(d Driver, root string, fn WalkFunc) error {
+	info, err := d.Stat(root)
+	if err != nil {
+		return err
+	}
+	return walk(d, root, info, fn)
+}
+
+func walk(d Driver, name string, info fs.FileInfo, fn WalkFunc) error {
+	if err := fn(name, info); err != nil {
+		if info.IsDir() && errors.Is(err, SkipDir) {
+			return nil
+		}
+		return err
+	}
+	if !info.IsDir() {
+		return nil
+	}
+
+	entries, err := d.List(name)
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	for _, entry := range entries {
+		if err := walk(d, Join(name, entry.Name()), entry, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subDriver 通用的子目录视图：为所有路径加上前缀后转发给父驱动
+type subDriver struct {
+	parent Driver
+	prefix string
+}
+
+func (s *subDriver) full(name string) (string, error) {
+	cleaned, err := CleanPath(name)
+	if err != nil {
+		return "", err
+	}
+	return Join(s.prefix, cleaned), nil
+}
+
+func (s *subDriver) Name() string { return s.parent.Name() }
+
+func (s *subDriver) Open(name string) (File, error) {
+	p, err := s.full(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.parent.Open(p)
+}
+
+func (s *subDriver) Stat(name string) (fs.FileInfo, error) {
+	p, err := s.full(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.parent.Stat(p)
+}
+
+func (s *subDriver) List(dir string) ([]fs.FileInfo, error) {
+	p, err := s.full(dir)
+	if err != nil {
+		return nil, err
+	}
+	return s.parent.List(p)
+}
+
+func (s *subDriver) Put(name string, r io.Reader, size int64) (int64, error) {
+	p, err := s.full(name)
+	if err != nil {
+		return 0, err
+	}
+	return s.parent.Put(p, r, size)
+}
+
+func (s *subDriver) Rename(oldName, newName string) error {
+	oldPath, err := s.full(oldName)
+	if err != nil {
+		return err
+	}
+	newPath, err := s.full(newName)
+	if err != nil {
+		return err
+	}
+	return s.parent.Rename(oldPath, newPath)
+}
+
+func (s *subDriver) Remove(name string) error {
+	p, err := s.full(name)
+	if err != nil {
+		return err
+	}
+	return s.parent.Remove(p)
+}
+
+func (s *subDriver) RemoveAll(name string) error {
+	cleaned, err := CleanPath(name)
+	if err != nil {
+		return err
+	}
+	if cleaned == "" {
+		// 禁止删除视图根目录本身
+		return ErrInvalidPath
+	}
+	return s.parent.RemoveAll(Join(s.prefix, cleaned))
+}
+
+func (s *subDriver) Mkdir(name string) error {
+	p, err := s.full(name)
+	if err != nil {
+		return err
+	}
+	return s.parent.Mkdir(p)
+}
+
+func (s *subDriver) Copy(src, dst string) error {
+	srcPath, err := s.full(src)
+	if err != nil {
+		return err
+	}
+	dstPath, err := s.full(dst)
+	if err != nil {
+		return err
+	}
+	return Copy(s.parent, srcPath, dstPath)
+}
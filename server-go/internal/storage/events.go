@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// ========== v0.8.0 新增：存储变更事件 ==========
+//
+// 本地驱动与 S3 驱动的每个写操作成功后都会发布一个 ChangeEvent，
+// 文件索引（t_file_meta，见 filemeta.go）等订阅方据此增量更新，无需遍历目录树。
+// 事件路径统一为相对于存储根目录（base_dir / storage.s3.prefix）的路径，与 Backend() 的寻址一致。
+
+// ChangeOp 变更类型
+type ChangeOp string
+
+const (
+	// OpPut 写入文件（新建或覆盖）
+	OpPut ChangeOp = "put"
+	// OpRemove 删除文件或空目录
+	OpRemove ChangeOp = "remove"
+	// OpRemoveAll 递归删除
+	OpRemoveAll ChangeOp = "remove_all"
+	// OpRename 重命名 / 移动（文件或目录）
+	OpRename ChangeOp = "rename"
+	// OpCopy 拷贝文件
+	OpCopy ChangeOp = "copy"
+	// OpMkdir 创建目录
+	OpMkdir ChangeOp = "mkdir"
+)
+
+// ChangeEvent 一次存储变更
+type ChangeEvent struct {
+	Op      ChangeOp
+	Path    string // 目标路径（rename / copy 为新路径）
+	OldPath string // rename / copy 的源路径
+	Size    int64  // put / copy 后的文件大小，未知时为 -1
+	Time    time.Time
+
+	// External v0.8.0 新增：外部改动，即未经存储驱动、由文件系统监听或定期对账发现的改动（只有 put / remove_all）
+	External bool
+}
+
+var (
+	listenersMu sync.RWMutex
+	listeners   []func(ChangeEvent)
+)
+
+// Subscribe 注册变更监听函数。监听函数在写操作所在的 goroutine 中同步调用，应尽快返回。
+func Subscribe(fn func(ChangeEvent)) {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+	listeners = append(listeners, fn)
+}
+
+func emit(ev ChangeEvent) {
+	listenersMu.RLock()
+	ls := listeners
+	listenersMu.RUnlock()
+	if len(ls) == 0 {
+		return
+	}
+	ev.Time = time.Now()
+	for _, fn := range ls {
+		fn(ev)
+	}
+}
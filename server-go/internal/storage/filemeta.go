@@ -0,0 +1,321 @@
+package storage
+
+import (
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+)
+
+// ========== v0.8.0 新增：文件索引 ==========
+//
+// t_file_meta 按路径记录每个文件的大小、上传者与校验和，由存储变更事件（events.go）增量维护，
+// 配额用量等统计直接对索引做 SUM 聚合，无需遍历目录树；校验和的缓存与失效规则见 checksum.go。
+// 绕过驱动的外部修改由 startFileIndexReconcile 定期对账修正（启动时执行一次，之后每天一次；开启文件系统监听时按配置的间隔），
+// 对账发现的改动作为外部改动（ChangeEvent.External）发布，与文件系统监听（watcher.go）走同一条事件管道。
+// 历史版本与回收站中的文件同样登记（保留上传者，恢复后沿用），但不计入用量；去重 blob 不登记。
+
+const fileIndexReconcileInterval = 24 * time.Hour
+
+// FileIndexEnabled 是否维护文件索引（依赖 SQLite）
+func FileIndexEnabled() bool {
+	return common.EnableSqlite
+}
+
+// withinPrefix 限定查询范围为 dir 本身及其下所有路径；dir 为空时不限
+func withinPrefix(db *gorm.DB, dir string) *gorm.DB {
+	if dir == "" {
+		return db
+	}
+	// "0" 是 "/" 的下一个字符，[dir/, dir0) 即 dir/ 下的所有路径，无需转义 LIKE 通配符
+	return db.Where("path = ? OR (path >= ? AND path < ?)", dir, dir+"/", dir+"0")
+}
+
+// excludeReserved 排除保留目录中的路径
+func excludeReserved(db *gorm.DB) *gorm.DB {
+	for _, dir := range []string{versionDir, trashDir, blobDir} {
+		db = db.Where("NOT (path = ? OR (path >= ? AND path < ?))", dir, dir+"/", dir+"0")
+	}
+	return db
+}
+
+// onFileChange 根据存储变更事件更新文件索引
+func onFileChange(ev ChangeEvent) {
+	db, err := common.GetDB()
+	if err != nil {
+		return
+	}
+	if common.StorageEncryptionEnable {
+		// 事件中的大小为密文大小，改为经加密驱动读取明文大小
+		ev.Size = -1
+	}
+	switch ev.Op {
+	case OpPut:
+		if !isBlobPath(ev.Path) {
+			upsertFileMeta(db, ev.Path, ev.Size, "")
+		}
+	case OpCopy:
+		if isBlobPath(ev.Path) {
+			return
+		}
+		// 拷贝（保存 / 恢复历史版本等）沿用源文件的上传者，源文件的校验和仍有效时一并沿用
+		owner := ""
+		if !isBlobPath(ev.OldPath) {
+			var src models.FileMetaModel
+			if db.Where("path = ?", ev.OldPath).Limit(1).Find(&src).RowsAffected > 0 {
+				owner = src.Owner
+				if copyFileMeta(db, &src, ev.Path) {
+					return
+				}
+			}
+		}
+		upsertFileMeta(db, ev.Path, ev.Size, owner)
+	case OpRename:
+		renameFileMeta(db, ev.OldPath, ev.Path)
+	case OpRemove, OpRemoveAll:
+		if err := withinPrefix(db, ev.Path).Delete(&models.FileMetaModel{}).Error; err != nil {
+			ylog.Errorf("fileIndex", "delete index of %s failed: %v", ev.Path, err)
+		}
+	}
+}
+
+// upsertFileMeta 登记或更新文件，size 未知（-1）时从存储读取
+func upsertFileMeta(db *gorm.DB, name string, size int64, owner string) {
+	modTime := time.Now()
+	if size < 0 {
+		info, err := Backend().Stat(name)
+		if err != nil || info.IsDir() {
+			return
+		}
+		size, modTime = info.Size(), info.ModTime()
+	}
+	putFileMeta(db, models.FileMetaModel{Path: name, Size: size, Owner: owner, ModTime: modTime})
+}
+
+// copyFileMeta 源文件的校验和仍有效时，以其登记拷贝出的 name；无法沿用时返回 false
+func copyFileMeta(db *gorm.DB, src *models.FileMetaModel, name string) bool {
+	if src.MD5 == "" {
+		return false
+	}
+	srcInfo, err := Backend().Stat(src.Path)
+	if err != nil || !checksumsValid(src, srcInfo) {
+		return false
+	}
+	info, err := Backend().Stat(name)
+	if err != nil || info.IsDir() || info.Size() != src.Size {
+		return false
+	}
+	putFileMeta(db, models.FileMetaModel{
+		Path: name, Size: info.Size(), Owner: src.Owner, ModTime: info.ModTime(),
+		MD5: src.MD5, SHA256: src.SHA256, MimeType: src.MimeType,
+	})
+	return true
+}
+
+// putFileMeta 按路径插入或更新索引条目，Owner 为空时保留原上传者；
+// 校验和随之覆盖（写入事件与对账时为空，即内容变化后清除，之后按需重新计算）
+func putFileMeta(db *gorm.DB, meta models.FileMetaModel) {
+	meta.UpdatedAt = time.Now()
+	columns := []string{"size", "mod_time", "md5", "sha256", "mime_type", "updated_at"}
+	if meta.Owner != "" {
+		columns = append(columns, "owner")
+	}
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "path"}},
+		DoUpdates: clause.AssignmentColumns(columns),
+	}).Create(&meta).Error
+	if err != nil {
+		ylog.Errorf("fileIndex", "upsert index of %s failed: %v", meta.Path, err)
+	}
+}
+
+// renameFileMeta 将 oldName（文件或目录）下的索引条目整体移动到 newName
+func renameFileMeta(db *gorm.DB, oldName, newName string) {
+	switch {
+	case isBlobPath(newName):
+		_ = withinPrefix(db, oldName).Delete(&models.FileMetaModel{}).Error
+		return
+	case isBlobPath(oldName):
+		upsertFileMeta(db, newName, -1, "")
+		return
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 目标位置原有的条目被覆盖
+		if err := withinPrefix(tx, newName).Delete(&models.FileMetaModel{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.FileMetaModel{}).Where("path = ?", oldName).
+			Update("path", newName).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.FileMetaModel{}).
+			Where("path >= ? AND path < ?", oldName+"/", oldName+"0").
+			Update("path", gorm.Expr("? || substr(path, ?)", newName, len(oldName)+1)).Error
+	})
+	if err != nil {
+		ylog.Errorf("fileIndex", "rename index %s -> %s failed: %v", oldName, newName, err)
+		return
+	}
+	// 源文件此前未登记（如索引建立前已存在）时补登
+	if info, err := Backend().Stat(newName); err == nil && !info.IsDir() {
+		var count int64
+		db.Model(&models.FileMetaModel{}).Where("path = ?", newName).Count(&count)
+		if count == 0 {
+			putFileMeta(db, models.FileMetaModel{Path: newName, Size: info.Size(), ModTime: info.ModTime()})
+		}
+	}
+}
+
+// SetFileOwner 上传成功后登记上传目录中 name 的上传者（用户名或 appkey）
+func SetFileOwner(name, owner string) {
+	if !FileIndexEnabled() || owner == "" {
+		return
+	}
+	cleaned, err := CleanPath(name)
+	if err != nil || cleaned == "" {
+		return
+	}
+	SetFileOwnerKey(UploadPath(cleaned), owner)
+}
+
+// SetFileOwnerKey 同 SetFileOwner，full 为相对于存储根目录的路径
+func SetFileOwnerKey(full, owner string) {
+	if !FileIndexEnabled() || owner == "" || full == "" {
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		return
+	}
+	result := db.Model(&models.FileMetaModel{}).Where("path = ?", full).Update("owner", owner)
+	if result.Error == nil && result.RowsAffected == 0 {
+		upsertFileMeta(db, full, -1, owner)
+	}
+}
+
+// startFileIndexReconcile 定期将文件索引与实际存储对账（启动时执行一次，之后每天一次或按 file.watch.reconcile_interval）
+func startFileIndexReconcile() {
+	interval := fileIndexReconcileInterval
+	if FileWatchEnabled() {
+		interval = time.Duration(common.FileWatchReconcileInterval) * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	reconcileFileIndex()
+	for range ticker.C {
+		reconcileFileIndex()
+	}
+}
+
+// indexRoots 需要对账的目录：上传 / 下载目录与历史版本、回收站目录（去掉互相包含的部分）
+func indexRoots() []string {
+	return coveringRoots([]string{UploadPath(""), DownloadPath(""), versionDir, trashDir})
+}
+
+// coveringRoots 去掉 candidates 中被其他目录包含的部分
+func coveringRoots(candidates []string) []string {
+	sort.Strings(candidates)
+	var roots []string
+	for _, dir := range candidates {
+		covered := false
+		for _, root := range roots {
+			if dir == root || strings.HasPrefix(dir, root+"/") {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			roots = append(roots, dir)
+		}
+	}
+	return roots
+}
+
+func reconcileFileIndex() {
+	db, err := common.GetDB()
+	if err != nil {
+		return
+	}
+	start := time.Now()
+
+	var rows []models.FileMetaModel
+	if err := db.Select("id", "path", "size").Find(&rows).Error; err != nil {
+		ylog.Errorf("fileIndex", "load file index failed: %v", err)
+		return
+	}
+	indexed := make(map[string]models.FileMetaModel, len(rows))
+	for _, row := range rows {
+		indexed[row.Path] = row
+	}
+	// 首次建立索引时直接登记，不作为外部改动发布
+	bootstrap := len(rows) == 0
+
+	added, updated := 0, 0
+	for _, root := range indexRoots() {
+		err := Walk(Backend(), root, func(name string, info fs.FileInfo) error {
+			if info.IsDir() {
+				return nil
+			}
+			// 写入中的临时文件（.xxx.*.tmp）由后续事件登记
+			if base := path.Base(name); strings.HasPrefix(base, ".") && strings.HasSuffix(base, ".tmp") {
+				return nil
+			}
+			row, ok := indexed[name]
+			delete(indexed, name)
+			if ok && row.Size == info.Size() {
+				return nil
+			}
+			if ok {
+				updated++
+			} else {
+				added++
+			}
+			if bootstrap {
+				putFileMeta(db, models.FileMetaModel{Path: name, Size: info.Size(), ModTime: info.ModTime()})
+			} else {
+				emit(ChangeEvent{Op: OpPut, Path: name, Size: info.Size(), External: true})
+			}
+			return nil
+		})
+		if err != nil && !IsNotExist(err) {
+			ylog.Errorf("fileIndex", "walk %s failed: %v", root, err)
+			return
+		}
+	}
+
+	// 剩余条目对应的文件已不存在；对账期间被事件更新过的条目（如重命名）不删除
+	stale := make([]uint, 0, len(indexed))
+	for _, row := range indexed {
+		stale = append(stale, row.ID)
+	}
+	removed := 0
+	for i := 0; i < len(stale); i += 500 {
+		end := i + 500
+		if end > len(stale) {
+			end = len(stale)
+		}
+		var paths []string
+		if err := db.Model(&models.FileMetaModel{}).Where("id IN ? AND updated_at < ?", stale[i:end], start).
+			Pluck("path", &paths).Error; err != nil {
+			ylog.Errorf("fileIndex", "load stale index failed: %v", err)
+			continue
+		}
+		// 经事件管道删除索引条目，订阅方据此清理图片记录、失效分享等
+		for _, name := range paths {
+			emit(ChangeEvent{Op: OpRemoveAll, Path: name, Size: -1, External: true})
+		}
+		removed += len(paths)
+	}
+	if added+updated > 0 || removed > 0 {
+		ylog.Infof("fileIndex", "reconciled file index: %d added, %d updated, %d removed", added, updated, removed)
+	}
+}
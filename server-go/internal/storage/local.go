@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"httpcat/internal/common"
+)
+
+// LocalDriver 本地文件系统驱动（默认驱动）
+// 所有路径都经过 common.ResolvePathWithinBase 解析，防止路径穿越与符号链接逃逸。
+type LocalDriver struct {
+	root   string
+	prefix string // 相对于存储根目录（base_dir）的位置，变更事件路径以此拼接
+}
+
+// NewLocalDriver 创建以 root 为根目录的本地驱动
+func NewLocalDriver(root string) *LocalDriver {
+	return &LocalDriver{root: filepath.Clean(root)}
+}
+
+// eventPath 将驱动内的相对路径转换为变更事件路径
+func (d *LocalDriver) eventPath(name string) string {
+	cleaned, _ := CleanPath(name)
+	return Join(d.prefix, cleaned)
+}
+
+// Root 返回驱动根目录
+func (d *LocalDriver) Root() string {
+	return d.root
+}
+
+// LocalPath 返回 name 对应的本地绝对路径（仅本地驱动可用，供硬链接等场景使用）
+func (d *LocalDriver) LocalPath(name string) (string, error) {
+	return d.resolve(name)
+}
+
+func (d *LocalDriver) resolve(name string) (string, error) {
+	cleaned, err := CleanPath(name)
+	if err != nil {
+		return "", err
+	}
+	p, err := common.ResolvePathWithinBase(d.root, cleaned)
+	if err != nil {
+		return "", ErrInvalidPath
+	}
+	return p, nil
+}
+
+func (d *LocalDriver) Name() string { return "local" }
+
+// Sub 以子目录为根创建新的本地驱动（与 common.GetUploadDir 等拼接规则一致）
+func (d *LocalDriver) Sub(dir string) Driver {
+	cleaned, err := CleanPath(dir)
+	if err != nil {
+		cleaned = ""
+	}
+	sub := NewLocalDriver(filepath.Join(d.root, filepath.FromSlash(cleaned)))
+	sub.prefix = Join(d.prefix, cleaned)
+	return sub
+}
+
+func (d *LocalDriver) Open(name string) (File, error) {
+	p, err := d.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (d *LocalDriver) Stat(name string) (fs.FileInfo, error) {
+	p, err := d.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(p)
+}
+
+func (d *LocalDriver) List(dir string) ([]fs.FileInfo, error) {
+	p, err := d.resolve(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(p)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]fs.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// Put 先写入同目录下的临时文件再 rename，保证读者不会看到写了一半的文件
+func (d *LocalDriver) Put(name string, r io.Reader, size int64) (int64, error) {
+	p, err := d.resolve(name)
+	if err != nil {
+		return 0, err
+	}
+	dir := filepath.Dir(p)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(p)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	tmpPath := tmp.Name()
+
+	n, err := io.Copy(tmp, r)
+	if err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return n, err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return n, err
+	}
+	_ = os.Chmod(tmpPath, 0644)
+	if err := os.Rename(tmpPath, p); err != nil {
+		_ = os.Remove(tmpPath)
+		return n, err
+	}
+	emit(ChangeEvent{Op: OpPut, Path: d.eventPath(name), Size: n})
+	return n, nil
+}
+
+func (d *LocalDriver) Rename(oldName, newName string) error {
+	oldPath, err := d.resolve(oldName)
+	if err != nil {
+		return err
+	}
+	newPath, err := d.resolve(newName)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return err
+	}
+	emit(ChangeEvent{Op: OpRename, Path: d.eventPath(newName), OldPath: d.eventPath(oldName), Size: -1})
+	return nil
+}
+
+func (d *LocalDriver) Remove(name string) error {
+	p, err := d.resolve(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil {
+		return err
+	}
+	emit(ChangeEvent{Op: OpRemove, Path: d.eventPath(name), Size: -1})
+	return nil
+}
+
+func (d *LocalDriver) RemoveAll(name string) error {
+	cleaned, err := CleanPath(name)
+	if err != nil {
+		return err
+	}
+	if cleaned == "" {
+		// 禁止删除驱动根目录本身
+		return ErrInvalidPath
+	}
+	p, err := d.resolve(cleaned)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(p); err != nil {
+		return err
+	}
+	emit(ChangeEvent{Op: OpRemoveAll, Path: d.eventPath(cleaned), Size: -1})
+	return nil
+}
+
+func (d *LocalDriver) Mkdir(name string) error {
+	p, err := d.resolve(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(p, 0755); err != nil {
+		return err
+	}
+	emit(ChangeEvent{Op: OpMkdir, Path: d.eventPath(name), Size: -1})
+	return nil
+}
+
+// Copy 优先使用硬链接（同分区不占额外空间），失败时退化为拷贝
+// 所有写入都是"临时文件 + rename"，因此共享 inode 的硬链接不会被后续覆盖写影响。
+func (d *LocalDriver) Copy(src, dst string) error {
+	srcPath, err := d.resolve(src)
+	if err != nil {
+		return err
+	}
+	dstPath, err := d.resolve(dst)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return err
+	}
+
+	tmpPath := filepath.Join(filepath.Dir(dstPath), "."+filepath.Base(dstPath)+"."+randomSuffix()+".tmp")
+	if err := os.Link(srcPath, tmpPath); err == nil {
+		if err := os.Rename(tmpPath, dstPath); err != nil {
+			_ = os.Remove(tmpPath)
+			return err
+		}
+		size := int64(-1)
+		if info, err := os.Stat(dstPath); err == nil {
+			size = info.Size()
+		}
+		emit(ChangeEvent{Op: OpCopy, Path: d.eventPath(dst), OldPath: d.eventPath(src), Size: size})
+		return nil
+	}
+
+	// 退化为拷贝时由 Put 发布 OpPut 事件
+	in, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	_, err = d.Put(dst, in, -1)
+	return err
+}
+
+func randomSuffix() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"httpcat/internal/common"
+	"httpcat/internal/storage/auth"
+)
+
+// ========== v0.8.0 新增：预签名 URL ==========
+//
+// 预签名 URL 的查询参数即签名内容，token 为 auth.Credentials.Sign 对其余参数（url.Values.Encode，按键排序）
+// 的签名，密钥为 server.http.presign.secret。服务端不保存状态：用途、路径、签发用户、截止时间、
+// 绑定的客户端 IP 与字节范围都在签名之内，任何一项被改动签名即失效。
+
+// presignAccessKey 预签名 URL 的 token 前缀
+const presignAccessKey = "presign"
+
+// 预签名 URL 的用途，签名包含用途，下载链接与上传链接不能互换
+const (
+	PresignDownload = "download"
+	PresignUpload   = "upload"
+)
+
+var (
+	// ErrPresignInvalid 参数缺失或签名不匹配
+	ErrPresignInvalid = errors.New("invalid presigned url")
+	// ErrPresignExpired 已超过截止时间
+	ErrPresignExpired = errors.New("presigned url expired")
+)
+
+// Presigned 预签名 URL 的签名内容
+type Presigned struct {
+	Op      string // 用途，如 download
+	Path    string // 签发用户视图内的路径
+	User    string // 签发用户，使用时须仍然有效且能访问该路径
+	Expires int64  // 截止时间，unix 秒
+	IP      string // 绑定的客户端 IP，为空不限
+	Range   string // 允许下载的字节范围 "start-end" 或 "start-"，为空表示整个文件
+
+	// 以下仅用于上传链接
+	Nonce     string // 随机串，链接只能使用一次（见 t_presigned_upload）
+	MaxSize   int64  // 文件大小上限（字节），0 使用全局 upload_policy 配置
+	MimeLimit string // 允许的内容类型，同 UploadPolicy.MimeLimit
+	Overwrite bool   // 是否允许覆盖已存在的文件
+}
+
+func presignCredentials() *auth.Credentials {
+	return auth.New(presignAccessKey, common.PresignSecret)
+}
+
+func (p *Presigned) values() url.Values {
+	v := url.Values{}
+	v.Set("op", p.Op)
+	v.Set("path", p.Path)
+	v.Set("u", p.User)
+	v.Set("e", strconv.FormatInt(p.Expires, 10))
+	if p.IP != "" {
+		v.Set("ip", p.IP)
+	}
+	if p.Range != "" {
+		v.Set("range", p.Range)
+	}
+	if p.Nonce != "" {
+		v.Set("n", p.Nonce)
+	}
+	if p.MaxSize > 0 {
+		v.Set("max", strconv.FormatInt(p.MaxSize, 10))
+	}
+	if p.MimeLimit != "" {
+		v.Set("mime", p.MimeLimit)
+	}
+	if p.Overwrite {
+		v.Set("ow", "1")
+	}
+	return v
+}
+
+// Query 返回带签名的查询串
+func (p *Presigned) Query() string {
+	v := p.values()
+	v.Set("token", presignCredentials().Sign([]byte(v.Encode())))
+	return v.Encode()
+}
+
+// ParsePresigned 从查询参数还原并校验预签名内容（签名、用途与截止时间）；未签名的额外参数被忽略
+func ParsePresigned(query url.Values, op string) (*Presigned, error) {
+	expires, err := strconv.ParseInt(query.Get("e"), 10, 64)
+	if err != nil || query.Get("op") != op || query.Get("u") == "" {
+		return nil, ErrPresignInvalid
+	}
+	p := &Presigned{
+		Op:        op,
+		Path:      query.Get("path"),
+		User:      query.Get("u"),
+		Expires:   expires,
+		IP:        query.Get("ip"),
+		Range:     query.Get("range"),
+		Nonce:     query.Get("n"),
+		MimeLimit: query.Get("mime"),
+		Overwrite: query.Get("ow") == "1",
+	}
+	if max := query.Get("max"); max != "" {
+		if p.MaxSize, err = strconv.ParseInt(max, 10, 64); err != nil {
+			return nil, ErrPresignInvalid
+		}
+	}
+	if !presignCredentials().VerifySign([]byte(p.values().Encode()), query.Get("token")) {
+		return nil, ErrPresignInvalid
+	}
+	if time.Now().Unix() > p.Expires {
+		return nil, ErrPresignExpired
+	}
+	return p, nil
+}
+
+// UploadPolicy 上传链接对应的上传策略：固定保存路径，有效期、大小与内容类型限制同 UploadToken
+func (p *Presigned) UploadPolicy() *UploadPolicy {
+	policy := &UploadPolicy{
+		Deadline:   uint64(p.Expires),
+		FsizeMin:   common.UploadPolicyFSizeMin,
+		FsizeLimit: common.UploadPolicyFSizeLimit,
+		MimeLimit:  p.MimeLimit,
+		SaveKey:    p.Path,
+	}
+	if p.MaxSize > 0 {
+		policy.FsizeLimit = p.MaxSize
+	}
+	if !p.Overwrite {
+		policy.InsertOnly = 1
+	}
+	return policy
+}
+
+// ParseByteRange 解析 "start-end" / "start-" 形式的字节范围，end 为 -1 表示到文件末尾
+func ParseByteRange(s string) (int64, int64, error) {
+	first, last, ok := strings.Cut(strings.TrimSpace(s), "-")
+	if !ok {
+		return 0, 0, errors.New("invalid byte range")
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, errors.New("invalid byte range")
+	}
+	if last == "" {
+		return start, -1, nil
+	}
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil || end < start {
+		return 0, 0, errors.New("invalid byte range")
+	}
+	return start, end, nil
+}
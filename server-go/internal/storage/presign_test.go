@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"httpcat/internal/common"
+)
+
+func setPresignSecret(t *testing.T, secret string) {
+	old := common.PresignSecret
+	common.PresignSecret = secret
+	t.Cleanup(func() { common.PresignSecret = old })
+}
+
+func TestParsePresigned(t *testing.T) {
+	setPresignSecret(t, "test-presign-secret")
+	future := time.Now().Add(time.Hour).Unix()
+	download := &Presigned{Op: PresignDownload, Path: "docs/a.pdf", User: "bob", Expires: future, IP: "10.0.0.1", Range: "0-99"}
+	upload := &Presigned{Op: PresignUpload, Path: "in/b.bin", User: "bob", Expires: future, Nonce: "n1", MaxSize: 1024, MimeLimit: "image/*"}
+
+	tests := []struct {
+		name    string
+		query   func() url.Values
+		op      string
+		want    *Presigned
+		wantErr error
+	}{
+		{
+			name:  "download round trip",
+			query: func() url.Values { return mustParseQuery(t, download.Query()) },
+			op:    PresignDownload,
+			want:  download,
+		},
+		{
+			name:  "upload round trip",
+			query: func() url.Values { return mustParseQuery(t, upload.Query()) },
+			op:    PresignUpload,
+			want:  upload,
+		},
+		{
+			name: "unsigned extra parameter is ignored",
+			query: func() url.Values {
+				q := mustParseQuery(t, download.Query())
+				q.Set("response-content-type", "text/plain")
+				return q
+			},
+			op:   PresignDownload,
+			want: download,
+		},
+		{
+			name:    "download link used for upload",
+			query:   func() url.Values { return mustParseQuery(t, download.Query()) },
+			op:      PresignUpload,
+			wantErr: ErrPresignInvalid,
+		},
+		{
+			name:    "tampered path",
+			query:   func() url.Values { return withParam(t, download.Query(), "path", "docs/secret.pdf") },
+			op:      PresignDownload,
+			wantErr: ErrPresignInvalid,
+		},
+		{
+			name:    "tampered expiry",
+			query:   func() url.Values { return withParam(t, download.Query(), "e", "9999999999") },
+			op:      PresignDownload,
+			wantErr: ErrPresignInvalid,
+		},
+		{
+			name:    "ip binding removed",
+			query:   func() url.Values { return withParam(t, download.Query(), "ip", "") },
+			op:      PresignDownload,
+			wantErr: ErrPresignInvalid,
+		},
+		{
+			name:    "range widened",
+			query:   func() url.Values { return withParam(t, download.Query(), "range", "0-") },
+			op:      PresignDownload,
+			wantErr: ErrPresignInvalid,
+		},
+		{
+			name:    "size limit raised",
+			query:   func() url.Values { return withParam(t, upload.Query(), "max", "1048576") },
+			op:      PresignUpload,
+			wantErr: ErrPresignInvalid,
+		},
+		{
+			name:    "overwrite added",
+			query:   func() url.Values { return withParam(t, upload.Query(), "ow", "1") },
+			op:      PresignUpload,
+			wantErr: ErrPresignInvalid,
+		},
+		{
+			name:    "missing token",
+			query:   func() url.Values { return withParam(t, download.Query(), "token", "") },
+			op:      PresignDownload,
+			wantErr: ErrPresignInvalid,
+		},
+		{
+			name:    "missing user",
+			query:   func() url.Values { return withParam(t, download.Query(), "u", "") },
+			op:      PresignDownload,
+			wantErr: ErrPresignInvalid,
+		},
+		{
+			name: "expired",
+			query: func() url.Values {
+				expired := *download
+				expired.Expires = time.Now().Add(-time.Minute).Unix()
+				return mustParseQuery(t, expired.Query())
+			},
+			op:      PresignDownload,
+			wantErr: ErrPresignExpired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePresigned(tt.query(), tt.op)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParsePresigned() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePresigned() error = %v", err)
+			}
+			if *got != *tt.want {
+				t.Errorf("ParsePresigned() = %+v, want %+v", *got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePresignedSecretChange(t *testing.T) {
+	setPresignSecret(t, "old-secret")
+	p := &Presigned{Op: PresignDownload, Path: "a.txt", User: "bob", Expires: time.Now().Add(time.Hour).Unix()}
+	query := mustParseQuery(t, p.Query())
+
+	common.PresignSecret = "new-secret"
+	if _, err := ParsePresigned(query, PresignDownload); !errors.Is(err, ErrPresignInvalid) {
+		t.Errorf("link signed with a rotated secret: error = %v, want %v", err, ErrPresignInvalid)
+	}
+}
+
+func TestPresignedUploadPolicy(t *testing.T) {
+	p := &Presigned{Op: PresignUpload, Path: "in/b.bin", Expires: 1700000000, MaxSize: 1024, MimeLimit: "image/*"}
+	policy := p.UploadPolicy()
+	if policy.SaveKey != "in/b.bin" || policy.Deadline != 1700000000 || policy.FsizeLimit != 1024 ||
+		policy.MimeLimit != "image/*" || policy.InsertOnly != 1 {
+		t.Errorf("UploadPolicy() = %+v", *policy)
+	}
+	p.Overwrite = true
+	if policy := p.UploadPolicy(); policy.InsertOnly != 0 {
+		t.Errorf("UploadPolicy() with overwrite: InsertOnly = %d, want 0", policy.InsertOnly)
+	}
+}
+
+func TestParseByteRange(t *testing.T) {
+	tests := []struct {
+		in         string
+		start, end int64
+		wantErr    bool
+	}{
+		{"0-99", 0, 99, false},
+		{"100-", 100, -1, false},
+		{" 5-5 ", 5, 5, false},
+		{"10-9", 0, 0, true},
+		{"-100", 0, 0, true},
+		{"abc", 0, 0, true},
+		{"1-x", 0, 0, true},
+		{"", 0, 0, true},
+	}
+	for _, tt := range tests {
+		start, end, err := ParseByteRange(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseByteRange(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (start != tt.start || end != tt.end) {
+			t.Errorf("ParseByteRange(%q) = %d, %d, want %d, %d", tt.in, start, end, tt.start, tt.end)
+		}
+	}
+}
+
+func mustParseQuery(t *testing.T, raw string) url.Values {
+	t.Helper()
+	q, err := url.ParseQuery(raw)
+	if err != nil {
+		t.Fatalf("parse query %q: %v", raw, err)
+	}
+	return q
+}
+
+// withParam 修改签名后的查询参数，value 为空时删除该参数
+func withParam(t *testing.T, raw, key, value string) url.Values {
+	q := mustParseQuery(t, raw)
+	if value == "" {
+		q.Del(key)
+	} else {
+		q.Set(key, value)
+	}
+	return q
+}
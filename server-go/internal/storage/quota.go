@@ -0,0 +1,291 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"httpcat/internal/common"
+	"httpcat/internal/models"
+)
+
+// ========== v0.8.0 新增：存储配额 ==========
+//
+// 配额分两类（t_quota）：
+//   - user：按上传者（用户名或 appkey）限制其上传文件的总大小，未单独设置时使用 quota.default_user_limit；
+//   - dir：按目录（相对于上传目录，空串为整个上传目录）限制其下文件的总大小，多级目录的配额同时生效。
+// 用量来自文件索引（filemeta.go）的 SUM 聚合；历史版本与回收站中的文件不计入。
+// 校验在上传开始前按声明大小进行，覆盖已有文件时扣除被替换文件的大小。
+
+// ErrQuotaExceeded 超出配额
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// QuotaError 超出配额的详情，errors.Is(err, ErrQuotaExceeded) 为 true
+type QuotaError struct {
+	Scope    string
+	Target   string
+	Limit    int64
+	Used     int64
+	Incoming int64
+}
+
+func (e *QuotaError) Error() string {
+	target := e.Target
+	if e.Scope == models.QuotaScopeDir && target == "" {
+		target = "/"
+	}
+	return fmt.Sprintf("%s quota exceeded for %q: used %d + incoming %d > limit %d",
+		e.Scope, target, e.Used, e.Incoming, e.Limit)
+}
+
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// QuotaUsage 配额及其当前用量
+type QuotaUsage struct {
+	ID     uint   `json:"id,omitempty"`
+	Scope  string `json:"scope"`
+	Target string `json:"target"`
+	Used   int64  `json:"used"`
+	Limit  int64  `json:"limit"` // 0 表示不限
+}
+
+// QuotaEnabled 是否启用了存储配额（依赖 SQLite 与文件索引）
+func QuotaEnabled() bool {
+	return common.QuotaEnable && FileIndexEnabled()
+}
+
+// NormalizeQuotaTarget 校验并规范化配额目标：dir 为相对于上传目录的路径，user 去掉首尾空白
+func NormalizeQuotaTarget(scope, target string) (string, error) {
+	switch scope {
+	case models.QuotaScopeUser:
+		target = strings.TrimSpace(target)
+		if target == "" {
+			return "", errors.New("user quota target is required")
+		}
+		return target, nil
+	case models.QuotaScopeDir:
+		cleaned, err := CleanPath(strings.Trim(strings.TrimSpace(target), "/"))
+		if err != nil {
+			return "", err
+		}
+		return cleaned, nil
+	default:
+		return "", fmt.Errorf("unknown quota scope: %s", scope)
+	}
+}
+
+// UserUsage 上传者 owner 当前占用的字节数
+func UserUsage(owner string) int64 {
+	db, err := common.GetDB()
+	if err != nil {
+		return 0
+	}
+	return sumFileSize(db.Where("owner = ?", owner))
+}
+
+// DirUsage 上传目录中 dir 下文件当前占用的字节数
+func DirUsage(dir string) int64 {
+	db, err := common.GetDB()
+	if err != nil {
+		return 0
+	}
+	return sumFileSize(withinPrefix(db, UploadPath(dir)))
+}
+
+// IndexTotals 文件索引中（不含历史版本与回收站）的文件总数与总大小
+func IndexTotals() (count int64, size int64) {
+	db, err := common.GetDB()
+	if err != nil {
+		return 0, 0
+	}
+	var result struct {
+		Count int64
+		Size  int64
+	}
+	excludeReserved(db.Model(&models.FileMetaModel{})).
+		Select("COUNT(*) AS count, COALESCE(SUM(size), 0) AS size").Scan(&result)
+	return result.Count, result.Size
+}
+
+func sumFileSize(query *gorm.DB) int64 {
+	var total int64
+	excludeReserved(query.Model(&models.FileMetaModel{})).
+		Select("COALESCE(SUM(size), 0)").Scan(&total)
+	return total
+}
+
+// userLimit 返回 owner 的配额上限：单独设置的优先，否则为默认值
+func userLimit(db *gorm.DB, owner string) (uint, int64) {
+	var quota models.QuotaModel
+	if db.Where("scope = ? AND target = ?", models.QuotaScopeUser, owner).Limit(1).Find(&quota).RowsAffected > 0 {
+		return quota.ID, quota.LimitBytes
+	}
+	return 0, common.QuotaDefaultUserLimit
+}
+
+// dirQuotas 返回对上传目录中 name 生效的目录配额（name 所在的各级目录）
+func dirQuotas(db *gorm.DB, name string) ([]models.QuotaModel, error) {
+	var quotas []models.QuotaModel
+	if err := db.Where("scope = ?", models.QuotaScopeDir).Find(&quotas).Error; err != nil {
+		return nil, err
+	}
+	matched := quotas[:0]
+	for _, q := range quotas {
+		if q.Target == "" || name == q.Target || strings.HasPrefix(name, q.Target+"/") {
+			matched = append(matched, q)
+		}
+	}
+	return matched, nil
+}
+
+// CheckQuota 上传前校验：owner 向上传目录中的 name 写入 size 字节后是否超出配额。
+// 超出时返回 *QuotaError；未启用配额时始终返回 nil。
+func CheckQuota(name, owner string, size int64) error {
+	if !QuotaEnabled() {
+		return nil
+	}
+	cleaned, err := CleanPath(name)
+	if err != nil || cleaned == "" {
+		return ErrInvalidPath
+	}
+	return CheckQuotaKey(UploadPath(cleaned), owner, size)
+}
+
+// CheckQuotaKey 同 CheckQuota，key 为相对于存储根目录的路径（供以下载目录为根的 WebDAV、SFTP 使用）；
+// key 不在上传目录内时只校验用户配额
+func CheckQuotaKey(key, owner string, size int64) error {
+	if !QuotaEnabled() {
+		return nil
+	}
+	tightest, err := tightestQuota(key, owner)
+	if err != nil || tightest == nil {
+		return err
+	}
+	if tightest.Used+size > tightest.Limit {
+		tightest.Incoming = size
+		return tightest
+	}
+	return nil
+}
+
+// LimitQuota 用于写入前不知道大小的场景（如 WebDAV 分块传输）：返回的 Reader 读取的字节数超出 owner 向 key
+// （相对于存储根目录）写入的剩余配额时返回 *QuotaError，经由驱动的写入随之失败，不会留下文件；
+// 未启用配额或没有生效的配额时原样返回 r
+func LimitQuota(key, owner string, r io.Reader) (io.Reader, error) {
+	if !QuotaEnabled() {
+		return r, nil
+	}
+	tightest, err := tightestQuota(key, owner)
+	if err != nil || tightest == nil {
+		return r, err
+	}
+	return &quotaReader{r: r, quota: *tightest}, nil
+}
+
+type quotaReader struct {
+	r     io.Reader
+	quota QuotaError
+	read  int64
+}
+
+func (q *quotaReader) Read(p []byte) (int, error) {
+	n, err := q.r.Read(p)
+	q.read += int64(n)
+	if q.quota.Used+q.read > q.quota.Limit {
+		exceeded := q.quota
+		exceeded.Incoming = q.read
+		return n, &exceeded
+	}
+	return n, err
+}
+
+// tightestQuota 返回对 owner 向 key 写入生效的配额中剩余空间最少的一项（Incoming 未填），没有生效的配额时返回 nil。
+// 覆盖已有文件时，被替换的大小不重复计算
+func tightestQuota(key, owner string) (*QuotaError, error) {
+	db, err := common.GetDB()
+	if err != nil {
+		return nil, err
+	}
+	var existing models.FileMetaModel
+	replaced := db.Where("path = ?", key).Limit(1).Find(&existing).RowsAffected > 0
+
+	var tightest *QuotaError
+	consider := func(q *QuotaError) {
+		if tightest == nil || q.Limit-q.Used < tightest.Limit-tightest.Used {
+			tightest = q
+		}
+	}
+	if owner != "" {
+		if _, limit := userLimit(db, owner); limit > 0 {
+			used := UserUsage(owner)
+			if replaced && existing.Owner == owner {
+				used -= existing.Size
+			}
+			consider(&QuotaError{Scope: models.QuotaScopeUser, Target: owner, Limit: limit, Used: used})
+		}
+	}
+
+	name, ok := key, true
+	if root := UploadPath(""); root != "" {
+		name, ok = strings.CutPrefix(key, root+"/")
+	}
+	if !ok {
+		return tightest, nil
+	}
+	quotas, err := dirQuotas(db, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, q := range quotas {
+		if q.LimitBytes <= 0 {
+			continue
+		}
+		used := DirUsage(q.Target)
+		if replaced {
+			used -= existing.Size
+		}
+		consider(&QuotaError{Scope: models.QuotaScopeDir, Target: q.Target, Limit: q.LimitBytes, Used: used})
+	}
+	return tightest, nil
+}
+
+// GetUserQuotaUsage 返回 owner 的配额与用量
+func GetUserQuotaUsage(owner string) QuotaUsage {
+	usage := QuotaUsage{Scope: models.QuotaScopeUser, Target: owner, Used: UserUsage(owner)}
+	if db, err := common.GetDB(); err == nil {
+		usage.ID, usage.Limit = userLimit(db, owner)
+	}
+	return usage
+}
+
+// ListQuotaUsage 列出所有配额条目及其用量（scope 为空时不过滤）
+func ListQuotaUsage(scope string) ([]QuotaUsage, error) {
+	db, err := common.GetDB()
+	if err != nil {
+		return nil, err
+	}
+	query := db.Order("scope, target")
+	if scope != "" {
+		query = query.Where("scope = ?", scope)
+	}
+	var quotas []models.QuotaModel
+	if err := query.Find(&quotas).Error; err != nil {
+		return nil, err
+	}
+	usages := make([]QuotaUsage, 0, len(quotas))
+	for _, q := range quotas {
+		usage := QuotaUsage{ID: q.ID, Scope: q.Scope, Target: q.Target, Limit: q.LimitBytes}
+		if q.Scope == models.QuotaScopeDir {
+			usage.Used = DirUsage(q.Target)
+		} else {
+			usage.Used = UserUsage(q.Target)
+		}
+		usages = append(usages, usage)
+	}
+	return usages, nil
+}
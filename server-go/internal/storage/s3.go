@@ -0,0 +1,652 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"httpcat/internal/common/ylog"
+)
+
+// S3Config S3 兼容对象存储配置
+type S3Config struct {
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+	Bucket    string
+	Region    string
+	Prefix    string // 对象 key 前缀，作用等同本地驱动的根目录
+	UseSSL    bool
+}
+
+// S3Driver S3 兼容对象存储驱动（MinIO / AWS S3 等）
+//
+// 对象存储没有真正的目录，这里按以下约定模拟：
+//   - 以 "/" 分隔的 key 前缀视为目录，List 使用 delimiter 分页列出直接子对象与子前缀
+//   - Mkdir 写入一个以 "/" 结尾的空对象作为目录标记，保证空目录也能被列出
+//   - Rename/Copy 走服务端拷贝，目录重命名会逐个拷贝前缀下的所有对象后再删除原对象
+type S3Driver struct {
+	client *minio.Client
+	bucket string
+	prefix string
+	root   string // 配置的 storage.s3.prefix，变更事件路径相对于它
+}
+
+const (
+	// s3MinPartSize S3 分段上传除最后一段外的最小段大小
+	s3MinPartSize = 5 * 1024 * 1024
+	// s3MaxParts S3 单次分段上传的最大段数
+	s3MaxParts = 10000
+	// s3PutPartSize Put 时 minio-go 内部分段大小（16MB × 10000 段足以覆盖 100GB 单文件上限，
+	// 同时避免长度未知时按 5TB/10000 预分配超大缓冲区）
+	s3PutPartSize = 16 * 1024 * 1024
+	// s3MaxCopySize 单次 CopyObject 的上限，超过时改用分段拷贝
+	s3MaxCopySize = 5 * 1024 * 1024 * 1024
+	// s3MultipartExpire 未完成的分段上传超过该时长视为遗留（分片上传会话默认有效期 24h）
+	s3MultipartExpire = 25 * time.Hour
+)
+
+var (
+	errNotDir   = errors.New("not a directory")
+	errNotEmpty = errors.New("directory not empty")
+	errIsDir    = errors.New("is a directory")
+)
+
+// NewS3Driver 创建 S3 驱动，桶不存在时自动创建
+func NewS3Driver(cfg S3Config) (*S3Driver, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("s3 endpoint and bucket are required")
+	}
+	prefix, err := CleanPath(cfg.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 prefix %q", cfg.Prefix)
+	}
+
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("check bucket %s: %w", cfg.Bucket, err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{Region: cfg.Region}); err != nil {
+			return nil, fmt.Errorf("create bucket %s: %w", cfg.Bucket, err)
+		}
+		ylog.Infof("storage", "s3 bucket %s created", cfg.Bucket)
+	}
+
+	d := &S3Driver{client: client, bucket: cfg.Bucket, prefix: prefix, root: prefix}
+	go d.startMultipartCleanup()
+	return d, nil
+}
+
+func (d *S3Driver) Name() string { return "s3" }
+
+// Sub 以子前缀为根创建新的 S3 驱动（共享同一个 client）
+func (d *S3Driver) Sub(dir string) Driver {
+	cleaned, err := CleanPath(dir)
+	if err != nil {
+		cleaned = ""
+	}
+	return &S3Driver{client: d.client, bucket: d.bucket, prefix: Join(d.prefix, cleaned), root: d.root}
+}
+
+// eventPath 将对象 key 转换为变更事件路径（相对于 root）
+func (d *S3Driver) eventPath(key string) string {
+	if d.root == "" {
+		return key
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(key, d.root), "/")
+}
+
+// key 将相对路径转换为对象 key，根目录返回 d.prefix（可能为空）
+func (d *S3Driver) key(name string) (string, error) {
+	cleaned, err := CleanPath(name)
+	if err != nil {
+		return "", err
+	}
+	return Join(d.prefix, cleaned), nil
+}
+
+// dirPrefix 返回目录 key 对应的列举前缀
+func dirPrefix(key string) string {
+	if key == "" {
+		return ""
+	}
+	return key + "/"
+}
+
+func (d *S3Driver) core() minio.Core {
+	return minio.Core{Client: d.client}
+}
+
+func (d *S3Driver) Open(name string) (File, error) {
+	key, err := d.key(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := d.stat(name, key)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &s3DirFile{info: info}, nil
+	}
+	obj, err := d.client.GetObject(context.Background(), d.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return &s3File{Object: obj, info: info}, nil
+}
+
+func (d *S3Driver) Stat(name string) (fs.FileInfo, error) {
+	key, err := d.key(name)
+	if err != nil {
+		return nil, err
+	}
+	return d.stat(name, key)
+}
+
+func (d *S3Driver) stat(name, key string) (fs.FileInfo, error) {
+	if key == d.prefix {
+		return &s3FileInfo{name: path.Base("/" + key), dir: true}, nil
+	}
+	obj, err := d.client.StatObject(context.Background(), d.bucket, key, minio.StatObjectOptions{})
+	if err == nil {
+		return &s3FileInfo{name: path.Base(key), size: obj.Size, modTime: obj.LastModified}, nil
+	}
+	if !isS3NotFound(err) {
+		return nil, err
+	}
+	isDir, err := d.hasPrefix(dirPrefix(key))
+	if err != nil {
+		return nil, err
+	}
+	if !isDir {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return &s3FileInfo{name: path.Base(key), dir: true}, nil
+}
+
+// hasPrefix 判断是否存在以 prefix 开头的对象（即该"目录"是否存在）
+func (d *S3Driver) hasPrefix(prefix string) (bool, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for obj := range d.client.ListObjects(ctx, d.bucket, minio.ListObjectsOptions{Prefix: prefix, MaxKeys: 1}) {
+		if obj.Err != nil {
+			return false, obj.Err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// List 通过 ListObjectsV2 + delimiter 列出直接子项，minio-go 内部按 continuation token 自动翻页
+func (d *S3Driver) List(dir string) ([]fs.FileInfo, error) {
+	key, err := d.key(dir)
+	if err != nil {
+		return nil, err
+	}
+	prefix := dirPrefix(key)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	infos := make([]fs.FileInfo, 0)
+	found := key == d.prefix
+	for obj := range d.client.ListObjects(ctx, d.bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		found = true
+		if obj.Key == prefix {
+			// 目录标记本身
+			continue
+		}
+		rel := strings.TrimPrefix(obj.Key, prefix)
+		if strings.HasSuffix(rel, "/") {
+			infos = append(infos, &s3FileInfo{name: strings.TrimSuffix(rel, "/"), dir: true})
+			continue
+		}
+		infos = append(infos, &s3FileInfo{name: rel, size: obj.Size, modTime: obj.LastModified})
+	}
+	if !found {
+		if _, err := d.client.StatObject(context.Background(), d.bucket, key, minio.StatObjectOptions{}); err == nil {
+			return nil, &fs.PathError{Op: "readdir", Path: dir, Err: errNotDir}
+		}
+		return nil, &fs.PathError{Op: "readdir", Path: dir, Err: fs.ErrNotExist}
+	}
+	return infos, nil
+}
+
+func (d *S3Driver) Put(name string, r io.Reader, size int64) (int64, error) {
+	key, err := d.key(name)
+	if err != nil {
+		return 0, err
+	}
+	if key == d.prefix {
+		return 0, ErrInvalidPath
+	}
+	info, err := d.client.PutObject(context.Background(), d.bucket, key, r, size, minio.PutObjectOptions{
+		ContentType: contentTypeByName(key),
+		PartSize:    s3PutPartSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+	emit(ChangeEvent{Op: OpPut, Path: d.eventPath(key), Size: info.Size})
+	return info.Size, nil
+}
+
+func (d *S3Driver) Rename(oldName, newName string) error {
+	oldKey, err := d.key(oldName)
+	if err != nil {
+		return err
+	}
+	newKey, err := d.key(newName)
+	if err != nil {
+		return err
+	}
+	if oldKey == d.prefix || newKey == d.prefix {
+		return ErrInvalidPath
+	}
+	if oldKey == newKey {
+		return nil
+	}
+
+	ctx := context.Background()
+	obj, err := d.client.StatObject(ctx, d.bucket, oldKey, minio.StatObjectOptions{})
+	if err == nil {
+		if err := d.copyObject(oldKey, newKey, obj.Size); err != nil {
+			return err
+		}
+		if err := d.client.RemoveObject(ctx, d.bucket, oldKey, minio.RemoveObjectOptions{}); err != nil {
+			return err
+		}
+		emit(ChangeEvent{Op: OpRename, Path: d.eventPath(newKey), OldPath: d.eventPath(oldKey), Size: obj.Size})
+		return nil
+	}
+	if !isS3NotFound(err) {
+		return err
+	}
+
+	// 目录：禁止移动到自身子目录下
+	oldPrefix := oldKey + "/"
+	if strings.HasPrefix(newKey, oldPrefix) {
+		return &fs.PathError{Op: "rename", Path: oldName, Err: ErrInvalidPath}
+	}
+
+	listCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	moved := false
+	for obj := range d.client.ListObjects(listCtx, d.bucket, minio.ListObjectsOptions{Prefix: oldPrefix, Recursive: true}) {
+		if obj.Err != nil {
+			return obj.Err
+		}
+		dst := newKey + "/" + strings.TrimPrefix(obj.Key, oldPrefix)
+		if err := d.copyObject(obj.Key, dst, obj.Size); err != nil {
+			return err
+		}
+		moved = true
+	}
+	if !moved {
+		return &fs.PathError{Op: "rename", Path: oldName, Err: fs.ErrNotExist}
+	}
+	if err := d.removePrefix(oldPrefix); err != nil {
+		return err
+	}
+	emit(ChangeEvent{Op: OpRename, Path: d.eventPath(newKey), OldPath: d.eventPath(oldKey), Size: -1})
+	return nil
+}
+
+func (d *S3Driver) Remove(name string) error {
+	key, err := d.key(name)
+	if err != nil {
+		return err
+	}
+	if key == d.prefix {
+		return ErrInvalidPath
+	}
+
+	ctx := context.Background()
+	if _, err := d.client.StatObject(ctx, d.bucket, key, minio.StatObjectOptions{}); err == nil {
+		if err := d.client.RemoveObject(ctx, d.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+			return err
+		}
+		emit(ChangeEvent{Op: OpRemove, Path: d.eventPath(key), Size: -1})
+		return nil
+	} else if !isS3NotFound(err) {
+		return err
+	}
+
+	// 目录：与 os.Remove 一致，仅允许删除空目录（只剩目录标记）
+	prefix := key + "/"
+	listCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	found := false
+	for obj := range d.client.ListObjects(listCtx, d.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return obj.Err
+		}
+		if obj.Key != prefix {
+			return &fs.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+		}
+		found = true
+	}
+	if !found {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if err := d.client.RemoveObject(ctx, d.bucket, prefix, minio.RemoveObjectOptions{}); err != nil {
+		return err
+	}
+	emit(ChangeEvent{Op: OpRemove, Path: d.eventPath(key), Size: -1})
+	return nil
+}
+
+func (d *S3Driver) RemoveAll(name string) error {
+	key, err := d.key(name)
+	if err != nil {
+		return err
+	}
+	if key == d.prefix {
+		// 禁止删除驱动根目录本身
+		return ErrInvalidPath
+	}
+	if err := d.client.RemoveObject(context.Background(), d.bucket, key, minio.RemoveObjectOptions{}); err != nil && !isS3NotFound(err) {
+		return err
+	}
+	if err := d.removePrefix(key + "/"); err != nil {
+		return err
+	}
+	emit(ChangeEvent{Op: OpRemoveAll, Path: d.eventPath(key), Size: -1})
+	return nil
+}
+
+// removePrefix 批量删除 prefix 下的所有对象（含目录标记）
+func (d *S3Driver) removePrefix(prefix string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var listErr error
+	objects := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(objects)
+		for obj := range d.client.ListObjects(ctx, d.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+			if obj.Err != nil {
+				listErr = obj.Err
+				return
+			}
+			objects <- obj
+		}
+	}()
+
+	var removeErr error
+	for e := range d.client.RemoveObjects(ctx, d.bucket, objects, minio.RemoveObjectsOptions{}) {
+		if e.Err != nil && removeErr == nil {
+			removeErr = fmt.Errorf("remove %s: %w", e.ObjectName, e.Err)
+		}
+	}
+	if listErr != nil {
+		return listErr
+	}
+	return removeErr
+}
+
+func (d *S3Driver) Mkdir(name string) error {
+	key, err := d.key(name)
+	if err != nil {
+		return err
+	}
+	if key == d.prefix {
+		return nil
+	}
+	ctx := context.Background()
+	if _, err := d.client.StatObject(ctx, d.bucket, key, minio.StatObjectOptions{}); err == nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: errNotDir}
+	}
+	_, err = d.client.PutObject(ctx, d.bucket, key+"/", bytes.NewReader(nil), 0, minio.PutObjectOptions{
+		ContentType: "application/x-directory",
+	})
+	if err != nil {
+		return err
+	}
+	emit(ChangeEvent{Op: OpMkdir, Path: d.eventPath(key), Size: -1})
+	return nil
+}
+
+// Copy 服务端拷贝（不经过本机流量）
+func (d *S3Driver) Copy(src, dst string) error {
+	srcKey, err := d.key(src)
+	if err != nil {
+		return err
+	}
+	dstKey, err := d.key(dst)
+	if err != nil {
+		return err
+	}
+	if srcKey == d.prefix || dstKey == d.prefix {
+		return ErrInvalidPath
+	}
+	obj, err := d.client.StatObject(context.Background(), d.bucket, srcKey, minio.StatObjectOptions{})
+	if err != nil {
+		if isS3NotFound(err) {
+			return &fs.PathError{Op: "copy", Path: src, Err: fs.ErrNotExist}
+		}
+		return err
+	}
+	if err := d.copyObject(srcKey, dstKey, obj.Size); err != nil {
+		return err
+	}
+	emit(ChangeEvent{Op: OpCopy, Path: d.eventPath(dstKey), OldPath: d.eventPath(srcKey), Size: obj.Size})
+	return nil
+}
+
+func (d *S3Driver) copyObject(srcKey, dstKey string, size int64) error {
+	ctx := context.Background()
+	dst := minio.CopyDestOptions{Bucket: d.bucket, Object: dstKey}
+	src := minio.CopySrcOptions{Bucket: d.bucket, Object: srcKey}
+	var err error
+	if size > s3MaxCopySize {
+		_, err = d.client.ComposeObject(ctx, dst, src)
+	} else {
+		_, err = d.client.CopyObject(ctx, dst, src)
+	}
+	return err
+}
+
+// ---------- MultipartUploader ----------
+
+func (d *S3Driver) MinPartSize() int64 { return s3MinPartSize }
+
+func (d *S3Driver) MaxParts() int { return s3MaxParts }
+
+func (d *S3Driver) InitMultipart(name string) (string, error) {
+	key, err := d.key(name)
+	if err != nil {
+		return "", err
+	}
+	if key == d.prefix {
+		return "", ErrInvalidPath
+	}
+	return d.core().NewMultipartUpload(context.Background(), d.bucket, key, minio.PutObjectOptions{
+		ContentType: contentTypeByName(key),
+	})
+}
+
+func (d *S3Driver) PutPart(name, uploadID string, partNumber int, r io.Reader, size int64) error {
+	key, err := d.key(name)
+	if err != nil {
+		return err
+	}
+	_, err = d.core().PutObjectPart(context.Background(), d.bucket, key, uploadID, partNumber, r, size, minio.PutObjectPartOptions{})
+	return err
+}
+
+func (d *S3Driver) CompleteMultipart(name, uploadID string, totalParts int) error {
+	key, err := d.key(name)
+	if err != nil {
+		return err
+	}
+
+	// ETag 以服务端记录为准，无需在会话中另行保存
+	ctx := context.Background()
+	parts := make([]minio.CompletePart, 0, totalParts)
+	marker := 0
+	for {
+		result, err := d.core().ListObjectParts(ctx, d.bucket, key, uploadID, marker, 1000)
+		if err != nil {
+			return err
+		}
+		for _, p := range result.ObjectParts {
+			if p.PartNumber >= 1 && p.PartNumber <= totalParts {
+				parts = append(parts, minio.CompletePart{PartNumber: p.PartNumber, ETag: p.ETag})
+			}
+		}
+		if !result.IsTruncated {
+			break
+		}
+		marker = result.NextPartNumberMarker
+	}
+	if len(parts) != totalParts {
+		return fmt.Errorf("multipart upload incomplete: %d/%d parts", len(parts), totalParts)
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+
+	_, err = d.core().CompleteMultipartUpload(ctx, d.bucket, key, uploadID, parts, minio.PutObjectOptions{
+		ContentType: contentTypeByName(key),
+	})
+	if err != nil {
+		return err
+	}
+	emit(ChangeEvent{Op: OpPut, Path: d.eventPath(key), Size: -1})
+	return nil
+}
+
+func (d *S3Driver) AbortMultipart(name, uploadID string) error {
+	key, err := d.key(name)
+	if err != nil {
+		return err
+	}
+	err = d.core().AbortMultipartUpload(context.Background(), d.bucket, key, uploadID)
+	if err != nil && isS3NotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// startMultipartCleanup 每小时中止一次遗留的未完成分段上传（会话过期、进程中途退出等）
+func (d *S3Driver) startMultipartCleanup() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	// 启动时先执行一次
+	d.cleanupIncompleteUploads()
+
+	for range ticker.C {
+		d.cleanupIncompleteUploads()
+	}
+}
+
+func (d *S3Driver) cleanupIncompleteUploads() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cutoff := time.Now().Add(-s3MultipartExpire)
+	aborted := 0
+	for upload := range d.client.ListIncompleteUploads(ctx, d.bucket, dirPrefix(d.prefix), true) {
+		if upload.Err != nil {
+			ylog.Errorf("storage", "list incomplete uploads failed: %v", upload.Err)
+			return
+		}
+		if upload.Initiated.After(cutoff) {
+			continue
+		}
+		if err := d.core().AbortMultipartUpload(ctx, d.bucket, upload.Key, upload.UploadID); err != nil {
+			ylog.Errorf("storage", "abort incomplete upload %s failed: %v", upload.Key, err)
+			continue
+		}
+		aborted++
+	}
+	if aborted > 0 {
+		ylog.Infof("storage", "aborted %d stale multipart uploads", aborted)
+	}
+}
+
+// ---------- 辅助类型 ----------
+
+func isS3NotFound(err error) bool {
+	resp := minio.ToErrorResponse(err)
+	switch resp.Code {
+	case "NoSuchKey", "NoSuchUpload", "NotFound":
+		return true
+	}
+	return resp.StatusCode == http.StatusNotFound
+}
+
+func contentTypeByName(name string) string {
+	if t := mime.TypeByExtension(path.Ext(name)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// s3FileInfo 对象或前缀对应的 fs.FileInfo
+type s3FileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i *s3FileInfo) Name() string       { return i.name }
+func (i *s3FileInfo) Size() int64        { return i.size }
+func (i *s3FileInfo) ModTime() time.Time { return i.modTime }
+func (i *s3FileInfo) IsDir() bool        { return i.dir }
+func (i *s3FileInfo) Sys() interface{}   { return nil }
+func (i *s3FileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+// s3File 以流的方式读取对象，Seek 由 minio.Object 以 Range 请求实现
+type s3File struct {
+	*minio.Object
+	info fs.FileInfo
+}
+
+func (f *s3File) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+// s3DirFile 目录没有内容，仅支持 Stat
+type s3DirFile struct {
+	info fs.FileInfo
+}
+
+func (f *s3DirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: f.info.Name(), Err: errIsDir}
+}
+func (f *s3DirFile) Seek(int64, int) (int64, error) { return 0, nil }
+func (f *s3DirFile) Close() error                  { return nil }
+func (f *s3DirFile) Stat() (fs.FileInfo, error)    { return f.info, nil }
@@ -0,0 +1,445 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+)
+
+// ========== v0.8.0 新增：用户主目录与共享目录授权 ==========
+//
+// 开启 server.http.file.home_dirs 后，非管理员用户看到的是一个虚拟目录视图：
+//   - 视图根目录映射到 <root>/<用户名>（相对于文件管理目录，首次访问时自动创建）；
+//   - 根目录下的 shared/ 为保留的虚拟目录，列出经 t_path_acl 授权给该用户或其用户组的共享目录，
+//     read 授权只读，rw 授权可在其中写入、新建、重命名与删除（共享目录本身不可重命名或删除）。
+// 管理员、Open API（AK/SK）与仅凭 UploadToken 的请求不受限制，看到完整目录树。
+// 视图内路径经 Resolve 翻译为相对于文件管理目录的真实路径，版本、回收站、配额等接口均使用真实路径。
+// 限制了目录的个人 API Key 在所属用户视图的基础上只能访问该目录及其子路径（Within），管理员的 Key 亦然。
+
+// SharedDirName 视图根目录下列出共享目录的虚拟目录名
+const SharedDirName = "shared"
+
+// ErrAccessDenied 路径超出用户视图或授权不允许写入
+var ErrAccessDenied = fmt.Errorf("access denied: %w", fs.ErrPermission)
+
+// SharedMount 授权给用户的共享目录
+type SharedMount struct {
+	Name     string `json:"name"` // shared/ 下的目录名
+	Path     string `json:"path"` // 相对于文件管理目录的真实路径
+	Writable bool   `json:"writable"`
+}
+
+// UserScope 用户的目录视图，nil 表示不受限
+type UserScope struct {
+	User   string
+	Home   string // 主目录，相对于文件管理目录
+	Mounts []SharedMount
+
+	PathPrefix string // v0.8.0: 目录限制（视图内路径），为空不限制
+	FullTree   bool   // v0.8.0: 视图为完整目录树（不受主目录限制），仅受 PathPrefix 限制
+
+	denied bool // 用户名不能作为主目录名，拒绝一切访问
+}
+
+// HomeDirsEnabled 是否启用了用户主目录
+func HomeDirsEnabled() bool {
+	return common.HomeDirEnable
+}
+
+// HomeDir 返回用户主目录（相对于文件管理目录）；用户名不能作为目录名（如 ".."）时返回空串
+func HomeDir(username string) string {
+	if !common.ValidUsername(username) {
+		return ""
+	}
+	return Join(common.HomeDirRoot, username)
+}
+
+// ScopeFor 返回用户的目录视图；未启用主目录、管理员、Open API 或无登录身份时返回 nil
+func ScopeFor(username string) *UserScope {
+	if !common.HomeDirEnable || username == "" || strings.HasPrefix(username, "openapi:") {
+		return nil
+	}
+	user := common.GetUser(username)
+	if user == nil || user.Role == common.RoleAdmin {
+		return nil
+	}
+	// 早期版本未校验用户名，"."、".." 之类的名字会让主目录逃出 home 根目录
+	if !common.ValidUsername(username) {
+		ylog.Warnf("scope", "user %q cannot have a home directory, access denied", username)
+		return &UserScope{User: username, denied: true}
+	}
+	return &UserScope{
+		User:   username,
+		Home:   HomeDir(username),
+		Mounts: loadSharedMounts(user),
+	}
+}
+
+// Within 返回在 s 的基础上限制到视图内目录 prefix（已规范化）的视图；s 为 nil 时基于完整目录树
+func (s *UserScope) Within(username, prefix string) *UserScope {
+	if prefix == "" {
+		return s
+	}
+	if s == nil {
+		return &UserScope{User: username, PathPrefix: prefix, FullTree: true}
+	}
+	scoped := *s
+	scoped.PathPrefix = prefix
+	return &scoped
+}
+
+// PrefixRoot 返回目录限制对应的真实路径
+func (s *UserScope) PrefixRoot() (string, error) {
+	return s.Resolve(s.PathPrefix, false)
+}
+
+// ContainsReal 判断真实路径是否落在目录限制之内，未设置目录限制时返回 true
+func (s *UserScope) ContainsReal(realPath string) bool {
+	if s == nil || s.PathPrefix == "" {
+		return true
+	}
+	root, err := s.PrefixRoot()
+	if err != nil {
+		return false
+	}
+	return root == "" || realPath == root || strings.HasPrefix(realPath, root+"/")
+}
+
+// CanReadKey 判断存储路径（相对于存储根目录）能否经由视图读取：须位于文件管理目录内（历史版本、回收站等保留目录除外），
+// 且在主目录、共享目录与目录限制之内；s 为 nil 时不受限
+func (s *UserScope) CanReadKey(key string) bool {
+	if s == nil {
+		return true
+	}
+	if s.denied || isReservedPath(key) {
+		return false
+	}
+	root := DownloadPath("")
+	realPath := key
+	if root != "" {
+		var ok bool
+		if realPath, ok = strings.CutPrefix(key, root+"/"); !ok {
+			return false
+		}
+	}
+	if !s.ContainsReal(realPath) {
+		return false
+	}
+	if s.FullTree {
+		return true
+	}
+	under := func(dir string) bool {
+		return realPath == dir || strings.HasPrefix(realPath, dir+"/")
+	}
+	if under(s.Home) {
+		return true
+	}
+	for _, m := range s.Mounts {
+		if under(m.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadSharedMounts 查询授权给用户及其用户组的共享目录；同一目录取最高权限，目录名重复时追加序号
+func loadSharedMounts(user *common.User) []SharedMount {
+	if !common.EnableSqlite {
+		return nil
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		return nil
+	}
+	query := db.Where("grantee_type = ? AND grantee = ?", models.ACLGranteeUser, user.Username)
+	if user.Group != "" {
+		query = query.Or("grantee_type = ? AND grantee = ?", models.ACLGranteeGroup, user.Group)
+	}
+	var grants []models.PathACLModel
+	if err := query.Order("path, id").Find(&grants).Error; err != nil {
+		ylog.Errorf("scope", "load shared folders of %s failed: %v", user.Username, err)
+		return nil
+	}
+
+	var mounts []SharedMount
+	byPath := make(map[string]int)
+	names := make(map[string]bool)
+	for _, g := range grants {
+		writable := g.Permission == models.ACLPermReadWrite
+		if i, ok := byPath[g.Path]; ok {
+			mounts[i].Writable = mounts[i].Writable || writable
+			continue
+		}
+		name := path.Base(g.Path)
+		for n := 2; names[name]; n++ {
+			name = fmt.Sprintf("%s (%d)", path.Base(g.Path), n)
+		}
+		names[name] = true
+		byPath[g.Path] = len(mounts)
+		mounts = append(mounts, SharedMount{Name: name, Path: g.Path, Writable: writable})
+	}
+	return mounts
+}
+
+// NormalizeACLPath 校验并规范化共享目录路径（不能为文件管理目录本身）
+func NormalizeACLPath(p string) (string, error) {
+	cleaned, err := CleanPath(strings.Trim(strings.TrimSpace(p), "/"))
+	if err != nil {
+		return "", err
+	}
+	if cleaned == "" {
+		return "", errors.New("cannot share the root directory")
+	}
+	return cleaned, nil
+}
+
+// 路径访问方式
+const (
+	accessRead      = iota // 浏览、读取
+	accessWrite            // 修改路径本身（写入、重命名、删除）
+	accessWriteInto        // 在目录下新建文件或子目录
+)
+
+// Resolve 把视图内路径翻译为相对于文件管理目录的真实路径；write 为 true 时校验写权限
+func (s *UserScope) Resolve(name string, write bool) (string, error) {
+	if write {
+		return s.resolve(name, accessWrite)
+	}
+	return s.resolve(name, accessRead)
+}
+
+// ResolveDir 翻译上传 / 新建的目标目录，校验能否在其中写入
+func (s *UserScope) ResolveDir(dir string) (string, error) {
+	return s.resolve(dir, accessWriteInto)
+}
+
+func (s *UserScope) resolve(name string, access int) (string, error) {
+	cleaned, err := CleanPath(name)
+	if err != nil {
+		return "", err
+	}
+	if s == nil {
+		return cleaned, nil
+	}
+	if s.denied {
+		return "", ErrAccessDenied
+	}
+	if s.PathPrefix != "" {
+		if cleaned != s.PathPrefix && !strings.HasPrefix(cleaned, s.PathPrefix+"/") {
+			return "", ErrAccessDenied
+		}
+		// 限制目录本身不能改名或删除
+		if cleaned == s.PathPrefix && access == accessWrite {
+			return "", ErrAccessDenied
+		}
+	}
+	if s.FullTree {
+		return cleaned, nil
+	}
+	if cleaned == "" {
+		if access == accessWrite {
+			return "", ErrAccessDenied
+		}
+		return s.Home, nil
+	}
+
+	first, rest, _ := strings.Cut(cleaned, "/")
+	if first != SharedDirName {
+		return Join(s.Home, cleaned), nil
+	}
+	if rest == "" {
+		// shared/ 本身只能列出，不能读写
+		return "", ErrAccessDenied
+	}
+	mountName, sub, _ := strings.Cut(rest, "/")
+	m := s.mount(mountName)
+	if m == nil {
+		return "", &fs.PathError{Op: "resolve", Path: cleaned, Err: fs.ErrNotExist}
+	}
+	if access != accessRead && (!m.Writable || (sub == "" && access == accessWrite)) {
+		return "", ErrAccessDenied
+	}
+	return Join(m.Path, sub), nil
+}
+
+func (s *UserScope) mount(name string) *SharedMount {
+	for i := range s.Mounts {
+		if s.Mounts[i].Name == name {
+			return &s.Mounts[i]
+		}
+	}
+	return nil
+}
+
+// FS 返回 fsys（以文件管理目录为根）上的用户视图，s 为 nil 时原样返回
+func (s *UserScope) FS(fsys Driver) Driver {
+	if s == nil {
+		return fsys
+	}
+	return &scopedDriver{inner: fsys, scope: s}
+}
+
+// scopedDriver 按 UserScope 翻译路径并校验授权的驱动视图
+type scopedDriver struct {
+	inner Driver
+	scope *UserScope
+}
+
+// isSharedRoot 判断视图内路径是否为虚拟的 shared/ 目录
+func (d *scopedDriver) isSharedRoot(name string) bool {
+	if d.scope.FullTree {
+		return false
+	}
+	cleaned, err := CleanPath(name)
+	return err == nil && cleaned == SharedDirName
+}
+
+func (d *scopedDriver) Name() string { return d.inner.Name() }
+
+func (d *scopedDriver) Open(name string) (File, error) {
+	p, err := d.scope.Resolve(name, false)
+	if err != nil {
+		return nil, err
+	}
+	return d.inner.Open(p)
+}
+
+func (d *scopedDriver) Stat(name string) (fs.FileInfo, error) {
+	if d.isSharedRoot(name) {
+		return d.sharedRootInfo(), nil
+	}
+	p, err := d.scope.Resolve(name, false)
+	if err != nil {
+		return nil, err
+	}
+	if !d.scope.FullTree && p == d.scope.Home {
+		if err := d.inner.Mkdir(p); err != nil {
+			return nil, err
+		}
+	}
+	return d.inner.Stat(p)
+}
+
+func (d *scopedDriver) List(dir string) ([]fs.FileInfo, error) {
+	if d.isSharedRoot(dir) {
+		infos := make([]fs.FileInfo, 0, len(d.scope.Mounts))
+		for _, m := range d.scope.Mounts {
+			info, err := d.inner.Stat(m.Path)
+			if err != nil || !info.IsDir() {
+				continue
+			}
+			infos = append(infos, &renamedInfo{FileInfo: info, name: m.Name})
+		}
+		return infos, nil
+	}
+	p, err := d.scope.Resolve(dir, false)
+	if err != nil {
+		return nil, err
+	}
+	if d.scope.FullTree || p != d.scope.Home {
+		return d.inner.List(p)
+	}
+
+	if err := d.inner.Mkdir(p); err != nil {
+		return nil, err
+	}
+	entries, err := d.inner.List(p)
+	if err != nil {
+		return nil, err
+	}
+	// 主目录下同名的 shared 被虚拟目录遮挡
+	infos := entries[:0]
+	for _, e := range entries {
+		if e.Name() != SharedDirName {
+			infos = append(infos, e)
+		}
+	}
+	if len(d.scope.Mounts) > 0 {
+		infos = append(infos, d.sharedRootInfo())
+	}
+	return infos, nil
+}
+
+func (d *scopedDriver) Put(name string, r io.Reader, size int64) (int64, error) {
+	p, err := d.scope.Resolve(name, true)
+	if err != nil {
+		return 0, err
+	}
+	return d.inner.Put(p, r, size)
+}
+
+func (d *scopedDriver) Rename(oldName, newName string) error {
+	oldPath, err := d.scope.Resolve(oldName, true)
+	if err != nil {
+		return err
+	}
+	newPath, err := d.scope.Resolve(newName, true)
+	if err != nil {
+		return err
+	}
+	return d.inner.Rename(oldPath, newPath)
+}
+
+func (d *scopedDriver) Remove(name string) error {
+	p, err := d.scope.Resolve(name, true)
+	if err != nil {
+		return err
+	}
+	return d.inner.Remove(p)
+}
+
+func (d *scopedDriver) RemoveAll(name string) error {
+	p, err := d.scope.Resolve(name, true)
+	if err != nil {
+		return err
+	}
+	return d.inner.RemoveAll(p)
+}
+
+func (d *scopedDriver) Mkdir(name string) error {
+	p, err := d.scope.ResolveDir(name)
+	if err != nil {
+		return err
+	}
+	return d.inner.Mkdir(p)
+}
+
+// sharedRootInfo 虚拟 shared/ 目录的信息，修改时间取各共享目录中最新的
+func (d *scopedDriver) sharedRootInfo() fs.FileInfo {
+	info := &virtualDirInfo{name: SharedDirName}
+	for _, m := range d.scope.Mounts {
+		if mi, err := d.inner.Stat(m.Path); err == nil && mi.ModTime().After(info.modTime) {
+			info.modTime = mi.ModTime()
+		}
+	}
+	return info
+}
+
+// renamedInfo 以挂载名覆盖共享目录的名称
+type renamedInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (i *renamedInfo) Name() string { return i.name }
+
+// virtualDirInfo 虚拟目录
+type virtualDirInfo struct {
+	name    string
+	modTime time.Time
+}
+
+func (i *virtualDirInfo) Name() string       { return i.name }
+func (i *virtualDirInfo) Size() int64        { return 0 }
+func (i *virtualDirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (i *virtualDirInfo) ModTime() time.Time { return i.modTime }
+func (i *virtualDirInfo) IsDir() bool        { return true }
+func (i *virtualDirInfo) Sys() interface{}   { return nil }
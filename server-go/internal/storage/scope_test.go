@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"httpcat/internal/common"
+)
+
+func TestUserScopeResolve(t *testing.T) {
+	home := &UserScope{
+		User: "bob",
+		Home: "home/bob",
+		Mounts: []SharedMount{
+			{Name: "team", Path: "projects/team", Writable: true},
+			{Name: "docs", Path: "docs", Writable: false},
+		},
+	}
+	limited := home.Within("bob", "work")
+	fullTree := (*UserScope)(nil).Within("admin", "exports")
+
+	tests := []struct {
+		name    string
+		scope   *UserScope
+		path    string
+		access  int
+		want    string
+		wantErr error
+	}{
+		{"unrestricted", nil, "a/b.txt", accessWrite, "a/b.txt", nil},
+		{"unrestricted rejects parent", nil, "../etc/passwd", accessRead, "", ErrInvalidPath},
+
+		{"view root is home", home, "", accessRead, "home/bob", nil},
+		{"slash is home", home, "/", accessRead, "home/bob", nil},
+		{"home itself cannot be replaced", home, "", accessWrite, "", ErrAccessDenied},
+		{"upload into home", home, "", accessWriteInto, "home/bob", nil},
+		{"file in home", home, "notes/a.txt", accessWrite, "home/bob/notes/a.txt", nil},
+		{"dot segments stay in home", home, "notes/../a.txt", accessRead, "home/bob/a.txt", nil},
+		{"parent escape", home, "../alice/a.txt", accessRead, "", ErrInvalidPath},
+		{"nested parent escape", home, "a/../../alice", accessRead, "", ErrInvalidPath},
+		{"absolute path", home, "/etc/passwd", accessRead, "", ErrInvalidPath},
+		{"backslash", home, `a\..\..\b`, accessRead, "", ErrInvalidPath},
+
+		{"shared root is listing only", home, "shared", accessRead, "", ErrAccessDenied},
+		{"read-write mount", home, "shared/team/plan.md", accessWrite, "projects/team/plan.md", nil},
+		{"upload into read-write mount", home, "shared/team", accessWriteInto, "projects/team", nil},
+		{"mount itself cannot be renamed", home, "shared/team", accessWrite, "", ErrAccessDenied},
+		{"read-only mount read", home, "shared/docs/a.pdf", accessRead, "docs/a.pdf", nil},
+		{"read-only mount write", home, "shared/docs/a.pdf", accessWrite, "", ErrAccessDenied},
+		{"read-only mount upload", home, "shared/docs", accessWriteInto, "", ErrAccessDenied},
+		{"unknown mount", home, "shared/other/a", accessRead, "", fs.ErrNotExist},
+		{"dot segments leave mount for home", home, "shared/team/../../x", accessRead, "home/bob/x", nil},
+
+		{"directory limit", limited, "work/a.txt", accessWrite, "home/bob/work/a.txt", nil},
+		{"directory limit root readable", limited, "work", accessRead, "home/bob/work", nil},
+		{"directory limit root cannot be renamed", limited, "work", accessWrite, "", ErrAccessDenied},
+		{"outside directory limit", limited, "other/a.txt", accessRead, "", ErrAccessDenied},
+		{"prefix lookalike", limited, "workshop/a.txt", accessRead, "", ErrAccessDenied},
+		{"mount outside directory limit", limited, "shared/team/a", accessRead, "", ErrAccessDenied},
+
+		{"full tree limit", fullTree, "exports/2024/a.csv", accessWrite, "exports/2024/a.csv", nil},
+		{"full tree outside limit", fullTree, "home/bob/a", accessRead, "", ErrAccessDenied},
+		{"full tree has no shared view", fullTree, "shared", accessRead, "", ErrAccessDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.scope.resolve(tt.path, tt.access)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("resolve(%q) = %q, %v, want error %v", tt.path, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("resolve(%q) = %q, %v, want %q", tt.path, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopeForUnsafeUsername(t *testing.T) {
+	oldEnable, oldRoot, oldTable := common.HomeDirEnable, common.HomeDirRoot, common.UserTable
+	t.Cleanup(func() {
+		common.HomeDirEnable, common.HomeDirRoot, common.UserTable = oldEnable, oldRoot, oldTable
+	})
+	common.HomeDirEnable = true
+	common.HomeDirRoot = "home"
+	common.UserTable = map[string]*common.User{
+		"bob":   {Username: "bob", Role: common.RoleUploader},
+		"..":    {Username: "..", Role: common.RoleUploader},
+		".":     {Username: ".", Role: common.RoleReadOnly},
+		"admin": {Username: "admin", Role: common.RoleAdmin},
+	}
+
+	if s := ScopeFor("bob"); s == nil || s.Home != "home/bob" {
+		t.Fatalf("ScopeFor(bob) = %+v, want home/bob", s)
+	}
+	if s := ScopeFor("admin"); s != nil {
+		t.Fatalf("ScopeFor(admin) = %+v, want unrestricted", s)
+	}
+	for _, name := range []string{"..", "."} {
+		if home := HomeDir(name); home != "" {
+			t.Errorf("HomeDir(%q) = %q, want empty", name, home)
+		}
+		s := ScopeFor(name)
+		if s == nil {
+			t.Fatalf("ScopeFor(%q) = nil, want a scope that denies access", name)
+		}
+		for _, p := range []string{"", "a.txt", "shared", "shared/x/y", "../bob"} {
+			if got, err := s.Resolve(p, false); err == nil {
+				t.Errorf("ScopeFor(%q).Resolve(%q) = %q, want an error", name, p, got)
+			}
+		}
+		if _, err := s.ResolveDir(""); !errors.Is(err, ErrAccessDenied) {
+			t.Errorf("ScopeFor(%q).ResolveDir(\"\") error = %v, want %v", name, err, ErrAccessDenied)
+		}
+		if s.Within(name, "sub").CanReadKey("home/x") {
+			t.Errorf("ScopeFor(%q) with a directory limit can read keys", name)
+		}
+		if s.CanReadKey("home/bob/a.txt") {
+			t.Errorf("ScopeFor(%q) can read keys", name)
+		}
+	}
+}
@@ -1,10 +1,31 @@
 package storage
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"httpcat/internal/common"
 	"httpcat/internal/storage/auth"
 )
 
+// defaultUploadTokenTTL 未指定有效期且未配置 upload_policy.deadline 时，上传凭证的有效期（秒）
+const defaultUploadTokenTTL = 3600
+
+// v0.8.0：上传凭证中签名的上传策略即为上传的约束条件，违反时拒绝上传
+var (
+	// ErrUploadTokenExpired 上传凭证已过期或未设置截止时间
+	ErrUploadTokenExpired = errors.New("UploadToken is expired")
+	// ErrUploadPolicyDenied 违反上传策略（目标位置、文件类型、大小下限、禁止覆盖）
+	ErrUploadPolicyDenied = errors.New("upload denied by upload policy")
+	// ErrUploadTooLarge 文件大小超出上传策略的上限
+	ErrUploadTooLarge = errors.New("file size exceeds upload policy limit")
+)
+
 type UploadPolicy struct {
 
 	// 上传凭证有效截止时间。Unix时间戳，单位为秒。该截止时间为上传完成后，在httpcat生成文件的校验时间，而非上传的开始时间，
@@ -21,6 +42,22 @@ type UploadPolicy struct {
 	// 理状态查询的处理结果一致。发送 body 格式是 Content-Type 为 application/json 的 POST 请求，需要按照读取流的形式读取请求的 body
 	// 才能获取。
 	PersistentNotifyURL string `json:"persistentNotifyUrl,omitempty"`
+
+	// v0.8.0 新增：限定上传的目标位置（上传目录下的路径）。以 / 结尾表示固定目录，如 "builds/"；否则最后一段为文件名前缀，
+	// 如 "builds/app-" 只允许上传到 builds 目录且文件名以 app- 开头；"/" 表示上传目录根。为空表示不限制。
+	Scope string `json:"scope,omitempty"`
+
+	// v0.8.0 新增：限定文件的 MIME 类型（按文件内容识别），多个用 ; 分隔，支持 image/* 通配；以 ! 开头表示禁止列出的类型。
+	MimeLimit string `json:"mimeLimit,omitempty"`
+
+	// v0.8.0 新增：限定文件扩展名，如 ".zip;.tar.gz"；以 ! 开头表示禁止列出的扩展名。
+	ExtLimit string `json:"extLimit,omitempty"`
+
+	// v0.8.0 新增：为 1 时只允许新增文件，目标文件已存在则拒绝上传（不覆盖）。
+	InsertOnly int `json:"insertOnly,omitempty"`
+
+	// v0.8.0 新增：固定的保存路径（上传目录下的路径，含文件名），设置后忽略请求中的目录与文件名。预签名上传链接使用。
+	SaveKey string `json:"saveKey,omitempty"`
 }
 
 // UploadToken 方法用来进行上传凭证的生成
@@ -30,12 +67,171 @@ func (p *UploadPolicy) UploadToken(cred *auth.Credentials) string {
 }
 
 func (p UploadPolicy) uploadToken(cred *auth.Credentials) (token string) {
-	// 暂时注释上传策略使用全局暂时不和用户关联，这样生成的上传token一样
-	//if p.Deadline == 0 {
-	//	p.Deadline = 7200 // 默认一小时过期
-	//}
-	//p.Deadline += uint64(time.Now().Unix())
+	// v0.8.0：Deadline 传入的是有效期（秒），未指定时使用 upload_policy.deadline 配置；大小限制未指定时使用全局配置
+	if p.Deadline == 0 {
+		p.Deadline = defaultUploadTokenTTL
+		if common.UploadPolicyDeadline > 0 {
+			p.Deadline = uint64(common.UploadPolicyDeadline)
+		}
+	}
+	p.Deadline += uint64(time.Now().Unix())
+	if p.FsizeMin == 0 {
+		p.FsizeMin = common.UploadPolicyFSizeMin
+	}
+	if p.FsizeLimit == 0 {
+		p.FsizeLimit = common.UploadPolicyFSizeLimit
+	}
 	putPolicyJSON, _ := json.Marshal(p)
 	token = cred.SignWithData(putPolicyJSON)
 	return
 }
+
+// ParseUploadPolicy 解析上传凭证中的上传策略，不校验签名（签名由 auth.Credentials.VerifyUploadToken 校验）
+func ParseUploadPolicy(token string) (*UploadPolicy, error) {
+	parts := strings.Split(token, ":")
+	if len(parts) != 3 {
+		return nil, errors.New("invalid UploadToken format")
+	}
+	data, err := base64.URLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("decode upload policy: %w", err)
+	}
+	var p UploadPolicy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parse upload policy: %w", err)
+	}
+	return &p, nil
+}
+
+// NormalizeUploadScope 校验并规范化 Scope：目录部分为合法的相对路径，文件名前缀不含路径分隔符
+func NormalizeUploadScope(scope string) (string, error) {
+	scope = strings.TrimPrefix(strings.TrimSpace(scope), "/")
+	if scope == "" {
+		return "", nil
+	}
+	dir, prefix := path.Split(scope)
+	dir, err := CleanPath(strings.TrimSuffix(dir, "/"))
+	if err != nil || strings.ContainsAny(prefix, "\\\x00") || prefix == "." || prefix == ".." {
+		return "", ErrInvalidPath
+	}
+	if dir == "" {
+		return "/" + prefix, nil
+	}
+	return dir + "/" + prefix, nil
+}
+
+// 以下校验方法在 p 为 nil 时（未启用 UploadToken）不做限制
+
+// CheckDeadline 校验上传凭证是否在有效期内；升级前签发的凭证没有截止时间，同样视为过期
+func (p *UploadPolicy) CheckDeadline() error {
+	if p == nil {
+		return nil
+	}
+	if p.Deadline == 0 || uint64(time.Now().Unix()) > p.Deadline {
+		return ErrUploadTokenExpired
+	}
+	return nil
+}
+
+// Target 设置了 SaveKey 时返回其目录与文件名，否则原样返回请求中的 dir 与 name
+func (p *UploadPolicy) Target(dir, name string) (string, string) {
+	if p == nil || p.SaveKey == "" {
+		return dir, name
+	}
+	saveDir, saveName := path.Split(p.SaveKey)
+	return strings.TrimSuffix(saveDir, "/"), saveName
+}
+
+// CheckTarget 校验目标位置与扩展名，dir 为上传目录下的目录（CleanPath 之后），name 为文件名
+func (p *UploadPolicy) CheckTarget(dir, name string) error {
+	if p == nil {
+		return nil
+	}
+	if p.SaveKey != "" && path.Join(dir, name) != p.SaveKey {
+		return fmt.Errorf("%w: target must be %q", ErrUploadPolicyDenied, p.SaveKey)
+	}
+	if p.Scope != "" {
+		scopeDir, prefix := path.Split(strings.TrimPrefix(p.Scope, "/"))
+		scopeDir = strings.TrimSuffix(scopeDir, "/")
+		if dir != scopeDir || !strings.HasPrefix(name, prefix) {
+			return fmt.Errorf("%w: target must match scope %q", ErrUploadPolicyDenied, p.Scope)
+		}
+	}
+	if p.ExtLimit != "" {
+		lower := strings.ToLower(name)
+		allowed := matchLimit(p.ExtLimit, func(ext string) bool {
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			return strings.HasSuffix(lower, ext)
+		})
+		if !allowed {
+			return fmt.Errorf("%w: file extension is not allowed (%s)", ErrUploadPolicyDenied, p.ExtLimit)
+		}
+	}
+	return nil
+}
+
+// CheckSize 校验文件大小
+func (p *UploadPolicy) CheckSize(size int64) error {
+	if p == nil {
+		return nil
+	}
+	if p.FsizeLimit > 0 && size > p.FsizeLimit {
+		return fmt.Errorf("%w: %d > %d bytes", ErrUploadTooLarge, size, p.FsizeLimit)
+	}
+	if p.FsizeMin > 0 && size < p.FsizeMin {
+		return fmt.Errorf("%w: file size is smaller than %d bytes", ErrUploadPolicyDenied, p.FsizeMin)
+	}
+	return nil
+}
+
+// NeedMime 是否需要按文件内容识别 MIME 类型
+func (p *UploadPolicy) NeedMime() bool {
+	return p != nil && p.MimeLimit != ""
+}
+
+// CheckMime 校验按文件内容识别出的 MIME 类型（可带参数，如 text/plain; charset=utf-8）
+func (p *UploadPolicy) CheckMime(mime string) error {
+	if !p.NeedMime() {
+		return nil
+	}
+	mime = strings.ToLower(strings.TrimSpace(strings.SplitN(mime, ";", 2)[0]))
+	if mime == "" {
+		mime = "application/octet-stream"
+	}
+	allowed := matchLimit(p.MimeLimit, func(pattern string) bool {
+		if pattern == "*" || pattern == "*/*" {
+			return true
+		}
+		if strings.HasSuffix(pattern, "/*") {
+			return strings.HasPrefix(mime, strings.TrimSuffix(pattern, "*"))
+		}
+		return mime == pattern
+	})
+	if !allowed {
+		return fmt.Errorf("%w: content type %s is not allowed (%s)", ErrUploadPolicyDenied, mime, p.MimeLimit)
+	}
+	return nil
+}
+
+// CheckOverwrite insertOnly 策略下目标文件已存在时拒绝
+func (p *UploadPolicy) CheckOverwrite(exists bool) error {
+	if p == nil || p.InsertOnly == 0 || !exists {
+		return nil
+	}
+	return fmt.Errorf("%w: target file already exists (insertOnly)", ErrUploadPolicyDenied)
+}
+
+// matchLimit 判断是否满足 "a;b;c" 形式的限制：任一项匹配即允许；以 ! 开头时任一项匹配即拒绝
+func matchLimit(limit string, match func(item string) bool) bool {
+	deny := strings.HasPrefix(limit, "!")
+	limit = strings.TrimPrefix(limit, "!")
+	for _, item := range strings.Split(limit, ";") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item != "" && match(item) {
+			return !deny
+		}
+	}
+	return deny
+}
@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+)
+
+// ========== v0.8.0 新增：回收站 ==========
+//
+// 开启 server.http.file.trash 后，文件管理（下载目录）中的删除不再立即生效：
+// 文件或文件夹被移动（rename）到 base_dir/.trash/<trash_id>，原路径、删除人、时间登记到 t_trash，
+// 可恢复到原路径或彻底删除；startTrashCleanup 每小时彻底删除超过保留天数的条目。
+
+const (
+	// trashDir 回收站目录（相对于 base_dir，不在上传 / 下载目录内，对使用方不可见）
+	trashDir = ".trash"
+	// trashCleanupInterval 过期条目清理间隔
+	trashCleanupInterval = time.Hour
+)
+
+var (
+	// ErrTrashNotFound 回收站条目不存在
+	ErrTrashNotFound = errors.New("trash item not found")
+	// ErrTrashTargetExists 恢复时原路径已被占用
+	ErrTrashTargetExists = errors.New("original path already exists")
+)
+
+// TrashEnabled 是否启用了回收站（依赖 SQLite）
+func TrashEnabled() bool {
+	return common.TrashEnable && common.EnableSqlite
+}
+
+// DownloadPath 返回下载目录下 name 相对于 base_dir 的路径（与 DownloadFS 的拼接规则一致）
+func DownloadPath(name string) string {
+	return Join(strings.Trim(path.Clean("/"+common.DownloadDir), "/"), name)
+}
+
+// MoveToTrash 将下载目录中的 name（文件或文件夹）移入回收站
+func MoveToTrash(name, deletedBy, source, ip string) (*models.TrashModel, error) {
+	cleaned, err := CleanPath(name)
+	if err != nil || cleaned == "" {
+		return nil, ErrInvalidPath
+	}
+	fsys := DownloadFS()
+	info, err := fsys.Stat(cleaned)
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	if info.IsDir() {
+		size = 0
+		_ = Walk(fsys, cleaned, func(_ string, fi fs.FileInfo) error {
+			if !fi.IsDir() {
+				size += fi.Size()
+			}
+			return nil
+		})
+	}
+
+	db, err := common.GetDB()
+	if err != nil {
+		return nil, err
+	}
+	trashID := randomSuffix() + randomSuffix()
+	item := models.TrashModel{
+		TrashID:      trashID,
+		OriginalPath: cleaned,
+		StoragePath:  Join(trashDir, trashID),
+		IsDir:        info.IsDir(),
+		Size:         size,
+		DeletedBy:    deletedBy,
+		Source:       source,
+		IP:           ip,
+		DeletedAt:    time.Now(),
+	}
+	if err := Backend().Rename(DownloadPath(cleaned), item.StoragePath); err != nil {
+		return nil, err
+	}
+	if err := db.Create(&item).Error; err != nil {
+		// 登记失败时移回原处，避免文件"消失"
+		if rerr := Backend().Rename(item.StoragePath, DownloadPath(cleaned)); rerr != nil {
+			ylog.Errorf("trash", "move %s back failed: %v", cleaned, rerr)
+		}
+		return nil, err
+	}
+	return &item, nil
+}
+
+// GetTrashItem 按 ID 查找回收站条目
+func GetTrashItem(id uint) (*models.TrashModel, error) {
+	db, err := common.GetDB()
+	if err != nil {
+		return nil, err
+	}
+	var item models.TrashModel
+	if err := db.Where("id = ?", id).First(&item).Error; err != nil {
+		return nil, ErrTrashNotFound
+	}
+	return &item, nil
+}
+
+// RestoreFromTrash 将条目恢复到原路径（原路径已存在时返回 ErrTrashTargetExists）
+func RestoreFromTrash(item *models.TrashModel) error {
+	if Exists(DownloadFS(), item.OriginalPath) {
+		return ErrTrashTargetExists
+	}
+	if err := Backend().Rename(item.StoragePath, DownloadPath(item.OriginalPath)); err != nil {
+		if IsNotExist(err) {
+			return ErrTrashNotFound
+		}
+		return err
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		return err
+	}
+	return db.Delete(item).Error
+}
+
+// PurgeTrash 彻底删除回收站条目
+func PurgeTrash(item *models.TrashModel) error {
+	var err error
+	if item.IsDir {
+		err = Backend().RemoveAll(item.StoragePath)
+	} else {
+		err = Backend().Remove(item.StoragePath)
+	}
+	if err != nil && !IsNotExist(err) {
+		return err
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		return err
+	}
+	return db.Delete(item).Error
+}
+
+// startTrashCleanup 定时彻底删除超过保留天数的回收站条目（每小时执行一次）
+func startTrashCleanup() {
+	ticker := time.NewTicker(trashCleanupInterval)
+	defer ticker.Stop()
+
+	// 启动时先执行一次
+	cleanupExpiredTrash()
+	for range ticker.C {
+		cleanupExpiredTrash()
+	}
+}
+
+func cleanupExpiredTrash() {
+	if common.TrashRetentionDays <= 0 {
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		return
+	}
+	deadline := time.Now().AddDate(0, 0, -common.TrashRetentionDays)
+	var items []models.TrashModel
+	if err := db.Where("deleted_at < ?", deadline).Find(&items).Error; err != nil {
+		ylog.Errorf("TrashCleanup", "query expired trash failed: %v", err)
+		return
+	}
+	purged := 0
+	for i := range items {
+		if err := PurgeTrash(&items[i]); err != nil {
+			ylog.Errorf("TrashCleanup", "purge %s failed: %v", items[i].OriginalPath, err)
+			continue
+		}
+		purged++
+	}
+	if purged > 0 {
+		ylog.Infof("TrashCleanup", "purged %d expired trash items", purged)
+	}
+}
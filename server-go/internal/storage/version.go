@@ -0,0 +1,229 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+	"httpcat/internal/models"
+)
+
+// ========== v0.8.0 新增：文件历史版本 ==========
+//
+// 开启 server.http.file.versioning 后，上传覆盖上传目录中已有文件之前，旧内容被拷贝到
+// base_dir/.versions/<version_id>（本地驱动为硬链接，S3 为服务端拷贝），并登记到 t_file_version。
+// 保留策略（最多版本数 / 最长保留天数）可按目录覆盖，见 common.VersioningPolicy；
+// 每次保存版本时按数量裁剪，startVersionCleanup 每小时按时间清理。
+
+const (
+	// versionDir 历史版本存放目录（相对于 base_dir，不在上传 / 下载目录内，对使用方不可见）
+	versionDir = ".versions"
+	// versionCleanupInterval 过期版本清理间隔
+	versionCleanupInterval = time.Hour
+)
+
+// ErrVersionNotFound 版本不存在
+var ErrVersionNotFound = errors.New("version not found")
+
+// VersioningEnabled 是否启用了历史版本（依赖 SQLite）
+func VersioningEnabled() bool {
+	return common.VersioningEnable && common.EnableSqlite
+}
+
+// SaveVersion 覆盖上传目录中的 name 之前调用，将当前内容保存为历史版本。
+// 未开启、文件不存在或所在目录不保留版本时返回 (nil, nil)。
+func SaveVersion(name, operator, reason string) (*models.FileVersionModel, error) {
+	version, err := saveVersion(name, operator, reason)
+	if version != nil {
+		PruneVersions(version.Path)
+	}
+	return version, err
+}
+
+// saveVersion 保存版本但不按保留策略裁剪
+func saveVersion(name, operator, reason string) (*models.FileVersionModel, error) {
+	if !VersioningEnabled() {
+		return nil, nil
+	}
+	cleaned, err := CleanPath(name)
+	if err != nil || cleaned == "" {
+		return nil, ErrInvalidPath
+	}
+	if maxCount, _ := common.VersioningPolicy(cleaned); maxCount == 0 {
+		return nil, nil
+	}
+	fsys := UploadFS()
+	info, err := fsys.Stat(cleaned)
+	if err != nil || info.IsDir() {
+		return nil, nil
+	}
+	fileMD5, err := CachedMD5(fsys, cleaned, UploadPath(cleaned), info)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := common.GetDB()
+	if err != nil {
+		return nil, err
+	}
+	versionID := randomSuffix() + randomSuffix()
+	version := models.FileVersionModel{
+		VersionID:   versionID,
+		Path:        cleaned,
+		StoragePath: Join(versionDir, versionID),
+		Size:        info.Size(),
+		MD5:         fileMD5,
+		ModTime:     info.ModTime(),
+		Operator:    operator,
+		Reason:      reason,
+		CreatedAt:   time.Now(),
+	}
+	if err := Copy(Backend(), UploadPath(cleaned), version.StoragePath); err != nil {
+		return nil, err
+	}
+	if err := db.Create(&version).Error; err != nil {
+		_ = Backend().Remove(version.StoragePath)
+		return nil, err
+	}
+	return &version, nil
+}
+
+// DiscardVersion 覆盖失败时删除刚保存的版本
+func DiscardVersion(version *models.FileVersionModel) {
+	if version == nil {
+		return
+	}
+	removeVersion(*version)
+}
+
+func removeVersion(version models.FileVersionModel) {
+	if err := Backend().Remove(version.StoragePath); err != nil && !IsNotExist(err) {
+		ylog.Errorf("fileVersion", "remove version %s failed: %v", version.VersionID, err)
+		return
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		return
+	}
+	if err := db.Delete(&version).Error; err != nil {
+		ylog.Errorf("fileVersion", "delete version %s failed: %v", version.VersionID, err)
+	}
+}
+
+// ListVersions 列出上传目录中 name 的历史版本（新的在前）
+func ListVersions(name string) ([]models.FileVersionModel, error) {
+	cleaned, err := CleanPath(name)
+	if err != nil || cleaned == "" {
+		return nil, ErrInvalidPath
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		return nil, err
+	}
+	versions := []models.FileVersionModel{}
+	if err := db.Where("path = ?", cleaned).Order("created_at DESC, id DESC").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// GetVersion 按路径与版本 ID 查找版本
+func GetVersion(name, versionID string) (*models.FileVersionModel, error) {
+	cleaned, err := CleanPath(name)
+	if err != nil || cleaned == "" {
+		return nil, ErrInvalidPath
+	}
+	db, err := common.GetDB()
+	if err != nil {
+		return nil, err
+	}
+	var version models.FileVersionModel
+	if err := db.Where("path = ? AND version_id = ?", cleaned, versionID).First(&version).Error; err != nil {
+		return nil, ErrVersionNotFound
+	}
+	return &version, nil
+}
+
+// OpenVersion 打开版本内容
+func OpenVersion(version *models.FileVersionModel) (File, error) {
+	f, err := Backend().Open(version.StoragePath)
+	if IsNotExist(err) {
+		return nil, ErrVersionNotFound
+	}
+	return f, err
+}
+
+// RestoreVersion 将版本内容恢复到原路径；当前文件（若存在）先保存为新版本，恢复操作本身可再撤销。
+// 返回恢复前保存的版本（文件不存在时为 nil）。
+func RestoreVersion(version *models.FileVersionModel, operator string) (*models.FileVersionModel, error) {
+	if _, err := Backend().Stat(version.StoragePath); err != nil {
+		if IsNotExist(err) {
+			return nil, ErrVersionNotFound
+		}
+		return nil, err
+	}
+	// 先恢复再裁剪，避免被恢复的版本恰好因超出数量被清理
+	saved, err := saveVersion(version.Path, operator, "restore")
+	if err != nil {
+		return nil, err
+	}
+	if err := Copy(Backend(), version.StoragePath, UploadPath(version.Path)); err != nil {
+		DiscardVersion(saved)
+		return nil, err
+	}
+	PruneVersions(version.Path)
+	return saved, nil
+}
+
+// PruneVersions 按保留策略清理 name 的历史版本，返回删除的数量
+func PruneVersions(name string) int {
+	db, err := common.GetDB()
+	if err != nil {
+		return 0
+	}
+	maxCount, maxAge := common.VersioningPolicy(name)
+	var versions []models.FileVersionModel
+	if err := db.Where("path = ?", name).Order("created_at DESC, id DESC").Find(&versions).Error; err != nil {
+		return 0
+	}
+	removed := 0
+	for i, version := range versions {
+		expired := maxAge > 0 && time.Since(version.CreatedAt) > maxAge
+		if (maxCount >= 0 && i >= maxCount) || expired {
+			removeVersion(version)
+			removed++
+		}
+	}
+	return removed
+}
+
+// startVersionCleanup 定时清理超出保留策略的历史版本（每小时执行一次）
+func startVersionCleanup() {
+	ticker := time.NewTicker(versionCleanupInterval)
+	defer ticker.Stop()
+
+	cleanupVersions()
+	for range ticker.C {
+		cleanupVersions()
+	}
+}
+
+func cleanupVersions() {
+	db, err := common.GetDB()
+	if err != nil {
+		return
+	}
+	var paths []string
+	if err := db.Model(&models.FileVersionModel{}).Distinct("path").Pluck("path", &paths).Error; err != nil {
+		ylog.Errorf("VersionCleanup", "query versioned paths failed: %v", err)
+		return
+	}
+	removed := 0
+	for _, p := range paths {
+		removed += PruneVersions(p)
+	}
+	if removed > 0 {
+		ylog.Infof("VersionCleanup", "cleaned up %d expired file versions", removed)
+	}
+}
@@ -0,0 +1,257 @@
+package storage
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"httpcat/internal/common"
+	"httpcat/internal/common/ylog"
+)
+
+// ========== v0.8.0 新增：文件系统监听 ==========
+//
+// rsync、cp 等工具直接写入 / 删除上传、下载目录中的文件时，存储驱动不会发布变更事件。
+// 开启 file.watch 后以 fsnotify 监听这些目录（仅本地驱动），把外部改动作为 ChangeEvent（External 为 true）发布，
+// 文件索引与其他订阅方（上传日志、图片表、分享）同 API 上传一样据此更新；定期全量对账（filemeta.go）兜底监听遗漏的改动。
+//
+// 驱动自身的写入同样会触发文件系统事件：经由驱动改动过的路径（重命名、删除时含其子路径）在 ownWriteWindow 内的事件视为自身改动，不再发布。
+// 同一路径的连续事件（如大文件持续写入）在 watchSettle 内没有新事件后才处理一次。
+// 隐藏文件（. 开头，如 rsync 的临时文件）与历史版本、回收站、去重 blob 目录不监听，隐藏文件由对账登记。
+
+const (
+	// watchSettle 路径最后一次事件之后的静默时间，之后才读取其状态并发布
+	watchSettle = 2 * time.Second
+	// ownWriteWindow 驱动写入后，对应路径的文件系统事件视为自身改动的时长
+	ownWriteWindow = 10 * time.Second
+)
+
+// ownWrite 经由存储驱动的一次改动
+type ownWrite struct {
+	at   time.Time
+	tree bool // 是否涉及子路径（目录的重命名、删除）
+}
+
+var (
+	ownWritesMu sync.Mutex
+	ownWrites   = make(map[string]ownWrite)
+)
+
+// FileWatchEnabled 是否开启文件系统监听与按配置间隔的全量对账（依赖文件索引）
+func FileWatchEnabled() bool {
+	return common.FileWatchEnable && FileIndexEnabled()
+}
+
+// noteOwnWrite 记录经由存储驱动改动的路径
+func noteOwnWrite(ev ChangeEvent) {
+	if ev.External {
+		return
+	}
+	w := ownWrite{at: time.Now()}
+	switch ev.Op {
+	case OpRename, OpRemove, OpRemoveAll:
+		w.tree = true
+	}
+	ownWritesMu.Lock()
+	defer ownWritesMu.Unlock()
+	if len(ownWrites) > 4096 {
+		for p, old := range ownWrites {
+			if w.at.Sub(old.at) > ownWriteWindow {
+				delete(ownWrites, p)
+			}
+		}
+	}
+	ownWrites[ev.Path] = w
+	if ev.OldPath != "" {
+		ownWrites[ev.OldPath] = w
+	}
+}
+
+// isOwnWrite name 本身或其被重命名、删除的上级目录是否刚经由存储驱动改动过
+func isOwnWrite(name string) bool {
+	now := time.Now()
+	ownWritesMu.Lock()
+	defer ownWritesMu.Unlock()
+	for p := name; ; p = path.Dir(p) {
+		if w, ok := ownWrites[p]; ok && now.Sub(w.at) < ownWriteWindow && (p == name || w.tree) {
+			return true
+		}
+		if !strings.Contains(p, "/") {
+			return false
+		}
+	}
+}
+
+// isReservedPath 是否位于历史版本、回收站或去重 blob 目录
+func isReservedPath(name string) bool {
+	for _, dir := range []string{versionDir, trashDir, blobDir} {
+		if name == dir || strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// watchIgnored 监听不处理的路径：存储根目录本身、保留目录与隐藏文件
+func watchIgnored(name string) bool {
+	return name == "" || isReservedPath(name) || strings.HasPrefix(path.Base(name), ".")
+}
+
+type fileWatcher struct {
+	watcher *fsnotify.Watcher
+	root    string // 存储根目录（base_dir）的本地路径
+
+	mu      sync.Mutex
+	pending map[string]time.Time // 相对于存储根目录的路径 -> 最后一次事件时间
+}
+
+// startFileWatcher 监听上传、下载目录中的外部改动
+func startFileWatcher() {
+	if common.StorageDriver != "" && common.StorageDriver != "local" {
+		ylog.Warnf("fileWatch", "file watch requires the local storage driver, only periodic reconciliation is enabled")
+		return
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		ylog.Errorf("fileWatch", "create file watcher failed: %v", err)
+		return
+	}
+	fw := &fileWatcher{
+		watcher: w,
+		root:    filepath.Clean(common.FileBaseDir),
+		pending: make(map[string]time.Time),
+	}
+	Subscribe(noteOwnWrite)
+	roots := coveringRoots([]string{UploadPath(""), DownloadPath("")})
+	for _, root := range roots {
+		fw.addTree(root)
+	}
+	ylog.Infof("fileWatch", "watching %v for external changes", roots)
+
+	go fw.settleLoop()
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			fw.onEvent(ev)
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			ylog.Errorf("fileWatch", "watch error: %v", err)
+		}
+	}
+}
+
+// addTree 监听 name（相对于存储根目录）及其下所有子目录
+func (fw *fileWatcher) addTree(name string) {
+	start := filepath.Join(fw.root, filepath.FromSlash(name))
+	err := filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if rel := fw.rel(p); rel != name && watchIgnored(rel) {
+			return filepath.SkipDir
+		}
+		if err := fw.watcher.Add(p); err != nil {
+			ylog.Errorf("fileWatch", "watch %s failed: %v", p, err)
+		}
+		return nil
+	})
+	if err != nil {
+		ylog.Errorf("fileWatch", "walk %s failed: %v", start, err)
+	}
+}
+
+// rel 本地路径转换为相对于存储根目录的路径
+func (fw *fileWatcher) rel(p string) string {
+	rel, err := filepath.Rel(fw.root, p)
+	if err != nil || rel == "." {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (fw *fileWatcher) onEvent(ev fsnotify.Event) {
+	if ev.Op == fsnotify.Chmod {
+		return
+	}
+	name := fw.rel(ev.Name)
+	if watchIgnored(name) {
+		return
+	}
+	fw.mu.Lock()
+	fw.pending[name] = time.Now()
+	fw.mu.Unlock()
+}
+
+// settleLoop 处理静默超过 watchSettle 的路径
+func (fw *fileWatcher) settleLoop() {
+	ticker := time.NewTicker(watchSettle / 4)
+	defer ticker.Stop()
+	for range ticker.C {
+		var ready []string
+		now := time.Now()
+		fw.mu.Lock()
+		for name, t := range fw.pending {
+			if now.Sub(t) >= watchSettle {
+				ready = append(ready, name)
+				delete(fw.pending, name)
+			}
+		}
+		fw.mu.Unlock()
+		for _, name := range ready {
+			fw.process(name)
+		}
+	}
+}
+
+// process 读取 name 的当前状态，作为外部改动发布
+func (fw *fileWatcher) process(name string) {
+	info, err := Backend().Stat(name)
+	switch {
+	case IsNotExist(err):
+		// 被删除目录的监听由 fsnotify 自动移除
+		if !isOwnWrite(name) {
+			emit(ChangeEvent{Op: OpRemoveAll, Path: name, Size: -1, External: true})
+		}
+	case err != nil:
+		ylog.Warnf("fileWatch", "stat %s failed: %v", name, err)
+	case info.IsDir():
+		// 新目录（含驱动创建的目录）都要监听；监听建立前已写入其中的文件逐个发布，其下待处理的事件随之合并
+		fw.addTree(name)
+		fw.mu.Lock()
+		for p := range fw.pending {
+			if strings.HasPrefix(p, name+"/") {
+				delete(fw.pending, p)
+			}
+		}
+		fw.mu.Unlock()
+		err := Walk(Backend(), name, func(p string, info fs.FileInfo) error {
+			if watchIgnored(p) {
+				if info.IsDir() {
+					return SkipDir
+				}
+				return nil
+			}
+			if !info.IsDir() && !isOwnWrite(p) {
+				emit(ChangeEvent{Op: OpPut, Path: p, Size: info.Size(), External: true})
+			}
+			return nil
+		})
+		if err != nil && !IsNotExist(err) {
+			ylog.Errorf("fileWatch", "walk %s failed: %v", name, err)
+		}
+	default:
+		if !isOwnWrite(name) {
+			emit(ChangeEvent{Op: OpPut, Path: name, Size: info.Size(), External: true})
+		}
+	}
+}
@@ -46,6 +46,7 @@ import {
   getFirstUploadToken,
   uploadFileToDir,
   chunkedUpload,
+  getDownloadUrl,
 } from '@/services/ant-design-pro/api';
 import FilePreview from '../components/FilePreview';
 
@@ -265,7 +266,7 @@ const FileList: React.FC = () => {
   // ========== 文件操作 ==========
   const handleDownload = (fileName: string) => {
     const filePath = currentDir ? `${currentDir}/${fileName}` : fileName;
-    window.open(`/api/v1/file/download?filename=${encodeURIComponent(filePath)}`);
+    window.open(getDownloadUrl(filePath));
   };
 
   // v0.6.0: 文件预览
@@ -1,4 +1,9 @@
-import { getFileInfo, removeUploadHistoryLog, uploadHistoryLogs } from '@/services/ant-design-pro/api';
+import {
+  getDownloadUrl,
+  getFileInfo,
+  removeUploadHistoryLog,
+  uploadHistoryLogs,
+} from '@/services/ant-design-pro/api';
 import { DownloadOutlined } from '@ant-design/icons';
 import type { ActionType, ProColumns, ProDescriptionsItemProps } from '@ant-design/pro-components';
 import {
@@ -201,7 +206,7 @@ const TableList: React.FC = () => {
                   icon={<DownloadOutlined />}
                   disabled={isDownloadButtonDisabled}
                   onClick={() => {
-                    const downloadUrl = `/api/v1/file/download?filename=${currentRow?.filename}`;
+                    const downloadUrl = getDownloadUrl(currentRow?.filename || '');
                     window.open(downloadUrl, '_blank');
                   }}
                 >
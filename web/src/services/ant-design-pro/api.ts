@@ -396,6 +396,12 @@ export function getPreviewUrl(filename: string): string {
   return `/api/v1/file/preview?filename=${encodeURIComponent(filename)}&token=${encodeURIComponent(token)}`;
 }
 
+/** 获取文件下载 URL（浏览器直接打开，token 经 query 携带） */
+export function getDownloadUrl(filename: string): string {
+  const token = localStorage.getItem('token') || '';
+  return `/api/v1/file/download?filename=${encodeURIComponent(filename)}&token=${encodeURIComponent(token)}`;
+}
+
 /** 打包下载 POST /api/v1/file/downloadZip */
 export async function downloadZip(data: API.DownloadZipParams) {
   const token = localStorage.getItem('token') || '';